@@ -50,6 +50,9 @@ done
 cd $(dirname "$0")
 ALL_SERVICE_PATHS=()
 
+# Shared Go library, passed to every build as the manifest build context
+MANIFEST_DIR="$(cd "${BASE_DIR}/manifest" && pwd)"
+
 # Function to find all service directories with Dockerfiles
 find_service_dirs() {
   while IFS= read -r dir; do
@@ -132,7 +135,7 @@ for SERVICE_PATH in "${SERVICE_PATHS[@]}"; do
     fi
     
     # Build the Docker image
-    docker buildx build --platform linux/amd64 --provenance=false -t $ECR_REPOSITORY:$IMAGE_TAG .
+    docker buildx build --platform linux/amd64 --provenance=false --build-context manifest="$MANIFEST_DIR" -t $ECR_REPOSITORY:$IMAGE_TAG .
     
     # Tag the image for ECR
     echo "Tagging image as $FULL_IMAGE_NAME"
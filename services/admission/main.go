@@ -0,0 +1,224 @@
+// Admission governs how many MediaConvert jobs run at once. The process
+// workflow calls it between profiler and encode as a waitForTaskToken task
+// with {"taskToken": $$.Task.Token, "input": $}. Every asset is queued and
+// the execution resumes with the profiler output and an admissionId as soon
+// as a slot is free. Encode tags the MediaConvert job with the admissionId,
+// its COMPLETE, ERROR and CANCELED events release the slot of that admission
+// and admit the next queued asset. When the job cannot be submitted the
+// workflow catches the error and sends {"release": {"guid", "admissionId"}}.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+var (
+	ErrInvalidEventObject = errors.New("invalid event object")
+)
+
+// Tenant modes selected with the AdmissionTenant env var. Every tenant gets
+// its own slots and queue.
+const (
+	TenantStack    = "STACK"
+	TenantPrefix   = "PREFIX"
+	TenantUploader = "UPLOADER"
+)
+
+const defaultMaxInFlightJobs = 20
+
+type AdmissionEvent struct {
+	TaskToken string          `json:"taskToken"`
+	Input     json.RawMessage `json:"input"`
+	Release   *SlotRelease    `json:"release"`
+}
+
+// SlotRelease frees the slot of an admission whose job was never created, so
+// no MediaConvert event will.
+type SlotRelease struct {
+	GUID        string `json:"guid"`
+	AdmissionId string `json:"admissionId"`
+}
+
+// AdmissionInput is the part of the profiler output admission needs.
+type AdmissionInput struct {
+	GUID        string `json:"guid"`
+	SrcVideo    string `json:"srcVideo"`
+	SrcUploader string `json:"srcUploader"`
+}
+
+type JobStateChange struct {
+	Status       string `json:"status"`
+	JobID        string `json:"jobId"`
+	UserMetadata struct {
+		GUID        string `json:"guid"`
+		AdmissionId string `json:"admissionId"`
+	} `json:"userMetadata"`
+}
+
+type AdmissionOutput struct {
+	GUID   string `json:"guid"`
+	Tenant string `json:"tenant"`
+	Status string `json:"status"`
+}
+
+type DynamoDBClient interface {
+	UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+}
+
+type StepFunctionClient interface {
+	SendTaskSuccess(input *sfn.SendTaskSuccessInput) (*sfn.SendTaskSuccessOutput, error)
+}
+
+type Handler struct {
+	DynamoDBClient     DynamoDBClient
+	StepFunctionClient StepFunctionClient
+}
+
+func (h *Handler) HandleRequest(event json.RawMessage) (*AdmissionOutput, error) {
+	log.Printf("REQUEST:: %s", event)
+
+	var eventBridgeEvent events.EventBridgeEvent
+	if err := json.Unmarshal(event, &eventBridgeEvent); err != nil {
+		return nil, fmt.Errorf("admission: main.Handler.HandleRequest: json.Unmarshal: %w", err)
+	}
+
+	if eventBridgeEvent.Source == "aws.mediaconvert" {
+		var detail JobStateChange
+		if err := json.Unmarshal(eventBridgeEvent.Detail, &detail); err != nil {
+			return nil, fmt.Errorf("admission: main.Handler.HandleRequest: json.Unmarshal: %w", err)
+		}
+		return h.release(detail)
+	}
+
+	var admissionEvent AdmissionEvent
+	if err := json.Unmarshal(event, &admissionEvent); err != nil {
+		return nil, fmt.Errorf("admission: main.Handler.HandleRequest: json.Unmarshal: %w", err)
+	}
+	if admissionEvent.Release != nil {
+		return h.releaseAdmission(admissionEvent.Release.GUID, admissionEvent.Release.AdmissionId, "")
+	}
+	if admissionEvent.TaskToken == "" || len(admissionEvent.Input) == 0 {
+		return nil, fmt.Errorf("admission: main.Handler.HandleRequest: %w", ErrInvalidEventObject)
+	}
+
+	return h.admit(admissionEvent)
+}
+
+// admit queues the asset and admits as many queued assets as there are free
+// slots. Queuing every asset, even when a slot is free, keeps admission FIFO.
+func (h *Handler) admit(event AdmissionEvent) (*AdmissionOutput, error) {
+	var input AdmissionInput
+	if err := json.Unmarshal(event.Input, &input); err != nil {
+		return nil, fmt.Errorf("admission: main.Handler.admit: json.Unmarshal: %w", err)
+	}
+	if input.GUID == "" {
+		return nil, fmt.Errorf("admission: main.Handler.admit: %w", ErrInvalidEventObject)
+	}
+
+	tenant := getTenant(input)
+	if err := h.enqueue(tenant, input.GUID, event); err != nil {
+		return nil, fmt.Errorf("admission: main.Handler.admit: %w", err)
+	}
+
+	if err := h.drain(tenant); err != nil {
+		return nil, fmt.Errorf("admission: main.Handler.admit: %w", err)
+	}
+
+	return &AdmissionOutput{GUID: input.GUID, Tenant: tenant, Status: "Queued"}, nil
+}
+
+// release frees the slot held by a finished job and admits the next queued
+// asset. Events for jobs that do not hold a slot are ignored.
+func (h *Handler) release(detail JobStateChange) (*AdmissionOutput, error) {
+	switch detail.Status {
+	case "COMPLETE", "ERROR", "CANCELED":
+	default:
+		log.Printf("Ignoring %s event for job %s", detail.Status, detail.JobID)
+		return &AdmissionOutput{GUID: detail.UserMetadata.GUID, Status: "Ignored"}, nil
+	}
+
+	return h.releaseAdmission(detail.UserMetadata.GUID, detail.UserMetadata.AdmissionId, detail.JobID)
+}
+
+// releaseAdmission frees the slot taken by the admission of an asset and
+// admits the next queued asset. jobId is only used for logging.
+func (h *Handler) releaseAdmission(guid string, admissionId string, jobId string) (*AdmissionOutput, error) {
+	if guid == "" {
+		return nil, fmt.Errorf("admission: main.Handler.releaseAdmission: %w", ErrInvalidEventObject)
+	}
+
+	tenant, err := h.releaseSlot(guid, admissionId)
+	if err != nil {
+		return nil, fmt.Errorf("admission: main.Handler.releaseAdmission: %w", err)
+	}
+	if tenant == "" {
+		log.Printf("Admission %s (job %s) of %s does not hold a slot", admissionId, jobId, guid)
+		return &AdmissionOutput{GUID: guid, Status: "Ignored"}, nil
+	}
+
+	if err := h.drain(tenant); err != nil {
+		return nil, fmt.Errorf("admission: main.Handler.releaseAdmission: %w", err)
+	}
+
+	return &AdmissionOutput{GUID: guid, Tenant: tenant, Status: "Released"}, nil
+}
+
+// getTenant returns the tenant of an asset: the whole stack, the first folder
+// of the source key or the uploader.
+func getTenant(input AdmissionInput) string {
+	switch os.Getenv("AdmissionTenant") {
+	case TenantPrefix:
+		if i := strings.Index(input.SrcVideo, "/"); i > 0 {
+			return input.SrcVideo[:i]
+		}
+	case TenantUploader:
+		if input.SrcUploader != "" {
+			return input.SrcUploader
+		}
+	}
+	return "stack"
+}
+
+// getMaxInFlightJobs returns the slot count of a tenant from the
+// TenantMaxInFlightJobs JSON map, falling back to MaxInFlightJobs.
+func getMaxInFlightJobs(tenant string) int {
+	var limits map[string]int
+	if err := json.Unmarshal([]byte(os.Getenv("TenantMaxInFlightJobs")), &limits); err == nil {
+		if limit, ok := limits[tenant]; ok && limit > 0 {
+			return limit
+		}
+	}
+
+	return getEnvInt("MaxInFlightJobs", defaultMaxInFlightJobs)
+}
+
+func main() {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("admission: main: session.NewSession: %v", err)
+	}
+
+	handler := &Handler{
+		DynamoDBClient:     dynamodb.New(sess),
+		StepFunctionClient: sfn.New(sess),
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
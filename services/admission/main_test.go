@@ -0,0 +1,195 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBClientMock struct {
+	mock.Mock
+}
+
+func (m *DynamoDBClientMock) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+}
+
+type StepFunctionClientMock struct {
+	mock.Mock
+}
+
+func (m *StepFunctionClientMock) SendTaskSuccess(input *sfn.SendTaskSuccessInput) (*sfn.SendTaskSuccessOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sfn.SendTaskSuccessOutput), args.Error(1)
+}
+
+const TestAdmissionEvent = `{
+	"taskToken": "token",
+	"input": {"guid": "guid", "srcVideo": "news/clip.mp4", "srcUploader": "AWS:editor"}
+}`
+
+const TestCompleteEvent = `{
+	"source": "aws.mediaconvert",
+	"detail-type": "MediaConvert Job State Change",
+	"detail": {"status": "COMPLETE", "jobId": "job", "userMetadata": {"guid": "guid", "admissionId": "00000000000000000001#guid"}}
+}`
+
+func TestHandleRequest(t *testing.T) {
+	t.Run("should queue the asset and admit it when a slot is free", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("Query", mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.Limit != nil
+		})).Return(&dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{TestWaitingItem}}, nil).Once()
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+		dynamoDBClientMock.On("DeleteItem", mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
+		stepFunctionClientMock.On("SendTaskSuccess", mock.Anything).Return(&sfn.SendTaskSuccessOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		output, err := handler.HandleRequest([]byte(TestAdmissionEvent))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "guid", output.GUID)
+		assert.Equal(t, "stack", output.Tenant)
+		stepFunctionClientMock.AssertCalled(t, "SendTaskSuccess", &sfn.SendTaskSuccessInput{
+			TaskToken: aws.String("token"),
+			Output:    aws.String(`{"admissionId":"00000000000000000001#guid","guid":"guid"}`),
+		})
+	})
+
+	t.Run("should release the slot of a finished job", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+
+		dynamoDBClientMock.On("UpdateItem", mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return *input.UpdateExpression == "SET #version = if_not_exists(#version, :zero) + :one REMOVE admissionSlot, admissionId"
+		})).Return(&dynamodb.UpdateItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{
+				"admissionTenant": {S: aws.String("stack")},
+			},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		output, err := handler.HandleRequest([]byte(TestCompleteEvent))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Released", output.Status)
+		dynamoDBClientMock.AssertNumberOfCalls(t, "UpdateItem", 2)
+		dynamoDBClientMock.AssertCalled(t, "UpdateItem", mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return input.ExpressionAttributeValues[":admissionId"] != nil && *input.ExpressionAttributeValues[":admissionId"].S == "00000000000000000001#guid"
+		}))
+	})
+
+	t.Run("should release the slot of a job that could not be submitted", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+
+		dynamoDBClientMock.On("UpdateItem", mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return *input.UpdateExpression == "SET #version = if_not_exists(#version, :zero) + :one REMOVE admissionSlot, admissionId"
+		})).Return(&dynamodb.UpdateItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{
+				"admissionTenant": {S: aws.String("stack")},
+			},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		output, err := handler.HandleRequest([]byte(`{"release": {"guid": "guid", "admissionId": "00000000000000000001#guid"}}`))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Released", output.Status)
+		dynamoDBClientMock.AssertCalled(t, "UpdateItem", isSlotUpdate("SET inFlight = inFlight - :one"))
+	})
+
+	t.Run("should ignore progress events", func(t *testing.T) {
+		handler := Handler{}
+		output, err := handler.HandleRequest([]byte(`{"source": "aws.mediaconvert", "detail": {"status": "PROGRESSING"}}`))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Ignored", output.Status)
+	})
+
+	t.Run("should fail on invalid events", func(t *testing.T) {
+		handler := Handler{}
+		_, err := handler.HandleRequest([]byte(`{"guid": "guid"}`))
+
+		assert.ErrorIs(t, err, ErrInvalidEventObject)
+	})
+}
+
+func TestTenant(t *testing.T) {
+	input := AdmissionInput{GUID: "guid", SrcVideo: "news/clip.mp4", SrcUploader: "AWS:editor"}
+
+	t.Run("should use the whole stack by default", func(t *testing.T) {
+		assert.Equal(t, "stack", getTenant(input))
+	})
+
+	t.Run("should use the first folder of the key", func(t *testing.T) {
+		os.Setenv("AdmissionTenant", TenantPrefix)
+		defer os.Unsetenv("AdmissionTenant")
+
+		assert.Equal(t, "news", getTenant(input))
+		assert.Equal(t, "stack", getTenant(AdmissionInput{SrcVideo: "clip.mp4"}))
+	})
+
+	t.Run("should use the uploader", func(t *testing.T) {
+		os.Setenv("AdmissionTenant", TenantUploader)
+		defer os.Unsetenv("AdmissionTenant")
+
+		assert.Equal(t, "AWS:editor", getTenant(input))
+	})
+
+	t.Run("should read per-tenant limits", func(t *testing.T) {
+		os.Setenv("MaxInFlightJobs", "5")
+		os.Setenv("TenantMaxInFlightJobs", `{"news": 2}`)
+		defer os.Unsetenv("MaxInFlightJobs")
+		defer os.Unsetenv("TenantMaxInFlightJobs")
+
+		assert.Equal(t, 2, getMaxInFlightJobs("news"))
+		assert.Equal(t, 5, getMaxInFlightJobs("sport"))
+	})
+}
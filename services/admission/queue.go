@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// Each tenant has a slot counter item (PK QUEUE#<tenant>, SK SLOTS) and one
+// waiting item per queued asset (PK QUEUE#<tenant>, SK
+// WAITING#<enqueued unix nano>#<guid>), so a query on the partition returns
+// the queue in arrival order.
+const (
+	queueKeyPrefix   = "QUEUE#"
+	slotsKey         = "SLOTS"
+	waitingKeyPrefix = "WAITING#"
+)
+
+func queueKey(tenant string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String(queueKeyPrefix + tenant)},
+		"SK": {S: aws.String(slotsKey)},
+	}
+}
+
+func assetKey(guid string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String("VIDEO#" + guid)},
+		"SK": {S: aws.String("METADATA")},
+	}
+}
+
+func isConditionalCheckFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// isTaskGone reports whether the execution waiting on a task token has
+// already finished, timed out or been stopped.
+func isTaskGone(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case sfn.ErrCodeTaskTimedOut, sfn.ErrCodeTaskDoesNotExist, sfn.ErrCodeInvalidToken:
+		return true
+	}
+	return false
+}
+
+// enqueue stores the task token and workflow input of an asset at the back of
+// the tenant queue.
+func (h *Handler) enqueue(tenant string, guid string, event AdmissionEvent) error {
+	now := time.Now().UTC()
+
+	_, err := h.DynamoDBClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Item: map[string]*dynamodb.AttributeValue{
+			"PK":        {S: aws.String(queueKeyPrefix + tenant)},
+			"SK":        {S: aws.String(fmt.Sprintf("%s%020d#%s", waitingKeyPrefix, now.UnixNano(), guid))},
+			"guid":      {S: aws.String(guid)},
+			"taskToken": {S: aws.String(event.TaskToken)},
+			"input":     {S: aws.String(string(event.Input))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue: PutItem: %w", err)
+	}
+
+	_, err = h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(os.Getenv("DynamoDBTable")),
+		Key:              assetKey(guid),
+		UpdateExpression: aws.String("SET admissionTenant = :tenant, queuedAt = :now, #version = if_not_exists(#version, :zero) + :one"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":tenant": {S: aws.String(tenant)},
+			":now":    {S: aws.String(now.Format(time.RFC3339))},
+			":zero":   {N: aws.String("0")},
+			":one":    {N: aws.String("1")},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue: UpdateItem: %w", err)
+	}
+
+	log.Printf("Queued %s for tenant %s", guid, tenant)
+	return nil
+}
+
+// drain admits queued assets in order while the tenant has free slots, then
+// refreshes the queue position of the assets still waiting.
+func (h *Handler) drain(tenant string) error {
+	for {
+		waiting, err := h.queryWaiting(tenant, aws.Int64(1), nil)
+		if err != nil {
+			return fmt.Errorf("drain: %w", err)
+		}
+		if len(waiting.Items) == 0 {
+			break
+		}
+		item := waiting.Items[0]
+
+		acquired, err := h.acquireSlot(tenant)
+		if err != nil {
+			return fmt.Errorf("drain: %w", err)
+		}
+		if !acquired {
+			break
+		}
+
+		// Another invocation may admit the same asset concurrently, only the
+		// one deleting the waiting item keeps the slot
+		_, err = h.DynamoDBClient.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(os.Getenv("DynamoDBTable")),
+			Key: map[string]*dynamodb.AttributeValue{
+				"PK": item["PK"],
+				"SK": item["SK"],
+			},
+			ConditionExpression: aws.String("attribute_exists(SK)"),
+		})
+		if err != nil {
+			if returnErr := h.returnSlot(tenant); returnErr != nil {
+				return fmt.Errorf("drain: %w", returnErr)
+			}
+			if isConditionalCheckFailed(err) {
+				continue
+			}
+			return fmt.Errorf("drain: DeleteItem: %w", err)
+		}
+
+		if err := h.start(tenant, item); err != nil {
+			return fmt.Errorf("drain: %w", err)
+		}
+	}
+
+	return h.refreshPositions(tenant)
+}
+
+// start marks the asset as holding a slot and resumes its execution with the
+// admissionId of the slot added to its input.
+func (h *Handler) start(tenant string, item map[string]*dynamodb.AttributeValue) error {
+	guid := aws.StringValue(item["guid"].S)
+	admissionId := strings.TrimPrefix(aws.StringValue(item["SK"].S), waitingKeyPrefix)
+
+	output, err := withAdmissionId(aws.StringValue(item["input"].S), admissionId)
+	if err != nil {
+		if returnErr := h.returnSlot(tenant); returnErr != nil {
+			return fmt.Errorf("start: %w", returnErr)
+		}
+		return fmt.Errorf("start: %w", err)
+	}
+
+	data, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(os.Getenv("DynamoDBTable")),
+		Key:              assetKey(guid),
+		UpdateExpression: aws.String("SET admissionSlot = :true, admissionId = :admissionId, admittedAt = :now, #version = if_not_exists(#version, :zero) + :one REMOVE queuePosition"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":true":        {BOOL: aws.Bool(true)},
+			":admissionId": {S: aws.String(admissionId)},
+			":now":         {S: aws.String(time.Now().UTC().Format(time.RFC3339))},
+			":zero":        {N: aws.String("0")},
+			":one":         {N: aws.String("1")},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		if returnErr := h.returnSlot(tenant); returnErr != nil {
+			return fmt.Errorf("start: %w", returnErr)
+		}
+		return fmt.Errorf("start: UpdateItem: %w", err)
+	}
+
+	// An asset is only admitted again once its previous execution is over,
+	// e.g. when error-handler retries a failed job. A slot still held by the
+	// earlier admission belongs to that finished job and its release event
+	// may not have arrived yet: hand it back now, the event no longer matches
+	// the admissionId on the record and is ignored.
+	if data != nil && data.Attributes["admissionSlot"] != nil {
+		if returnErr := h.returnSlot(stringAttribute(data.Attributes, "admissionTenant")); returnErr != nil {
+			return fmt.Errorf("start: %w", returnErr)
+		}
+		log.Printf("Returned the slot of admission %s of %s", stringAttribute(data.Attributes, "admissionId"), guid)
+	}
+
+	_, err = h.StepFunctionClient.SendTaskSuccess(&sfn.SendTaskSuccessInput{
+		TaskToken: item["taskToken"].S,
+		Output:    aws.String(output),
+	})
+	if err != nil {
+		if _, releaseErr := h.releaseSlot(guid, admissionId); releaseErr != nil {
+			return fmt.Errorf("start: %w", releaseErr)
+		}
+		if isTaskGone(err) {
+			log.Printf("Execution of %s is gone, skipping: %v", guid, err)
+			return nil
+		}
+
+		// Put the asset back at its place in the queue
+		if _, putErr := h.DynamoDBClient.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String(os.Getenv("DynamoDBTable")),
+			Item:      item,
+		}); putErr != nil {
+			return fmt.Errorf("start: PutItem: %w", putErr)
+		}
+		return fmt.Errorf("start: SendTaskSuccess: %w", err)
+	}
+
+	log.Printf("Admitted %s for tenant %s", guid, tenant)
+	return nil
+}
+
+// refreshPositions writes the 1-based queue position on the record of every
+// asset still waiting.
+func (h *Handler) refreshPositions(tenant string) error {
+	var startKey map[string]*dynamodb.AttributeValue
+	position := 0
+
+	for {
+		waiting, err := h.queryWaiting(tenant, nil, startKey)
+		if err != nil {
+			return fmt.Errorf("refreshPositions: %w", err)
+		}
+
+		for _, item := range waiting.Items {
+			position++
+			_, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+				TableName:        aws.String(os.Getenv("DynamoDBTable")),
+				Key:              assetKey(aws.StringValue(item["guid"].S)),
+				UpdateExpression: aws.String("SET queuePosition = :position, #version = if_not_exists(#version, :zero) + :one"),
+				ExpressionAttributeNames: map[string]*string{
+					"#version": aws.String("version"),
+				},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":position": {N: aws.String(strconv.Itoa(position))},
+					":zero":     {N: aws.String("0")},
+					":one":      {N: aws.String("1")},
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("refreshPositions: UpdateItem: %w", err)
+			}
+		}
+
+		if len(waiting.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		startKey = waiting.LastEvaluatedKey
+	}
+}
+
+func (h *Handler) queryWaiting(tenant string, limit *int64, startKey map[string]*dynamodb.AttributeValue) (*dynamodb.QueryOutput, error) {
+	data, err := h.DynamoDBClient.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(os.Getenv("DynamoDBTable")),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :waiting)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk":      {S: aws.String(queueKeyPrefix + tenant)},
+			":waiting": {S: aws.String(waitingKeyPrefix)},
+		},
+		ConsistentRead:    aws.Bool(true),
+		Limit:             limit,
+		ExclusiveStartKey: startKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queryWaiting: Query: %w", err)
+	}
+	return data, nil
+}
+
+// acquireSlot takes a slot from the tenant counter. It returns false when all
+// slots are in use.
+func (h *Handler) acquireSlot(tenant string) (bool, error) {
+	_, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(os.Getenv("DynamoDBTable")),
+		Key:                 queueKey(tenant),
+		UpdateExpression:    aws.String("SET inFlight = if_not_exists(inFlight, :zero) + :one"),
+		ConditionExpression: aws.String("attribute_not_exists(inFlight) OR inFlight < :max"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":zero": {N: aws.String("0")},
+			":one":  {N: aws.String("1")},
+			":max":  {N: aws.String(strconv.Itoa(getMaxInFlightJobs(tenant)))},
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("acquireSlot: UpdateItem: %w", err)
+	}
+	return true, nil
+}
+
+// returnSlot gives a slot back to the tenant counter.
+func (h *Handler) returnSlot(tenant string) error {
+	_, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(os.Getenv("DynamoDBTable")),
+		Key:                 queueKey(tenant),
+		UpdateExpression:    aws.String("SET inFlight = inFlight - :one"),
+		ConditionExpression: aws.String("inFlight > :zero"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":zero": {N: aws.String("0")},
+			":one":  {N: aws.String("1")},
+		},
+	})
+	if err != nil && !isConditionalCheckFailed(err) {
+		return fmt.Errorf("returnSlot: UpdateItem: %w", err)
+	}
+	return nil
+}
+
+// releaseSlot frees the slot held by an admission of an asset and returns its
+// tenant. The slot is only freed while the record still holds it for the same
+// admissionId, which makes it safe to call more than once for the same job
+// and keeps a late event of an earlier job from freeing the slot of a retry.
+// An empty admissionId matches slots taken before admission ids were
+// recorded. An empty tenant means the admission held no slot.
+func (h *Handler) releaseSlot(guid string, admissionId string) (string, error) {
+	condition := "attribute_exists(admissionSlot) AND attribute_not_exists(admissionId)"
+	values := map[string]*dynamodb.AttributeValue{
+		":zero": {N: aws.String("0")},
+		":one":  {N: aws.String("1")},
+	}
+	if admissionId != "" {
+		condition = "attribute_exists(admissionSlot) AND admissionId = :admissionId"
+		values[":admissionId"] = &dynamodb.AttributeValue{S: aws.String(admissionId)}
+	}
+
+	data, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(os.Getenv("DynamoDBTable")),
+		Key:                 assetKey(guid),
+		UpdateExpression:    aws.String("SET #version = if_not_exists(#version, :zero) + :one REMOVE admissionSlot, admissionId"),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllOld),
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("releaseSlot: UpdateItem: %w", err)
+	}
+
+	tenant := stringAttribute(data.Attributes, "admissionTenant")
+	if tenant == "" {
+		return "", nil
+	}
+
+	if err := h.returnSlot(tenant); err != nil {
+		return "", fmt.Errorf("releaseSlot: %w", err)
+	}
+	return tenant, nil
+}
+
+// withAdmissionId adds the admissionId to the workflow input the execution
+// resumes with.
+func withAdmissionId(input string, admissionId string) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(input), &fields); err != nil {
+		return "", fmt.Errorf("withAdmissionId: json.Unmarshal: %w", err)
+	}
+
+	fields["admissionId"], _ = json.Marshal(admissionId)
+	output, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("withAdmissionId: json.Marshal: %w", err)
+	}
+	return string(output), nil
+}
+
+func stringAttribute(item map[string]*dynamodb.AttributeValue, name string) string {
+	if value, ok := item[name]; ok && value != nil {
+		return aws.StringValue(value.S)
+	}
+	return ""
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
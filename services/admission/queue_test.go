@@ -0,0 +1,161 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var TestWaitingItem = map[string]*dynamodb.AttributeValue{
+	"PK":        {S: aws.String("QUEUE#stack")},
+	"SK":        {S: aws.String("WAITING#00000000000000000001#guid")},
+	"guid":      {S: aws.String("guid")},
+	"taskToken": {S: aws.String("token")},
+	"input":     {S: aws.String(`{"guid":"guid"}`)},
+}
+
+var TestConditionalCheckFailed = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+
+func isSlotUpdate(expression string) interface{} {
+	return mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return *input.UpdateExpression == expression
+	})
+}
+
+func TestQueue(t *testing.T) {
+	t.Run("should keep waiting and write positions when all slots are in use", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		second := map[string]*dynamodb.AttributeValue{
+			"guid": {S: aws.String("second")},
+		}
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{TestWaitingItem, second},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", isSlotUpdate("SET inFlight = if_not_exists(inFlight, :zero) + :one")).Return(nil, TestConditionalCheckFailed)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		err := handler.drain("stack")
+
+		assert.Nil(t, err)
+		stepFunctionClientMock.AssertNotCalled(t, "SendTaskSuccess", mock.Anything)
+		dynamoDBClientMock.AssertCalled(t, "UpdateItem", mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return *input.Key["PK"].S == "VIDEO#second" && *input.ExpressionAttributeValues[":position"].N == "2"
+		}))
+	})
+
+	t.Run("should give the slot back when another invocation admitted the asset", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{TestWaitingItem},
+		}, nil).Once()
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("DeleteItem", mock.Anything).Return(nil, TestConditionalCheckFailed)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		err := handler.drain("stack")
+
+		assert.Nil(t, err)
+		dynamoDBClientMock.AssertCalled(t, "UpdateItem", isSlotUpdate("SET inFlight = inFlight - :one"))
+		stepFunctionClientMock.AssertNotCalled(t, "SendTaskSuccess", mock.Anything)
+	})
+
+	t.Run("should release the slot when the execution is gone", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{TestWaitingItem},
+		}, nil).Once()
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+		dynamoDBClientMock.On("DeleteItem", mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", isSlotUpdate("SET #version = if_not_exists(#version, :zero) + :one REMOVE admissionSlot, admissionId")).Return(&dynamodb.UpdateItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{
+				"admissionTenant": {S: aws.String("stack")},
+			},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		stepFunctionClientMock.On("SendTaskSuccess", mock.Anything).Return(nil, awserr.New(sfn.ErrCodeTaskTimedOut, "timed out", nil))
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		err := handler.drain("stack")
+
+		assert.Nil(t, err)
+		dynamoDBClientMock.AssertCalled(t, "UpdateItem", isSlotUpdate("SET inFlight = inFlight - :one"))
+		dynamoDBClientMock.AssertNotCalled(t, "PutItem", mock.Anything)
+	})
+
+	t.Run("should ignore jobs that do not hold a slot", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(nil, TestConditionalCheckFailed)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		tenant, err := handler.releaseSlot("guid", "")
+
+		assert.Nil(t, err)
+		assert.Empty(t, tenant)
+		dynamoDBClientMock.AssertNumberOfCalls(t, "UpdateItem", 1)
+	})
+
+	t.Run("should only release the slot of the same admission", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(nil, TestConditionalCheckFailed)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		tenant, err := handler.releaseSlot("guid", "00000000000000000001#guid")
+
+		assert.Nil(t, err)
+		assert.Empty(t, tenant)
+		dynamoDBClientMock.AssertCalled(t, "UpdateItem", mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return *input.ConditionExpression == "attribute_exists(admissionSlot) AND admissionId = :admissionId" &&
+				*input.ExpressionAttributeValues[":admissionId"].S == "00000000000000000001#guid"
+		}))
+	})
+
+	t.Run("should hand back the slot of the previous admission of the asset", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("UpdateItem", isSlotUpdate("SET admissionSlot = :true, admissionId = :admissionId, admittedAt = :now, #version = if_not_exists(#version, :zero) + :one REMOVE queuePosition")).Return(&dynamodb.UpdateItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{
+				"admissionSlot":   {BOOL: aws.Bool(true)},
+				"admissionId":     {S: aws.String("00000000000000000000#guid")},
+				"admissionTenant": {S: aws.String("stack")},
+			},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		stepFunctionClientMock.On("SendTaskSuccess", mock.Anything).Return(&sfn.SendTaskSuccessOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		err := handler.start("stack", TestWaitingItem)
+
+		assert.Nil(t, err)
+		dynamoDBClientMock.AssertCalled(t, "UpdateItem", isSlotUpdate("SET inFlight = inFlight - :one"))
+	})
+}
@@ -76,22 +76,32 @@ type ArchiveSourceEvent struct {
 	EncodeJobId            string                      `json:"encodeJobId"`
 	EncodingOutput         EventDetail                 `json:"encodingOutput"`
 	EndTime                time.Time                   `json:"endTime"`
+	OutputErrors           []string                    `json:"outputErrors,omitempty"`
+	QualityReport          *QualityReport              `json:"qualityReport,omitempty"`
+	Renditions             []*Rendition                `json:"renditions,omitempty"`
+	FileOutputs            *FileOutputs                `json:"fileOutputs,omitempty"`
+	PrivateContent         bool                        `json:"privateContent,omitempty"`
+	PlaybackUrl            string                      `json:"playbackUrl,omitempty"`
+	Cdns                   []*Cdn                      `json:"cdns,omitempty"`
+	CdnUrls                CdnUrls                     `json:"cdnUrls,omitempty"`
+	Version                int                         `json:"version,omitempty"`
 
 	// Output
-	HlsPlaylist      *string   `json:"hlsPlaylist"`
-	HlsUrl           *string   `json:"hlsUrl"`
-	DashPlaylist     *string   `json:"dashPlaylist"`
-	DashUrl          *string   `json:"dashUrl"`
-	Mp4Outputs       []*string `json:"mp4Outputs"`
-	Mp4Urls          []*string `json:"mp4Urls"`
-	MssPlaylist      *string   `json:"mssPlaylist"`
-	MssUrl           *string   `json:"mssUrl"`
-	CmafDashPlaylist *string   `json:"cmafDashPlaylist"`
-	CmafDashUrl      *string   `json:"cmafDashUrl"`
-	CmafHlsPlaylist  *string   `json:"cmafHlsPlaylist"`
-	CmafHlsUrl       *string   `json:"cmafHlsUrl"`
-	ThumbNails       []*string `json:"thumbNails"`
-	ThumbNailsUrls   []*string `json:"thumbNailsUrls"`
+	HlsPlaylist      *string      `json:"hlsPlaylist"`
+	HlsUrl           *string      `json:"hlsUrl"`
+	DashPlaylist     *string      `json:"dashPlaylist"`
+	DashUrl          *string      `json:"dashUrl"`
+	Mp4Outputs       []*string    `json:"mp4Outputs"`
+	Mp4Urls          []*string    `json:"mp4Urls"`
+	MssPlaylist      *string      `json:"mssPlaylist"`
+	MssUrl           *string      `json:"mssUrl"`
+	CmafDashPlaylist *string      `json:"cmafDashPlaylist"`
+	CmafDashUrl      *string      `json:"cmafDashUrl"`
+	CmafHlsPlaylist  *string      `json:"cmafHlsPlaylist"`
+	CmafHlsUrl       *string      `json:"cmafHlsUrl"`
+	CmafGroups       []*CmafGroup `json:"cmafGroups,omitempty"`
+	ThumbNails       []*string    `json:"thumbNails"`
+	ThumbNailsUrls   []*string    `json:"thumbNailsUrls"`
 }
 
 type Warning struct {
@@ -99,6 +109,75 @@ type Warning struct {
 	Count int64 `json:"count"`
 }
 
+type QualityReport struct {
+	QvbrQualityFloor float64             `json:"qvbrQualityFloor"`
+	Renditions       []*RenditionQuality `json:"renditions"`
+	BlackVideoMs     int64               `json:"blackVideoMs,omitempty"`
+	PaddingMs        int64               `json:"paddingMs,omitempty"`
+	Warnings         []*Warning          `json:"warnings,omitempty"`
+	Flagged          bool                `json:"flagged"`
+	Summary          string              `json:"summary"`
+}
+
+type RenditionQuality struct {
+	Output                 string   `json:"output"`
+	GroupType              string   `json:"groupType"`
+	WidthInPx              int64    `json:"widthInPx"`
+	HeightInPx             int64    `json:"heightInPx"`
+	AverageBitrate         float64  `json:"averageBitrate"`
+	QvbrAvgQuality         float64  `json:"qvbrAvgQuality,omitempty"`
+	QvbrMinQuality         float64  `json:"qvbrMinQuality,omitempty"`
+	QvbrMaxQuality         float64  `json:"qvbrMaxQuality,omitempty"`
+	QvbrMinQualityLocation float64  `json:"qvbrMinQualityLocation,omitempty"`
+	QvbrMaxQualityLocation float64  `json:"qvbrMaxQualityLocation,omitempty"`
+	Flags                  []string `json:"flags,omitempty"`
+}
+
+type Rendition struct {
+	GroupType      string  `json:"groupType"`
+	File           string  `json:"file"`
+	Url            string  `json:"url"`
+	WidthInPx      int64   `json:"widthInPx,omitempty"`
+	HeightInPx     int64   `json:"heightInPx,omitempty"`
+	AverageBitrate float64 `json:"averageBitrate,omitempty"`
+	DurationInMs   int64   `json:"durationInMs"`
+	Codec          string  `json:"codec,omitempty"`
+}
+
+type FileOutput struct {
+	File string `json:"file"`
+	Url  string `json:"url"`
+}
+
+type FileOutputs struct {
+	Mp4       []*FileOutput `json:"mp4,omitempty"`
+	Mezzanine []*FileOutput `json:"mezzanine,omitempty"`
+	Audio     []*FileOutput `json:"audio,omitempty"`
+	Webm      []*FileOutput `json:"webm,omitempty"`
+	Captions  []*FileOutput `json:"captions,omitempty"`
+	Other     []*FileOutput `json:"other,omitempty"`
+}
+
+type CmafGroup struct {
+	Name         string  `json:"name,omitempty"`
+	DashPlaylist *string `json:"dashPlaylist,omitempty"`
+	DashUrl      *string `json:"dashUrl,omitempty"`
+	HlsPlaylist  *string `json:"hlsPlaylist,omitempty"`
+	HlsUrl       *string `json:"hlsUrl,omitempty"`
+}
+
+// Cdn is a delivery hostname, see output-validate
+type Cdn struct {
+	Name    string   `json:"name"`
+	Host    string   `json:"host"`
+	Weight  int      `json:"weight"`
+	Regions []string `json:"regions,omitempty"`
+	Origin  bool     `json:"origin,omitempty"`
+}
+
+// CdnUrls maps a protocol to its URL on each CDN, by CDN name
+type CdnUrls map[string]map[string]string
+
 type UserMetadata struct {
 	GUID     string `json:"guid"`
 	Workflow string `json:"workflow"`
@@ -116,6 +195,12 @@ func (h *Handler) HandleRequest(event ArchiveSourceEvent) (*ArchiveSourceEvent,
 	eventJson, _ := json.Marshal(event)
 	log.Printf("REQUEST:: %s", eventJson)
 
+	// Keep the source in its storage class so the asset can be reprocessed
+	if event.WorkflowStatus == "OutputInvalid" {
+		log.Printf("Not archiving %s, outputs are invalid", event.SrcVideo)
+		return &event, nil
+	}
+
 	stackName := strings.ReplaceAll(os.Getenv("AWS_LAMBDA_FUNCTION_NAME"), "-archive-source", "")
 	input := &s3.PutObjectTaggingInput{
 		Bucket: aws.String(event.SrcBucket),
@@ -63,4 +63,25 @@ func TestArchiveSource(t *testing.T) {
 		_, err := handler.HandleRequest(event)
 		assert.Error(t, err)
 	})
+
+	t.Run("should not archive sources with invalid outputs", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		handler := &Handler{
+			S3Client: s3ClientMock,
+		}
+
+		event := ArchiveSourceEvent{
+			SrcBucket:      "bucket",
+			SrcVideo:       "video",
+			GUID:           "guid",
+			ArchiveSource:  "GLACIER",
+			WorkflowStatus: "OutputInvalid",
+			OutputErrors:   []string{"s3://bucket/guid/hls/video.m3u8: not found"},
+		}
+
+		res, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+		assert.Equal(t, event.OutputErrors, res.OutputErrors)
+		s3ClientMock.AssertNotCalled(t, "PutObjectTagging", mock.Anything)
+	})
 }
@@ -74,6 +74,11 @@ func (h *Handler) HandleRequest(event cfn.Event) (*CustomResourceResponse, error
 			if err != nil {
 				return nil, fmt.Errorf("custom-resource: main.Handler.HandleRequest: CreateTemplates: %w", err)
 			}
+		case "MediaConvertQueues":
+			err := h.MediaConvertCustomResource.CreateQueues(config)
+			if err != nil {
+				return nil, fmt.Errorf("custom-resource: main.Handler.HandleRequest: CreateQueues: %w", err)
+			}
 		case "UUID":
 			uuid := uuid.New().String()
 			responseData.UUID = &uuid
@@ -116,6 +121,11 @@ func (h *Handler) HandleRequest(event cfn.Event) (*CustomResourceResponse, error
 		default:
 			log.Printf("custom-resource: main.Handler.HandleRequest: %s not defined as a custom resource, sending success response", resourceStr)
 		}
+	} else if event.RequestType == cfn.RequestUpdate && config["Resource"] == "MediaConvertQueues" {
+		err := h.MediaConvertCustomResource.CreateQueues(config)
+		if err != nil {
+			return nil, fmt.Errorf("custom-resource: main.Handler.HandleRequest: CreateQueues: %w", err)
+		}
 	}
 
 	res, err := h.CfnCustomResource.Send(event, "SUCCESS", responseData)
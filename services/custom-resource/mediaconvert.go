@@ -7,6 +7,7 @@ import (
 	"log"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/mediaconvert"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/mitchellh/mapstructure"
@@ -168,6 +169,9 @@ type MediaConvertCustomResource struct {
 
 type MediaConvertClient interface {
 	CreateJobTemplate(input *mediaconvert.CreateJobTemplateInput) (*mediaconvert.CreateJobTemplateOutput, error)
+	CreateQueue(input *mediaconvert.CreateQueueInput) (*mediaconvert.CreateQueueOutput, error)
+	GetQueue(input *mediaconvert.GetQueueInput) (*mediaconvert.GetQueueOutput, error)
+	UpdateQueue(input *mediaconvert.UpdateQueueInput) (*mediaconvert.UpdateQueueOutput, error)
 }
 
 type MediaConvertS3Client interface {
@@ -275,3 +279,123 @@ func (m *MediaConvertCustomResource) CreateTemplates(config map[string]interface
 	return nil
 
 }
+
+// QueueConfig describes a MediaConvert queue. Reserved queues need the
+// number of reserved transcode slots; the commitment is always ONE_YEAR.
+type QueueConfig struct {
+	Name          string
+	Description   string
+	PricingPlan   string
+	ReservedSlots int64
+	RenewalType   string
+}
+
+type MediaConvertQueuesConfig struct {
+	StackName string
+	Queues    []QueueConfig
+}
+
+// CreateQueues creates the queues listed in the Queues property. Queue names
+// are prefixed with the stack name like the job templates. Queues that
+// already exist are updated to match the config, so it is also used for
+// stack updates.
+func (m *MediaConvertCustomResource) CreateQueues(config map[string]interface{}) error {
+	var queuesConfig MediaConvertQueuesConfig
+	if err := mapstructure.WeakDecode(config, &queuesConfig); err != nil {
+		return fmt.Errorf("MediaConvertCustomResource.CreateQueues: WeakDecode: failed to decode config: %w", err)
+	}
+
+	for _, queue := range queuesConfig.Queues {
+		if queue.Name == "" {
+			return fmt.Errorf("MediaConvertCustomResource.CreateQueues: queue name is missing")
+		}
+
+		input := &mediaconvert.CreateQueueInput{
+			Name:        aws.String(queuesConfig.StackName + queue.Name),
+			PricingPlan: aws.String("ON_DEMAND"),
+			Tags: map[string]*string{
+				"SolutionId": aws.String("vod-solution"),
+			},
+		}
+		if queue.Description != "" {
+			input.Description = aws.String(queue.Description)
+		}
+
+		if queue.PricingPlan == "RESERVED" {
+			if queue.ReservedSlots <= 0 {
+				return fmt.Errorf("MediaConvertCustomResource.CreateQueues: queue %s: ReservedSlots is required for reserved queues", queue.Name)
+			}
+
+			renewalType := queue.RenewalType
+			if renewalType == "" {
+				renewalType = "EXPIRE"
+			}
+
+			input.PricingPlan = aws.String("RESERVED")
+			input.ReservationPlanSettings = &mediaconvert.ReservationPlanSettings{
+				Commitment:    aws.String("ONE_YEAR"),
+				RenewalType:   aws.String(renewalType),
+				ReservedSlots: aws.Int64(queue.ReservedSlots),
+			}
+		}
+
+		_, err := m.MediaConvertClient.CreateQueue(input)
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == mediaconvert.ErrCodeConflictException {
+				log.Printf("MediaConvertCustomResource.CreateQueues: queue %s already exists, updating", *input.Name)
+				if err := m.updateQueue(input); err != nil {
+					return fmt.Errorf("MediaConvertCustomResource.CreateQueues: %w", err)
+				}
+				continue
+			}
+			return fmt.Errorf("MediaConvertCustomResource.CreateQueues: CreateQueue: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// updateQueue brings an existing queue in line with the create input. The
+// pricing plan of a queue cannot be changed, and reserved slots can only be
+// sent when they differ from the current reservation.
+func (m *MediaConvertCustomResource) updateQueue(input *mediaconvert.CreateQueueInput) error {
+	res, err := m.MediaConvertClient.GetQueue(&mediaconvert.GetQueueInput{Name: input.Name})
+	if err != nil {
+		return fmt.Errorf("updateQueue: GetQueue: %w", err)
+	}
+
+	queue := res.Queue
+	if queue == nil {
+		return fmt.Errorf("updateQueue: queue %s not found", *input.Name)
+	}
+	if aws.StringValue(queue.PricingPlan) != aws.StringValue(input.PricingPlan) {
+		return fmt.Errorf("updateQueue: queue %s has pricing plan %s, cannot change it to %s", *input.Name, aws.StringValue(queue.PricingPlan), aws.StringValue(input.PricingPlan))
+	}
+
+	update := &mediaconvert.UpdateQueueInput{
+		Name:        input.Name,
+		Description: input.Description,
+	}
+	if input.Description == nil {
+		update.Description = aws.String("")
+	}
+
+	if settings := input.ReservationPlanSettings; settings != nil {
+		current := queue.ReservationPlan
+		if current == nil ||
+			aws.Int64Value(current.ReservedSlots) != aws.Int64Value(settings.ReservedSlots) ||
+			aws.StringValue(current.RenewalType) != aws.StringValue(settings.RenewalType) {
+			update.ReservationPlanSettings = settings
+		}
+	}
+
+	if aws.StringValue(queue.Description) == aws.StringValue(update.Description) && update.ReservationPlanSettings == nil {
+		return nil
+	}
+
+	if _, err := m.MediaConvertClient.UpdateQueue(update); err != nil {
+		return fmt.Errorf("updateQueue: UpdateQueue: %w", err)
+	}
+
+	return nil
+}
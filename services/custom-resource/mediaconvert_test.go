@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/mediaconvert"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/stretchr/testify/mock"
@@ -41,6 +42,30 @@ func (m *MediaConvertClientMock) CreateJobTemplate(input *mediaconvert.CreateJob
 	return args.Get(0).(*mediaconvert.CreateJobTemplateOutput), args.Error(1)
 }
 
+func (m *MediaConvertClientMock) CreateQueue(input *mediaconvert.CreateQueueInput) (*mediaconvert.CreateQueueOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*mediaconvert.CreateQueueOutput), args.Error(1)
+}
+
+func (m *MediaConvertClientMock) GetQueue(input *mediaconvert.GetQueueInput) (*mediaconvert.GetQueueOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*mediaconvert.GetQueueOutput), args.Error(1)
+}
+
+func (m *MediaConvertClientMock) UpdateQueue(input *mediaconvert.UpdateQueueInput) (*mediaconvert.UpdateQueueOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*mediaconvert.UpdateQueueOutput), args.Error(1)
+}
+
 func (m *MediaConvertS3ClientMock) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
 	args := m.Called(input)
 	if args.Get(0) == nil {
@@ -124,4 +149,101 @@ func TestMediaConvert(t *testing.T) {
 		})
 
 	})
+
+	t.Run("Queues", func(t *testing.T) {
+		queuesConfig := map[string]interface{}{
+			"StackName": "test",
+			"Queues": []interface{}{
+				map[string]interface{}{"Name": "-breaking-news", "PricingPlan": "ON_DEMAND"},
+				map[string]interface{}{"Name": "-reserved", "PricingPlan": "RESERVED", "ReservedSlots": "2"},
+			},
+		}
+
+		t.Run("should create on-demand and reserved queues", func(t *testing.T) {
+			mediaConvertClientMock := new(MediaConvertClientMock)
+			mediaConvertClientMock.On("CreateQueue", mock.MatchedBy(func(input *mediaconvert.CreateQueueInput) bool {
+				return *input.Name == "test-breaking-news" && *input.PricingPlan == "ON_DEMAND"
+			})).Return(&mediaconvert.CreateQueueOutput{}, nil)
+			mediaConvertClientMock.On("CreateQueue", mock.MatchedBy(func(input *mediaconvert.CreateQueueInput) bool {
+				return *input.Name == "test-reserved" && *input.ReservationPlanSettings.ReservedSlots == 2
+			})).Return(&mediaconvert.CreateQueueOutput{}, nil)
+
+			mediaConvertCustomResource := &MediaConvertCustomResource{
+				MediaConvertClient: mediaConvertClientMock,
+			}
+
+			err := mediaConvertCustomResource.CreateQueues(queuesConfig)
+			if err != nil {
+				t.Errorf("expect no error, got %v", err)
+			}
+			mediaConvertClientMock.AssertNumberOfCalls(t, "CreateQueue", 2)
+		})
+
+		t.Run("should update queues that already exist", func(t *testing.T) {
+			mediaConvertClientMock := new(MediaConvertClientMock)
+			mediaConvertClientMock.On("CreateQueue", mock.Anything).Return(nil, awserr.New(mediaconvert.ErrCodeConflictException, "exists", nil))
+			mediaConvertClientMock.On("GetQueue", mock.MatchedBy(func(input *mediaconvert.GetQueueInput) bool {
+				return *input.Name == "test-breaking-news"
+			})).Return(&mediaconvert.GetQueueOutput{Queue: &mediaconvert.Queue{
+				Name:        aws.String("test-breaking-news"),
+				PricingPlan: aws.String("ON_DEMAND"),
+			}}, nil)
+			mediaConvertClientMock.On("GetQueue", mock.MatchedBy(func(input *mediaconvert.GetQueueInput) bool {
+				return *input.Name == "test-reserved"
+			})).Return(&mediaconvert.GetQueueOutput{Queue: &mediaconvert.Queue{
+				Name:        aws.String("test-reserved"),
+				PricingPlan: aws.String("RESERVED"),
+				ReservationPlan: &mediaconvert.ReservationPlan{
+					RenewalType:   aws.String("EXPIRE"),
+					ReservedSlots: aws.Int64(1),
+				},
+			}}, nil)
+			mediaConvertClientMock.On("UpdateQueue", mock.MatchedBy(func(input *mediaconvert.UpdateQueueInput) bool {
+				return *input.Name == "test-reserved" && *input.ReservationPlanSettings.ReservedSlots == 2
+			})).Return(&mediaconvert.UpdateQueueOutput{}, nil)
+
+			mediaConvertCustomResource := &MediaConvertCustomResource{
+				MediaConvertClient: mediaConvertClientMock,
+			}
+
+			err := mediaConvertCustomResource.CreateQueues(queuesConfig)
+			if err != nil {
+				t.Errorf("expect no error, got %v", err)
+			}
+			mediaConvertClientMock.AssertNumberOfCalls(t, "UpdateQueue", 1)
+		})
+
+		t.Run("should fail when an existing queue has another pricing plan", func(t *testing.T) {
+			mediaConvertClientMock := new(MediaConvertClientMock)
+			mediaConvertClientMock.On("CreateQueue", mock.Anything).Return(nil, awserr.New(mediaconvert.ErrCodeConflictException, "exists", nil))
+			mediaConvertClientMock.On("GetQueue", mock.Anything).Return(&mediaconvert.GetQueueOutput{Queue: &mediaconvert.Queue{
+				PricingPlan: aws.String("RESERVED"),
+			}}, nil)
+
+			mediaConvertCustomResource := &MediaConvertCustomResource{
+				MediaConvertClient: mediaConvertClientMock,
+			}
+
+			err := mediaConvertCustomResource.CreateQueues(queuesConfig)
+			if err == nil {
+				t.Error("expect error, got nil")
+			}
+			mediaConvertClientMock.AssertNotCalled(t, "UpdateQueue", mock.Anything)
+		})
+
+		t.Run("should fail on reserved queues without slots", func(t *testing.T) {
+			mediaConvertCustomResource := &MediaConvertCustomResource{
+				MediaConvertClient: new(MediaConvertClientMock),
+			}
+
+			err := mediaConvertCustomResource.CreateQueues(map[string]interface{}{
+				"Queues": []interface{}{
+					map[string]interface{}{"Name": "reserved", "PricingPlan": "RESERVED"},
+				},
+			})
+			if err == nil {
+				t.Error("expect error, got nil")
+			}
+		})
+	})
 }
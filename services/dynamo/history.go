@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Workflow steps that update the record through this service
+const (
+	stepIngest  = "Ingest"
+	stepProcess = "Process"
+	stepPublish = "Publish"
+)
+
+const (
+	startTimeFormat = "2006-01-02T15:04:05.000Z"
+	// eventTimeFormat is fixed width so EVENT# sort keys order by time
+	eventTimeFormat = "2006-01-02T15:04:05.000000Z"
+	workflowActor   = "workflow"
+)
+
+// HistoryEvent is stored as an EVENT#<timestamp>#<step> item next to the
+// METADATA item of the asset. Events are never updated, the items under
+// VIDEO#<guid> with an EVENT# sort key are the timeline of the asset. The
+// timestamp is taken from the event, not the clock, so a retried
+// invocation writes the same sort key.
+type HistoryEvent struct {
+	GUID      string `json:"guid"`
+	Step      string `json:"step"`
+	Status    string `json:"status"`
+	Actor     string `json:"actor"`
+	Timestamp string `json:"timestamp"`
+	// DurationMs is the time since the workflow started
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// getStep infers the workflow step from the fields set so far, the state
+// machines call this service with their state as the event.
+func getStep(event DynamoEvent) string {
+	switch {
+	case !event.EndTime.IsZero() || event.EncodingOutput.JobId != "":
+		return stepPublish
+	case event.EncodeJobId != "":
+		return stepProcess
+	}
+	return stepIngest
+}
+
+// getEventTime returns the time of the step that produced event: the start of
+// the workflow for ingest, the job submission for process and the end of the
+// job for publish. These are set once per run, retries carry them as they
+// are. now is only used for events that lack them.
+func getEventTime(event DynamoEvent, step string, now time.Time) time.Time {
+	var value string
+	switch step {
+	case stepIngest:
+		value = event.StartTime
+	case stepProcess:
+		value = event.SubmitTime
+	case stepPublish:
+		if !event.EndTime.IsZero() {
+			return event.EndTime.UTC()
+		}
+	}
+	if eventTime, err := time.Parse(startTimeFormat, value); err == nil {
+		return eventTime
+	}
+	return now
+}
+
+// newHistoryEvent describes the update of event, now stands in for a missing
+// event time. The uploader is the actor of ingest, later steps are run by the
+// workflow.
+func newHistoryEvent(event DynamoEvent, now time.Time) HistoryEvent {
+	step := getStep(event)
+	eventTime := getEventTime(event, step, now)
+	history := HistoryEvent{
+		GUID:      event.GUID,
+		Step:      step,
+		Status:    event.WorkflowStatus,
+		Actor:     workflowActor,
+		Timestamp: eventTime.Format(eventTimeFormat),
+	}
+	if history.Step == stepIngest && event.SrcUploader != "" {
+		history.Actor = event.SrcUploader
+	}
+	if startTime, err := time.Parse(startTimeFormat, event.StartTime); err == nil {
+		history.DurationMs = eventTime.Sub(startTime).Milliseconds()
+	}
+
+	switch event.WorkflowStatus {
+	case "Rejected":
+		history.Error = strings.Join(event.RejectReasons, "; ")
+	case "OutputInvalid":
+		history.Error = strings.Join(event.OutputErrors, "; ")
+	}
+	return history
+}
+
+// putHistoryEvent appends history to the timeline of the asset. An event
+// already written by a retried invocation has the same sort key and is left
+// as it is.
+func (h *Handler) putHistoryEvent(history HistoryEvent) error {
+	item, err := dynamodbattribute.MarshalMap(history)
+	if err != nil {
+		return fmt.Errorf("putHistoryEvent: MarshalMap: %w", err)
+	}
+	item["PK"] = &dynamodb.AttributeValue{S: aws.String("VIDEO#" + history.GUID)}
+	item["SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("EVENT#%s#%s", history.Timestamp, history.Step))}
+
+	_, err = h.DynamoDBClient.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(os.Getenv("DynamoDBTable")),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(SK)"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			log.Printf("History event %s already recorded", aws.StringValue(item["SK"].S))
+			return nil
+		}
+		return fmt.Errorf("putHistoryEvent: PutItem: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var historyNow = time.Date(2025, 2, 23, 10, 5, 4, 556000000, time.UTC)
+
+func TestGetStep(t *testing.T) {
+	assert.Equal(t, stepIngest, getStep(DynamoEvent{WorkflowStatus: "Ingest"}))
+	assert.Equal(t, stepProcess, getStep(DynamoEvent{EncodeJobId: "job"}))
+	assert.Equal(t, stepPublish, getStep(DynamoEvent{EncodeJobId: "job", EndTime: historyNow}))
+	assert.Equal(t, stepPublish, getStep(DynamoEvent{EncodingOutput: EventDetail{JobId: "job"}}))
+}
+
+func TestNewHistoryEvent(t *testing.T) {
+	t.Run("should record the uploader as the actor of ingest", func(t *testing.T) {
+		history := newHistoryEvent(DynamoEvent{
+			GUID:           "guid",
+			StartTime:      "2025-02-23T10:04:34.556Z",
+			WorkflowStatus: "Ingest",
+			SrcUploader:    "AWS:AIDAEXAMPLE",
+		}, historyNow)
+		assert.Equal(t, HistoryEvent{
+			GUID:       "guid",
+			Step:       stepIngest,
+			Status:     "Ingest",
+			Actor:      "AWS:AIDAEXAMPLE",
+			Timestamp:  "2025-02-23T10:04:34.556000Z",
+			DurationMs: 0,
+		}, history)
+	})
+
+	t.Run("should date the process event with the job submission", func(t *testing.T) {
+		history := newHistoryEvent(DynamoEvent{
+			GUID:           "guid",
+			StartTime:      "2025-02-23T10:04:34.556Z",
+			WorkflowStatus: "Processing",
+			EncodeJobId:    "job",
+			SubmitTime:     "2025-02-23T10:04:54.556Z",
+		}, historyNow)
+		assert.Equal(t, stepProcess, history.Step)
+		assert.Equal(t, "2025-02-23T10:04:54.556000Z", history.Timestamp)
+		assert.Equal(t, int64(20000), history.DurationMs)
+	})
+
+	t.Run("should fall back to now without an event time", func(t *testing.T) {
+		history := newHistoryEvent(DynamoEvent{GUID: "guid", EncodeJobId: "job"}, historyNow)
+		assert.Equal(t, "2025-02-23T10:05:04.556000Z", history.Timestamp)
+	})
+
+	t.Run("should record why outputs are invalid", func(t *testing.T) {
+		history := newHistoryEvent(DynamoEvent{
+			GUID:           "guid",
+			WorkflowStatus: "OutputInvalid",
+			EndTime:        historyNow,
+			SrcUploader:    "AWS:AIDAEXAMPLE",
+			OutputErrors:   []string{"missing output a.mp4", "empty output b.mp4"},
+		}, historyNow)
+		assert.Equal(t, stepPublish, history.Step)
+		assert.Equal(t, workflowActor, history.Actor)
+		assert.Equal(t, "missing output a.mp4; empty output b.mp4", history.Error)
+		assert.Zero(t, history.DurationMs)
+	})
+}
+
+func TestHandleRequestHistory(t *testing.T) {
+	t.Run("should append an event after the update", func(t *testing.T) {
+		mockDB := new(MockDynamoDBClient)
+		handler := Handler{
+			DynamoDBClient: mockDB,
+			Now:            func() time.Time { return historyNow },
+		}
+
+		mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		mockDB.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+		_, err := handler.HandleRequest(DynamoEvent{GUID: "guid", WorkflowStatus: "Ingest"})
+		assert.NoError(t, err)
+
+		input := mockDB.Calls[1].Arguments.Get(0).(*dynamodb.PutItemInput)
+		assert.Equal(t, "VIDEO#guid", *input.Item["PK"].S)
+		assert.Equal(t, "EVENT#2025-02-23T10:05:04.556000Z#Ingest", *input.Item["SK"].S)
+		assert.Equal(t, "Ingest", *input.Item["status"].S)
+		assert.Equal(t, "attribute_not_exists(SK)", *input.ConditionExpression)
+	})
+
+	t.Run("should write the same sort key on retries", func(t *testing.T) {
+		event := DynamoEvent{GUID: "guid", WorkflowStatus: "Complete", EncodeJobId: "job", EndTime: historyNow}
+
+		var keys []string
+		for _, now := range []time.Time{historyNow.Add(time.Second), historyNow.Add(time.Minute)} {
+			mockDB := new(MockDynamoDBClient)
+			handler := Handler{
+				DynamoDBClient: mockDB,
+				Now:            func() time.Time { return now },
+			}
+			mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+			mockDB.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+			_, err := handler.HandleRequest(event)
+			assert.NoError(t, err)
+			keys = append(keys, *mockDB.Calls[1].Arguments.Get(0).(*dynamodb.PutItemInput).Item["SK"].S)
+		}
+		assert.Equal(t, []string{"EVENT#2025-02-23T10:05:04.556000Z#Publish", "EVENT#2025-02-23T10:05:04.556000Z#Publish"}, keys)
+	})
+
+	t.Run("should not fail when the event is already recorded", func(t *testing.T) {
+		mockDB := new(MockDynamoDBClient)
+		handler := Handler{DynamoDBClient: mockDB}
+
+		mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		mockDB.On("PutItem", mock.Anything).Return(nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil))
+
+		_, err := handler.HandleRequest(DynamoEvent{GUID: "guid"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should not fail the step when the event cannot be written", func(t *testing.T) {
+		mockDB := new(MockDynamoDBClient)
+		handler := Handler{DynamoDBClient: mockDB}
+
+		mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		mockDB.On("PutItem", mock.Anything).Return(nil, errors.New("throttled"))
+
+		result, err := handler.HandleRequest(DynamoEvent{GUID: "guid"})
+		assert.NoError(t, err)
+		assert.Equal(t, "guid", result.GUID)
+	})
+}
@@ -5,24 +5,31 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 	"time"
-	"unicode"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 type DynamoDBClient interface {
 	UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+}
+
+type S3Client interface {
+	PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error)
 }
 
 type Handler struct {
 	DynamoDBClient DynamoDBClient
+	S3Client       S3Client
+	Now            func() time.Time
 }
 
 type EventDetail struct {
@@ -82,10 +89,32 @@ type DynamoEvent struct {
 	SrcVideo               string                      `json:"srcVideo"`
 	EnableMediaPackage     bool                        `json:"enableMediaPackage"`
 	SrcMediainfo           string                      `json:"srcMediainfo"`
+	SrcUploader            string                      `json:"srcUploader,omitempty"`
+	TemplateRule           string                      `json:"templateRule,omitempty"`
+	Queue                  string                      `json:"queue,omitempty"`
+	Priority               *int                        `json:"priority,omitempty"`
+	EncodingProfile        int                         `json:"encodingProfile,omitempty"`
+	JobTemplate            string                      `json:"jobTemplate,omitempty"`
+	IsCustomTemplate       bool                        `json:"isCustomTemplate,omitempty"`
+	Fingerprint            string                      `json:"fingerprint,omitempty"`
+	DuplicateOf            string                      `json:"duplicateOf,omitempty"`
+	DuplicatePolicy        string                      `json:"duplicatePolicy,omitempty"`
+	RejectReasons          []string                    `json:"rejectReasons,omitempty"`
 	EncodingJob            mediaconvert.CreateJobInput `json:"encodingJob"`
 	EncodeJobId            string                      `json:"encodeJobId"`
+	SubmitTime             string                      `json:"submitTime,omitempty"`
 	EncodingOutput         EventDetail                 `json:"encodingOutput"`
 	EndTime                time.Time                   `json:"endTime"`
+	OutputErrors           []string                    `json:"outputErrors,omitempty"`
+	QualityReport          *QualityReport              `json:"qualityReport,omitempty"`
+	Renditions             []*Rendition                `json:"renditions,omitempty"`
+	FileOutputs            *FileOutputs                `json:"fileOutputs,omitempty"`
+	PrivateContent         bool                        `json:"privateContent,omitempty"`
+	PlaybackUrl            string                      `json:"playbackUrl,omitempty"`
+	Cdns                   []*Cdn                      `json:"cdns,omitempty"`
+	CdnUrls                CdnUrls                     `json:"cdnUrls,omitempty"`
+	CdnEgressEndpoints     CdnUrls                     `json:"cdnEgressEndpoints,omitempty"`
+	CdnWeights             map[string]int              `json:"cdnWeights,omitempty"`
 
 	// Output
 	HlsPlaylist            *string           `json:"hlsPlaylist"`
@@ -100,10 +129,12 @@ type DynamoEvent struct {
 	CmafDashUrl            *string           `json:"cmafDashUrl"`
 	CmafHlsPlaylist        *string           `json:"cmafHlsPlaylist"`
 	CmafHlsUrl             *string           `json:"cmafHlsUrl"`
+	CmafGroups             []*CmafGroup      `json:"cmafGroups,omitempty"`
 	ThumbNails             []*string         `json:"thumbNails"`
 	ThumbNailsUrls         []*string         `json:"thumbNailsUrls"`
 	MediaPackageResourceId string            `json:"mediaPackageResourceId"`
 	EgressEndpoints        map[string]string `json:"egressEndpoints"`
+	Version                int               `json:"version,omitempty"`
 }
 
 type Warning struct {
@@ -111,6 +142,75 @@ type Warning struct {
 	Count int64 `json:"count"`
 }
 
+type QualityReport struct {
+	QvbrQualityFloor float64             `json:"qvbrQualityFloor"`
+	Renditions       []*RenditionQuality `json:"renditions"`
+	BlackVideoMs     int64               `json:"blackVideoMs,omitempty"`
+	PaddingMs        int64               `json:"paddingMs,omitempty"`
+	Warnings         []*Warning          `json:"warnings,omitempty"`
+	Flagged          bool                `json:"flagged"`
+	Summary          string              `json:"summary"`
+}
+
+type RenditionQuality struct {
+	Output                 string   `json:"output"`
+	GroupType              string   `json:"groupType"`
+	WidthInPx              int64    `json:"widthInPx"`
+	HeightInPx             int64    `json:"heightInPx"`
+	AverageBitrate         float64  `json:"averageBitrate"`
+	QvbrAvgQuality         float64  `json:"qvbrAvgQuality,omitempty"`
+	QvbrMinQuality         float64  `json:"qvbrMinQuality,omitempty"`
+	QvbrMaxQuality         float64  `json:"qvbrMaxQuality,omitempty"`
+	QvbrMinQualityLocation float64  `json:"qvbrMinQualityLocation,omitempty"`
+	QvbrMaxQualityLocation float64  `json:"qvbrMaxQualityLocation,omitempty"`
+	Flags                  []string `json:"flags,omitempty"`
+}
+
+type Rendition struct {
+	GroupType      string  `json:"groupType"`
+	File           string  `json:"file"`
+	Url            string  `json:"url"`
+	WidthInPx      int64   `json:"widthInPx,omitempty"`
+	HeightInPx     int64   `json:"heightInPx,omitempty"`
+	AverageBitrate float64 `json:"averageBitrate,omitempty"`
+	DurationInMs   int64   `json:"durationInMs"`
+	Codec          string  `json:"codec,omitempty"`
+}
+
+type FileOutput struct {
+	File string `json:"file"`
+	Url  string `json:"url"`
+}
+
+type FileOutputs struct {
+	Mp4       []*FileOutput `json:"mp4,omitempty"`
+	Mezzanine []*FileOutput `json:"mezzanine,omitempty"`
+	Audio     []*FileOutput `json:"audio,omitempty"`
+	Webm      []*FileOutput `json:"webm,omitempty"`
+	Captions  []*FileOutput `json:"captions,omitempty"`
+	Other     []*FileOutput `json:"other,omitempty"`
+}
+
+type CmafGroup struct {
+	Name         string  `json:"name,omitempty"`
+	DashPlaylist *string `json:"dashPlaylist,omitempty"`
+	DashUrl      *string `json:"dashUrl,omitempty"`
+	HlsPlaylist  *string `json:"hlsPlaylist,omitempty"`
+	HlsUrl       *string `json:"hlsUrl,omitempty"`
+}
+
+// Cdn is a delivery hostname, see output-validate
+type Cdn struct {
+	Name    string   `json:"name"`
+	Host    string   `json:"host"`
+	Weight  int      `json:"weight"`
+	Regions []string `json:"regions,omitempty"`
+	Origin  bool     `json:"origin,omitempty"`
+}
+
+// CdnUrls maps a protocol to its URL on each CDN, by CDN name
+type CdnUrls map[string]map[string]string
+
 type UserMetadata struct {
 	GUID     string `json:"guid"`
 	Workflow string `json:"workflow"`
@@ -137,10 +237,31 @@ type DynamoOutput struct {
 	SrcVideo               string                      `json:"srcVideo"`
 	EnableMediaPackage     bool                        `json:"enableMediaPackage"`
 	SrcMediainfo           string                      `json:"srcMediainfo"`
+	SrcUploader            string                      `json:"srcUploader,omitempty"`
+	TemplateRule           string                      `json:"templateRule,omitempty"`
+	Queue                  string                      `json:"queue,omitempty"`
+	Priority               *int                        `json:"priority,omitempty"`
+	EncodingProfile        int                         `json:"encodingProfile,omitempty"`
+	JobTemplate            string                      `json:"jobTemplate,omitempty"`
+	IsCustomTemplate       bool                        `json:"isCustomTemplate,omitempty"`
+	Fingerprint            string                      `json:"fingerprint,omitempty"`
+	DuplicateOf            string                      `json:"duplicateOf,omitempty"`
+	DuplicatePolicy        string                      `json:"duplicatePolicy,omitempty"`
+	RejectReasons          []string                    `json:"rejectReasons,omitempty"`
 	EncodingJob            mediaconvert.CreateJobInput `json:"encodingJob"`
 	EncodeJobId            string                      `json:"encodeJobId"`
+	SubmitTime             string                      `json:"submitTime,omitempty"`
 	EncodingOutput         EventDetail                 `json:"encodingOutput"`
 	EndTime                time.Time                   `json:"endTime"`
+	OutputErrors           []string                    `json:"outputErrors,omitempty"`
+	QualityReport          *QualityReport              `json:"qualityReport,omitempty"`
+	Renditions             []*Rendition                `json:"renditions,omitempty"`
+	FileOutputs            *FileOutputs                `json:"fileOutputs,omitempty"`
+	PrivateContent         bool                        `json:"privateContent,omitempty"`
+	PlaybackUrl            string                      `json:"playbackUrl,omitempty"`
+	Cdns                   []*Cdn                      `json:"cdns,omitempty"`
+	CdnUrls                CdnUrls                     `json:"cdnUrls,omitempty"`
+	CdnEgressEndpoints     CdnUrls                     `json:"cdnEgressEndpoints,omitempty"`
 
 	// Output
 	HlsPlaylist            *string           `json:"hlsPlaylist"`
@@ -155,10 +276,12 @@ type DynamoOutput struct {
 	CmafDashUrl            *string           `json:"cmafDashUrl"`
 	CmafHlsPlaylist        *string           `json:"cmafHlsPlaylist"`
 	CmafHlsUrl             *string           `json:"cmafHlsUrl"`
+	CmafGroups             []*CmafGroup      `json:"cmafGroups,omitempty"`
 	ThumbNails             []*string         `json:"thumbNails"`
 	ThumbNailsUrls         []*string         `json:"thumbNailsUrls"`
 	MediaPackageResourceId string            `json:"mediaPackageResourceId"`
 	EgressEndpoints        map[string]string `json:"egressEndpoints"`
+	Version                int               `json:"version,omitempty"`
 }
 
 func (h *Handler) HandleRequest(event DynamoEvent) (*DynamoOutput, error) {
@@ -175,47 +298,47 @@ func (h *Handler) HandleRequest(event DynamoEvent) (*DynamoOutput, error) {
 		return nil, fmt.Errorf("dynamo: main.Handler.HandleRequest: MarshalMap: %w", err)
 	}
 
-	expression := "SET "
-	attributeValues := make(map[string]*dynamodb.AttributeValue)
-	valuesWithNumberKey := make(map[string]*dynamodb.AttributeValue)
-	counter := 1
-	for key, value := range values {
-		placeholder := fmt.Sprintf(":%d", counter)
-		key = string(unicode.ToLower(rune(key[0]))) + key[1:]
-		expression += fmt.Sprintf("%s = %s, ", key, placeholder)
-		attributeValues[placeholder] = value
-		valuesWithNumberKey[":"+strconv.Itoa(counter)] = value
-		counter++
+	// The version is maintained by the update expression
+	delete(values, versionAttribute)
+
+	remove, err := h.offloadBlobs(event, values)
+	if err != nil {
+		return nil, fmt.Errorf("dynamo: main.Handler.HandleRequest: %w", err)
 	}
-	if len(expression) > 2 {
-		expression = expression[:len(expression)-2]
+
+	// Errors of an earlier output check must not outlive a passing one
+	if len(event.OutputErrors) == 0 {
+		remove = append(remove, "outputErrors")
 	}
 
-	log.Printf("expression:: %s", expression)
-	valuesJson, _ := json.Marshal(valuesWithNumberKey)
+	input := buildUpdateInput(event.GUID, event.Version, values, remove)
+	log.Printf("expression:: %s", aws.StringValue(input.UpdateExpression))
+	namesJson, _ := json.Marshal(input.ExpressionAttributeNames)
+	log.Printf("names:: %s", namesJson)
+	valuesJson, _ := json.Marshal(input.ExpressionAttributeValues)
 	log.Printf("values:: %s", valuesJson)
 
-	input := dynamodb.UpdateItemInput{
-		TableName: aws.String(os.Getenv("DynamoDBTable")),
-		Key: map[string]*dynamodb.AttributeValue{
-			"PK": {
-				S: aws.String("VIDEO#"+ event.GUID),
-			},
-			"SK": {
-				S: aws.String("METADATA"),
-			},
-		},
-		UpdateExpression:          aws.String(expression),
-		ExpressionAttributeValues: valuesWithNumberKey,
-	}
-
-	_, err = h.DynamoDBClient.UpdateItem(&input)
+	data, err := h.DynamoDBClient.UpdateItem(input)
 	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil, fmt.Errorf("dynamo: main.Handler.HandleRequest: UpdateItem: version %d: %w", event.Version, ErrVersionConflict)
+		}
 		return nil, fmt.Errorf("dynamo: main.Handler.HandleRequest: UpdateItem: %w", err)
 	}
+	version := getUpdatedVersion(data, event.Version)
 
 	log.Println("UPDATE:: Successfully updated item in DynamoDB")
 
+	// The timeline is for troubleshooting, a failed write does not fail the
+	// workflow
+	now := time.Now().UTC()
+	if h.Now != nil {
+		now = h.Now().UTC()
+	}
+	if err := h.putHistoryEvent(newHistoryEvent(event, now)); err != nil {
+		log.Printf("dynamo: main.Handler.HandleRequest: %v", err)
+	}
+
 	output := &DynamoOutput{
 		GUID:                   event.GUID,
 		StartTime:              event.StartTime,
@@ -237,8 +360,29 @@ func (h *Handler) HandleRequest(event DynamoEvent) (*DynamoOutput, error) {
 		SrcVideo:               event.SrcVideo,
 		EnableMediaPackage:     event.EnableMediaPackage,
 		SrcMediainfo:           event.SrcMediainfo,
+		SrcUploader:            event.SrcUploader,
+		TemplateRule:           event.TemplateRule,
+		Queue:                  event.Queue,
+		Priority:               event.Priority,
+		EncodingProfile:        event.EncodingProfile,
+		JobTemplate:            event.JobTemplate,
+		IsCustomTemplate:       event.IsCustomTemplate,
+		Fingerprint:            event.Fingerprint,
+		DuplicateOf:            event.DuplicateOf,
+		DuplicatePolicy:        event.DuplicatePolicy,
+		RejectReasons:          event.RejectReasons,
+		OutputErrors:           event.OutputErrors,
+		QualityReport:          event.QualityReport,
+		Renditions:             event.Renditions,
+		FileOutputs:            event.FileOutputs,
+		PrivateContent:         event.PrivateContent,
+		PlaybackUrl:            event.PlaybackUrl,
+		Cdns:                   event.Cdns,
+		CdnUrls:                event.CdnUrls,
+		CdnEgressEndpoints:     event.CdnEgressEndpoints,
 		EncodingJob:            event.EncodingJob,
 		EncodeJobId:            event.EncodeJobId,
+		SubmitTime:             event.SubmitTime,
 		EncodingOutput:         event.EncodingOutput,
 		EndTime:                event.EndTime,
 		HlsPlaylist:            event.HlsPlaylist,
@@ -253,10 +397,12 @@ func (h *Handler) HandleRequest(event DynamoEvent) (*DynamoOutput, error) {
 		CmafDashUrl:            event.CmafDashUrl,
 		CmafHlsPlaylist:        event.CmafHlsPlaylist,
 		CmafHlsUrl:             event.CmafHlsUrl,
+		CmafGroups:             event.CmafGroups,
 		ThumbNails:             event.ThumbNails,
 		ThumbNailsUrls:         event.ThumbNailsUrls,
 		MediaPackageResourceId: event.MediaPackageResourceId,
 		EgressEndpoints:        event.EgressEndpoints,
+		Version:                version,
 	}
 
 	return output, nil
@@ -277,6 +423,7 @@ func main() {
 
 	handler := &Handler{
 		DynamoDBClient: dynamo,
+		S3Client:       s3.New(sess),
 	}
 
 	lambda.Start(handler.HandleRequest)
@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -13,8 +14,19 @@ type MockDynamoDBClient struct {
 }
 
 func (m *MockDynamoDBClient) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
-	m.Called(input)
-	return &dynamodb.UpdateItemOutput{}, nil
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
 }
 
 func TestHandleRequest(t *testing.T) {
@@ -133,12 +145,88 @@ func TestHandleRequest(t *testing.T) {
 				}
 			]
 		}`,
+		Version: 1,
 	}
 
 	mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+	mockDB.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
 	
 	result, err := handler.HandleRequest(event)
 	assert.NoError(t, err)
 	assert.Equal(t, &output, result)
 
 }
+
+func TestHandleRequestSkipsEmptyOptionalFields(t *testing.T) {
+	mockDB := new(MockDynamoDBClient)
+	handler := Handler{
+		DynamoDBClient: mockDB,
+	}
+
+	mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+	mockDB.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+	_, err := handler.HandleRequest(DynamoEvent{GUID: "guid", Queue: "breaking-news", Priority: aws.Int(40)})
+	assert.NoError(t, err)
+
+	input := mockDB.Calls[0].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+	names := attributeNames(input)
+	assert.Contains(t, names, "queue")
+	assert.Contains(t, names, "priority")
+	assert.NotContains(t, names, "srcUploader")
+	assert.NotContains(t, names, "templateRule")
+	assert.NotContains(t, names, "duplicateOf")
+	assert.NotContains(t, names, "cdnWeights")
+}
+
+func TestHandleRequestRecordsCdnWeights(t *testing.T) {
+	mockDB := new(MockDynamoDBClient)
+	handler := Handler{
+		DynamoDBClient: mockDB,
+	}
+
+	mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+	mockDB.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+	_, err := handler.HandleRequest(DynamoEvent{GUID: "guid", CdnWeights: map[string]int{"primary": 20}})
+	assert.NoError(t, err)
+
+	input := mockDB.Calls[0].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+	assert.Contains(t, attributeNames(input), "cdnWeights")
+}
+
+func TestHandleRequestClearsOutputErrors(t *testing.T) {
+	t.Run("should remove the errors of an earlier output check", func(t *testing.T) {
+		mockDB := new(MockDynamoDBClient)
+		handler := Handler{
+			DynamoDBClient: mockDB,
+		}
+
+		mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		mockDB.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+		_, err := handler.HandleRequest(DynamoEvent{GUID: "guid", WorkflowStatus: "Complete"})
+		assert.NoError(t, err)
+
+		input := mockDB.Calls[0].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+		assert.Contains(t, *input.UpdateExpression, "REMOVE #r1")
+		assert.Equal(t, "outputErrors", *input.ExpressionAttributeNames["#r1"])
+	})
+
+	t.Run("should keep the errors of a failed output check", func(t *testing.T) {
+		mockDB := new(MockDynamoDBClient)
+		handler := Handler{
+			DynamoDBClient: mockDB,
+		}
+
+		mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		mockDB.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+		_, err := handler.HandleRequest(DynamoEvent{GUID: "guid", WorkflowStatus: "OutputInvalid", OutputErrors: []string{"missing output"}})
+		assert.NoError(t, err)
+
+		input := mockDB.Calls[0].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+		assert.NotContains(t, *input.UpdateExpression, "REMOVE")
+		assert.Contains(t, attributeNames(input), "outputErrors")
+	})
+}
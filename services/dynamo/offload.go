@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// defaultOffloadThreshold keeps the largest attributes well under the
+// 400KB DynamoDB item limit
+const defaultOffloadThreshold = 64 * 1024
+
+// offloadAttributes are the attributes that can be moved to S3, with the
+// value written for each. Readers find the JSON document at the
+// <attribute>Pointer S3 URI when the attribute is not on the item.
+var offloadAttributes = map[string]func(DynamoEvent) interface{}{
+	"encodingJob":    func(event DynamoEvent) interface{} { return event.EncodingJob },
+	"encodingOutput": func(event DynamoEvent) interface{} { return event.EncodingOutput },
+}
+
+// offloadBlobs writes the offload attributes larger than
+// OffloadThresholdBytes to MetadataBucket and replaces them in values with a
+// pointer. It returns the attributes to remove from the item: the inline
+// copy of offloaded attributes, the stale pointer of inline ones. Nothing is
+// offloaded when MetadataBucket is not set.
+func (h *Handler) offloadBlobs(event DynamoEvent, values map[string]*dynamodb.AttributeValue) ([]string, error) {
+	bucket := os.Getenv("MetadataBucket")
+	if bucket == "" {
+		return nil, nil
+	}
+	threshold := defaultOffloadThreshold
+	if value, err := strconv.Atoi(os.Getenv("OffloadThresholdBytes")); err == nil && value > 0 {
+		threshold = value
+	}
+
+	names := make([]string, 0, len(offloadAttributes))
+	for name := range offloadAttributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var remove []string
+	for _, name := range names {
+		if _, ok := values[name]; !ok {
+			continue
+		}
+		body, err := json.Marshal(offloadAttributes[name](event))
+		if err != nil {
+			return nil, fmt.Errorf("offloadBlobs: json.Marshal %s: %w", name, err)
+		}
+		if len(body) <= threshold {
+			remove = append(remove, name+"Pointer")
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s.json", event.GUID, name)
+		_, err = h.S3Client.PutObject(&s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String("application/json"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("offloadBlobs: PutObject %s: %w", key, err)
+		}
+		log.Printf("Offloaded %s of %s (%d bytes) to s3://%s/%s", name, event.GUID, len(body), bucket, key)
+
+		delete(values, name)
+		values[name+"Pointer"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("s3://%s/%s", bucket, key))}
+		remove = append(remove, name)
+	}
+	return remove, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockS3Client struct {
+	mock.Mock
+}
+
+func (m *MockS3Client) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.PutObjectOutput), args.Error(1)
+}
+
+func TestOffloadBlobs(t *testing.T) {
+	event := DynamoEvent{GUID: "guid"}
+	event.EncodingOutput.JobId = "job-id"
+	event.EncodingOutput.Queue = "arn:aws:mediaconvert:us-east-1:000000000000:queues/Default"
+
+	newValues := func() map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"encodingOutput": {M: map[string]*dynamodb.AttributeValue{"jobId": {S: aws.String("job-id")}}},
+		}
+	}
+
+	t.Run("should keep attributes inline without a bucket", func(t *testing.T) {
+		t.Setenv("MetadataBucket", "")
+		handler := Handler{}
+
+		values := newValues()
+		remove, err := handler.offloadBlobs(event, values)
+		assert.NoError(t, err)
+		assert.Nil(t, remove)
+		assert.Contains(t, values, "encodingOutput")
+	})
+
+	t.Run("should keep small attributes inline", func(t *testing.T) {
+		t.Setenv("MetadataBucket", "metadata")
+		handler := Handler{}
+
+		values := newValues()
+		remove, err := handler.offloadBlobs(event, values)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"encodingOutputPointer"}, remove)
+		assert.Contains(t, values, "encodingOutput")
+	})
+
+	t.Run("should offload large attributes to S3", func(t *testing.T) {
+		t.Setenv("MetadataBucket", "metadata")
+		t.Setenv("OffloadThresholdBytes", "16")
+		mockS3 := new(MockS3Client)
+		handler := Handler{S3Client: mockS3}
+
+		mockS3.On("PutObject", mock.Anything).Return(&s3.PutObjectOutput{}, nil)
+
+		values := newValues()
+		remove, err := handler.offloadBlobs(event, values)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"encodingOutput"}, remove)
+		assert.NotContains(t, values, "encodingOutput")
+		assert.Equal(t, "s3://metadata/guid/encodingOutput.json", *values["encodingOutputPointer"].S)
+
+		input := mockS3.Calls[0].Arguments.Get(0).(*s3.PutObjectInput)
+		assert.Equal(t, "guid/encodingOutput.json", *input.Key)
+		body, _ := io.ReadAll(input.Body)
+		assert.Contains(t, string(body), `"jobId":"job-id"`)
+	})
+
+	t.Run("should fail when the upload fails", func(t *testing.T) {
+		t.Setenv("MetadataBucket", "metadata")
+		t.Setenv("OffloadThresholdBytes", "16")
+		mockS3 := new(MockS3Client)
+		handler := Handler{S3Client: mockS3}
+
+		mockS3.On("PutObject", mock.Anything).Return(nil, assert.AnError)
+
+		_, err := handler.offloadBlobs(event, newValues())
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
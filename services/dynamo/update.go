@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const versionAttribute = "version"
+
+// ErrVersionConflict is returned when the record changed since the version
+// the event was read at, the update is not applied.
+var ErrVersionConflict = errors.New("record was updated concurrently")
+
+// buildUpdateInput sets every attribute of values and removes the ones in
+// remove. Names go through ExpressionAttributeNames so reserved words such
+// as status or queue can be written.
+//
+// Every update increments the version attribute. When the event carries the
+// version it was read at, the update only applies if the record is still at
+// that version.
+func buildUpdateInput(guid string, version int, values map[string]*dynamodb.AttributeValue, remove []string) *dynamodb.UpdateItemInput {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	names := map[string]*string{"#version": aws.String(versionAttribute)}
+	attributeValues := map[string]*dynamodb.AttributeValue{
+		":zero": {N: aws.String("0")},
+		":one":  {N: aws.String("1")},
+	}
+
+	var set []string
+	for i, key := range keys {
+		name, placeholder := "#"+strconv.Itoa(i+1), ":"+strconv.Itoa(i+1)
+		names[name] = aws.String(string(unicode.ToLower(rune(key[0]))) + key[1:])
+		attributeValues[placeholder] = values[key]
+		set = append(set, fmt.Sprintf("%s = %s", name, placeholder))
+	}
+	set = append(set, "#version = if_not_exists(#version, :zero) + :one")
+	expression := "SET " + strings.Join(set, ", ")
+
+	if len(remove) > 0 {
+		var removed []string
+		for i, key := range remove {
+			name := "#r" + strconv.Itoa(i+1)
+			names[name] = aws.String(key)
+			removed = append(removed, name)
+		}
+		expression += " REMOVE " + strings.Join(removed, ", ")
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String("VIDEO#" + guid)},
+			"SK": {S: aws.String("METADATA")},
+		},
+		UpdateExpression:          aws.String(expression),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: attributeValues,
+		ReturnValues:              aws.String(dynamodb.ReturnValueUpdatedNew),
+	}
+	if version > 0 {
+		input.ConditionExpression = aws.String("#version = :expected")
+		input.ExpressionAttributeValues[":expected"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(version))}
+	}
+	return input
+}
+
+// getUpdatedVersion returns the version the update wrote.
+func getUpdatedVersion(data *dynamodb.UpdateItemOutput, version int) int {
+	if data != nil {
+		if value, ok := data.Attributes[versionAttribute]; ok && value.N != nil {
+			if updated, err := strconv.Atoi(*value.N); err == nil {
+				return updated
+			}
+		}
+	}
+	return version + 1
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// attributeNames lists the attribute names the update expression refers to
+func attributeNames(input *dynamodb.UpdateItemInput) []string {
+	var names []string
+	for _, name := range input.ExpressionAttributeNames {
+		names = append(names, *name)
+	}
+	return names
+}
+
+func TestBuildUpdateInput(t *testing.T) {
+	t.Run("should use placeholders for every attribute name", func(t *testing.T) {
+		input := buildUpdateInput("guid", 0, map[string]*dynamodb.AttributeValue{
+			"status": {S: aws.String("Complete")},
+			"queue":  {S: aws.String("default")},
+		}, nil)
+
+		assert.Equal(t, "SET #1 = :1, #2 = :2, #version = if_not_exists(#version, :zero) + :one", *input.UpdateExpression)
+		assert.Equal(t, "queue", *input.ExpressionAttributeNames["#1"])
+		assert.Equal(t, "status", *input.ExpressionAttributeNames["#2"])
+		assert.Equal(t, "default", *input.ExpressionAttributeValues[":1"].S)
+		assert.Equal(t, "VIDEO#guid", *input.Key["PK"].S)
+		assert.Nil(t, input.ConditionExpression)
+	})
+
+	t.Run("should only apply at the expected version", func(t *testing.T) {
+		input := buildUpdateInput("guid", 3, map[string]*dynamodb.AttributeValue{
+			"workflowStatus": {S: aws.String("Complete")},
+		}, nil)
+
+		assert.Equal(t, "#version = :expected", *input.ConditionExpression)
+		assert.Equal(t, "3", *input.ExpressionAttributeValues[":expected"].N)
+	})
+
+	t.Run("should remove attributes", func(t *testing.T) {
+		input := buildUpdateInput("guid", 0, map[string]*dynamodb.AttributeValue{
+			"encodingJobPointer": {S: aws.String("s3://metadata/guid/encodingJob.json")},
+		}, []string{"encodingJob"})
+
+		assert.Equal(t, "SET #1 = :1, #version = if_not_exists(#version, :zero) + :one REMOVE #r1", *input.UpdateExpression)
+		assert.Equal(t, "encodingJob", *input.ExpressionAttributeNames["#r1"])
+	})
+}
+
+func TestGetUpdatedVersion(t *testing.T) {
+	assert.Equal(t, 5, getUpdatedVersion(&dynamodb.UpdateItemOutput{
+		Attributes: map[string]*dynamodb.AttributeValue{"version": {N: aws.String("5")}},
+	}, 3))
+	assert.Equal(t, 4, getUpdatedVersion(&dynamodb.UpdateItemOutput{}, 3))
+}
+
+func TestHandleRequestVersion(t *testing.T) {
+	t.Run("should return the updated version", func(t *testing.T) {
+		mockDB := new(MockDynamoDBClient)
+		handler := Handler{DynamoDBClient: mockDB}
+
+		mockDB.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{
+			Attributes: map[string]*dynamodb.AttributeValue{"version": {N: aws.String("4")}},
+		}, nil)
+		mockDB.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+		result, err := handler.HandleRequest(DynamoEvent{GUID: "guid", Version: 3})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, result.Version)
+
+		input := mockDB.Calls[0].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+		versions := 0
+		for _, name := range attributeNames(input) {
+			if name == versionAttribute {
+				versions++
+			}
+		}
+		assert.Equal(t, 1, versions)
+		assert.Equal(t, "3", *input.ExpressionAttributeValues[":expected"].N)
+	})
+
+	t.Run("should fail on a version conflict", func(t *testing.T) {
+		mockDB := new(MockDynamoDBClient)
+		handler := Handler{DynamoDBClient: mockDB}
+
+		mockDB.On("UpdateItem", mock.Anything).Return(nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil))
+
+		_, err := handler.HandleRequest(DynamoEvent{GUID: "guid", Version: 3})
+		assert.True(t, errors.Is(err, ErrVersionConflict))
+		mockDB.AssertNotCalled(t, "PutItem", mock.Anything)
+	})
+}
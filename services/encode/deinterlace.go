@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+// Scan types assigned by the profiler.
+const (
+	ScanProgressive = "PROGRESSIVE"
+	ScanInterlaced  = "INTERLACED"
+	ScanMixed       = "MIXED"
+	ScanTelecine    = "TELECINE"
+)
+
+var deinterlaceModes = map[string]string{
+	ScanInterlaced: "DEINTERLACE",
+	ScanMixed:      "ADAPTIVE",
+	ScanTelecine:   "INVERSE_TELECINE",
+}
+
+// applyDeinterlacer adds a Deinterlacer preprocessor to every video output of
+// the job when the source is not progressive. Inverse telecine restores the
+// original 23.976 fps film rate, so telecined sources are also encoded at
+// that rate.
+func applyDeinterlacer(outputGroups []*mediaconvert.OutputGroup, event EncodeInput) {
+	mode, ok := deinterlaceModes[event.SrcScanType]
+	if !ok {
+		return
+	}
+
+	for _, group := range outputGroups {
+		for _, output := range group.Outputs {
+			if output.VideoDescription == nil {
+				continue
+			}
+
+			if output.VideoDescription.VideoPreprocessors == nil {
+				output.VideoDescription.VideoPreprocessors = &mediaconvert.VideoPreprocessor{}
+			}
+			output.VideoDescription.VideoPreprocessors.Deinterlacer = &mediaconvert.Deinterlacer{
+				Algorithm: aws.String("INTERPOLATE"),
+				Mode:      aws.String(mode),
+				Control:   aws.String("NORMAL"),
+			}
+
+			if event.SrcScanType == ScanTelecine && output.VideoDescription.CodecSettings != nil {
+				setFramerate(output.VideoDescription.CodecSettings, framerateSettings{
+					Control:     aws.String("SPECIFIED"),
+					Numerator:   aws.Int64(24000),
+					Denominator: aws.Int64(1001),
+					Algorithm:   aws.String("DUPLICATE_DROP"),
+				})
+			}
+		}
+	}
+
+	log.Printf("Deinterlacer:: %s applied for %s source", mode, event.SrcScanType)
+}
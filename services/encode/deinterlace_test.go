@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeinterlacer(t *testing.T) {
+	t.Run("should deinterlace every video output of an interlaced source", func(t *testing.T) {
+		ladder := getLadder()
+		ladder = append(ladder, getFrameGroup(EncodeInput{}, "s3://dest/guid"))
+		applyDeinterlacer(ladder, EncodeInput{SrcScanType: ScanInterlaced})
+
+		for _, group := range ladder {
+			for _, output := range group.Outputs {
+				if output.VideoDescription == nil {
+					continue
+				}
+				deinterlacer := output.VideoDescription.VideoPreprocessors.Deinterlacer
+				assert.Equal(t, "DEINTERLACE", *deinterlacer.Mode)
+				assert.Equal(t, "INTERPOLATE", *deinterlacer.Algorithm)
+			}
+		}
+	})
+
+	t.Run("should inverse telecine and restore the film rate", func(t *testing.T) {
+		ladder := getLadder()
+		applyDeinterlacer(ladder, EncodeInput{SrcScanType: ScanTelecine})
+
+		videoDescription := ladder[0].Outputs[0].VideoDescription
+		assert.Equal(t, "INVERSE_TELECINE", *videoDescription.VideoPreprocessors.Deinterlacer.Mode)
+		assert.Equal(t, int64(24000), *videoDescription.CodecSettings.H264Settings.FramerateNumerator)
+		assert.Equal(t, int64(1001), *videoDescription.CodecSettings.H264Settings.FramerateDenominator)
+	})
+
+	t.Run("should keep existing preprocessors", func(t *testing.T) {
+		ladder := getLadder()
+		ladder[0].Outputs[0].VideoDescription.VideoPreprocessors = &mediaconvert.VideoPreprocessor{
+			ImageInserter: &mediaconvert.ImageInserter{
+				InsertableImages: []*mediaconvert.InsertableImage{{ImageX: aws.Int64(0)}},
+			},
+		}
+		applyDeinterlacer(ladder, EncodeInput{SrcScanType: ScanMixed})
+
+		preprocessors := ladder[0].Outputs[0].VideoDescription.VideoPreprocessors
+		assert.NotNil(t, preprocessors.ImageInserter)
+		assert.Equal(t, "ADAPTIVE", *preprocessors.Deinterlacer.Mode)
+	})
+
+	t.Run("should not touch progressive sources", func(t *testing.T) {
+		ladder := getLadder()
+		applyDeinterlacer(ladder, EncodeInput{SrcScanType: ScanProgressive})
+
+		assert.Nil(t, ladder[0].Outputs[0].VideoDescription.VideoPreprocessors)
+	})
+}
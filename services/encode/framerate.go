@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+// Framerate classes assigned by the profiler.
+const (
+	FramerateStandard = "STANDARD"
+	FramerateHigh     = "HIGH"
+	FramerateLow      = "LOW"
+	FramerateVariable = "VARIABLE"
+)
+
+// defaultHighFramerateMinHeight is the smallest rendition height that keeps
+// the full 50/60 fps of a high frame rate source.
+const defaultHighFramerateMinHeight = 720
+
+type framerateSettings struct {
+	Control     *string
+	Numerator   *int64
+	Denominator *int64
+	Algorithm   *string
+}
+
+// applyFramerate overrides the frame rate of the video outputs merged from the
+// job template according to the class the profiler assigned to the source:
+//   - HIGH keeps the source rate on rungs at or above HighFramerateMinHeight
+//     and halves it on the lower rungs
+//   - LOW and VARIABLE encode every rung at the normalized source rate, so
+//     screen recordings are not padded to 30 fps and VFR inputs get a
+//     constant output rate
+//   - STANDARD keeps whatever the template defines
+func applyFramerate(outputGroups []*mediaconvert.OutputGroup, event EncodeInput) {
+	if event.FramerateNumerator <= 0 || event.FramerateDenominator <= 0 {
+		return
+	}
+
+	switch event.FramerateClass {
+	case FramerateHigh, FramerateLow, FramerateVariable:
+	default:
+		return
+	}
+
+	minHeight := defaultHighFramerateMinHeight
+	if value, err := strconv.Atoi(os.Getenv("HighFramerateMinHeight")); err == nil && value > 0 {
+		minHeight = value
+	}
+
+	for _, group := range outputGroups {
+		for _, output := range group.Outputs {
+			if output.VideoDescription == nil || output.VideoDescription.CodecSettings == nil {
+				continue
+			}
+
+			numerator := int64(event.FramerateNumerator)
+			denominator := int64(event.FramerateDenominator)
+			height := output.VideoDescription.Height
+			if event.FramerateClass == FramerateHigh && height != nil && *height < int64(minHeight) {
+				denominator *= 2
+			}
+
+			settings := framerateSettings{
+				Control:     aws.String("SPECIFIED"),
+				Numerator:   aws.Int64(numerator),
+				Denominator: aws.Int64(denominator),
+				Algorithm:   aws.String("DUPLICATE_DROP"),
+			}
+			if setFramerate(output.VideoDescription.CodecSettings, settings) {
+				log.Printf("Framerate:: %s set to %d/%d", aws.StringValue(output.NameModifier), numerator, denominator)
+			}
+		}
+	}
+}
+
+func setFramerate(codecSettings *mediaconvert.VideoCodecSettings, settings framerateSettings) bool {
+	switch aws.StringValue(codecSettings.Codec) {
+	case "H_264":
+		if codecSettings.H264Settings == nil {
+			codecSettings.H264Settings = &mediaconvert.H264Settings{}
+		}
+		codecSettings.H264Settings.FramerateControl = settings.Control
+		codecSettings.H264Settings.FramerateNumerator = settings.Numerator
+		codecSettings.H264Settings.FramerateDenominator = settings.Denominator
+		codecSettings.H264Settings.FramerateConversionAlgorithm = settings.Algorithm
+	case "H_265":
+		if codecSettings.H265Settings == nil {
+			codecSettings.H265Settings = &mediaconvert.H265Settings{}
+		}
+		codecSettings.H265Settings.FramerateControl = settings.Control
+		codecSettings.H265Settings.FramerateNumerator = settings.Numerator
+		codecSettings.H265Settings.FramerateDenominator = settings.Denominator
+		codecSettings.H265Settings.FramerateConversionAlgorithm = settings.Algorithm
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/stretchr/testify/assert"
+)
+
+func getLadder() []*mediaconvert.OutputGroup {
+	rung := func(height int64) *mediaconvert.Output {
+		return &mediaconvert.Output{
+			VideoDescription: &mediaconvert.VideoDescription{
+				Height: aws.Int64(height),
+				CodecSettings: &mediaconvert.VideoCodecSettings{
+					Codec: aws.String("H_264"),
+					H264Settings: &mediaconvert.H264Settings{
+						FramerateControl: aws.String("INITIALIZE_FROM_SOURCE"),
+					},
+				},
+			},
+		}
+	}
+
+	return []*mediaconvert.OutputGroup{
+		{
+			Outputs: []*mediaconvert.Output{
+				rung(1080),
+				rung(720),
+				rung(360),
+				{
+					AudioDescriptions: []*mediaconvert.AudioDescription{{}},
+				},
+			},
+		},
+	}
+}
+
+func TestFramerate(t *testing.T) {
+	t.Run("should keep 60 fps on upper rungs and halve lower rungs", func(t *testing.T) {
+		ladder := getLadder()
+		applyFramerate(ladder, EncodeInput{
+			FramerateClass:       FramerateHigh,
+			FramerateNumerator:   60000,
+			FramerateDenominator: 1001,
+		})
+
+		outputs := ladder[0].Outputs
+		assert.Equal(t, "SPECIFIED", *outputs[0].VideoDescription.CodecSettings.H264Settings.FramerateControl)
+		assert.Equal(t, int64(60000), *outputs[0].VideoDescription.CodecSettings.H264Settings.FramerateNumerator)
+		assert.Equal(t, int64(1001), *outputs[0].VideoDescription.CodecSettings.H264Settings.FramerateDenominator)
+		assert.Equal(t, int64(1001), *outputs[1].VideoDescription.CodecSettings.H264Settings.FramerateDenominator)
+		assert.Equal(t, int64(2002), *outputs[2].VideoDescription.CodecSettings.H264Settings.FramerateDenominator)
+		assert.Nil(t, outputs[3].VideoDescription)
+	})
+
+	t.Run("should honour HighFramerateMinHeight", func(t *testing.T) {
+		os.Setenv("HighFramerateMinHeight", "1080")
+		defer os.Unsetenv("HighFramerateMinHeight")
+
+		ladder := getLadder()
+		applyFramerate(ladder, EncodeInput{
+			FramerateClass:       FramerateHigh,
+			FramerateNumerator:   50,
+			FramerateDenominator: 1,
+		})
+
+		outputs := ladder[0].Outputs
+		assert.Equal(t, int64(1), *outputs[0].VideoDescription.CodecSettings.H264Settings.FramerateDenominator)
+		assert.Equal(t, int64(2), *outputs[1].VideoDescription.CodecSettings.H264Settings.FramerateDenominator)
+	})
+
+	t.Run("should encode every rung at the source rate for low frame rates", func(t *testing.T) {
+		ladder := getLadder()
+		applyFramerate(ladder, EncodeInput{
+			FramerateClass:       FramerateLow,
+			FramerateNumerator:   15,
+			FramerateDenominator: 1,
+		})
+
+		for _, output := range ladder[0].Outputs[:3] {
+			assert.Equal(t, int64(15), *output.VideoDescription.CodecSettings.H264Settings.FramerateNumerator)
+			assert.Equal(t, int64(1), *output.VideoDescription.CodecSettings.H264Settings.FramerateDenominator)
+		}
+	})
+
+	t.Run("should leave standard sources to the template", func(t *testing.T) {
+		ladder := getLadder()
+		applyFramerate(ladder, EncodeInput{
+			FramerateClass:       FramerateStandard,
+			FramerateNumerator:   25,
+			FramerateDenominator: 1,
+		})
+
+		settings := ladder[0].Outputs[0].VideoDescription.CodecSettings.H264Settings
+		assert.Equal(t, "INITIALIZE_FROM_SOURCE", *settings.FramerateControl)
+		assert.Nil(t, settings.FramerateNumerator)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"dario.cat/mergo"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -13,34 +14,51 @@ import (
 	"github.com/aws/aws-sdk-go/service/mediaconvert"
 )
 
+// submitTimeFormat matches the startTime format written by input-validate
+const submitTimeFormat = "2006-01-02T15:04:05.000Z"
+
 type EncodeInput struct {
-	GUID                   string `json:"guid"`
-	StartTime              string `json:"startTime"`
-	WorkflowTrigger        string `json:"workflowTrigger"`
-	WorkflowStatus         string `json:"workflowStatus"`
-	WorkflowName           string `json:"workflowName"`
-	SrcBucket              string `json:"srcBucket"`
-	DestBucket             string `json:"destBucket"`
-	CloudFront             string `json:"cloudFront"`
-	FrameCapture           bool   `json:"frameCapture"`
-	ArchiveSource          string `json:"archiveSource"`
-	JobTemplate2160p       string `json:"jobTemplate_2160p"`
-	JobTemplate1080p       string `json:"jobTemplate_1080p"`
-	JobTemplate720p        string `json:"jobTemplate_720p"`
-	InputRotate            string `json:"inputRotate"`
-	AcceleratedTranscoding string `json:"acceleratedTranscoding"`
-	EnableSns              bool   `json:"enableSns"`
-	EnableSqs              bool   `json:"enableSqs"`
-	SrcVideo               string `json:"srcVideo"`
-	EnableMediaPackage     bool   `json:"enableMediaPackage"`
-	SrcMediainfo           string `json:"srcMediainfo"`
-	SrcHeight              int    `json:"srcHeight"`
-	SrcWidth               int    `json:"srcWidth"`
-	EncodingProfile        int    `json:"encodingProfile"`
-	FrameCaptureHeight     int    `json:"frameCaptureHeight"`
-	FrameCaptureWidth      int    `json:"frameCaptureWidth"`
-	JobTemplate            string `json:"jobTemplate"`
-	IsCustomTemplate       bool   `json:"isCustomTemplate"`
+	GUID                   string  `json:"guid"`
+	StartTime              string  `json:"startTime"`
+	WorkflowTrigger        string  `json:"workflowTrigger"`
+	WorkflowStatus         string  `json:"workflowStatus"`
+	WorkflowName           string  `json:"workflowName"`
+	SrcBucket              string  `json:"srcBucket"`
+	DestBucket             string  `json:"destBucket"`
+	CloudFront             string  `json:"cloudFront"`
+	FrameCapture           bool    `json:"frameCapture"`
+	ArchiveSource          string  `json:"archiveSource"`
+	JobTemplate2160p       string  `json:"jobTemplate_2160p"`
+	JobTemplate1080p       string  `json:"jobTemplate_1080p"`
+	JobTemplate720p        string  `json:"jobTemplate_720p"`
+	InputRotate            string  `json:"inputRotate"`
+	AcceleratedTranscoding string  `json:"acceleratedTranscoding"`
+	EnableSns              bool    `json:"enableSns"`
+	EnableSqs              bool    `json:"enableSqs"`
+	SrcVideo               string  `json:"srcVideo"`
+	EnableMediaPackage     bool    `json:"enableMediaPackage"`
+	SrcMediainfo           string  `json:"srcMediainfo"`
+	SrcHeight              int     `json:"srcHeight"`
+	SrcWidth               int     `json:"srcWidth"`
+	EncodingProfile        int     `json:"encodingProfile"`
+	FrameCaptureHeight     int     `json:"frameCaptureHeight"`
+	FrameCaptureWidth      int     `json:"frameCaptureWidth"`
+	JobTemplate            string  `json:"jobTemplate"`
+	IsCustomTemplate       bool    `json:"isCustomTemplate"`
+	SrcFramerate           float64 `json:"srcFramerate"`
+	FramerateClass         string  `json:"framerateClass"`
+	FramerateNumerator     int     `json:"framerateNumerator"`
+	FramerateDenominator   int     `json:"framerateDenominator"`
+	SrcScanType            string  `json:"srcScanType"`
+	TemplateRule           string  `json:"templateRule"`
+	SrcUploader            string  `json:"srcUploader"`
+	Queue                  string  `json:"queue"`
+	Priority               *int    `json:"priority,omitempty"`
+	OutputVersion          int     `json:"outputVersion"`
+	Version                int     `json:"version,omitempty"`
+	// AdmissionId identifies the admission slot the job runs in, admission
+	// only frees the slot for events carrying the same id
+	AdmissionId string `json:"admissionId,omitempty"`
 }
 
 type EncodeResponse struct {
@@ -71,8 +89,21 @@ type EncodeResponse struct {
 	FrameCaptureWidth      int                         `json:"frameCaptureWidth"`
 	JobTemplate            string                      `json:"jobTemplate"`
 	IsCustomTemplate       bool                        `json:"isCustomTemplate"`
+	SrcFramerate           float64                     `json:"srcFramerate"`
+	FramerateClass         string                      `json:"framerateClass"`
+	FramerateNumerator     int                         `json:"framerateNumerator"`
+	FramerateDenominator   int                         `json:"framerateDenominator"`
+	SrcScanType            string                      `json:"srcScanType"`
+	TemplateRule           string                      `json:"templateRule"`
+	SrcUploader            string                      `json:"srcUploader"`
+	Queue                  string                      `json:"queue"`
+	Priority               int                         `json:"priority"`
 	EncodingJob            mediaconvert.CreateJobInput `json:"encodingJob"`
 	EncodeJobId            string                      `json:"encodeJobId"`
+	// SubmitTime is when MediaConvert created the job, dynamo dates the
+	// Process event of the timeline with it
+	SubmitTime string `json:"submitTime"`
+	Version    int    `json:"version,omitempty"`
 }
 
 type MediaConvertClient interface {
@@ -82,6 +113,8 @@ type MediaConvertClient interface {
 
 type Handler struct {
 	MediaConvertClient MediaConvertClient
+	TemplateCache      *TemplateCache
+	Limiter            *TokenBucket
 }
 
 func (h *Handler) HandleRequest(event EncodeInput) (*EncodeResponse, error) {
@@ -93,6 +126,10 @@ func (h *Handler) HandleRequest(event EncodeInput) (*EncodeResponse, error) {
 
 	inputPath := fmt.Sprintf("s3://%s/%s", event.SrcBucket, event.SrcVideo)
 	outputPath := fmt.Sprintf("s3://%s/%s", event.DestBucket, event.GUID)
+	if event.OutputVersion > 1 {
+		// Reprocessed assets keep the outputs of earlier versions
+		outputPath = fmt.Sprintf("%s/v%d", outputPath, event.OutputVersion)
+	}
 
 	// init job to create
 	job := mediaconvert.CreateJobInput{
@@ -136,11 +173,9 @@ func (h *Handler) HandleRequest(event EncodeInput) (*EncodeResponse, error) {
 	mssGroup := getMssGroup(outputPath)
 	frameCaptureGroup := getFrameGroup(event, outputPath)
 
-	template, err := h.MediaConvertClient.GetJobTemplate(&mediaconvert.GetJobTemplateInput{
-		Name: aws.String(event.JobTemplate),
-	})
+	template, err := h.getJobTemplate(event.JobTemplate)
 	if err != nil {
-		return nil, fmt.Errorf("encode: main.Handler.HandleRequest: GetJobTemplate: %w", err)
+		return nil, err
 	}
 	templateJson, err := json.Marshal(template)
 	if err != nil {
@@ -180,10 +215,14 @@ func (h *Handler) HandleRequest(event EncodeInput) (*EncodeResponse, error) {
 		}
 	}
 
+	applyFramerate(job.Settings.OutputGroups, event)
+
 	if event.FrameCapture {
 		job.Settings.OutputGroups = append(job.Settings.OutputGroups, frameCaptureGroup)
 	}
 
+	applyDeinterlacer(job.Settings.OutputGroups, event)
+
 	if event.AcceleratedTranscoding == "PREFERRED" || event.AcceleratedTranscoding == "ENABLED" {
 		job.AccelerationSettings = &mediaconvert.AccelerationSettings{
 			Mode: aws.String(event.AcceleratedTranscoding),
@@ -194,9 +233,15 @@ func (h *Handler) HandleRequest(event EncodeInput) (*EncodeResponse, error) {
 		job.Settings.Inputs[0].TimecodeSource = aws.String("ZEROBASED")
 	}
 
-	data, err := h.MediaConvertClient.CreateJob(&job)
+	applyQueue(&job, event)
+
+	if event.AdmissionId != "" {
+		job.UserMetadata["admissionId"] = aws.String(event.AdmissionId)
+	}
+
+	data, err := h.createJob(&job)
 	if err != nil {
-		return nil, fmt.Errorf("encode: main.Handler.HandleRequest: CreateJob: %w", err)
+		return nil, err
 	}
 
 	dataJson, err := json.Marshal(data)
@@ -205,11 +250,16 @@ func (h *Handler) HandleRequest(event EncodeInput) (*EncodeResponse, error) {
 	}
 	log.Printf("JOB:: %s", dataJson)
 
+	submitTime := time.Now().UTC()
+	if data.Job.CreatedAt != nil {
+		submitTime = data.Job.CreatedAt.UTC()
+	}
+
 	EncodeReponse := EncodeResponse{
 		GUID:                   event.GUID,
 		StartTime:              event.StartTime,
 		WorkflowTrigger:        event.WorkflowTrigger,
-		WorkflowStatus:         event.WorkflowStatus,
+		WorkflowStatus:         "Processing",
 		WorkflowName:           event.WorkflowName,
 		SrcBucket:              event.SrcBucket,
 		DestBucket:             event.DestBucket,
@@ -233,14 +283,50 @@ func (h *Handler) HandleRequest(event EncodeInput) (*EncodeResponse, error) {
 		FrameCaptureWidth:      event.FrameCaptureWidth,
 		JobTemplate:            event.JobTemplate,
 		IsCustomTemplate:       event.IsCustomTemplate,
+		SrcFramerate:           event.SrcFramerate,
+		FramerateClass:         event.FramerateClass,
+		FramerateNumerator:     event.FramerateNumerator,
+		FramerateDenominator:   event.FramerateDenominator,
+		SrcScanType:            event.SrcScanType,
+		TemplateRule:           event.TemplateRule,
+		SrcUploader:            event.SrcUploader,
+		Queue:                  aws.StringValue(job.Queue),
+		Priority:               int(aws.Int64Value(job.Priority)),
 		EncodingJob:            job,
 		EncodeJobId:            *data.Job.Id,
+		SubmitTime:             submitTime.Format(submitTimeFormat),
+		Version:                event.Version,
 	}
 
 	return &EncodeReponse, nil
 
 }
 
+// getJobTemplate returns the job template from the cache, fetching and caching
+// it on a miss.
+func (h *Handler) getJobTemplate(name string) (*mediaconvert.GetJobTemplateOutput, error) {
+	if template, ok := h.TemplateCache.Get(name); ok {
+		log.Printf("Job template %s found in cache", name)
+		return template, nil
+	}
+
+	template, err := h.MediaConvertClient.GetJobTemplate(&mediaconvert.GetJobTemplateInput{
+		Name: aws.String(name),
+	})
+	if err != nil {
+		if isThrottle(err) {
+			return nil, &ThrottledError{Op: "GetJobTemplate", Err: err}
+		}
+		return nil, fmt.Errorf("encode: main.Handler.HandleRequest: GetJobTemplate: %w", err)
+	}
+
+	if err := h.TemplateCache.Put(name, template); err != nil {
+		log.Printf("Failed to cache job template %s: %v", name, err)
+	}
+
+	return template, nil
+}
+
 func getMp4Group(outputPath string) *mediaconvert.OutputGroup {
 	return &mediaconvert.OutputGroup{
 		Name: aws.String("File Group"),
@@ -366,10 +452,14 @@ func main() {
 		log.Fatalf("encode: main: session.NewSession: %v", err)
 	}
 
-	mediaConvertClient := mediaconvert.New(sess)
+	// createJob does its own backoff on throttling, so the SDK retryer is
+	// turned off to keep the two from stacking.
+	mediaConvertClient := mediaconvert.New(sess, aws.NewConfig().WithMaxRetries(0))
 
 	handler := Handler{
 		MediaConvertClient: mediaConvertClient,
+		TemplateCache:      NewTemplateCacheFromEnv(),
+		Limiter:            NewTokenBucketFromEnv(),
 	}
 
 	lambda.Start(handler.HandleRequest)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/mediaconvert"
@@ -53,7 +54,8 @@ func TestEncode(t *testing.T) {
 
 		data := mediaconvert.CreateJobOutput{
 			Job: &mediaconvert.Job{
-				Id: aws.String("12345"),
+				Id:        aws.String("12345"),
+				CreatedAt: aws.Time(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
 			},
 		}
 
@@ -79,6 +81,8 @@ func TestEncode(t *testing.T) {
 			t.Errorf("Expect no error, but got %v", err)
 		}
 		assert.Equal(t, "12345", res.EncodeJobId)
+		assert.Equal(t, "Processing", res.WorkflowStatus)
+		assert.Equal(t, "2024-05-01T12:00:00.000Z", res.SubmitTime)
 		assert.Equal(t, "HLS_GROUP_SETTINGS", *res.EncodingJob.Settings.OutputGroups[0].OutputGroupSettings.Type)
 	})
 	t.Run("should succeed when FrameCapture is enabled", func(t *testing.T) {
@@ -244,4 +248,74 @@ func TestEncode(t *testing.T) {
 		_, err := handler.HandleRequest(event)
 		assert.Error(t, err)
 	})
+	t.Run("should write reprocessed versions under a version prefix", func(t *testing.T) {
+		template := mediaconvert.GetJobTemplateOutput{
+			JobTemplate: &mediaconvert.JobTemplate{
+				Settings: &mediaconvert.JobTemplateSettings{
+					OutputGroups: []*mediaconvert.OutputGroup{
+						{
+							OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+								Type: aws.String("HLS_GROUP_SETTINGS"),
+							},
+							Name: aws.String("test-output-group"),
+						},
+					},
+				},
+			},
+		}
+
+		event := EncodeInput{
+			GUID:          "GUID",
+			JobTemplate:   "JobTemplate",
+			SrcVideo:      "video.mp4",
+			SrcBucket:     "src",
+			DestBucket:    "dest",
+			OutputVersion: 3,
+			Version:       2,
+		}
+
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		handler := Handler{
+			MediaConvertClient: mediaConvertClientMock,
+		}
+
+		mediaConvertClientMock.On("GetJobTemplate", mock.Anything).Return(&template, nil)
+		mediaConvertClientMock.On("CreateJob", mock.Anything).Return(&mediaconvert.CreateJobOutput{Job: &mediaconvert.Job{Id: aws.String("12345")}}, nil)
+
+		response, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, response.Version)
+
+		job := mediaConvertClientMock.Calls[1].Arguments.Get(0).(*mediaconvert.CreateJobInput)
+		assert.Equal(t, "s3://dest/GUID/v3/hls/", *job.Settings.OutputGroups[0].OutputGroupSettings.HlsGroupSettings.Destination)
+	})
+
+	t.Run("should tag the job with its admission id", func(t *testing.T) {
+		template := mediaconvert.GetJobTemplateOutput{
+			JobTemplate: &mediaconvert.JobTemplate{
+				Settings: &mediaconvert.JobTemplateSettings{},
+			},
+		}
+
+		event := EncodeInput{
+			GUID:        "GUID",
+			JobTemplate: "JobTemplate",
+			SrcVideo:    "video.mp4",
+			AdmissionId: "00000000000000000001#GUID",
+		}
+
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		handler := Handler{
+			MediaConvertClient: mediaConvertClientMock,
+		}
+
+		mediaConvertClientMock.On("GetJobTemplate", mock.Anything).Return(&template, nil)
+		mediaConvertClientMock.On("CreateJob", mock.Anything).Return(&mediaconvert.CreateJobOutput{Job: &mediaconvert.Job{Id: aws.String("12345")}}, nil)
+
+		_, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+
+		job := mediaConvertClientMock.Calls[1].Arguments.Get(0).(*mediaconvert.CreateJobInput)
+		assert.Equal(t, "00000000000000000001#GUID", *job.UserMetadata["admissionId"])
+	})
 }
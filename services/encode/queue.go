@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+// MediaConvert accepts job priorities between -50 and 50.
+const (
+	minJobPriority = -50
+	maxJobPriority = 50
+)
+
+// applyQueue routes the job to the queue chosen by the profiler, falling back
+// to the MediaConvertQueue env var, and sets its priority from the profiler or
+// the MediaConvertPriority env var. Jobs without a queue land on the account
+// default queue. Queues can be given by name or ARN.
+func applyQueue(job *mediaconvert.CreateJobInput, event EncodeInput) {
+	queue := event.Queue
+	if queue == "" {
+		queue = os.Getenv("MediaConvertQueue")
+	}
+	if queue != "" {
+		job.Queue = aws.String(queue)
+	}
+
+	priority := 0
+	if event.Priority != nil {
+		priority = *event.Priority
+	} else if value, err := strconv.Atoi(os.Getenv("MediaConvertPriority")); err == nil {
+		priority = value
+	}
+	if priority < minJobPriority {
+		priority = minJobPriority
+	}
+	if priority > maxJobPriority {
+		priority = maxJobPriority
+	}
+	job.Priority = aws.Int64(int64(priority))
+
+	log.Printf("Queue:: %s priority %d", queue, priority)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyQueue(t *testing.T) {
+	t.Run("should use the queue and priority chosen for the asset", func(t *testing.T) {
+		os.Setenv("MediaConvertQueue", "default-queue")
+		defer os.Unsetenv("MediaConvertQueue")
+
+		job := &mediaconvert.CreateJobInput{}
+		applyQueue(job, EncodeInput{Queue: "breaking-news", Priority: aws.Int(40)})
+
+		assert.Equal(t, "breaking-news", *job.Queue)
+		assert.Equal(t, int64(40), *job.Priority)
+	})
+
+	t.Run("should fall back to env defaults", func(t *testing.T) {
+		os.Setenv("MediaConvertQueue", "arn:aws:mediaconvert:us-east-1:123456789012:queues/back-catalog")
+		os.Setenv("MediaConvertPriority", "-10")
+		defer os.Unsetenv("MediaConvertQueue")
+		defer os.Unsetenv("MediaConvertPriority")
+
+		job := &mediaconvert.CreateJobInput{}
+		applyQueue(job, EncodeInput{})
+
+		assert.Equal(t, "arn:aws:mediaconvert:us-east-1:123456789012:queues/back-catalog", *job.Queue)
+		assert.Equal(t, int64(-10), *job.Priority)
+	})
+
+	t.Run("should leave the queue unset and clamp the priority", func(t *testing.T) {
+		job := &mediaconvert.CreateJobInput{}
+		applyQueue(job, EncodeInput{Priority: aws.Int(90)})
+
+		assert.Nil(t, job.Queue)
+		assert.Equal(t, int64(50), *job.Priority)
+	})
+
+	t.Run("should keep a priority of 0 chosen for the asset", func(t *testing.T) {
+		os.Setenv("MediaConvertPriority", "-10")
+		defer os.Unsetenv("MediaConvertPriority")
+
+		job := &mediaconvert.CreateJobInput{}
+		applyQueue(job, EncodeInput{Priority: aws.Int(0)})
+
+		assert.Equal(t, int64(0), *job.Priority)
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+// defaultTemplateCacheTTL is used when TemplateCacheTTL (seconds) is not set.
+const defaultTemplateCacheTTL = 5 * time.Minute
+
+// TemplateCache keeps job templates in memory across warm invocations so
+// encode does not call GetJobTemplate for every job.
+type TemplateCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]templateCacheEntry
+}
+
+type templateCacheEntry struct {
+	template []byte
+	expires  time.Time
+}
+
+func NewTemplateCache(ttl time.Duration) *TemplateCache {
+	return &TemplateCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]templateCacheEntry{},
+	}
+}
+
+// NewTemplateCacheFromEnv builds a cache with the TTL from TemplateCacheTTL.
+// A TTL of 0 disables the cache.
+func NewTemplateCacheFromEnv() *TemplateCache {
+	ttl := defaultTemplateCacheTTL
+	if value, err := strconv.Atoi(os.Getenv("TemplateCacheTTL")); err == nil && value >= 0 {
+		ttl = time.Duration(value) * time.Second
+	}
+	return NewTemplateCache(ttl)
+}
+
+// Get returns a copy of the cached template. The job settings built from a
+// template are modified in place, so callers must never share the cached one.
+func (c *TemplateCache) Get(name string) (*mediaconvert.GetJobTemplateOutput, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	if ok && !c.now().Before(entry.expires) {
+		delete(c.entries, name)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	var template mediaconvert.GetJobTemplateOutput
+	if err := json.Unmarshal(entry.template, &template); err != nil {
+		return nil, false
+	}
+	return &template, true
+}
+
+func (c *TemplateCache) Put(name string, template *mediaconvert.GetJobTemplateOutput) error {
+	if c == nil || c.ttl <= 0 {
+		return nil
+	}
+
+	templateJson, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("TemplateCache.Put: json.Marshal: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[name] = templateCacheEntry{
+		template: templateJson,
+		expires:  c.now().Add(c.ttl),
+	}
+	return nil
+}
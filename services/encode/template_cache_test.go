@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTemplateCache(t *testing.T) {
+	template := &mediaconvert.GetJobTemplateOutput{
+		JobTemplate: &mediaconvert.JobTemplate{
+			Name: aws.String("tmpl"),
+			Settings: &mediaconvert.JobTemplateSettings{
+				OutputGroups: []*mediaconvert.OutputGroup{
+					{
+						Name: aws.String("HLS"),
+						OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+							Type: aws.String("HLS_GROUP_SETTINGS"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("should return copies until the TTL expires", func(t *testing.T) {
+		now := time.Now()
+		cache := NewTemplateCache(time.Minute)
+		cache.now = func() time.Time { return now }
+
+		assert.Nil(t, cache.Put("tmpl", template))
+
+		cached, ok := cache.Get("tmpl")
+		assert.True(t, ok)
+		assert.Equal(t, "HLS", *cached.JobTemplate.Settings.OutputGroups[0].Name)
+
+		cached.JobTemplate.Settings.OutputGroups[0].Name = aws.String("changed")
+		cached, _ = cache.Get("tmpl")
+		assert.Equal(t, "HLS", *cached.JobTemplate.Settings.OutputGroups[0].Name)
+
+		now = now.Add(time.Minute)
+		_, ok = cache.Get("tmpl")
+		assert.False(t, ok)
+	})
+
+	t.Run("should not cache when disabled", func(t *testing.T) {
+		cache := NewTemplateCache(0)
+		assert.Nil(t, cache.Put("tmpl", template))
+
+		_, ok := cache.Get("tmpl")
+		assert.False(t, ok)
+
+		var nilCache *TemplateCache
+		_, ok = nilCache.Get("tmpl")
+		assert.False(t, ok)
+	})
+
+	t.Run("should only call GetJobTemplate once across invocations", func(t *testing.T) {
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		mediaConvertClientMock.On("GetJobTemplate", mock.Anything).Return(template, nil)
+		mediaConvertClientMock.On("CreateJob", mock.Anything).Return(&mediaconvert.CreateJobOutput{
+			Job: &mediaconvert.Job{Id: aws.String("job")},
+		}, nil)
+
+		handler := Handler{
+			MediaConvertClient: mediaConvertClientMock,
+			TemplateCache:      NewTemplateCache(time.Minute),
+		}
+
+		event := EncodeInput{GUID: "guid", JobTemplate: "tmpl", SrcVideo: "video.mp4"}
+		_, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+		_, err = handler.HandleRequest(event)
+		assert.Nil(t, err)
+
+		mediaConvertClientMock.AssertNumberOfCalls(t, "GetJobTemplate", 1)
+		mediaConvertClientMock.AssertNumberOfCalls(t, "CreateJob", 2)
+	})
+}
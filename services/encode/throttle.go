@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+// Defaults for the CreateJob limiter and retries, overridden with the
+// CreateJobRate, CreateJobBurst, CreateJobMaxWait (ms), CreateJobMaxRetries,
+// CreateJobBaseDelay (ms) and CreateJobMaxDelay (ms) env vars.
+const (
+	defaultCreateJobRate       = 10.0
+	defaultCreateJobBurst      = 10
+	defaultCreateJobMaxWait    = 10 * time.Second
+	defaultCreateJobMaxRetries = 5
+	defaultCreateJobBaseDelay  = 200 * time.Millisecond
+	defaultCreateJobMaxDelay   = 5 * time.Second
+)
+
+// sleep is replaced in tests.
+var sleep = time.Sleep
+
+// ThrottledError is returned when MediaConvert keeps throttling encode after
+// every retry. It is returned unwrapped so the Lambda error type is
+// "ThrottledError", which the state machine can match in a Retry block.
+type ThrottledError struct {
+	Op  string
+	Err error
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("encode: main.Handler.HandleRequest: %s: throttled by MediaConvert: %v", e.Op, e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// isThrottle reports whether MediaConvert rejected the request because of
+// its rate limits.
+func isThrottle(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == mediaconvert.ErrCodeTooManyRequestsException || request.IsErrorThrottle(aerr)
+	}
+	return false
+}
+
+// TokenBucket limits the CreateJob rate of a Lambda container. Tokens are
+// refilled continuously at Rate per second up to Burst. The limit is per
+// container, not per account: with N warm containers the account can see up
+// to N times CreateJobRate, so set it to the MediaConvert quota divided by
+// the expected concurrency (or cap the function's reserved concurrency).
+type TokenBucket struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	maxWait time.Duration
+	now     func() time.Time
+}
+
+func NewTokenBucket(rate float64, burst int, maxWait time.Duration) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:    rate,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		maxWait: maxWait,
+		now:     time.Now,
+	}
+}
+
+func NewTokenBucketFromEnv() *TokenBucket {
+	rate := defaultCreateJobRate
+	if value, err := strconv.ParseFloat(os.Getenv("CreateJobRate"), 64); err == nil && value > 0 {
+		rate = value
+	}
+
+	return NewTokenBucket(
+		rate,
+		getEnvInt("CreateJobBurst", defaultCreateJobBurst),
+		getEnvDuration("CreateJobMaxWait", defaultCreateJobMaxWait),
+	)
+}
+
+// Wait takes a token, sleeping until one is available. It returns false
+// without taking a token when the wait would exceed the bucket max wait.
+func (b *TokenBucket) Wait() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mu.Unlock()
+		return true
+	}
+
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if wait > b.maxWait {
+		b.mu.Unlock()
+		return false
+	}
+	// Reserve the token now, the refill while sleeping pays it back
+	b.tokens--
+	b.mu.Unlock()
+
+	sleep(wait)
+	return true
+}
+
+// backoff returns a full-jitter exponential delay for the given attempt.
+func backoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	delay := base << attempt
+	if delay <= 0 || delay > max {
+		delay = max
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// createJob calls CreateJob through the token bucket and retries throttled
+// calls with jittered exponential backoff. Other errors are not retried.
+func (h *Handler) createJob(job *mediaconvert.CreateJobInput) (*mediaconvert.CreateJobOutput, error) {
+	maxRetries := getEnvInt("CreateJobMaxRetries", defaultCreateJobMaxRetries)
+	baseDelay := getEnvDuration("CreateJobBaseDelay", defaultCreateJobBaseDelay)
+	maxDelay := getEnvDuration("CreateJobMaxDelay", defaultCreateJobMaxDelay)
+
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if !h.Limiter.Wait() {
+			return nil, &ThrottledError{Op: "CreateJob", Err: fmt.Errorf("rate limiter wait exceeded")}
+		}
+
+		var data *mediaconvert.CreateJobOutput
+		data, err = h.MediaConvertClient.CreateJob(job)
+		if err == nil {
+			return data, nil
+		}
+		if !isThrottle(err) {
+			return nil, fmt.Errorf("encode: main.Handler.HandleRequest: CreateJob: %w", err)
+		}
+
+		if attempt < maxRetries {
+			delay := backoff(attempt, baseDelay, maxDelay)
+			log.Printf("CreateJob throttled, retrying in %s (attempt %d/%d)", delay, attempt+1, maxRetries)
+			sleep(delay)
+		}
+	}
+
+	return nil, &ThrottledError{Op: "CreateJob", Err: err}
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return time.Duration(value) * time.Millisecond
+	}
+	return fallback
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestThrottle(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	throttled := awserr.New(mediaconvert.ErrCodeTooManyRequestsException, "slow down", nil)
+	job := &mediaconvert.CreateJobInput{}
+
+	t.Run("should retry throttled CreateJob calls", func(t *testing.T) {
+		slept = nil
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		mediaConvertClientMock.On("CreateJob", mock.Anything).Return(nil, throttled).Twice()
+		mediaConvertClientMock.On("CreateJob", mock.Anything).Return(&mediaconvert.CreateJobOutput{
+			Job: &mediaconvert.Job{Id: aws.String("job")},
+		}, nil).Once()
+
+		handler := Handler{MediaConvertClient: mediaConvertClientMock}
+		data, err := handler.createJob(job)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "job", *data.Job.Id)
+		assert.Len(t, slept, 2)
+	})
+
+	t.Run("should return a ThrottledError when retries are exhausted", func(t *testing.T) {
+		os.Setenv("CreateJobMaxRetries", "2")
+		defer os.Unsetenv("CreateJobMaxRetries")
+
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		mediaConvertClientMock.On("CreateJob", mock.Anything).Return(nil, throttled)
+
+		handler := Handler{MediaConvertClient: mediaConvertClientMock}
+		_, err := handler.createJob(job)
+
+		var throttledError *ThrottledError
+		assert.True(t, errors.As(err, &throttledError))
+		assert.Equal(t, "CreateJob", throttledError.Op)
+		mediaConvertClientMock.AssertNumberOfCalls(t, "CreateJob", 3)
+	})
+
+	t.Run("should not retry other errors", func(t *testing.T) {
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		mediaConvertClientMock.On("CreateJob", mock.Anything).Return(nil, assert.AnError)
+
+		handler := Handler{MediaConvertClient: mediaConvertClientMock}
+		_, err := handler.createJob(job)
+
+		var throttledError *ThrottledError
+		assert.False(t, errors.As(err, &throttledError))
+		mediaConvertClientMock.AssertNumberOfCalls(t, "CreateJob", 1)
+	})
+
+	t.Run("should return the ThrottledError unwrapped from HandleRequest", func(t *testing.T) {
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		mediaConvertClientMock.On("GetJobTemplate", mock.Anything).Return(nil, throttled)
+
+		handler := Handler{MediaConvertClient: mediaConvertClientMock}
+		_, err := handler.HandleRequest(EncodeInput{JobTemplate: "tmpl"})
+
+		_, ok := err.(*ThrottledError)
+		assert.True(t, ok)
+	})
+
+	t.Run("TokenBucket", func(t *testing.T) {
+		slept = nil
+		now := time.Now()
+		bucket := NewTokenBucket(2, 2, time.Second)
+		bucket.now = func() time.Time { return now }
+
+		assert.True(t, bucket.Wait())
+		assert.True(t, bucket.Wait())
+		assert.Empty(t, slept)
+
+		assert.True(t, bucket.Wait())
+		assert.Equal(t, []time.Duration{500 * time.Millisecond}, slept)
+
+		// Two tokens are owed now, the next one is a second away
+		assert.True(t, bucket.Wait())
+		assert.False(t, bucket.Wait())
+
+		now = now.Add(2 * time.Second)
+		assert.True(t, bucket.Wait())
+	})
+
+	t.Run("should keep backoff within the max delay", func(t *testing.T) {
+		for attempt := 0; attempt < 10; attempt++ {
+			delay := backoff(attempt, 100*time.Millisecond, time.Second)
+			assert.True(t, delay >= 0 && delay <= time.Second)
+		}
+	})
+}
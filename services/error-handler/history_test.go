@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var historyNow = time.Date(2025, 2, 23, 10, 14, 34, 556000000, time.UTC)
+
+func TestNewHistoryEvent(t *testing.T) {
+	history := newHistoryEvent(
+		AssetRecord{GUID: "guid", StartTime: "2025-02-23T10:04:34.556Z"},
+		JobStateChange{JobId: "job-2", ErrorCode: 1404, ErrorMessage: "Input not found"},
+		Resubmission{Action: ActionGiveUp},
+		historyNow,
+	)
+	assert.Equal(t, HistoryEvent{
+		GUID:       "guid",
+		Step:       stepEncode,
+		Status:     "Error",
+		Actor:      errorActor,
+		Timestamp:  "2025-02-23T10:14:34.556000Z",
+		DurationMs: 600000,
+		Error:      "job job-2 failed with 1404: Input not found, action " + ActionGiveUp,
+	}, history)
+}
+
+func TestHandleRequestHistory(t *testing.T) {
+	t.Run("should append an event for the failed job", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: TestRecord}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+			Now:                func() time.Time { return historyNow },
+		}
+
+		_, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 1550, "errorMessage": "Accelerated transcoding failed", "userMetadata": {"guid": "guid"}}`))
+		assert.Nil(t, err)
+
+		put := dynamoDBClientMock.Calls[2].Arguments.Get(0).(*dynamodb.PutItemInput)
+		assert.Equal(t, "VIDEO#guid", *put.Item["PK"].S)
+		assert.Equal(t, "EVENT#2025-02-23T10:14:34.556000Z#Encode", *put.Item["SK"].S)
+		assert.Equal(t, "Retrying", *put.Item["status"].S)
+		assert.Equal(t, "600000", *put.Item["durationMs"].N)
+	})
+
+	t.Run("should date the event with the job state change", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: TestRecord}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+			Now:                func() time.Time { return historyNow.Add(time.Minute) },
+		}
+
+		event := getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 1550, "userMetadata": {"guid": "guid"}}`)
+		event.Time = historyNow
+		_, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+
+		put := dynamoDBClientMock.Calls[2].Arguments.Get(0).(*dynamodb.PutItemInput)
+		assert.Equal(t, "EVENT#2025-02-23T10:14:34.556000Z#Encode", *put.Item["SK"].S)
+	})
+
+	t.Run("should resubmit when the event cannot be written", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: TestRecord}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, errors.New("throttled"))
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		_, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 1550, "userMetadata": {"guid": "guid"}}`))
+		assert.Nil(t, err)
+		stepFunctionClientMock.AssertNumberOfCalls(t, "StartExecution", 1)
+	})
+}
@@ -1,15 +1,307 @@
 package main
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/sfn"
 )
 
-func handler(ctx context.Context, event interface{}) (string, error) {
-	return "hello from error-handle", nil
+var (
+	ErrExecutionNameTaken = errors.New("retry execution name is taken by another failure")
+)
+
+type JobStateChange struct {
+	Status       string `json:"status"`
+	JobId        string `json:"jobId"`
+	ErrorCode    int    `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+	UserMetadata struct {
+		GUID string `json:"guid"`
+	} `json:"userMetadata"`
+}
+
+// AssetRecord holds the fields of the asset record the retry policy needs.
+type AssetRecord struct {
+	GUID                   string `json:"guid"`
+	JobTemplate            string `json:"jobTemplate"`
+	JobTemplate2160p       string `json:"jobTemplate_2160p"`
+	JobTemplate1080p       string `json:"jobTemplate_1080p"`
+	JobTemplate720p        string `json:"jobTemplate_720p"`
+	EncodingProfile        int    `json:"encodingProfile"`
+	IsCustomTemplate       bool   `json:"isCustomTemplate"`
+	AcceleratedTranscoding string `json:"acceleratedTranscoding"`
+	RetryCount             int    `json:"retryCount"`
+	LastFailedJobId        string `json:"lastFailedJobId"`
+	StartTime              string `json:"startTime"`
+}
+
+func (r AssetRecord) defaultLadder() string {
+	return map[int]string{
+		2160: r.JobTemplate2160p,
+		1080: r.JobTemplate1080p,
+		720:  r.JobTemplate720p,
+	}[r.EncodingProfile]
+}
+
+// EncodeAttempt is appended to the encodeAttempts list of the record for
+// every failed job.
+type EncodeAttempt struct {
+	Attempt      int    `json:"attempt"`
+	JobId        string `json:"jobId"`
+	ErrorCode    int    `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+	Action       string `json:"action"`
+	Time         string `json:"time"`
+}
+
+type ProcessWorkflowInput struct {
+	GUID                   string `json:"guid"`
+	JobTemplate            string `json:"jobTemplate,omitempty"`
+	AcceleratedTranscoding string `json:"acceleratedTranscoding,omitempty"`
+	// FailedJobId is the job the execution retries
+	FailedJobId string `json:"failedJobId,omitempty"`
+}
+
+type ErrorHandlerOutput struct {
+	GUID   string `json:"guid"`
+	JobId  string `json:"jobId"`
+	Action string `json:"action"`
+}
+
+type DynamoDBClient interface {
+	GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+}
+
+type StepFunctionClient interface {
+	StartExecution(input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error)
+	DescribeExecution(input *sfn.DescribeExecutionInput) (*sfn.DescribeExecutionOutput, error)
+}
+
+type Handler struct {
+	DynamoDBClient     DynamoDBClient
+	StepFunctionClient StepFunctionClient
+	Now                func() time.Time
+}
+
+// HandleRequest resubmits MediaConvert jobs reported as ERROR by restarting
+// the process workflow with the template and acceleration mode chosen by the
+// retry policy. Every attempt is recorded on the asset record.
+func (h *Handler) HandleRequest(event events.EventBridgeEvent) (*ErrorHandlerOutput, error) {
+	eventJson, _ := json.Marshal(event)
+	log.Printf("REQUEST:: %s", eventJson)
+
+	var detail JobStateChange
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return nil, fmt.Errorf("error-handler: main.Handler.HandleRequest: json.Unmarshal: %w", err)
+	}
+
+	if event.Source != "aws.mediaconvert" || detail.Status != "ERROR" {
+		log.Printf("Ignoring %s event with status %s", event.Source, detail.Status)
+		return &ErrorHandlerOutput{GUID: detail.UserMetadata.GUID, JobId: detail.JobId}, nil
+	}
+
+	guid := detail.UserMetadata.GUID
+	data, err := h.DynamoDBClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Key:       assetKey(guid),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error-handler: main.Handler.HandleRequest: GetItem: %w", err)
+	}
+	if len(data.Item) == 0 {
+		return nil, fmt.Errorf("error-handler: main.Handler.HandleRequest: item with GUID %s not found", guid)
+	}
+
+	var record AssetRecord
+	if err := dynamodbattribute.UnmarshalMap(data.Item, &record); err != nil {
+		return nil, fmt.Errorf("error-handler: main.Handler.HandleRequest: UnmarshalMap: %w", err)
+	}
+
+	resubmission := decide(record, detail)
+	log.Printf("Job %s failed with %d: %s, action %s", detail.JobId, detail.ErrorCode, detail.ErrorMessage, resubmission.Action)
+
+	if record.LastFailedJobId == detail.JobId {
+		log.Printf("Job %s was already handled", detail.JobId)
+		return &ErrorHandlerOutput{GUID: guid, JobId: detail.JobId}, nil
+	}
+
+	// The execution is started before the attempt is recorded, so a failed
+	// start leaves the record untouched and the event can be retried. The
+	// execution name is deterministic per failed job: a retried invocation
+	// finds the execution it started and goes on to record the attempt.
+	attempt := record.RetryCount + 1
+	if resubmission.Action != ActionGiveUp {
+		if err := h.startExecution(guid, detail.JobId, resubmission); err != nil {
+			return nil, fmt.Errorf("error-handler: main.Handler.HandleRequest: %w", err)
+		}
+	}
+
+	recorded, err := h.recordAttempt(guid, attempt, detail, resubmission)
+	if err != nil {
+		return nil, fmt.Errorf("error-handler: main.Handler.HandleRequest: %w", err)
+	}
+	if !recorded {
+		log.Printf("Job %s was already handled", detail.JobId)
+		return &ErrorHandlerOutput{GUID: guid, JobId: detail.JobId}, nil
+	}
+
+	// The timeline is for troubleshooting, a failed write does not stop the
+	// resubmission. The event is dated with the EventBridge event, so a
+	// retried invocation writes the same sort key.
+	eventTime := event.Time.UTC()
+	if event.Time.IsZero() {
+		eventTime = time.Now().UTC()
+		if h.Now != nil {
+			eventTime = h.Now().UTC()
+		}
+	}
+	if err := h.putHistoryEvent(newHistoryEvent(record, detail, resubmission, eventTime)); err != nil {
+		log.Printf("error-handler: main.Handler.HandleRequest: %v", err)
+	}
+
+	return &ErrorHandlerOutput{GUID: guid, JobId: detail.JobId, Action: resubmission.Action}, nil
+}
+
+// startExecution restarts the process workflow to retry the failed job. The
+// execution is named <guid>-retry-<failed job id>, retry counts restart at
+// zero on reprocess and would reuse names Step Functions still holds. An
+// existing execution with that name only counts as success when it retries
+// the same job, i.e. an earlier invocation started it.
+func (h *Handler) startExecution(guid string, failedJobId string, resubmission Resubmission) error {
+	inputJson, err := json.Marshal(ProcessWorkflowInput{
+		GUID:                   guid,
+		JobTemplate:            resubmission.JobTemplate,
+		AcceleratedTranscoding: resubmission.AcceleratedTranscoding,
+		FailedJobId:            failedJobId,
+	})
+	if err != nil {
+		return fmt.Errorf("startExecution: json.Marshal: %w", err)
+	}
+
+	stateMachineArn := os.Getenv("ProcessWorkflow")
+	name := fmt.Sprintf("%s-retry-%s", guid, failedJobId)
+	_, err = h.StepFunctionClient.StartExecution(&sfn.StartExecutionInput{
+		Name:            aws.String(name),
+		Input:           aws.String(string(inputJson)),
+		StateMachineArn: aws.String(stateMachineArn),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != sfn.ErrCodeExecutionAlreadyExists {
+			return fmt.Errorf("startExecution: StartExecution: %w", err)
+		}
+
+		data, err := h.StepFunctionClient.DescribeExecution(&sfn.DescribeExecutionInput{
+			ExecutionArn: aws.String(executionArn(stateMachineArn, name)),
+		})
+		if err != nil {
+			return fmt.Errorf("startExecution: DescribeExecution: %w", err)
+		}
+
+		var existing ProcessWorkflowInput
+		if err := json.Unmarshal([]byte(aws.StringValue(data.Input)), &existing); err != nil || existing.FailedJobId != failedJobId {
+			return fmt.Errorf("startExecution: %s: %w", name, ErrExecutionNameTaken)
+		}
+		log.Printf("Execution %s was already started", name)
+	}
+
+	return nil
+}
+
+// executionArn returns the ARN of the execution of the state machine with the
+// given name.
+func executionArn(stateMachineArn string, name string) string {
+	return strings.Replace(stateMachineArn, ":stateMachine:", ":execution:", 1) + ":" + name
+}
+
+// recordAttempt appends the attempt to the record and bumps retryCount. It
+// returns false when the job was already recorded, so duplicate events do not
+// resubmit twice.
+func (h *Handler) recordAttempt(guid string, attempt int, detail JobStateChange, resubmission Resubmission) (bool, error) {
+	attemptValue, err := dynamodbattribute.Marshal(EncodeAttempt{
+		Attempt:      attempt,
+		JobId:        detail.JobId,
+		ErrorCode:    detail.ErrorCode,
+		ErrorMessage: detail.ErrorMessage,
+		Action:       resubmission.Action,
+		Time:         time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return false, fmt.Errorf("recordAttempt: Marshal: %w", err)
+	}
+
+	_, err = h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Key:       assetKey(guid),
+		UpdateExpression: aws.String("SET encodeAttempts = list_append(if_not_exists(encodeAttempts, :empty), :attempt), " +
+			"retryCount = :retryCount, lastFailedJobId = :jobId, workflowStatus = :status, #version = if_not_exists(#version, :zero) + :one"),
+		ConditionExpression: aws.String("attribute_not_exists(lastFailedJobId) OR lastFailedJobId <> :jobId"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":empty":      {L: []*dynamodb.AttributeValue{}},
+			":attempt":    {L: []*dynamodb.AttributeValue{attemptValue}},
+			":retryCount": {N: aws.String(strconv.Itoa(attempt))},
+			":jobId":      {S: aws.String(detail.JobId)},
+			":status":     {S: aws.String(attemptStatus(resubmission))},
+			":zero":       {N: aws.String("0")},
+			":one":        {N: aws.String("1")},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, fmt.Errorf("recordAttempt: UpdateItem: %w", err)
+	}
+
+	return true, nil
+}
+
+// attemptStatus is the workflow status after a failed job, Error once the
+// retry policy gives up.
+func attemptStatus(resubmission Resubmission) string {
+	if resubmission.Action == ActionGiveUp {
+		return "Error"
+	}
+	return "Retrying"
+}
+
+func assetKey(guid string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String("VIDEO#" + guid)},
+		"SK": {S: aws.String("METADATA")},
+	}
 }
 
 func main() {
-	lambda.Start(handler)
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("error-handler: main: session.NewSession: %v", err)
+	}
+
+	handler := &Handler{
+		DynamoDBClient:     dynamodb.New(sess),
+		StepFunctionClient: sfn.New(sess),
+	}
+
+	lambda.Start(handler.HandleRequest)
 }
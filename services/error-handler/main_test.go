@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBClientMock struct {
+	mock.Mock
+}
+
+func (m *DynamoDBClientMock) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+type StepFunctionClientMock struct {
+	mock.Mock
+}
+
+func (m *StepFunctionClientMock) StartExecution(input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sfn.StartExecutionOutput), args.Error(1)
+}
+
+func (m *StepFunctionClientMock) DescribeExecution(input *sfn.DescribeExecutionInput) (*sfn.DescribeExecutionOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sfn.DescribeExecutionOutput), args.Error(1)
+}
+
+var TestRecord = map[string]*dynamodb.AttributeValue{
+	"guid":                   {S: aws.String("guid")},
+	"jobTemplate":            {S: aws.String("custom")},
+	"jobTemplate_1080p":      {S: aws.String("ladder-1080p")},
+	"encodingProfile":        {N: aws.String("1080")},
+	"isCustomTemplate":       {BOOL: aws.Bool(true)},
+	"acceleratedTranscoding": {S: aws.String("ENABLED")},
+	"retryCount":             {N: aws.String("1")},
+	"startTime":              {S: aws.String("2025-02-23T10:04:34.556Z")},
+}
+
+func getErrorEvent(detail string) events.EventBridgeEvent {
+	return events.EventBridgeEvent{
+		Source:     "aws.mediaconvert",
+		DetailType: "MediaConvert Job State Change",
+		Detail:     json.RawMessage(detail),
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	t.Run("should record the attempt and restart the process workflow", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: TestRecord}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		output, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 1550, "errorMessage": "Accelerated transcoding failed", "userMetadata": {"guid": "guid"}}`))
+
+		assert.Nil(t, err)
+		assert.Equal(t, ActionDisableAcceleration, output.Action)
+
+		update := dynamoDBClientMock.Calls[1].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+		assert.Equal(t, "2", *update.ExpressionAttributeValues[":retryCount"].N)
+		assert.Contains(t, *update.UpdateExpression, "#version = if_not_exists(#version, :zero) + :one")
+		assert.Equal(t, "version", *update.ExpressionAttributeNames["#version"])
+		attempt := update.ExpressionAttributeValues[":attempt"].L[0].M
+		assert.Equal(t, "job-2", *attempt["jobId"].S)
+		assert.Equal(t, "1550", *attempt["errorCode"].N)
+
+		stepFunctionClientMock.AssertCalled(t, "StartExecution", mock.MatchedBy(func(input *sfn.StartExecutionInput) bool {
+			return *input.Name == "guid-retry-job-2" &&
+				*input.Input == `{"guid":"guid","jobTemplate":"custom","acceleratedTranscoding":"DISABLED","failedJobId":"job-2"}`
+		}))
+	})
+
+	t.Run("should not resubmit when giving up", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: TestRecord}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		output, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 1404, "userMetadata": {"guid": "guid"}}`))
+
+		assert.Nil(t, err)
+		assert.Equal(t, ActionGiveUp, output.Action)
+		update := dynamoDBClientMock.Calls[1].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+		assert.Equal(t, "Error", *update.ExpressionAttributeValues[":status"].S)
+		stepFunctionClientMock.AssertNotCalled(t, "StartExecution", mock.Anything)
+	})
+
+	t.Run("should ignore duplicate events", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		record := map[string]*dynamodb.AttributeValue{}
+		for key, value := range TestRecord {
+			record[key] = value
+		}
+		record["lastFailedJobId"] = &dynamodb.AttributeValue{S: aws.String("job-2")}
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: record}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		_, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 3450, "userMetadata": {"guid": "guid"}}`))
+
+		assert.Nil(t, err)
+		stepFunctionClientMock.AssertNotCalled(t, "StartExecution", mock.Anything)
+		dynamoDBClientMock.AssertNotCalled(t, "UpdateItem", mock.Anything)
+	})
+
+	t.Run("should record the attempt when the execution already exists", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: TestRecord}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(nil, awserr.New(sfn.ErrCodeExecutionAlreadyExists, "exists", nil))
+		stepFunctionClientMock.On("DescribeExecution", &sfn.DescribeExecutionInput{
+			ExecutionArn: aws.String("arn:aws:states:us-east-1:123456789012:execution:process:guid-retry-job-2"),
+		}).Return(&sfn.DescribeExecutionOutput{Input: aws.String(`{"guid":"guid","failedJobId":"job-2"}`)}, nil)
+
+		t.Setenv("ProcessWorkflow", "arn:aws:states:us-east-1:123456789012:stateMachine:process")
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		output, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 3450, "userMetadata": {"guid": "guid"}}`))
+
+		assert.Nil(t, err)
+		assert.Equal(t, ActionRetry, output.Action)
+		dynamoDBClientMock.AssertNumberOfCalls(t, "UpdateItem", 1)
+	})
+
+	t.Run("should fail when the execution name belongs to another failure", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: TestRecord}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(nil, awserr.New(sfn.ErrCodeExecutionAlreadyExists, "exists", nil))
+		stepFunctionClientMock.On("DescribeExecution", mock.Anything).Return(&sfn.DescribeExecutionOutput{Input: aws.String(`{"guid":"guid"}`)}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		_, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 3450, "userMetadata": {"guid": "guid"}}`))
+
+		assert.ErrorIs(t, err, ErrExecutionNameTaken)
+		dynamoDBClientMock.AssertNotCalled(t, "UpdateItem", mock.Anything)
+	})
+
+	t.Run("should not record the attempt when the execution fails to start", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{Item: TestRecord}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(nil, awserr.New(sfn.ErrCodeExecutionLimitExceeded, "limit", nil))
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		_, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "errorCode": 3450, "userMetadata": {"guid": "guid"}}`))
+
+		assert.Error(t, err)
+		dynamoDBClientMock.AssertNotCalled(t, "UpdateItem", mock.Anything)
+	})
+
+	t.Run("should ignore other events", func(t *testing.T) {
+		handler := Handler{}
+		output, err := handler.HandleRequest(getErrorEvent(`{"status": "COMPLETE", "jobId": "job-2"}`))
+
+		assert.Nil(t, err)
+		assert.Empty(t, output.Action)
+	})
+
+	t.Run("should fail when the record is missing", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		_, err := handler.HandleRequest(getErrorEvent(`{"status": "ERROR", "jobId": "job-2", "userMetadata": {"guid": "guid"}}`))
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Resubmission actions, recorded on every attempt.
+const (
+	ActionRetry               = "Retry"
+	ActionDisableAcceleration = "DisableAcceleration"
+	ActionDefaultLadder       = "DefaultLadder"
+	ActionGiveUp              = "GiveUp"
+)
+
+const defaultMaxJobRetries = 2
+
+// Source errors (unreadable container, bad input, access denied, missing
+// file) fail the same way on every attempt and are never resubmitted.
+var defaultInputErrorCodes = []int{1010, 1030, 1401, 1404}
+
+// Resubmission is the decision taken for a failed job.
+type Resubmission struct {
+	Action                 string
+	JobTemplate            string
+	AcceleratedTranscoding string
+}
+
+// decide applies the resubmission policy to a failed job:
+//   - after MaxJobRetries attempts the job is given up
+//   - acceleration errors on an ENABLED job are resubmitted with DISABLED
+//   - settings errors on a custom template fall back to the default ladder
+//   - transient errors (1999 and the 3xxx internal errors, or the codes in
+//     RetryableErrorCodes) are resubmitted unchanged
+//
+// Resubmissions pin the template and acceleration mode of the failed job so
+// a later retry does not undo an earlier fallback.
+func decide(record AssetRecord, detail JobStateChange) Resubmission {
+	resubmission := Resubmission{
+		Action:                 ActionGiveUp,
+		JobTemplate:            record.JobTemplate,
+		AcceleratedTranscoding: record.AcceleratedTranscoding,
+	}
+
+	if record.RetryCount >= getEnvInt("MaxJobRetries", defaultMaxJobRetries) {
+		return resubmission
+	}
+
+	if record.AcceleratedTranscoding == "ENABLED" && isAccelerationError(detail) {
+		resubmission.Action = ActionDisableAcceleration
+		resubmission.AcceleratedTranscoding = "DISABLED"
+		return resubmission
+	}
+
+	ladder := record.defaultLadder()
+	if record.IsCustomTemplate && ladder != "" && ladder != record.JobTemplate && isSettingsError(detail.ErrorCode) {
+		resubmission.Action = ActionDefaultLadder
+		resubmission.JobTemplate = ladder
+		return resubmission
+	}
+
+	if isRetryableError(detail.ErrorCode) {
+		resubmission.Action = ActionRetry
+	}
+
+	return resubmission
+}
+
+func isAccelerationError(detail JobStateChange) bool {
+	if containsCode(getEnvCodes("AccelerationErrorCodes", nil), detail.ErrorCode) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(detail.ErrorMessage), "accelerat")
+}
+
+func isSettingsError(code int) bool {
+	return code >= 1000 && code < 2000 && code != 1999 && !containsCode(getEnvCodes("InputErrorCodes", defaultInputErrorCodes), code)
+}
+
+func isRetryableError(code int) bool {
+	if codes := getEnvCodes("RetryableErrorCodes", nil); codes != nil {
+		return containsCode(codes, code)
+	}
+	return code == 1999 || (code >= 3000 && code < 4000)
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// getEnvCodes reads a comma separated list of error codes.
+func getEnvCodes(key string, fallback []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var codes []int
+	for _, code := range strings.Split(value, ",") {
+		if c, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+			codes = append(codes, c)
+		}
+	}
+	return codes
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecide(t *testing.T) {
+	record := AssetRecord{
+		GUID:                   "guid",
+		JobTemplate:            "custom",
+		JobTemplate1080p:       "ladder-1080p",
+		EncodingProfile:        1080,
+		IsCustomTemplate:       true,
+		AcceleratedTranscoding: "ENABLED",
+	}
+
+	tests := []struct {
+		name     string
+		record   func(r AssetRecord) AssetRecord
+		detail   JobStateChange
+		expected Resubmission
+	}{
+		{
+			name:     "should disable acceleration on acceleration errors",
+			detail:   JobStateChange{ErrorCode: 1040, ErrorMessage: "Input not supported by accelerated transcoding"},
+			expected: Resubmission{Action: ActionDisableAcceleration, JobTemplate: "custom", AcceleratedTranscoding: "DISABLED"},
+		},
+		{
+			name:     "should fall back to the default ladder on settings errors",
+			detail:   JobStateChange{ErrorCode: 1040, ErrorMessage: "Invalid settings"},
+			expected: Resubmission{Action: ActionDefaultLadder, JobTemplate: "ladder-1080p", AcceleratedTranscoding: "ENABLED"},
+		},
+		{
+			name: "should not fall back when the default ladder already failed",
+			record: func(r AssetRecord) AssetRecord {
+				r.JobTemplate = "ladder-1080p"
+				return r
+			},
+			detail:   JobStateChange{ErrorCode: 1040},
+			expected: Resubmission{Action: ActionGiveUp, JobTemplate: "ladder-1080p", AcceleratedTranscoding: "ENABLED"},
+		},
+		{
+			name:     "should retry internal errors unchanged",
+			detail:   JobStateChange{ErrorCode: 3450},
+			expected: Resubmission{Action: ActionRetry, JobTemplate: "custom", AcceleratedTranscoding: "ENABLED"},
+		},
+		{
+			name:     "should give up on input errors",
+			detail:   JobStateChange{ErrorCode: 1404},
+			expected: Resubmission{Action: ActionGiveUp, JobTemplate: "custom", AcceleratedTranscoding: "ENABLED"},
+		},
+		{
+			name: "should give up after the maximum retries",
+			record: func(r AssetRecord) AssetRecord {
+				r.RetryCount = 2
+				return r
+			},
+			detail:   JobStateChange{ErrorCode: 3450},
+			expected: Resubmission{Action: ActionGiveUp, JobTemplate: "custom", AcceleratedTranscoding: "ENABLED"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := record
+			if tt.record != nil {
+				r = tt.record(r)
+			}
+			assert.Equal(t, tt.expected, decide(r, tt.detail))
+		})
+	}
+
+	t.Run("should read error codes from env", func(t *testing.T) {
+		os.Setenv("RetryableErrorCodes", "1404")
+		os.Setenv("MaxJobRetries", "5")
+		defer os.Unsetenv("RetryableErrorCodes")
+		defer os.Unsetenv("MaxJobRetries")
+
+		r := record
+		r.IsCustomTemplate = false
+		r.RetryCount = 3
+		assert.Equal(t, ActionRetry, decide(r, JobStateChange{ErrorCode: 1404}).Action)
+		assert.Equal(t, ActionGiveUp, decide(r, JobStateChange{ErrorCode: 3450}).Action)
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// cdnWeightsMetadata is the user metadata of a source video overriding the
+// weights of the CdnHosts CDNs for that asset, for example
+// x-amz-meta-cdn-weights: primary=20,secondary=80
+const cdnWeightsMetadata = "cdn-weights"
+
+// parseCdnWeights parses a comma separated list of name=weight pairs.
+func parseCdnWeights(value string) (map[string]int, error) {
+	weights := map[string]int{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("parseCdnWeights: expected name=weight: %q", pair)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("parseCdnWeights: invalid weight: %q", pair)
+		}
+		weights[strings.TrimSpace(name)] = w
+	}
+	if len(weights) == 0 {
+		return nil, nil
+	}
+	return weights, nil
+}
+
+// getCdnWeights returns the per-asset CDN weights set in the user metadata
+// of the source video, or nil when it has none.
+func (h *Handler) getCdnWeights(bucket string, key string) (map[string]int, error) {
+	data, err := h.S3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getCdnWeights: HeadObject: %w", err)
+	}
+
+	// the SDK canonicalizes metadata keys like HTTP headers
+	for name, value := range data.Metadata {
+		if strings.EqualFold(name, cdnWeightsMetadata) {
+			weights, err := parseCdnWeights(aws.StringValue(value))
+			if err != nil {
+				return nil, fmt.Errorf("getCdnWeights: %w", err)
+			}
+			return weights, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCdnWeights(t *testing.T) {
+	t.Run("should parse name=weight pairs", func(t *testing.T) {
+		weights, err := parseCdnWeights(" primary=20, secondary = 80,")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"primary": 20, "secondary": 80}, weights)
+
+		weights, err = parseCdnWeights("")
+		assert.Nil(t, err)
+		assert.Nil(t, weights)
+	})
+
+	t.Run("should reject malformed pairs", func(t *testing.T) {
+		for _, value := range []string{"primary", "=20", "primary=high", "primary=-1"} {
+			_, err := parseCdnWeights(value)
+			assert.Error(t, err, value)
+		}
+	})
+
+	t.Run("should record the weights of the source metadata when CdnHosts is set", func(t *testing.T) {
+		os.Setenv("CdnHosts", `[{"name":"primary"},{"name":"secondary"}]`)
+		defer os.Unsetenv("CdnHosts")
+
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		s3ClientMock := new(S3ClientMock)
+		s3ClientMock.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{
+			Metadata: map[string]*string{"Cdn-Weights": aws.String("primary=20,secondary=80")},
+		}, nil)
+
+		handler := &Handler{DynamoDBClient: dynamoDBClientMock, S3Client: s3ClientMock}
+		data, err := handler.HandleRequest(InputValidateEvent{
+			GUID:            "1234",
+			WorkflowTrigger: "Video",
+			Records:         []events.S3EventRecord{{S3: events.S3Entity{Object: events.S3Object{Key: "clip.mp4"}}}},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"primary": 20, "secondary": 80}, data.CdnWeights)
+		input := s3ClientMock.Calls[0].Arguments.Get(0).(*s3.HeadObjectInput)
+		assert.Equal(t, "clip.mp4", *input.Key)
+	})
+
+	t.Run("should fail on malformed weights in the source metadata", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		s3ClientMock.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{
+			Metadata: map[string]*string{"Cdn-Weights": aws.String("primary")},
+		}, nil)
+
+		handler := &Handler{S3Client: s3ClientMock}
+		_, err := handler.getCdnWeights("src", "clip.mp4")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Duplicate policies, set per stack with the DuplicatePolicy env var.
+const (
+	// DuplicateSkip records the duplicate without processing it.
+	DuplicateSkip = "skip"
+	// DuplicateLink records the duplicate with the outputs of the asset it
+	// duplicates, once that asset is complete. Neither is processed.
+	DuplicateLink = "link-to-existing"
+	// DuplicateReprocess processes the duplicate like any other upload.
+	DuplicateReprocess = "reprocess"
+)
+
+// LinkedOutputs are the output attributes copied from the existing asset
+// under the link-to-existing policy.
+type LinkedOutputs struct {
+	HlsPlaylist      *string   `json:"hlsPlaylist,omitempty"`
+	HlsUrl           *string   `json:"hlsUrl,omitempty"`
+	DashPlaylist     *string   `json:"dashPlaylist,omitempty"`
+	DashUrl          *string   `json:"dashUrl,omitempty"`
+	Mp4Outputs       []*string `json:"mp4Outputs,omitempty"`
+	Mp4Urls          []*string `json:"mp4Urls,omitempty"`
+	MssPlaylist      *string   `json:"mssPlaylist,omitempty"`
+	MssUrl           *string   `json:"mssUrl,omitempty"`
+	CmafDashPlaylist *string   `json:"cmafDashPlaylist,omitempty"`
+	CmafDashUrl      *string   `json:"cmafDashUrl,omitempty"`
+	CmafHlsPlaylist  *string   `json:"cmafHlsPlaylist,omitempty"`
+	CmafHlsUrl       *string   `json:"cmafHlsUrl,omitempty"`
+	ThumbNails       []*string `json:"thumbNails,omitempty"`
+	ThumbNailsUrls   []*string `json:"thumbNailsUrls,omitempty"`
+}
+
+type FingerprintRecord struct {
+	GUID     string `json:"guid"`
+	SrcVideo string `json:"srcVideo"`
+}
+
+type AssetRecord struct {
+	WorkflowStatus string `json:"workflowStatus"`
+	LinkedOutputs
+}
+
+func getDuplicatePolicy() string {
+	switch policy := os.Getenv("DuplicatePolicy"); policy {
+	case DuplicateSkip, DuplicateLink:
+		return policy
+	}
+	return DuplicateReprocess
+}
+
+// getFingerprint identifies the uploaded content by key, size and ETag, plus
+// the full object checksum when one is available.
+func getFingerprint(object events.S3Object, checksum string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%d\n%s\n%s", object.Key, object.Size, object.ETag, checksum)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// getChecksum returns the additional checksum S3 stored for the object, or
+// an empty string when it was uploaded without one.
+func (h *Handler) getChecksum(bucket string, key string) (string, error) {
+	data, err := h.S3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+	})
+	if err != nil {
+		return "", fmt.Errorf("getChecksum: HeadObject: %w", err)
+	}
+
+	for _, checksum := range []*string{data.ChecksumSHA256, data.ChecksumSHA1, data.ChecksumCRC32C, data.ChecksumCRC32} {
+		if aws.StringValue(checksum) != "" {
+			return *checksum, nil
+		}
+	}
+	return "", nil
+}
+
+// maxClaimAttempts bounds the claim loop of checkDuplicate when concurrent
+// uploads keep taking the fingerprint over.
+const maxClaimAttempts = 3
+
+// failedStatuses are the workflow statuses of assets that will never publish.
+// Their fingerprint is taken over by the next upload of the same content
+// instead of turning it into a duplicate of a failed asset.
+var failedStatuses = []string{"Error", "Rejected", "OutputInvalid"}
+
+// checkDuplicate claims the fingerprint of the upload for this GUID. When
+// another asset already holds it, the duplicate policy is applied to data,
+// unless that asset failed, in which case this upload takes the fingerprint
+// over. A retried execution finds its own GUID and is not a duplicate.
+func (h *Handler) checkDuplicate(data *InputValidateData, record events.S3EventRecord) error {
+	checksum := ""
+	if os.Getenv("FingerprintChecksum") == "true" {
+		var err error
+		checksum, err = h.getChecksum(data.SrcBucket, data.SrcVideo)
+		if err != nil {
+			return fmt.Errorf("checkDuplicate: %w", err)
+		}
+	}
+	data.Fingerprint = getFingerprint(record.S3.Object, checksum)
+
+	key := map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String("FINGERPRINT#" + data.Fingerprint)},
+		"SK": {S: aws.String("FINGERPRINT")},
+	}
+
+	for attempt := 0; attempt < maxClaimAttempts; attempt++ {
+		claimed, err := h.claimFingerprint(key, data, record)
+		if err != nil {
+			return fmt.Errorf("checkDuplicate: %w", err)
+		}
+		if claimed {
+			return nil
+		}
+
+		existing, err := h.DynamoDBClient.GetItem(&dynamodb.GetItemInput{
+			TableName:      aws.String(os.Getenv("DynamoDBTable")),
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return fmt.Errorf("checkDuplicate: GetItem: %w", err)
+		}
+
+		var fingerprint FingerprintRecord
+		if err := dynamodbattribute.UnmarshalMap(existing.Item, &fingerprint); err != nil {
+			return fmt.Errorf("checkDuplicate: UnmarshalMap: %w", err)
+		}
+		if fingerprint.GUID == "" {
+			// Removed since the claim, try again
+			continue
+		}
+
+		asset, err := h.getAsset(fingerprint.GUID)
+		if err != nil {
+			return fmt.Errorf("checkDuplicate: %w", err)
+		}
+
+		if isFailedStatus(asset.WorkflowStatus) {
+			log.Printf("%s takes the fingerprint over from %s, which is %q", data.GUID, fingerprint.GUID, asset.WorkflowStatus)
+			taken, err := h.takeOverFingerprint(key, fingerprint.GUID, data)
+			if err != nil {
+				return fmt.Errorf("checkDuplicate: %w", err)
+			}
+			if taken {
+				return nil
+			}
+			continue
+		}
+
+		return h.applyDuplicatePolicy(key, data, fingerprint, asset)
+	}
+
+	return fmt.Errorf("checkDuplicate: fingerprint %s still contended after %d attempts", data.Fingerprint, maxClaimAttempts)
+}
+
+// claimFingerprint stores the fingerprint for data.GUID. It returns false
+// when another asset holds it.
+func (h *Handler) claimFingerprint(key map[string]*dynamodb.AttributeValue, data *InputValidateData, record events.S3EventRecord) (bool, error) {
+	_, err := h.DynamoDBClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Item: map[string]*dynamodb.AttributeValue{
+			"PK":        key["PK"],
+			"SK":        key["SK"],
+			"guid":      {S: aws.String(data.GUID)},
+			"srcVideo":  {S: aws.String(data.SrcVideo)},
+			"size":      {N: aws.String(strconv.FormatInt(record.S3.Object.Size, 10))},
+			"eTag":      {S: aws.String(record.S3.Object.ETag)},
+			"createdAt": {S: aws.String(data.StartTime)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(PK) OR guid = :guid"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":guid": {S: aws.String(data.GUID)},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, fmt.Errorf("claimFingerprint: PutItem: %w", err)
+	}
+	return true, nil
+}
+
+// applyDuplicatePolicy marks data as a duplicate of the asset holding the
+// fingerprint and applies the duplicate policy.
+func (h *Handler) applyDuplicatePolicy(key map[string]*dynamodb.AttributeValue, data *InputValidateData, fingerprint FingerprintRecord, asset AssetRecord) error {
+	data.DuplicateOf = fingerprint.GUID
+	data.DuplicatePolicy = getDuplicatePolicy()
+	log.Printf("%s duplicates %s (%s), policy %s", data.GUID, fingerprint.GUID, fingerprint.SrcVideo, data.DuplicatePolicy)
+
+	switch data.DuplicatePolicy {
+	case DuplicateSkip:
+		data.WorkflowStatus = "Duplicate"
+	case DuplicateLink:
+		data.WorkflowStatus = "Duplicate"
+		linkOutputs(data, asset)
+	case DuplicateReprocess:
+		// Later duplicates link to the most recent encode
+		if err := h.moveFingerprint(key, data); err != nil {
+			return fmt.Errorf("applyDuplicatePolicy: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func isFailedStatus(status string) bool {
+	for _, failed := range failedStatuses {
+		if status == failed {
+			return true
+		}
+	}
+	return false
+}
+
+// getAsset reads the record of the asset holding a fingerprint.
+func (h *Handler) getAsset(guid string) (AssetRecord, error) {
+	var asset AssetRecord
+
+	existing, err := h.DynamoDBClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String("VIDEO#" + guid)},
+			"SK": {S: aws.String("METADATA")},
+		},
+	})
+	if err != nil {
+		return asset, fmt.Errorf("getAsset: GetItem: %w", err)
+	}
+
+	if err := dynamodbattribute.UnmarshalMap(existing.Item, &asset); err != nil {
+		return asset, fmt.Errorf("getAsset: UnmarshalMap: %w", err)
+	}
+	return asset, nil
+}
+
+// linkOutputs copies the outputs of the asset data duplicates. Until that
+// asset is complete there is nothing to link and only duplicateOf is kept.
+func linkOutputs(data *InputValidateData, asset AssetRecord) {
+	if asset.WorkflowStatus != "Complete" {
+		log.Printf("%s is %q, not linking outputs", data.DuplicateOf, asset.WorkflowStatus)
+		return
+	}
+
+	data.LinkedOutputs = asset.LinkedOutputs
+}
+
+// takeOverFingerprint moves the fingerprint from the failed asset owner to
+// data. It returns false when another upload took it over first.
+func (h *Handler) takeOverFingerprint(key map[string]*dynamodb.AttributeValue, owner string, data *InputValidateData) (bool, error) {
+	_, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(os.Getenv("DynamoDBTable")),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET guid = :guid, srcVideo = :srcVideo, createdAt = :now"),
+		ConditionExpression: aws.String("guid = :owner"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":guid":     {S: aws.String(data.GUID)},
+			":srcVideo": {S: aws.String(data.SrcVideo)},
+			":now":      {S: aws.String(data.StartTime)},
+			":owner":    {S: aws.String(owner)},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, fmt.Errorf("takeOverFingerprint: UpdateItem: %w", err)
+	}
+	return true, nil
+}
+
+func (h *Handler) moveFingerprint(key map[string]*dynamodb.AttributeValue, data *InputValidateData) error {
+	_, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(os.Getenv("DynamoDBTable")),
+		Key:              key,
+		UpdateExpression: aws.String("SET guid = :guid, srcVideo = :srcVideo, createdAt = :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":guid":     {S: aws.String(data.GUID)},
+			":srcVideo": {S: aws.String(data.SrcVideo)},
+			":now":      {S: aws.String(data.StartTime)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("moveFingerprint: UpdateItem: %w", err)
+	}
+	return nil
+}
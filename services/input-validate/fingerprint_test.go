@@ -0,0 +1,201 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetFingerprint(t *testing.T) {
+	object := events.S3Object{Key: "clip.mp4", Size: 1024, ETag: "etag"}
+
+	assert.Equal(t, getFingerprint(object, ""), getFingerprint(object, ""))
+	assert.NotEqual(t, getFingerprint(object, ""), getFingerprint(object, "checksum"))
+	assert.NotEqual(t, getFingerprint(object, ""), getFingerprint(events.S3Object{Key: "clip.mp4", Size: 1024, ETag: "other"}, ""))
+}
+
+func TestCheckDuplicate(t *testing.T) {
+	record := events.S3EventRecord{
+		S3: events.S3Entity{Object: events.S3Object{Key: "clip.mp4", Size: 1024, ETag: "etag"}},
+	}
+	conditionFailed := awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+	fingerprint := &dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"guid":     {S: aws.String("existing")},
+			"srcVideo": {S: aws.String("clip.mp4")},
+		},
+	}
+	getFingerprintItem := mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+		return *input.Key["SK"].S == "FINGERPRINT"
+	})
+	getAsset := mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+		return *input.Key["PK"].S == "VIDEO#existing"
+	})
+	ingesting := &dynamodb.GetItemOutput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"workflowStatus": {S: aws.String("Ingest")},
+		},
+	}
+
+	t.Run("should claim the fingerprint of a new upload", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		data := &InputValidateData{GUID: "guid", WorkflowStatus: "Ingest"}
+		err := handler.checkDuplicate(data, record)
+
+		assert.Nil(t, err)
+		assert.Equal(t, getFingerprint(record.S3.Object, ""), data.Fingerprint)
+		assert.Empty(t, data.DuplicateOf)
+		assert.Equal(t, "Ingest", data.WorkflowStatus)
+
+		put := dynamoDBClientMock.Calls[0].Arguments.Get(0).(*dynamodb.PutItemInput)
+		assert.Equal(t, "FINGERPRINT#"+data.Fingerprint, *put.Item["PK"].S)
+	})
+
+	t.Run("should reprocess duplicates by default", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, conditionFailed)
+		dynamoDBClientMock.On("GetItem", getFingerprintItem).Return(fingerprint, nil)
+		dynamoDBClientMock.On("GetItem", getAsset).Return(ingesting, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		data := &InputValidateData{GUID: "guid", WorkflowStatus: "Ingest"}
+		err := handler.checkDuplicate(data, record)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "existing", data.DuplicateOf)
+		assert.Equal(t, DuplicateReprocess, data.DuplicatePolicy)
+		assert.Equal(t, "Ingest", data.WorkflowStatus)
+		dynamoDBClientMock.AssertNumberOfCalls(t, "UpdateItem", 1)
+	})
+
+	t.Run("should mark skipped duplicates", func(t *testing.T) {
+		os.Setenv("DuplicatePolicy", DuplicateSkip)
+		defer os.Unsetenv("DuplicatePolicy")
+
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, conditionFailed)
+		dynamoDBClientMock.On("GetItem", getFingerprintItem).Return(fingerprint, nil)
+		dynamoDBClientMock.On("GetItem", getAsset).Return(ingesting, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		data := &InputValidateData{GUID: "guid", WorkflowStatus: "Ingest"}
+		err := handler.checkDuplicate(data, record)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Duplicate", data.WorkflowStatus)
+		assert.Equal(t, "existing", data.DuplicateOf)
+		dynamoDBClientMock.AssertNotCalled(t, "UpdateItem", mock.Anything)
+	})
+
+	t.Run("should link the outputs of a complete asset", func(t *testing.T) {
+		os.Setenv("DuplicatePolicy", DuplicateLink)
+		defer os.Unsetenv("DuplicatePolicy")
+
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, conditionFailed)
+		dynamoDBClientMock.On("GetItem", getFingerprintItem).Return(fingerprint, nil)
+		dynamoDBClientMock.On("GetItem", getAsset).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"workflowStatus": {S: aws.String("Complete")},
+				"hlsUrl":         {S: aws.String("https://cdn/existing/hls/clip.m3u8")},
+			},
+		}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		data := &InputValidateData{GUID: "guid", WorkflowStatus: "Ingest"}
+		err := handler.checkDuplicate(data, record)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Duplicate", data.WorkflowStatus)
+		assert.Equal(t, "https://cdn/existing/hls/clip.m3u8", *data.HlsUrl)
+	})
+
+	t.Run("should take the fingerprint over from a failed asset", func(t *testing.T) {
+		os.Setenv("DuplicatePolicy", DuplicateSkip)
+		defer os.Unsetenv("DuplicatePolicy")
+
+		for _, status := range []string{"Error", "Rejected", "OutputInvalid"} {
+			dynamoDBClientMock := new(DynamoDBClientMock)
+			dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, conditionFailed)
+			dynamoDBClientMock.On("GetItem", getFingerprintItem).Return(fingerprint, nil)
+			dynamoDBClientMock.On("GetItem", getAsset).Return(&dynamodb.GetItemOutput{
+				Item: map[string]*dynamodb.AttributeValue{
+					"workflowStatus": {S: aws.String(status)},
+				},
+			}, nil)
+			dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+
+			handler := Handler{DynamoDBClient: dynamoDBClientMock}
+			data := &InputValidateData{GUID: "guid", WorkflowStatus: "Ingest"}
+			err := handler.checkDuplicate(data, record)
+
+			assert.Nil(t, err)
+			assert.Equal(t, "Ingest", data.WorkflowStatus)
+			assert.Empty(t, data.DuplicateOf)
+
+			update := dynamoDBClientMock.Calls[3].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+			assert.Equal(t, "guid = :owner", *update.ConditionExpression)
+			assert.Equal(t, "existing", *update.ExpressionAttributeValues[":owner"].S)
+			assert.Equal(t, "guid", *update.ExpressionAttributeValues[":guid"].S)
+		}
+	})
+
+	t.Run("should claim again when another upload took the fingerprint over first", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, conditionFailed).Once()
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		dynamoDBClientMock.On("GetItem", getFingerprintItem).Return(fingerprint, nil)
+		dynamoDBClientMock.On("GetItem", getAsset).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"workflowStatus": {S: aws.String("Error")},
+			},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(nil, conditionFailed)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		data := &InputValidateData{GUID: "guid", WorkflowStatus: "Ingest"}
+		err := handler.checkDuplicate(data, record)
+
+		assert.Nil(t, err)
+		assert.Empty(t, data.DuplicateOf)
+		dynamoDBClientMock.AssertNumberOfCalls(t, "PutItem", 2)
+	})
+
+	t.Run("should include the object checksum when enabled", func(t *testing.T) {
+		os.Setenv("FingerprintChecksum", "true")
+		defer os.Unsetenv("FingerprintChecksum")
+
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		s3ClientMock := new(S3ClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		s3ClientMock.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{ChecksumSHA256: aws.String("sha")}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock, S3Client: s3ClientMock}
+		data := &InputValidateData{GUID: "guid", SrcBucket: "source", SrcVideo: "clip.mp4"}
+		err := handler.checkDuplicate(data, record)
+
+		assert.Nil(t, err)
+		assert.Equal(t, getFingerprint(record.S3.Object, "sha"), data.Fingerprint)
+	})
+
+	t.Run("should fail when the fingerprint cannot be stored", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, assert.AnError)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		err := handler.checkDuplicate(&InputValidateData{GUID: "guid"}, record)
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
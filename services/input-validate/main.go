@@ -5,16 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 var (
 	ErrEventWorkflowTriggerNotDefined = errors.New("event.workflowTrigger is not defined")
+	ErrEventRecordCount               = errors.New("event.Records must contain exactly one record")
 )
 
 // InputValidateEvent represents the input event structure
@@ -44,9 +49,32 @@ type InputValidateData struct {
 	EnableSqs              bool   `json:"enableSqs"`
 	SrcVideo               string `json:"srcVideo"`
 	EnableMediaPackage     bool   `json:"enableMediaPackage"`
+	SrcUploader            string `json:"srcUploader"`
+	Fingerprint            string `json:"fingerprint,omitempty"`
+	DuplicateOf            string `json:"duplicateOf,omitempty"`
+	DuplicatePolicy        string `json:"duplicatePolicy,omitempty"`
+	LinkedOutputs
+
+	// CdnWeights overrides the CdnHosts weights for this asset
+	CdnWeights map[string]int `json:"cdnWeights,omitempty"`
+}
+
+type DynamoDBClient interface {
+	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+}
+
+type S3Client interface {
+	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+}
+
+type Handler struct {
+	DynamoDBClient DynamoDBClient
+	S3Client       S3Client
 }
 
-func Handler(event InputValidateEvent) (*InputValidateData, error) {
+func (h *Handler) HandleRequest(event InputValidateEvent) (*InputValidateData, error) {
 	log.Printf("newest version")
 
 	eventJson, err := json.Marshal(event)
@@ -83,7 +111,30 @@ func Handler(event InputValidateEvent) (*InputValidateData, error) {
 
 	switch event.WorkflowTrigger {
 	case "Video":
-		inputValidateData.SrcVideo = strings.Replace(event.Records[0].S3.Object.Key, "+", " ", -1)
+		// step-functions starts one execution per S3 record
+		if len(event.Records) != 1 {
+			return nil, fmt.Errorf("input-validate: main.Handler: %w", ErrEventRecordCount)
+		}
+		record := event.Records[0]
+
+		// S3 event keys are form encoded
+		srcVideo, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("input-validate: main.Handler: QueryUnescape: %w", err)
+		}
+		inputValidateData.SrcVideo = srcVideo
+		inputValidateData.SrcUploader = record.PrincipalID.PrincipalID
+
+		if err := h.checkDuplicate(&inputValidateData, record); err != nil {
+			return nil, fmt.Errorf("input-validate: main.Handler: %w", err)
+		}
+
+		if os.Getenv("CdnHosts") != "" {
+			inputValidateData.CdnWeights, err = h.getCdnWeights(inputValidateData.SrcBucket, srcVideo)
+			if err != nil {
+				return nil, fmt.Errorf("input-validate: main.Handler: %w", err)
+			}
+		}
 	default:
 		return nil, fmt.Errorf("input-validate: main.Handler: %w", ErrEventWorkflowTriggerNotDefined)
 	}
@@ -93,5 +144,13 @@ func Handler(event InputValidateEvent) (*InputValidateData, error) {
 }
 
 func main() {
-	lambda.Start(Handler)
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}))
+	handler := &Handler{
+		DynamoDBClient: dynamodb.New(sess),
+		S3Client:       s3.New(sess),
+	}
+
+	lambda.Start(handler.HandleRequest)
 }
@@ -7,9 +7,59 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
+type DynamoDBClientMock struct {
+	mock.Mock
+}
+
+func (m *DynamoDBClientMock) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+type S3ClientMock struct {
+	mock.Mock
+}
+
+func (m *S3ClientMock) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.HeadObjectOutput), args.Error(1)
+}
+
+// newHandler returns a handler whose uploads are never duplicates.
+func newHandler() *Handler {
+	dynamoDBClientMock := new(DynamoDBClientMock)
+	dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+	return &Handler{DynamoDBClient: dynamoDBClientMock}
+}
+
 func TestHandler(t *testing.T) {
 	os.Setenv("WorkflowName", "TestWorkflow")
 	os.Setenv("Source", "source_bucket")
@@ -83,7 +133,7 @@ func TestHandler(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			data, err := Handler(c.event)
+			data, err := newHandler().HandleRequest(c.event)
 			if c.expectedError != nil {
 				assert.Equal(t, c.expectedError, err)
 			} else {
@@ -111,3 +161,46 @@ func TestHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerRecords(t *testing.T) {
+	record := func(key string) events.S3EventRecord {
+		return events.S3EventRecord{
+			S3: events.S3Entity{
+				Object: events.S3Object{
+					Key: key,
+				},
+			},
+		}
+	}
+
+	t.Run("should URL decode the object key", func(t *testing.T) {
+		data, err := newHandler().HandleRequest(InputValidateEvent{
+			GUID:            "1234",
+			WorkflowTrigger: "Video",
+			Records:         []events.S3EventRecord{record("news/caf%C3%A9+clip%282%29.mp4")},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "news/café clip(2).mp4", data.SrcVideo)
+	})
+
+	t.Run("should fail on an invalid key encoding", func(t *testing.T) {
+		_, err := newHandler().HandleRequest(InputValidateEvent{
+			GUID:            "1234",
+			WorkflowTrigger: "Video",
+			Records:         []events.S3EventRecord{record("clip%zz.mp4")},
+		})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should require exactly one record", func(t *testing.T) {
+		_, err := newHandler().HandleRequest(InputValidateEvent{
+			GUID:            "1234",
+			WorkflowTrigger: "Video",
+			Records:         []events.S3EventRecord{record("a.mp4"), record("b.mp4")},
+		})
+
+		assert.ErrorIs(t, err, ErrEventRecordCount)
+	})
+}
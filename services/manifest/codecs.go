@@ -0,0 +1,53 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// codecPatterns are the RFC 6381 codecs strings of the formats the workflows
+// encode to.
+var codecPatterns = []*regexp.Regexp{
+	// H.264, avc1.PPCCLL
+	regexp.MustCompile(`^avc[13]\.[0-9A-Fa-f]{6}$`),
+	// H.265, hvc1.P.C.TL.B
+	regexp.MustCompile(`^(hvc1|hev1)\.[A-C]?\d{1,2}\.[0-9A-Fa-f]{1,8}\.[LH]\d{1,3}(\.[0-9A-Fa-f]{1,2}){0,6}$`),
+	// Dolby Vision
+	regexp.MustCompile(`^(dvh1|dvhe|dva1|dvav)\.\d{2}\.\d{2}$`),
+	// AV1
+	regexp.MustCompile(`^av01\.\d\.\d{2}[MH]\.\d{2}(\.\d+)*$`),
+	// VP9
+	regexp.MustCompile(`^vp09\.\d{2}\.\d{2}\.\d{2}(\.\d{2}){0,5}$`),
+	// AAC and MP3, mp4a.OT or mp4a.40.AOT
+	regexp.MustCompile(`^mp4a\.(40\.\d{1,2}|[0-9A-Fa-f]{2})$`),
+	// Dolby Digital, Digital Plus and AC-4
+	regexp.MustCompile(`^(ac-3|ec-3|ac-4(\.[0-9A-Fa-f]{2}){3})$`),
+	regexp.MustCompile(`^(fLaC|Opus|opus|alac)$`),
+	// Captions
+	regexp.MustCompile(`^(stpp(\.ttml(\.\w+)?)?|wvtt|tx3g)$`),
+}
+
+// ValidateCodecs checks every codec of a comma separated codecs attribute.
+func ValidateCodecs(codecs string) error {
+	if strings.TrimSpace(codecs) == "" {
+		return fmt.Errorf("manifest: empty codecs string")
+	}
+
+	for _, codec := range strings.Split(codecs, ",") {
+		codec = strings.TrimSpace(codec)
+		if !validCodec(codec) {
+			return fmt.Errorf("manifest: invalid codec %q", codec)
+		}
+	}
+	return nil
+}
+
+func validCodec(codec string) bool {
+	for _, pattern := range codecPatterns {
+		if pattern.MatchString(codec) {
+			return true
+		}
+	}
+	return false
+}
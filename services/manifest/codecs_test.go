@@ -0,0 +1,39 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCodecs(t *testing.T) {
+	valid := []string{
+		"avc1.64001f",
+		"avc1.4D401E,mp4a.40.2",
+		"hvc1.2.4.L150.B0",
+		"hev1.1.6.L93.90",
+		"dvh1.05.06",
+		"av01.0.08M.08",
+		"vp09.00.10.08",
+		"mp4a.40.5, ec-3",
+		"ac-3",
+		"mp4a.6B",
+		"stpp.ttml.im1t",
+		"wvtt",
+	}
+	for _, codecs := range valid {
+		assert.Nil(t, ValidateCodecs(codecs), codecs)
+	}
+
+	invalid := []string{
+		"",
+		"h264",
+		"avc1",
+		"avc1.64001",
+		"avc1.64001f,",
+		"hvc1.2.4",
+	}
+	for _, codecs := range invalid {
+		assert.Error(t, ValidateCodecs(codecs), codecs)
+	}
+}
@@ -0,0 +1,478 @@
+package manifest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DashNamespace is the MPD schema namespace.
+const DashNamespace = "urn:mpeg:dash:schema:mpd:2011"
+
+// MPD is a DASH media presentation description. Elements and attributes
+// this package does not model are kept in Extra and Attrs, Extra is written
+// before the modelled children as the schema orders descriptors first.
+type MPD struct {
+	XMLName                   xml.Name   `xml:"MPD"`
+	XMLNS                     string     `xml:"xmlns,attr,omitempty"`
+	ID                        string     `xml:"id,attr,omitempty"`
+	Profiles                  string     `xml:"profiles,attr,omitempty"`
+	Type                      string     `xml:"type,attr,omitempty"`
+	MinBufferTime             string     `xml:"minBufferTime,attr,omitempty"`
+	MediaPresentationDuration string     `xml:"mediaPresentationDuration,attr,omitempty"`
+	Attrs                     []xml.Attr `xml:",any,attr"`
+	Extra                     []*Node    `xml:",any"`
+	BaseURL                   string     `xml:"BaseURL,omitempty"`
+	Periods                   []*Period  `xml:"Period"`
+}
+
+// Period is a part of the presentation with its own adaptation sets.
+type Period struct {
+	ID             string           `xml:"id,attr,omitempty"`
+	Start          string           `xml:"start,attr,omitempty"`
+	Duration       string           `xml:"duration,attr,omitempty"`
+	Attrs          []xml.Attr       `xml:",any,attr"`
+	Extra          []*Node          `xml:",any"`
+	BaseURL        string           `xml:"BaseURL,omitempty"`
+	AdaptationSets []*AdaptationSet `xml:"AdaptationSet"`
+}
+
+// AdaptationSet groups interchangeable representations of one component.
+type AdaptationSet struct {
+	ID               string            `xml:"id,attr,omitempty"`
+	ContentType      string            `xml:"contentType,attr,omitempty"`
+	MimeType         string            `xml:"mimeType,attr,omitempty"`
+	Codecs           string            `xml:"codecs,attr,omitempty"`
+	Lang             string            `xml:"lang,attr,omitempty"`
+	SegmentAlignment string            `xml:"segmentAlignment,attr,omitempty"`
+	Attrs            []xml.Attr        `xml:",any,attr"`
+	Extra            []*Node           `xml:",any"`
+	BaseURL          string            `xml:"BaseURL,omitempty"`
+	SegmentTemplate  *SegmentTemplate  `xml:"SegmentTemplate,omitempty"`
+	Representations  []*Representation `xml:"Representation"`
+}
+
+// Representation is one encoding, such as a rendition of the video.
+type Representation struct {
+	ID                string           `xml:"id,attr,omitempty"`
+	Bandwidth         int64            `xml:"bandwidth,attr,omitempty"`
+	Codecs            string           `xml:"codecs,attr,omitempty"`
+	MimeType          string           `xml:"mimeType,attr,omitempty"`
+	Width             int              `xml:"width,attr,omitempty"`
+	Height            int              `xml:"height,attr,omitempty"`
+	FrameRate         string           `xml:"frameRate,attr,omitempty"`
+	AudioSamplingRate string           `xml:"audioSamplingRate,attr,omitempty"`
+	Attrs             []xml.Attr       `xml:",any,attr"`
+	Extra             []*Node          `xml:",any"`
+	BaseURL           string           `xml:"BaseURL,omitempty"`
+	SegmentBase       *SegmentBase     `xml:"SegmentBase,omitempty"`
+	SegmentList       *SegmentList     `xml:"SegmentList,omitempty"`
+	SegmentTemplate   *SegmentTemplate `xml:"SegmentTemplate,omitempty"`
+}
+
+// SegmentTemplate addresses segments by $Number$ or $Time$ templates.
+type SegmentTemplate struct {
+	Timescale              int64            `xml:"timescale,attr,omitempty"`
+	Duration               int64            `xml:"duration,attr,omitempty"`
+	StartNumber            *int64           `xml:"startNumber,attr"`
+	PresentationTimeOffset int64            `xml:"presentationTimeOffset,attr,omitempty"`
+	Media                  string           `xml:"media,attr,omitempty"`
+	Initialization         string           `xml:"initialization,attr,omitempty"`
+	SegmentTimeline        *SegmentTimeline `xml:"SegmentTimeline,omitempty"`
+}
+
+// SegmentTimeline lists segment start times and durations.
+type SegmentTimeline struct {
+	S []*S `xml:"S"`
+}
+
+// S is a SegmentTimeline entry of R+1 segments of duration D from T.
+type S struct {
+	T *int64 `xml:"t,attr"`
+	D int64  `xml:"d,attr"`
+	R int64  `xml:"r,attr,omitempty"`
+}
+
+// SegmentBase addresses a single file representation by byte ranges.
+type SegmentBase struct {
+	Timescale      int64    `xml:"timescale,attr,omitempty"`
+	IndexRange     string   `xml:"indexRange,attr,omitempty"`
+	Initialization *URLType `xml:"Initialization,omitempty"`
+}
+
+// SegmentList lists segment URLs explicitly.
+type SegmentList struct {
+	Timescale      int64         `xml:"timescale,attr,omitempty"`
+	Duration       int64         `xml:"duration,attr,omitempty"`
+	Initialization *URLType      `xml:"Initialization,omitempty"`
+	SegmentURLs    []*SegmentURL `xml:"SegmentURL"`
+}
+
+// URLType is an Initialization or RepresentationIndex URL.
+type URLType struct {
+	SourceURL string `xml:"sourceURL,attr,omitempty"`
+	Range     string `xml:"range,attr,omitempty"`
+}
+
+// SegmentURL is a SegmentList entry.
+type SegmentURL struct {
+	Media      string `xml:"media,attr,omitempty"`
+	MediaRange string `xml:"mediaRange,attr,omitempty"`
+}
+
+// Node is an element this package does not model, kept verbatim.
+type Node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXML string     `xml:",innerxml"`
+}
+
+var templateField = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time)(?:%0(\d+)d)?\$`)
+
+// ParseDASH parses an MPD.
+func ParseDASH(data []byte) (*MPD, error) {
+	var mpd MPD
+	if err := xml.Unmarshal(data, &mpd); err != nil {
+		return nil, fmt.Errorf("manifest: ParseDASH: %w", err)
+	}
+
+	// encoding/xml resolves prefixes to namespace URLs and does not write
+	// them back, keep the prefixed names of the document instead
+	prefixes := map[string]string{}
+	for _, attr := range mpd.Attrs {
+		if attr.Name.Space == "xmlns" {
+			prefixes[attr.Value] = attr.Name.Local
+		}
+	}
+	name := func(n xml.Name) xml.Name {
+		switch {
+		case n.Space == "xmlns":
+			return xml.Name{Local: "xmlns:" + n.Local}
+		case n.Space == DashNamespace:
+			return xml.Name{Local: n.Local}
+		case prefixes[n.Space] != "":
+			return xml.Name{Local: prefixes[n.Space] + ":" + n.Local}
+		}
+		return n
+	}
+	attrs := func(attrs []xml.Attr) {
+		for i := range attrs {
+			attrs[i].Name = name(attrs[i].Name)
+		}
+	}
+	nodes := func(nodes []*Node) {
+		for _, node := range nodes {
+			node.XMLName = name(node.XMLName)
+			attrs(node.Attrs)
+		}
+	}
+
+	mpd.XMLName = xml.Name{Local: "MPD"}
+	attrs(mpd.Attrs)
+	nodes(mpd.Extra)
+	for _, period := range mpd.Periods {
+		attrs(period.Attrs)
+		nodes(period.Extra)
+		for _, set := range period.AdaptationSets {
+			attrs(set.Attrs)
+			nodes(set.Extra)
+			for _, rep := range set.Representations {
+				attrs(rep.Attrs)
+				nodes(rep.Extra)
+			}
+		}
+	}
+
+	return &mpd, nil
+}
+
+// Encode serialises the MPD.
+func (m *MPD) Encode() ([]byte, error) {
+	data, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("manifest: MPD.Encode: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// Duration returns mediaPresentationDuration, or the sum of the period
+// durations, in seconds.
+func (m *MPD) Duration() (float64, error) {
+	if m.MediaPresentationDuration != "" {
+		return ParseDuration(m.MediaPresentationDuration)
+	}
+
+	var total float64
+	for _, period := range m.Periods {
+		duration, err := ParseDuration(period.Duration)
+		if err != nil {
+			return 0, err
+		}
+		total += duration
+	}
+	return total, nil
+}
+
+// SegmentURIs returns the initialization and media segment URIs of every
+// representation, relative to the MPD and expanded from segment templates
+// over the period duration. Single file representations are listed once.
+func (m *MPD) SegmentURIs() ([]string, error) {
+	total, err := m.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("manifest: MPD.SegmentURIs: %w", err)
+	}
+
+	var uris []string
+	for _, period := range m.Periods {
+		duration := total
+		if period.Duration != "" {
+			if duration, err = ParseDuration(period.Duration); err != nil {
+				return nil, fmt.Errorf("manifest: MPD.SegmentURIs: %w", err)
+			}
+		}
+		periodBase := joinURL(m.BaseURL, period.BaseURL)
+
+		for _, set := range period.AdaptationSets {
+			setBase := joinURL(periodBase, set.BaseURL)
+
+			for _, rep := range set.Representations {
+				template := rep.SegmentTemplate
+				if template == nil {
+					template = set.SegmentTemplate
+				}
+
+				switch {
+				case template != nil:
+					segments, err := template.expand(rep, duration)
+					if err != nil {
+						return nil, fmt.Errorf("manifest: MPD.SegmentURIs: Representation %s: %w", rep.ID, err)
+					}
+					repBase := joinURL(setBase, rep.BaseURL)
+					for _, segment := range segments {
+						uris = append(uris, joinURL(repBase, segment))
+					}
+				case rep.SegmentList != nil:
+					repBase := joinURL(setBase, rep.BaseURL)
+					if rep.SegmentList.Initialization != nil && rep.SegmentList.Initialization.SourceURL != "" {
+						uris = append(uris, joinURL(repBase, rep.SegmentList.Initialization.SourceURL))
+					}
+					for _, segment := range rep.SegmentList.SegmentURLs {
+						if segment.Media != "" {
+							uris = append(uris, joinURL(repBase, segment.Media))
+						} else {
+							uris = append(uris, repBase)
+						}
+					}
+				case rep.BaseURL != "":
+					// Single file addressed by byte range
+					uris = append(uris, joinURL(setBase, rep.BaseURL))
+				default:
+					return nil, fmt.Errorf("manifest: MPD.SegmentURIs: Representation %s has no segments", rep.ID)
+				}
+			}
+		}
+	}
+
+	return unique(uris), nil
+}
+
+// joinURL resolves ref against a BaseURL. Directories end with a slash.
+func joinURL(base string, ref string) string {
+	if ref == "" {
+		return base
+	}
+	if base == "" || strings.Contains(ref, "://") || strings.HasPrefix(ref, "/") {
+		return ref
+	}
+	return base[:strings.LastIndex(base, "/")+1] + ref
+}
+
+// expand lists the initialization and media segments of the template.
+func (t *SegmentTemplate) expand(rep *Representation, duration float64) ([]string, error) {
+	timescale := t.Timescale
+	if timescale == 0 {
+		timescale = 1
+	}
+	number := int64(1)
+	if t.StartNumber != nil {
+		number = *t.StartNumber
+	}
+
+	var segments []string
+	if t.Initialization != "" {
+		segments = append(segments, fill(t.Initialization, rep, 0, 0))
+	}
+
+	end := int64(math.Round(duration*float64(timescale))) + t.PresentationTimeOffset
+	switch {
+	case t.SegmentTimeline != nil && len(t.SegmentTimeline.S) > 0:
+		time := t.PresentationTimeOffset
+		for _, s := range t.SegmentTimeline.S {
+			if s.T != nil {
+				time = *s.T
+			}
+			if s.D <= 0 {
+				return nil, fmt.Errorf("invalid S@d %d", s.D)
+			}
+			repeat := s.R
+			if repeat < 0 {
+				// Repeats until the end of the period
+				repeat = (end-time+s.D-1)/s.D - 1
+			}
+			for i := int64(0); i <= repeat; i++ {
+				segments = append(segments, fill(t.Media, rep, number, time))
+				number++
+				time += s.D
+			}
+		}
+	case t.Duration > 0:
+		if duration <= 0 {
+			return nil, fmt.Errorf("unknown period duration")
+		}
+		count := (int64(math.Round(duration*float64(timescale))) + t.Duration - 1) / t.Duration
+		for i := int64(0); i < count; i++ {
+			segments = append(segments, fill(t.Media, rep, number+i, t.PresentationTimeOffset+i*t.Duration))
+		}
+	default:
+		return nil, fmt.Errorf("SegmentTemplate without SegmentTimeline or duration")
+	}
+
+	return segments, nil
+}
+
+func fill(template string, rep *Representation, number int64, time int64) string {
+	filled := templateField.ReplaceAllStringFunc(template, func(field string) string {
+		match := templateField.FindStringSubmatch(field)
+
+		var value int64
+		switch match[1] {
+		case "RepresentationID":
+			return rep.ID
+		case "Number":
+			value = number
+		case "Bandwidth":
+			value = rep.Bandwidth
+		case "Time":
+			value = time
+		}
+
+		if match[2] != "" {
+			width, _ := strconv.Atoi(match[2])
+			return fmt.Sprintf("%0*d", width, value)
+		}
+		return strconv.FormatInt(value, 10)
+	})
+	return strings.ReplaceAll(filled, "$$", "$")
+}
+
+// Validate checks the MPD against ISO/IEC 23009-1.
+func (m *MPD) Validate() error {
+	var errs problems
+
+	switch m.Type {
+	case "", "static":
+		if m.MediaPresentationDuration == "" {
+			for i, period := range m.Periods {
+				if period.Duration == "" {
+					errs.add("Period %d: static MPD without mediaPresentationDuration requires Period@duration", i)
+				}
+			}
+		}
+	case "dynamic":
+	default:
+		errs.add("invalid MPD@type %q", m.Type)
+	}
+	if _, err := ParseDuration(m.MediaPresentationDuration); err != nil {
+		errs.add("invalid mediaPresentationDuration: %v", err)
+	}
+	if _, err := ParseDuration(m.MinBufferTime); err != nil {
+		errs.add("invalid minBufferTime: %v", err)
+	}
+	if len(m.Periods) == 0 {
+		errs.add("no Period")
+	}
+
+	for p, period := range m.Periods {
+		if _, err := ParseDuration(period.Duration); err != nil {
+			errs.add("Period %d: invalid duration: %v", p, err)
+		}
+		if len(period.AdaptationSets) == 0 {
+			errs.add("Period %d: no AdaptationSet", p)
+		}
+
+		ids := map[string]bool{}
+		for a, set := range period.AdaptationSets {
+			if len(set.Representations) == 0 {
+				errs.add("Period %d AdaptationSet %d: no Representation", p, a)
+			}
+			for _, rep := range set.Representations {
+				where := fmt.Sprintf("Period %d Representation %s", p, rep.ID)
+				if rep.ID == "" {
+					errs.add("Period %d AdaptationSet %d: Representation@id is required", p, a)
+				} else if ids[rep.ID] {
+					errs.add("%s: duplicate Representation@id", where)
+				}
+				ids[rep.ID] = true
+
+				if rep.Bandwidth <= 0 {
+					errs.add("%s: bandwidth is required", where)
+				}
+				if rep.MimeType == "" && set.MimeType == "" {
+					errs.add("%s: mimeType is required", where)
+				}
+				codecs := rep.Codecs
+				if codecs == "" {
+					codecs = set.Codecs
+				}
+				if codecs != "" {
+					if err := ValidateCodecs(codecs); err != nil {
+						errs.add("%s: %v", where, err)
+					}
+				}
+
+				template := rep.SegmentTemplate
+				if template == nil {
+					template = set.SegmentTemplate
+				}
+				switch {
+				case template != nil:
+					validateTemplate(&errs, where, template)
+				case rep.SegmentList != nil, rep.SegmentBase != nil, rep.BaseURL != "":
+				default:
+					errs.add("%s: no segment information", where)
+				}
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+func validateTemplate(errs *problems, where string, t *SegmentTemplate) {
+	if t.Media == "" {
+		errs.add("%s: SegmentTemplate@media is required", where)
+	}
+	hasNumber := strings.Contains(t.Media, "$Number")
+	hasTime := strings.Contains(t.Media, "$Time")
+	if hasNumber && hasTime {
+		errs.add("%s: SegmentTemplate@media must not use both $Number$ and $Time$", where)
+	}
+
+	hasTimeline := t.SegmentTimeline != nil && len(t.SegmentTimeline.S) > 0
+	if hasTime && !hasTimeline {
+		errs.add("%s: $Time$ requires a SegmentTimeline", where)
+	}
+	if !hasTimeline && t.Duration <= 0 {
+		errs.add("%s: SegmentTemplate requires a SegmentTimeline or duration", where)
+	}
+	if hasTimeline {
+		for i, s := range t.SegmentTimeline.S {
+			if s.D <= 0 {
+				errs.add("%s: S %d: d must be positive", where, i)
+			}
+		}
+	}
+}
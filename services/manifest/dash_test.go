@@ -0,0 +1,170 @@
+package manifest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const TestMPD = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" type="static" minBufferTime="PT10S" mediaPresentationDuration="PT13.471S" profiles="urn:mpeg:dash:profile:isoff-main:2011">
+  <Period id="1" start="PT0S" duration="PT13.471S">
+    <AdaptationSet mimeType="video/mp4" segmentAlignment="true" maxWidth="1280">
+      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="abc"><cenc:pssh>AAA</cenc:pssh></ContentProtection>
+      <SegmentTemplate timescale="90000" media="dude_$RepresentationID$_$Number%09d$.mp4" initialization="dude_$RepresentationID$init.mp4" startNumber="1">
+        <SegmentTimeline>
+          <S t="0" d="540000" r="1"/>
+          <S d="132390"/>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="720p" width="1280" height="720" bandwidth="3000000" codecs="avc1.64001f"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4" lang="eng">
+      <Role schemeIdUri="urn:mpeg:dash:role:2011" value="main"/>
+      <BaseURL>audio/</BaseURL>
+      <Representation id="audio" bandwidth="96000" codecs="mp4a.40.2" audioSamplingRate="48000">
+        <SegmentTemplate timescale="1000" duration="6000" media="$Bandwidth$/$Number$.m4s" initialization="$Bandwidth$/init.m4s"/>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+const TestSingleFileMPD = `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" mediaPresentationDuration="PT0H0M13.471S" minBufferTime="PT2S">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <Representation id="1" bandwidth="3000000" codecs="hvc1.1.6.L93.B0">
+        <BaseURL>dude_720p.mp4</BaseURL>
+        <SegmentBase indexRange="800-1000"><Initialization range="0-799"/></SegmentBase>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+func TestParseDASH(t *testing.T) {
+	mpd, err := ParseDASH([]byte(TestMPD))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "static", mpd.Type)
+	assert.Len(t, mpd.Periods[0].AdaptationSets, 2)
+
+	video := mpd.Periods[0].AdaptationSets[0]
+	assert.Equal(t, "video/mp4", video.MimeType)
+	assert.Equal(t, "ContentProtection", video.Extra[0].XMLName.Local)
+	assert.Equal(t, "cenc:default_KID", video.Extra[0].Attrs[2].Name.Local)
+	assert.Equal(t, &Representation{ID: "720p", Width: 1280, Height: 720, Bandwidth: 3000000, Codecs: "avc1.64001f"}, video.Representations[0])
+	assert.Len(t, video.SegmentTemplate.SegmentTimeline.S, 2)
+
+	duration, err := mpd.Duration()
+	assert.Nil(t, err)
+	assert.InDelta(t, 13.471, duration, 0.0001)
+
+	assert.Nil(t, mpd.Validate())
+}
+
+func TestSegmentURIs(t *testing.T) {
+	t.Run("should expand timelines and durations", func(t *testing.T) {
+		mpd, _ := ParseDASH([]byte(TestMPD))
+
+		uris, err := mpd.SegmentURIs()
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"dude_720pinit.mp4",
+			"dude_720p_000000001.mp4",
+			"dude_720p_000000002.mp4",
+			"dude_720p_000000003.mp4",
+			"audio/96000/init.m4s",
+			"audio/96000/1.m4s",
+			"audio/96000/2.m4s",
+			"audio/96000/3.m4s",
+		}, uris)
+	})
+
+	t.Run("should list single file representations", func(t *testing.T) {
+		mpd, _ := ParseDASH([]byte(TestSingleFileMPD))
+
+		uris, err := mpd.SegmentURIs()
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"dude_720p.mp4"}, uris)
+		assert.Nil(t, mpd.Validate())
+	})
+
+	t.Run("should repeat until the end of the period", func(t *testing.T) {
+		template := &SegmentTemplate{
+			Media:           "$Time$.m4s",
+			Timescale:       10,
+			SegmentTimeline: &SegmentTimeline{S: []*S{{D: 60, R: -1}}},
+		}
+
+		segments, err := template.expand(&Representation{}, 13.471)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"0.m4s", "60.m4s", "120.m4s"}, segments)
+	})
+
+	t.Run("should fail without segment information", func(t *testing.T) {
+		mpd := &MPD{
+			MediaPresentationDuration: "PT10S",
+			Periods:                   []*Period{{AdaptationSets: []*AdaptationSet{{Representations: []*Representation{{ID: "1"}}}}}},
+		}
+
+		_, err := mpd.SegmentURIs()
+
+		assert.Error(t, err)
+	})
+}
+
+func TestEncodeDASH(t *testing.T) {
+	mpd, _ := ParseDASH([]byte(TestMPD))
+
+	encoded, err := mpd.Encode()
+	assert.Nil(t, err)
+	assert.Contains(t, string(encoded), `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-main:2011" type="static" minBufferTime="PT10S" mediaPresentationDuration="PT13.471S" xmlns:cenc="urn:mpeg:cenc:2013">`)
+	assert.Contains(t, string(encoded), `<ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="abc"><cenc:pssh>AAA</cenc:pssh></ContentProtection>`)
+
+	reparsed, err := ParseDASH(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, mpd, reparsed)
+}
+
+func TestValidateDASH(t *testing.T) {
+	start := int64(1)
+	mpd := &MPD{
+		Type: "static",
+		Periods: []*Period{
+			{
+				AdaptationSets: []*AdaptationSet{
+					{
+						SegmentTemplate: &SegmentTemplate{Media: "$Number$_$Time$.m4s", StartNumber: &start},
+						Representations: []*Representation{
+							{ID: "1", Codecs: "avc1.64001f"},
+							{ID: "1", Bandwidth: 96000, MimeType: "audio/mp4", Codecs: "aac"},
+						},
+					},
+					{},
+				},
+			},
+		},
+	}
+
+	err := mpd.Validate()
+
+	var validationError *ValidationError
+	assert.True(t, errors.As(err, &validationError))
+	assert.Equal(t, []string{
+		"Period 0: static MPD without mediaPresentationDuration requires Period@duration",
+		"Period 0 Representation 1: bandwidth is required",
+		"Period 0 Representation 1: mimeType is required",
+		"Period 0 Representation 1: SegmentTemplate@media must not use both $Number$ and $Time$",
+		"Period 0 Representation 1: $Time$ requires a SegmentTimeline",
+		"Period 0 Representation 1: SegmentTemplate requires a SegmentTimeline or duration",
+		"Period 0 Representation 1: duplicate Representation@id",
+		`Period 0 Representation 1: manifest: invalid codec "aac"`,
+		"Period 0 Representation 1: SegmentTemplate@media must not use both $Number$ and $Time$",
+		"Period 0 Representation 1: $Time$ requires a SegmentTimeline",
+		"Period 0 Representation 1: SegmentTemplate requires a SegmentTimeline or duration",
+		"Period 0 AdaptationSet 1: no Representation",
+	}, validationError.Problems)
+}
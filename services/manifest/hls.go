@@ -0,0 +1,745 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Playlist is a *MasterPlaylist or a *MediaPlaylist.
+type Playlist interface {
+	Encode() []byte
+	Validate() error
+}
+
+// MasterPlaylist is an HLS multivariant playlist.
+type MasterPlaylist struct {
+	Version             int
+	IndependentSegments bool
+	Media               []*Media
+	Variants            []*Variant
+	IFrameVariants      []*Variant
+	// Tags are the tags this package does not model, in order
+	Tags []string
+}
+
+// Variant is an EXT-X-STREAM-INF or EXT-X-I-FRAME-STREAM-INF.
+type Variant struct {
+	URI              string
+	Bandwidth        int64
+	AverageBandwidth int64
+	Codecs           string
+	Resolution       string
+	FrameRate        float64
+	VideoRange       string
+	Audio            string
+	Video            string
+	Subtitles        string
+	ClosedCaptions   string
+	// Attributes are the attributes this package does not model, with their
+	// raw, possibly quoted, values
+	Attributes map[string]string
+}
+
+// Media is an EXT-X-MEDIA rendition.
+type Media struct {
+	Type            string
+	GroupID         string
+	Name            string
+	Language        string
+	URI             string
+	Default         bool
+	Autoselect      bool
+	Forced          bool
+	Channels        string
+	Characteristics string
+	InstreamID      string
+	Attributes      map[string]string
+}
+
+// MediaPlaylist is an HLS media playlist.
+type MediaPlaylist struct {
+	Version             int
+	TargetDuration      int
+	MediaSequence       int64
+	PlaylistType        string
+	IndependentSegments bool
+	IFramesOnly         bool
+	Segments            []*Segment
+	EndList             bool
+	Tags                []string
+}
+
+// Segment is a media segment with the tags that precede it.
+type Segment struct {
+	URI             string
+	Duration        float64
+	Title           string
+	ByteRange       *ByteRange
+	Discontinuity   bool
+	ProgramDateTime string
+	// Map is the EXT-X-MAP that applies from this segment on, nil when it
+	// does not change
+	Map *Map
+	// Tags are the tags before the segment this package does not model,
+	// such as EXT-X-KEY
+	Tags []string
+}
+
+// ByteRange is an EXT-X-BYTERANGE. Offset is nil when the range follows the
+// previous one.
+type ByteRange struct {
+	Length int64
+	Offset *int64
+}
+
+// Map is an EXT-X-MAP initialization section.
+type Map struct {
+	URI       string
+	ByteRange *ByteRange
+}
+
+var resolutionExpr = regexp.MustCompile(`^\d+x\d+$`)
+
+// ParseHLS parses a master or media playlist.
+func ParseHLS(data []byte) (Playlist, error) {
+	lines, err := readLines(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") || strings.HasPrefix(line, "#EXT-X-I-FRAME-STREAM-INF:") || strings.HasPrefix(line, "#EXT-X-MEDIA:") {
+			return parseMaster(lines)
+		}
+	}
+	return parseMedia(lines)
+}
+
+func readLines(data []byte) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("manifest: ParseHLS: %w", err)
+	}
+
+	if len(lines) == 0 || lines[0] != "#EXTM3U" {
+		return nil, fmt.Errorf("manifest: ParseHLS: missing #EXTM3U header")
+	}
+	return lines[1:], nil
+}
+
+func parseMaster(lines []string) (*MasterPlaylist, error) {
+	playlist := &MasterPlaylist{}
+
+	var variant *Variant
+	for _, line := range lines {
+		tag, value := splitTag(line)
+		switch {
+		case variant != nil:
+			if strings.HasPrefix(line, "#") {
+				return nil, fmt.Errorf("manifest: ParseHLS: EXT-X-STREAM-INF without URI")
+			}
+			variant.URI = line
+			playlist.Variants = append(playlist.Variants, variant)
+			variant = nil
+		case tag == "#EXT-X-VERSION":
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("manifest: ParseHLS: invalid %s: %w", line, err)
+			}
+			playlist.Version = version
+		case tag == "#EXT-X-INDEPENDENT-SEGMENTS":
+			playlist.IndependentSegments = true
+		case tag == "#EXT-X-MEDIA":
+			media, err := parseMediaTag(value)
+			if err != nil {
+				return nil, fmt.Errorf("manifest: ParseHLS: %w", err)
+			}
+			playlist.Media = append(playlist.Media, media)
+		case tag == "#EXT-X-STREAM-INF":
+			parsed, err := parseVariant(value)
+			if err != nil {
+				return nil, fmt.Errorf("manifest: ParseHLS: %w", err)
+			}
+			variant = parsed
+		case tag == "#EXT-X-I-FRAME-STREAM-INF":
+			parsed, err := parseVariant(value)
+			if err != nil {
+				return nil, fmt.Errorf("manifest: ParseHLS: %w", err)
+			}
+			playlist.IFrameVariants = append(playlist.IFrameVariants, parsed)
+		case strings.HasPrefix(line, "#EXT"):
+			playlist.Tags = append(playlist.Tags, line)
+		case strings.HasPrefix(line, "#"):
+			// Comment
+		default:
+			return nil, fmt.Errorf("manifest: ParseHLS: URI %s without EXT-X-STREAM-INF", line)
+		}
+	}
+	if variant != nil {
+		return nil, fmt.Errorf("manifest: ParseHLS: EXT-X-STREAM-INF without URI")
+	}
+
+	return playlist, nil
+}
+
+func parseMedia(lines []string) (*MediaPlaylist, error) {
+	playlist := &MediaPlaylist{}
+
+	segment := &Segment{}
+	hasDuration := false
+	for _, line := range lines {
+		tag, value := splitTag(line)
+		var err error
+		switch tag {
+		case "#EXT-X-VERSION":
+			playlist.Version, err = strconv.Atoi(value)
+		case "#EXT-X-TARGETDURATION":
+			playlist.TargetDuration, err = strconv.Atoi(value)
+		case "#EXT-X-MEDIA-SEQUENCE":
+			playlist.MediaSequence, err = strconv.ParseInt(value, 10, 64)
+		case "#EXT-X-PLAYLIST-TYPE":
+			playlist.PlaylistType = value
+		case "#EXT-X-INDEPENDENT-SEGMENTS":
+			playlist.IndependentSegments = true
+		case "#EXT-X-I-FRAMES-ONLY":
+			playlist.IFramesOnly = true
+		case "#EXT-X-ENDLIST":
+			playlist.EndList = true
+		case "#EXTINF":
+			duration, title, _ := strings.Cut(value, ",")
+			segment.Duration, err = strconv.ParseFloat(duration, 64)
+			segment.Title = title
+			hasDuration = true
+		case "#EXT-X-BYTERANGE":
+			segment.ByteRange, err = parseByteRange(value)
+		case "#EXT-X-DISCONTINUITY":
+			segment.Discontinuity = true
+		case "#EXT-X-PROGRAM-DATE-TIME":
+			segment.ProgramDateTime = value
+		case "#EXT-X-MAP":
+			segment.Map, err = parseMap(value)
+		default:
+			switch {
+			case strings.HasPrefix(line, "#EXT"):
+				if len(playlist.Segments) == 0 && !hasDuration && isPlaylistTag(tag) {
+					playlist.Tags = append(playlist.Tags, line)
+				} else {
+					segment.Tags = append(segment.Tags, line)
+				}
+			case strings.HasPrefix(line, "#"):
+				// Comment
+			default:
+				if !hasDuration {
+					return nil, fmt.Errorf("manifest: ParseHLS: segment %s without EXTINF", line)
+				}
+				segment.URI = line
+				playlist.Segments = append(playlist.Segments, segment)
+				segment = &Segment{}
+				hasDuration = false
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("manifest: ParseHLS: invalid %s: %w", line, err)
+		}
+	}
+
+	return playlist, nil
+}
+
+// isPlaylistTag reports whether an unmodelled tag applies to the whole
+// playlist rather than to the next segment.
+func isPlaylistTag(tag string) bool {
+	switch tag {
+	case "#EXT-X-KEY", "#EXT-X-GAP", "#EXT-X-BITRATE", "#EXT-X-DATERANGE", "#EXT-X-CUE-OUT", "#EXT-X-CUE-IN":
+		return false
+	}
+	return true
+}
+
+func splitTag(line string) (string, string) {
+	if !strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	tag, value, _ := strings.Cut(line, ":")
+	return tag, value
+}
+
+// parseAttributes parses an attribute list, keeping quoted values quoted.
+func parseAttributes(value string) (map[string]string, error) {
+	attributes := map[string]string{}
+	for value != "" {
+		name, rest, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid attribute list %q", value)
+		}
+
+		var raw string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string in %q", value)
+			}
+			raw, rest = rest[:end+2], rest[end+2:]
+		} else {
+			raw, rest, _ = strings.Cut(rest, ",")
+			rest = "," + rest
+		}
+
+		attributes[strings.TrimSpace(name)] = raw
+		value = strings.TrimPrefix(rest, ",")
+	}
+	return attributes, nil
+}
+
+// take removes an attribute and returns its unquoted value.
+func take(attributes map[string]string, name string) string {
+	value, ok := attributes[name]
+	if !ok {
+		return ""
+	}
+	delete(attributes, name)
+	return strings.Trim(value, `"`)
+}
+
+func takeInt(attributes map[string]string, name string) (int64, error) {
+	value := take(attributes, name)
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return number, nil
+}
+
+func parseVariant(value string) (*Variant, error) {
+	attributes, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+
+	variant := &Variant{
+		URI:            take(attributes, "URI"),
+		Codecs:         take(attributes, "CODECS"),
+		Resolution:     take(attributes, "RESOLUTION"),
+		VideoRange:     take(attributes, "VIDEO-RANGE"),
+		Audio:          take(attributes, "AUDIO"),
+		Video:          take(attributes, "VIDEO"),
+		Subtitles:      take(attributes, "SUBTITLES"),
+		ClosedCaptions: take(attributes, "CLOSED-CAPTIONS"),
+	}
+	if variant.Bandwidth, err = takeInt(attributes, "BANDWIDTH"); err != nil {
+		return nil, err
+	}
+	if variant.AverageBandwidth, err = takeInt(attributes, "AVERAGE-BANDWIDTH"); err != nil {
+		return nil, err
+	}
+	if frameRate := take(attributes, "FRAME-RATE"); frameRate != "" {
+		if variant.FrameRate, err = strconv.ParseFloat(frameRate, 64); err != nil {
+			return nil, fmt.Errorf("invalid FRAME-RATE: %w", err)
+		}
+	}
+	if len(attributes) > 0 {
+		variant.Attributes = attributes
+	}
+	return variant, nil
+}
+
+func parseMediaTag(value string) (*Media, error) {
+	attributes, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+
+	media := &Media{
+		Type:            take(attributes, "TYPE"),
+		GroupID:         take(attributes, "GROUP-ID"),
+		Name:            take(attributes, "NAME"),
+		Language:        take(attributes, "LANGUAGE"),
+		URI:             take(attributes, "URI"),
+		Default:         take(attributes, "DEFAULT") == "YES",
+		Autoselect:      take(attributes, "AUTOSELECT") == "YES",
+		Forced:          take(attributes, "FORCED") == "YES",
+		Channels:        take(attributes, "CHANNELS"),
+		Characteristics: take(attributes, "CHARACTERISTICS"),
+		InstreamID:      take(attributes, "INSTREAM-ID"),
+	}
+	if len(attributes) > 0 {
+		media.Attributes = attributes
+	}
+	return media, nil
+}
+
+func parseByteRange(value string) (*ByteRange, error) {
+	length, offset, hasOffset := strings.Cut(value, "@")
+
+	byteRange := &ByteRange{}
+	var err error
+	if byteRange.Length, err = strconv.ParseInt(length, 10, 64); err != nil {
+		return nil, err
+	}
+	if hasOffset {
+		start, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		byteRange.Offset = &start
+	}
+	return byteRange, nil
+}
+
+func parseMap(value string) (*Map, error) {
+	attributes, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Map{URI: take(attributes, "URI")}
+	if byteRange := take(attributes, "BYTERANGE"); byteRange != "" {
+		if m.ByteRange, err = parseByteRange(byteRange); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// PlaylistURIs returns the variant, I-frame and rendition playlist URIs.
+func (p *MasterPlaylist) PlaylistURIs() []string {
+	var uris []string
+	for _, media := range p.Media {
+		if media.URI != "" {
+			uris = append(uris, media.URI)
+		}
+	}
+	for _, variant := range p.Variants {
+		uris = append(uris, variant.URI)
+	}
+	for _, variant := range p.IFrameVariants {
+		uris = append(uris, variant.URI)
+	}
+	return unique(uris)
+}
+
+// SegmentURIs returns the initialization and media segment URIs. Segments
+// addressed by byte range within one file are listed once.
+func (p *MediaPlaylist) SegmentURIs() []string {
+	var uris []string
+	for _, segment := range p.Segments {
+		if segment.Map != nil {
+			uris = append(uris, segment.Map.URI)
+		}
+		uris = append(uris, segment.URI)
+	}
+	return unique(uris)
+}
+
+// Duration returns the sum of the segment durations in seconds.
+func (p *MediaPlaylist) Duration() float64 {
+	var duration float64
+	for _, segment := range p.Segments {
+		duration += segment.Duration
+	}
+	return duration
+}
+
+// Validate checks the playlist against RFC 8216.
+func (p *MasterPlaylist) Validate() error {
+	var errs problems
+
+	groups := map[string]bool{}
+	for i, media := range p.Media {
+		switch media.Type {
+		case "AUDIO", "VIDEO", "SUBTITLES":
+		case "CLOSED-CAPTIONS":
+			if media.URI != "" {
+				errs.add("EXT-X-MEDIA %d: CLOSED-CAPTIONS must not have a URI", i)
+			}
+			if media.InstreamID == "" {
+				errs.add("EXT-X-MEDIA %d: CLOSED-CAPTIONS requires INSTREAM-ID", i)
+			}
+		default:
+			errs.add("EXT-X-MEDIA %d: invalid TYPE %q", i, media.Type)
+		}
+		if media.Type == "SUBTITLES" && media.URI == "" {
+			errs.add("EXT-X-MEDIA %d: SUBTITLES requires a URI", i)
+		}
+		if media.GroupID == "" {
+			errs.add("EXT-X-MEDIA %d: GROUP-ID is required", i)
+		}
+		if media.Name == "" {
+			errs.add("EXT-X-MEDIA %d: NAME is required", i)
+		}
+		groups[media.Type+"/"+media.GroupID] = true
+	}
+
+	if len(p.Variants) == 0 {
+		errs.add("no EXT-X-STREAM-INF")
+	}
+
+	check := func(kind string, i int, variant *Variant) {
+		if variant.URI == "" {
+			errs.add("%s %d: URI is required", kind, i)
+		}
+		if variant.Bandwidth <= 0 {
+			errs.add("%s %d (%s): BANDWIDTH is required", kind, i, variant.URI)
+		}
+		if variant.AverageBandwidth < 0 {
+			errs.add("%s %d (%s): invalid AVERAGE-BANDWIDTH %d", kind, i, variant.URI, variant.AverageBandwidth)
+		}
+		if variant.Codecs != "" {
+			if err := ValidateCodecs(variant.Codecs); err != nil {
+				errs.add("%s %d (%s): %v", kind, i, variant.URI, err)
+			}
+		}
+		if variant.Resolution != "" && !resolutionExpr.MatchString(variant.Resolution) {
+			errs.add("%s %d (%s): invalid RESOLUTION %q", kind, i, variant.URI, variant.Resolution)
+		}
+		references := [][2]string{{"AUDIO", variant.Audio}, {"VIDEO", variant.Video}, {"SUBTITLES", variant.Subtitles}, {"CLOSED-CAPTIONS", variant.ClosedCaptions}}
+		for _, reference := range references {
+			mediaType, group := reference[0], reference[1]
+			if group != "" && group != "NONE" && !groups[mediaType+"/"+group] {
+				errs.add("%s %d (%s): no %s rendition group %q", kind, i, variant.URI, mediaType, group)
+			}
+		}
+	}
+	for i, variant := range p.Variants {
+		check("EXT-X-STREAM-INF", i, variant)
+	}
+	for i, variant := range p.IFrameVariants {
+		check("EXT-X-I-FRAME-STREAM-INF", i, variant)
+	}
+
+	return errs.err()
+}
+
+// Validate checks the playlist against RFC 8216.
+func (p *MediaPlaylist) Validate() error {
+	var errs problems
+
+	if p.TargetDuration <= 0 {
+		errs.add("EXT-X-TARGETDURATION is required")
+	}
+	switch p.PlaylistType {
+	case "", "EVENT":
+	case "VOD":
+		if !p.EndList {
+			errs.add("VOD playlist without EXT-X-ENDLIST")
+		}
+	default:
+		errs.add("invalid EXT-X-PLAYLIST-TYPE %q", p.PlaylistType)
+	}
+
+	for i, segment := range p.Segments {
+		if segment.Duration < 0 {
+			errs.add("segment %d (%s): negative duration", i, segment.URI)
+		}
+		// Durations rounded to the nearest integer must not exceed it
+		if p.TargetDuration > 0 && int(math.Round(segment.Duration)) > p.TargetDuration {
+			errs.add("segment %d (%s): duration %.3f exceeds EXT-X-TARGETDURATION %d", i, segment.URI, segment.Duration, p.TargetDuration)
+		}
+		if p.Version < 3 && segment.Duration != math.Trunc(segment.Duration) {
+			errs.add("segment %d (%s): fractional duration requires EXT-X-VERSION 3", i, segment.URI)
+		}
+		if segment.ByteRange != nil && segment.ByteRange.Offset == nil && i == 0 {
+			errs.add("segment %d (%s): first EXT-X-BYTERANGE requires an offset", i, segment.URI)
+		}
+	}
+
+	return errs.err()
+}
+
+// Encode serialises the playlist.
+func (p *MasterPlaylist) Encode() []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	if p.Version > 0 {
+		fmt.Fprintf(&b, "#EXT-X-VERSION:%d\n", p.Version)
+	}
+	if p.IndependentSegments {
+		b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	for _, tag := range p.Tags {
+		b.WriteString(tag + "\n")
+	}
+
+	for _, media := range p.Media {
+		attributes := []string{
+			"TYPE=" + media.Type,
+			quoted("GROUP-ID", media.GroupID),
+			quoted("NAME", media.Name),
+			quoted("LANGUAGE", media.Language),
+			yes("DEFAULT", media.Default),
+			yes("AUTOSELECT", media.Autoselect),
+			yes("FORCED", media.Forced),
+			quoted("INSTREAM-ID", media.InstreamID),
+			quoted("CHARACTERISTICS", media.Characteristics),
+			quoted("CHANNELS", media.Channels),
+			quoted("URI", media.URI),
+		}
+		b.WriteString("#EXT-X-MEDIA:" + joinAttributes(attributes, media.Attributes) + "\n")
+	}
+
+	for _, variant := range p.Variants {
+		b.WriteString("#EXT-X-STREAM-INF:" + joinAttributes(variantAttributes(variant), variant.Attributes) + "\n")
+		b.WriteString(variant.URI + "\n")
+	}
+	for _, variant := range p.IFrameVariants {
+		attributes := append(variantAttributes(variant), quoted("URI", variant.URI))
+		b.WriteString("#EXT-X-I-FRAME-STREAM-INF:" + joinAttributes(attributes, variant.Attributes) + "\n")
+	}
+
+	return b.Bytes()
+}
+
+func variantAttributes(variant *Variant) []string {
+	attributes := []string{fmt.Sprintf("BANDWIDTH=%d", variant.Bandwidth)}
+	if variant.AverageBandwidth > 0 {
+		attributes = append(attributes, fmt.Sprintf("AVERAGE-BANDWIDTH=%d", variant.AverageBandwidth))
+	}
+	attributes = append(attributes, quoted("CODECS", variant.Codecs))
+	if variant.Resolution != "" {
+		attributes = append(attributes, "RESOLUTION="+variant.Resolution)
+	}
+	if variant.FrameRate > 0 {
+		attributes = append(attributes, "FRAME-RATE="+strconv.FormatFloat(variant.FrameRate, 'f', 3, 64))
+	}
+	if variant.VideoRange != "" {
+		attributes = append(attributes, "VIDEO-RANGE="+variant.VideoRange)
+	}
+	attributes = append(attributes,
+		quoted("AUDIO", variant.Audio),
+		quoted("VIDEO", variant.Video),
+		quoted("SUBTITLES", variant.Subtitles),
+	)
+	switch variant.ClosedCaptions {
+	case "":
+	case "NONE":
+		attributes = append(attributes, "CLOSED-CAPTIONS=NONE")
+	default:
+		attributes = append(attributes, quoted("CLOSED-CAPTIONS", variant.ClosedCaptions))
+	}
+	return attributes
+}
+
+// Encode serialises the playlist.
+func (p *MediaPlaylist) Encode() []byte {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	if p.Version > 0 {
+		fmt.Fprintf(&b, "#EXT-X-VERSION:%d\n", p.Version)
+	}
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", p.TargetDuration)
+	if p.MediaSequence > 0 {
+		fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence)
+	}
+	if p.PlaylistType != "" {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:" + p.PlaylistType + "\n")
+	}
+	if p.IndependentSegments {
+		b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	}
+	if p.IFramesOnly {
+		b.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+	}
+	for _, tag := range p.Tags {
+		b.WriteString(tag + "\n")
+	}
+
+	for _, segment := range p.Segments {
+		for _, tag := range segment.Tags {
+			b.WriteString(tag + "\n")
+		}
+		if segment.Map != nil {
+			attributes := []string{quoted("URI", segment.Map.URI)}
+			if segment.Map.ByteRange != nil {
+				attributes = append(attributes, quoted("BYTERANGE", segment.Map.ByteRange.String()))
+			}
+			b.WriteString("#EXT-X-MAP:" + joinAttributes(attributes, nil) + "\n")
+		}
+		if segment.Discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if segment.ProgramDateTime != "" {
+			b.WriteString("#EXT-X-PROGRAM-DATE-TIME:" + segment.ProgramDateTime + "\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:%s,%s\n", strconv.FormatFloat(segment.Duration, 'f', 3, 64), segment.Title)
+		if segment.ByteRange != nil {
+			b.WriteString("#EXT-X-BYTERANGE:" + segment.ByteRange.String() + "\n")
+		}
+		b.WriteString(segment.URI + "\n")
+	}
+
+	if p.EndList {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return b.Bytes()
+}
+
+func (r *ByteRange) String() string {
+	if r.Offset == nil {
+		return strconv.FormatInt(r.Length, 10)
+	}
+	return fmt.Sprintf("%d@%d", r.Length, *r.Offset)
+}
+
+func quoted(name string, value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
+
+func yes(name string, value bool) string {
+	if !value {
+		return ""
+	}
+	return name + "=YES"
+}
+
+// joinAttributes joins the non-empty attributes, then the unmodelled ones
+// sorted by name.
+func joinAttributes(attributes []string, others map[string]string) string {
+	var parts []string
+	for _, attribute := range attributes {
+		if attribute != "" {
+			parts = append(parts, attribute)
+		}
+	}
+
+	names := make([]string, 0, len(others))
+	for name := range others {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+others[name])
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,227 @@
+package manifest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const TestMaster = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Dude"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="English",LANGUAGE="eng",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="dude_audio.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=3000000,AVERAGE-BANDWIDTH=2800000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=29.970,AUDIO="audio",CLOSED-CAPTIONS=NONE,HDCP-LEVEL=TYPE-0
+dude_720p.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=600000,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio"
+dude_360p.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=300000,CODECS="avc1.64001f",RESOLUTION=1280x720,URI="dude_720p_iframe.m3u8"
+`
+
+const TestMedia = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:1
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key",KEYFORMAT="com.apple.streamingkeydelivery"
+#EXT-X-MAP:URI="dude_720p_init.mp4"
+#EXTINF:6.000,
+dude_720p_00001.mp4
+#EXTINF:6.000,
+dude_720p_00002.mp4
+#EXTINF:1.471,
+dude_720p_00003.mp4
+#EXT-X-ENDLIST
+`
+
+const TestByteRange = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.0,
+#EXT-X-BYTERANGE:1000@0
+dude.ts
+#EXTINF:6.0,
+#EXT-X-BYTERANGE:1000
+dude.ts
+#EXT-X-ENDLIST
+`
+
+func TestParseHLSMaster(t *testing.T) {
+	playlist, err := ParseHLS([]byte(TestMaster))
+	assert.Nil(t, err)
+
+	master := playlist.(*MasterPlaylist)
+	assert.Equal(t, 4, master.Version)
+	assert.True(t, master.IndependentSegments)
+	assert.Equal(t, []string{`#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Dude"`}, master.Tags)
+
+	assert.Equal(t, &Media{
+		Type:       "AUDIO",
+		GroupID:    "audio",
+		Name:       "English",
+		Language:   "eng",
+		URI:        "dude_audio.m3u8",
+		Default:    true,
+		Autoselect: true,
+		Channels:   "2",
+	}, master.Media[0])
+
+	assert.Equal(t, &Variant{
+		URI:              "dude_720p.m3u8",
+		Bandwidth:        3000000,
+		AverageBandwidth: 2800000,
+		Codecs:           "avc1.64001f,mp4a.40.2",
+		Resolution:       "1280x720",
+		FrameRate:        29.97,
+		Audio:            "audio",
+		ClosedCaptions:   "NONE",
+		Attributes:       map[string]string{"HDCP-LEVEL": "TYPE-0"},
+	}, master.Variants[0])
+	assert.Len(t, master.Variants, 2)
+	assert.Equal(t, "dude_720p_iframe.m3u8", master.IFrameVariants[0].URI)
+
+	assert.Equal(t, []string{"dude_audio.m3u8", "dude_720p.m3u8", "dude_360p.m3u8", "dude_720p_iframe.m3u8"}, master.PlaylistURIs())
+	assert.Nil(t, master.Validate())
+}
+
+func TestParseHLSMedia(t *testing.T) {
+	playlist, err := ParseHLS([]byte(TestMedia))
+	assert.Nil(t, err)
+
+	media := playlist.(*MediaPlaylist)
+	assert.Equal(t, 6, media.TargetDuration)
+	assert.Equal(t, int64(1), media.MediaSequence)
+	assert.Equal(t, "VOD", media.PlaylistType)
+	assert.True(t, media.EndList)
+	assert.Len(t, media.Segments, 3)
+	assert.Equal(t, &Map{URI: "dude_720p_init.mp4"}, media.Segments[0].Map)
+	assert.Equal(t, []string{`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key",KEYFORMAT="com.apple.streamingkeydelivery"`}, media.Segments[0].Tags)
+	assert.Nil(t, media.Segments[1].Map)
+
+	assert.InDelta(t, 13.471, media.Duration(), 0.0001)
+	assert.Equal(t, []string{"dude_720p_init.mp4", "dude_720p_00001.mp4", "dude_720p_00002.mp4", "dude_720p_00003.mp4"}, media.SegmentURIs())
+	assert.Nil(t, media.Validate())
+}
+
+func TestParseHLSByteRange(t *testing.T) {
+	playlist, err := ParseHLS([]byte(TestByteRange))
+	assert.Nil(t, err)
+
+	media := playlist.(*MediaPlaylist)
+	assert.Equal(t, int64(0), *media.Segments[0].ByteRange.Offset)
+	assert.Nil(t, media.Segments[1].ByteRange.Offset)
+	assert.Equal(t, []string{"dude.ts"}, media.SegmentURIs())
+}
+
+func TestParseHLSErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing header":      "#EXT-X-VERSION:3\n",
+		"variant without URI": "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\n",
+		"segment without inf": "#EXTM3U\n#EXT-X-TARGETDURATION:6\nsegment.ts\n",
+		"invalid duration":    "#EXTM3U\n#EXTINF:six,\nsegment.ts\n",
+		"unterminated quote":  "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1,CODECS=\"avc1\nvariant.m3u8\n",
+	}
+	for name, playlist := range tests {
+		_, err := ParseHLS([]byte(playlist))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestEncodeHLS(t *testing.T) {
+	t.Run("should round trip master playlists", func(t *testing.T) {
+		playlist, _ := ParseHLS([]byte(TestMaster))
+
+		encoded := playlist.Encode()
+		assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-SESSION-DATA:DATA-ID="com.example.title",VALUE="Dude"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="English",LANGUAGE="eng",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="dude_audio.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=3000000,AVERAGE-BANDWIDTH=2800000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=29.970,AUDIO="audio",CLOSED-CAPTIONS=NONE,HDCP-LEVEL=TYPE-0
+dude_720p.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=600000,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio"
+dude_360p.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=300000,CODECS="avc1.64001f",RESOLUTION=1280x720,URI="dude_720p_iframe.m3u8"
+`, string(encoded))
+
+		reparsed, err := ParseHLS(encoded)
+		assert.Nil(t, err)
+		assert.Equal(t, playlist, reparsed)
+	})
+
+	t.Run("should round trip media playlists", func(t *testing.T) {
+		playlist, _ := ParseHLS([]byte(TestMedia))
+
+		assert.Equal(t, TestMedia, string(playlist.Encode()))
+	})
+
+	t.Run("should write byte ranges", func(t *testing.T) {
+		playlist, _ := ParseHLS([]byte(TestByteRange))
+
+		reparsed, err := ParseHLS(playlist.Encode())
+		assert.Nil(t, err)
+		assert.Equal(t, playlist, reparsed)
+	})
+}
+
+func TestValidateHLS(t *testing.T) {
+	t.Run("should report master playlist problems", func(t *testing.T) {
+		master := &MasterPlaylist{
+			Media: []*Media{
+				{Type: "SUBTITLES", GroupID: "subs", Name: "English"},
+				{Type: "CLOSED-CAPTIONS", GroupID: "cc", Name: "English", URI: "cc.m3u8"},
+			},
+			Variants: []*Variant{
+				{URI: "dude_720p.m3u8", Codecs: "avc1.64001f,mp4a.40.2", Resolution: "720p", Audio: "audio"},
+				{URI: "dude_360p.m3u8", Bandwidth: 600000, Codecs: "h264"},
+			},
+		}
+
+		err := master.Validate()
+
+		var validationError *ValidationError
+		assert.True(t, errors.As(err, &validationError))
+		assert.Equal(t, []string{
+			"EXT-X-MEDIA 0: SUBTITLES requires a URI",
+			"EXT-X-MEDIA 1: CLOSED-CAPTIONS must not have a URI",
+			"EXT-X-MEDIA 1: CLOSED-CAPTIONS requires INSTREAM-ID",
+			"EXT-X-STREAM-INF 0 (dude_720p.m3u8): BANDWIDTH is required",
+			`EXT-X-STREAM-INF 0 (dude_720p.m3u8): invalid RESOLUTION "720p"`,
+			`EXT-X-STREAM-INF 0 (dude_720p.m3u8): no AUDIO rendition group "audio"`,
+			`EXT-X-STREAM-INF 1 (dude_360p.m3u8): manifest: invalid codec "h264"`,
+		}, validationError.Problems)
+	})
+
+	t.Run("should require variants", func(t *testing.T) {
+		assert.EqualError(t, (&MasterPlaylist{}).Validate(), "manifest: invalid: no EXT-X-STREAM-INF")
+	})
+
+	t.Run("should report media playlist problems", func(t *testing.T) {
+		media := &MediaPlaylist{
+			Version:        2,
+			TargetDuration: 6,
+			PlaylistType:   "VOD",
+			Segments: []*Segment{
+				{URI: "1.ts", Duration: 6.4},
+				{URI: "2.ts", Duration: 6.5},
+				{URI: "3.ts", Duration: 1},
+			},
+		}
+
+		err := media.Validate()
+
+		var validationError *ValidationError
+		assert.True(t, errors.As(err, &validationError))
+		assert.Equal(t, []string{
+			"VOD playlist without EXT-X-ENDLIST",
+			"segment 0 (1.ts): fractional duration requires EXT-X-VERSION 3",
+			"segment 1 (2.ts): duration 6.500 exceeds EXT-X-TARGETDURATION 6",
+			"segment 1 (2.ts): fractional duration requires EXT-X-VERSION 3",
+		}, validationError.Problems)
+	})
+
+	t.Run("should require a target duration", func(t *testing.T) {
+		assert.EqualError(t, (&MediaPlaylist{}).Validate(), "manifest: invalid: EXT-X-TARGETDURATION is required")
+	})
+}
@@ -0,0 +1,76 @@
+// Package manifest parses, validates and serialises the HLS playlists and
+// DASH MPDs written by the encoding workflows.
+//
+// Services import it as the manifest module, replaced with ../manifest in
+// their go.mod. deploy.sh passes this directory to docker as the manifest
+// build context.
+package manifest
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidationError lists every spec rule a manifest breaks.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "manifest: invalid: " + strings.Join(e.Problems, "; ")
+}
+
+type problems []string
+
+func (p *problems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: p}
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:([\d.]+)Y)?(?:([\d.]+)M)?(?:([\d.]+)D)?(?:T(?:([\d.]+)H)?(?:([\d.]+)M)?(?:([\d.]+)S)?)?$`)
+
+// ParseDuration parses an ISO 8601 duration such as PT1M28.189S into
+// seconds. An empty duration is 0. Years and months are approximated as 365
+// and 30 days.
+func ParseDuration(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	match := isoDuration.FindStringSubmatch(value)
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("manifest: invalid duration %q", value)
+	}
+
+	units := []float64{365 * 86400, 30 * 86400, 86400, 3600, 60, 1}
+	var seconds float64
+	for i, unit := range units {
+		if match[i+1] == "" {
+			continue
+		}
+		number, err := strconv.ParseFloat(match[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("manifest: invalid duration %q: %w", value, err)
+		}
+		seconds += number * unit
+	}
+	return seconds, nil
+}
+
+// FormatDuration formats seconds as an ISO 8601 duration with millisecond
+// precision, the way MediaConvert writes them.
+func FormatDuration(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	hours := ms / 3600000
+	minutes := ms / 60000 % 60
+	return fmt.Sprintf("PT%dH%dM%.3fS", hours, minutes, float64(ms%60000)/1000)
+}
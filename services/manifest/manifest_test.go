@@ -0,0 +1,41 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]float64{
+		"":              0,
+		"PT13.471S":     13.471,
+		"PT1H2M3S":      3723,
+		"P1DT0H0M0.5S":  86400.5,
+		"PT0H0M13.471S": 13.471,
+	}
+	for value, expected := range tests {
+		seconds, err := ParseDuration(value)
+		assert.Nil(t, err)
+		assert.InDelta(t, expected, seconds, 0.0001, value)
+	}
+
+	for _, value := range []string{"13.471", "P", "PT", "PT1.2.3S"} {
+		_, err := ParseDuration(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "PT0H0M13.471S", FormatDuration(13.471))
+	assert.Equal(t, "PT1H2M3.000S", FormatDuration(3723))
+
+	seconds, _ := ParseDuration(FormatDuration(5025.5))
+	assert.Equal(t, 5025.5, seconds)
+}
+
+func TestValidationError(t *testing.T) {
+	err := &ValidationError{Problems: []string{"one", "two"}}
+
+	assert.EqualError(t, err, "manifest: invalid: one; two")
+}
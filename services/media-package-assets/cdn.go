@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// convertCdnEndpoints maps each egress endpoint to its URL on every CDN of
+// the asset, by packaging configuration then CDN name. Origin-direct CDNs
+// get the MediaPackage URL itself.
+func convertCdnEndpoints(egressEndpoints map[string]string, cdns []*Cdn, cloudFrontEndpoint string) (CdnUrls, error) {
+	if len(cdns) == 0 || len(egressEndpoints) == 0 {
+		return nil, nil
+	}
+
+	groupDomain, err := url.Parse(os.Getenv("GroupDomainName"))
+	if err != nil {
+		return nil, fmt.Errorf("convertCdnEndpoints: failed to parse MediaPackage endpoint: %w", err)
+	}
+
+	cdnEndpoints := CdnUrls{}
+	for config, endpoint := range egressEndpoints {
+		cdnEndpoints[config] = map[string]string{}
+		for _, cdn := range cdns {
+			host := cdn.Host
+			if cdn.Origin {
+				host = groupDomain.Host
+			}
+			cdnEndpoints[config][cdn.Name] = strings.Replace(endpoint, cloudFrontEndpoint, host, 1)
+		}
+	}
+	return cdnEndpoints, nil
+}
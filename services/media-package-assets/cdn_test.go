@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertCdnEndpoints(t *testing.T) {
+	t.Setenv("GroupDomainName", domainName)
+
+	cdns := []*Cdn{
+		{Name: "primary", Host: "random-id.cloudfront.net", Weight: 80},
+		{Name: "secondary", Host: "vod.cdn.example.com", Weight: 20},
+		{Name: "origin", Host: "bucket.s3.amazonaws.com", Origin: true},
+	}
+
+	t.Run("should map endpoints to every CDN", func(t *testing.T) {
+		cdnEndpoints, err := convertCdnEndpoints(map[string]string{
+			"HLS": "https://random-id.cloudfront.net/out/index.m3u8",
+		}, cdns, "random-id.cloudfront.net")
+		assert.Nil(t, err)
+		assert.Equal(t, CdnUrls{
+			"HLS": {
+				"primary":   "https://random-id.cloudfront.net/out/index.m3u8",
+				"secondary": "https://vod.cdn.example.com/out/index.m3u8",
+				"origin":    domainName + "/out/index.m3u8",
+			},
+		}, cdnEndpoints)
+	})
+
+	t.Run("should return nil without CDNs", func(t *testing.T) {
+		cdnEndpoints, err := convertCdnEndpoints(map[string]string{
+			"HLS": "https://random-id.cloudfront.net/out/index.m3u8",
+		}, nil, "random-id.cloudfront.net")
+		assert.Nil(t, err)
+		assert.Nil(t, cdnEndpoints)
+	})
+}
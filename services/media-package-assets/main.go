@@ -79,6 +79,16 @@ type MediaPackageAssetsEvent struct {
 	EncodeJobId            string                      `json:"encodeJobId"`
 	EncodingOutput         EventDetail                 `json:"encodingOutput"`
 	EndTime                time.Time                   `json:"endTime"`
+	OutputErrors           []string                    `json:"outputErrors,omitempty"`
+	QualityReport          *QualityReport              `json:"qualityReport,omitempty"`
+	Renditions             []*Rendition                `json:"renditions,omitempty"`
+	FileOutputs            *FileOutputs                `json:"fileOutputs,omitempty"`
+	PrivateContent         bool                        `json:"privateContent,omitempty"`
+	PlaybackUrl            string                      `json:"playbackUrl,omitempty"`
+	Cdns                   []*Cdn                      `json:"cdns,omitempty"`
+	CdnUrls                CdnUrls                     `json:"cdnUrls,omitempty"`
+	CdnEgressEndpoints     CdnUrls                     `json:"cdnEgressEndpoints,omitempty"`
+	Version                int                         `json:"version,omitempty"`
 
 	// Output
 	HlsPlaylist            *string           `json:"hlsPlaylist"`
@@ -93,6 +103,7 @@ type MediaPackageAssetsEvent struct {
 	CmafDashUrl            *string           `json:"cmafDashUrl"`
 	CmafHlsPlaylist        *string           `json:"cmafHlsPlaylist"`
 	CmafHlsUrl             *string           `json:"cmafHlsUrl"`
+	CmafGroups             []*CmafGroup      `json:"cmafGroups,omitempty"`
 	ThumbNails             []*string         `json:"thumbNails"`
 	ThumbNailsUrls         []*string         `json:"thumbNailsUrls"`
 	MediaPackageResourceId string            `json:"mediaPackageResourceId"`
@@ -104,6 +115,75 @@ type Warning struct {
 	Count int64 `json:"count"`
 }
 
+type QualityReport struct {
+	QvbrQualityFloor float64             `json:"qvbrQualityFloor"`
+	Renditions       []*RenditionQuality `json:"renditions"`
+	BlackVideoMs     int64               `json:"blackVideoMs,omitempty"`
+	PaddingMs        int64               `json:"paddingMs,omitempty"`
+	Warnings         []*Warning          `json:"warnings,omitempty"`
+	Flagged          bool                `json:"flagged"`
+	Summary          string              `json:"summary"`
+}
+
+type RenditionQuality struct {
+	Output                 string   `json:"output"`
+	GroupType              string   `json:"groupType"`
+	WidthInPx              int64    `json:"widthInPx"`
+	HeightInPx             int64    `json:"heightInPx"`
+	AverageBitrate         float64  `json:"averageBitrate"`
+	QvbrAvgQuality         float64  `json:"qvbrAvgQuality,omitempty"`
+	QvbrMinQuality         float64  `json:"qvbrMinQuality,omitempty"`
+	QvbrMaxQuality         float64  `json:"qvbrMaxQuality,omitempty"`
+	QvbrMinQualityLocation float64  `json:"qvbrMinQualityLocation,omitempty"`
+	QvbrMaxQualityLocation float64  `json:"qvbrMaxQualityLocation,omitempty"`
+	Flags                  []string `json:"flags,omitempty"`
+}
+
+type Rendition struct {
+	GroupType      string  `json:"groupType"`
+	File           string  `json:"file"`
+	Url            string  `json:"url"`
+	WidthInPx      int64   `json:"widthInPx,omitempty"`
+	HeightInPx     int64   `json:"heightInPx,omitempty"`
+	AverageBitrate float64 `json:"averageBitrate,omitempty"`
+	DurationInMs   int64   `json:"durationInMs"`
+	Codec          string  `json:"codec,omitempty"`
+}
+
+type FileOutput struct {
+	File string `json:"file"`
+	Url  string `json:"url"`
+}
+
+type FileOutputs struct {
+	Mp4       []*FileOutput `json:"mp4,omitempty"`
+	Mezzanine []*FileOutput `json:"mezzanine,omitempty"`
+	Audio     []*FileOutput `json:"audio,omitempty"`
+	Webm      []*FileOutput `json:"webm,omitempty"`
+	Captions  []*FileOutput `json:"captions,omitempty"`
+	Other     []*FileOutput `json:"other,omitempty"`
+}
+
+type CmafGroup struct {
+	Name         string  `json:"name,omitempty"`
+	DashPlaylist *string `json:"dashPlaylist,omitempty"`
+	DashUrl      *string `json:"dashUrl,omitempty"`
+	HlsPlaylist  *string `json:"hlsPlaylist,omitempty"`
+	HlsUrl       *string `json:"hlsUrl,omitempty"`
+}
+
+// Cdn is a delivery hostname, see output-validate
+type Cdn struct {
+	Name    string   `json:"name"`
+	Host    string   `json:"host"`
+	Weight  int      `json:"weight"`
+	Regions []string `json:"regions,omitempty"`
+	Origin  bool     `json:"origin,omitempty"`
+}
+
+// CdnUrls maps a protocol to its URL on each CDN, by CDN name
+type CdnUrls map[string]map[string]string
+
 type UserMetadata struct {
 	GUID     string `json:"guid"`
 	Workflow string `json:"workflow"`
@@ -121,6 +201,11 @@ func (h *Handler) HanleRequest(event MediaPackageAssetsEvent) (*MediaPackageAsse
 	eventJson, _ := json.Marshal(event)
 	log.Printf("REQUEST:: %s", eventJson)
 
+	if event.WorkflowStatus == "OutputInvalid" {
+		log.Printf("Not ingesting %s into MediaPackage, outputs are invalid", event.GUID)
+		return &event, nil
+	}
+
 	randomBytes := make([]byte, 16)
 	_, err := rand.Read(randomBytes)
 	if err != nil {
@@ -157,6 +242,11 @@ func (h *Handler) HanleRequest(event MediaPackageAssetsEvent) (*MediaPackageAsse
 		return nil, fmt.Errorf("media-package-assets: main.Handler.HandleRequest: convertEndpoint: %w", err)
 	}
 
+	event.CdnEgressEndpoints, err = convertCdnEndpoints(event.EgressEndpoints, event.Cdns, event.CloudFront)
+	if err != nil {
+		return nil, fmt.Errorf("media-package-assets: main.Handler.HandleRequest: %w", err)
+	}
+
 	endpointJson, _ := json.Marshal(event.EgressEndpoints)
 	log.Printf("ENDPOINTS:: %s", endpointJson)
 
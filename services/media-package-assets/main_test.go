@@ -93,6 +93,25 @@ func TestMediaPackageAssets(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
+	t.Run("should not ingest invalid outputs", func(t *testing.T) {
+		event := MediaPackageAssetsEvent{
+			GUID:           "guid",
+			SrcVideo:       "video.mp4",
+			HlsPlaylist:    aws.String("s3://my-bucket/video.m3u8"),
+			WorkflowStatus: "OutputInvalid",
+		}
+
+		mediaPackageVodClientMock := new(MediaPackageVodClientMock)
+		handler := &Handler{
+			MediaPackageVodClient: mediaPackageVodClientMock,
+		}
+
+		res, err := handler.HanleRequest(event)
+		assert.Nil(t, err)
+		assert.Empty(t, res.EgressEndpoints)
+		mediaPackageVodClientMock.AssertNotCalled(t, "CreateAsset", mock.Anything)
+	})
+
 	t.Run("should correctly parse s3Uri without subfolders", func(t *testing.T) {
 		arn, err := buildArnFromUri("s3://my-bucket/video.m3u8")
 		assert.Nil(t, err)
@@ -55,11 +55,15 @@ def parse_video_attributes(track):
     attributes['width'] = parse_number(track.get('Width'))
     attributes['height'] = parse_number(track.get('Height'))
     attributes['framerate'] = parse_number(track.get('FrameRate'))
+    attributes['framerateMode'] = track.get('FrameRate_Mode')
     attributes['scanType'] = track.get('ScanType')
+    attributes['scanOrder'] = track.get('ScanOrder')
     attributes['aspectRatio'] = track.get('DisplayAspectRatio')
 
     attributes['bitDepth'] = parse_number(track.get('BitDepth'))
     attributes['colorSpace'] = '{0} {1}'.format(track.get('ColorSpace'), track.get('ChromaSubsampling'))
+    attributes['hdrFormat'] = track.get('HDR_Format')
+    attributes['transferCharacteristics'] = track.get('transfer_characteristics')
 
     return compact(attributes)
 
@@ -43,11 +43,14 @@ class TestParser(unittest.TestCase):
             'Width': '1920',
             'Height': '1080',
             'FrameRate': '29.970',
+            'FrameRate_Mode': 'CFR',
             'ScanType': 'Progressive',
+            'ScanOrder': 'TFF',
             'DisplayAspectRatio': '1.778',
             'BitDepth': '8',
             'ColorSpace': 'YUV',
-            'ChromaSubsampling': '4:2:0'
+            'ChromaSubsampling': '4:2:0',
+            'transfer_characteristics': 'BT.709'
         }
 
         expected = {
@@ -59,10 +62,13 @@ class TestParser(unittest.TestCase):
             'width': 1920,
             'height': 1080,
             'framerate': 29.97,
+            'framerateMode': 'CFR',
             'scanType': 'Progressive',
+            'scanOrder': 'TFF',
             'aspectRatio': '1.778',
             'bitDepth': 8,
-            'colorSpace': 'YUV 4:2:0'
+            'colorSpace': 'YUV 4:2:0',
+            'transferCharacteristics': 'BT.709'
         }
 
         self.assertEqual(function.parse_video_attributes(track), expected)
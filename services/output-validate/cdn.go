@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+)
+
+// Cdn is a delivery hostname for published assets. CdnHosts lists them as
+// JSON, for example
+//
+//	[{"name":"primary","weight":80,"regions":["NA","EU"]},
+//	 {"name":"secondary","host":"vod.cdn.example.com","weight":20},
+//	 {"name":"origin","host":"bucket.s3.us-east-1.amazonaws.com","origin":true}]
+//
+// An empty Host is the CloudFront distribution of the stack. Weight and
+// Regions are hints for players choosing a CDN, a weight of 0 is only used
+// for failover. Origin marks origin-direct hosts, MediaPackage egress URLs
+// keep the MediaPackage domain on them.
+type Cdn struct {
+	Name    string   `json:"name"`
+	Host    string   `json:"host"`
+	Weight  int      `json:"weight"`
+	Regions []string `json:"regions,omitempty"`
+	Origin  bool     `json:"origin,omitempty"`
+}
+
+// CdnUrls maps a protocol to the URL of its manifest on each CDN, by CDN
+// name.
+type CdnUrls map[string]map[string]string
+
+// getCdns returns the CdnHosts list ordered by weight, with the per-asset
+// cdnWeights of the record applied. It returns nil when CdnHosts is not
+// set, URLs then only use the CloudFront domain.
+func getCdns(cloudFront string, weights map[string]int) ([]*Cdn, error) {
+	config := os.Getenv("CdnHosts")
+	if config == "" {
+		return nil, nil
+	}
+
+	var cdns []*Cdn
+	if err := json.Unmarshal([]byte(config), &cdns); err != nil {
+		return nil, fmt.Errorf("getCdns: json.Unmarshal: %w", err)
+	}
+
+	names := map[string]bool{}
+	for _, cdn := range cdns {
+		if cdn.Name == "" || names[cdn.Name] {
+			return nil, fmt.Errorf("getCdns: CDN names must be unique and not empty: %q", cdn.Name)
+		}
+		names[cdn.Name] = true
+
+		if cdn.Host == "" {
+			cdn.Host = cloudFront
+		}
+		if weight, ok := weights[cdn.Name]; ok {
+			cdn.Weight = weight
+		}
+	}
+
+	sort.SliceStable(cdns, func(i, j int) bool {
+		return cdns[i].Weight > cdns[j].Weight
+	})
+	return cdns, nil
+}
+
+// buildCdnUrls maps each manifest URL of dynamoData to its URL on every
+// CDN, by protocol then CDN name.
+func buildCdnUrls(dynamoData DynamoData) CdnUrls {
+	if len(dynamoData.Cdns) == 0 {
+		return nil
+	}
+
+	manifests := map[string]*string{
+		"hls":      dynamoData.HlsUrl,
+		"dash":     dynamoData.DashUrl,
+		"mss":      dynamoData.MssUrl,
+		"cmafDash": dynamoData.CmafDashUrl,
+		"cmafHls":  dynamoData.CmafHlsUrl,
+	}
+
+	cdnUrls := CdnUrls{}
+	for protocol, manifestUrl := range manifests {
+		if manifestUrl == nil {
+			continue
+		}
+		cdnUrls[protocol] = map[string]string{}
+		for _, cdn := range dynamoData.Cdns {
+			cdnUrls[protocol][cdn.Name] = withHost(*manifestUrl, cdn.Host)
+		}
+	}
+	return cdnUrls
+}
+
+func withHost(u string, host string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	parsed.Host = host
+	return parsed.String()
+}
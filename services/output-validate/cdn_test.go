@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCdnHosts = `[
+	{"name":"secondary","host":"vod.cdn.example.com","weight":20,"regions":["EU"]},
+	{"name":"primary","weight":80},
+	{"name":"origin","host":"bucket.s3.us-east-1.amazonaws.com","origin":true}
+]`
+
+func TestGetCdns(t *testing.T) {
+	t.Run("should return nil without CdnHosts", func(t *testing.T) {
+		cdns, err := getCdns("cloudfront", nil)
+		assert.Nil(t, err)
+		assert.Nil(t, cdns)
+	})
+
+	t.Run("should order CDNs by weight", func(t *testing.T) {
+		t.Setenv("CdnHosts", testCdnHosts)
+
+		cdns, err := getCdns("cloudfront", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []*Cdn{
+			{Name: "primary", Host: "cloudfront", Weight: 80},
+			{Name: "secondary", Host: "vod.cdn.example.com", Weight: 20, Regions: []string{"EU"}},
+			{Name: "origin", Host: "bucket.s3.us-east-1.amazonaws.com", Origin: true},
+		}, cdns)
+	})
+
+	t.Run("should apply per-asset weights", func(t *testing.T) {
+		t.Setenv("CdnHosts", testCdnHosts)
+
+		cdns, err := getCdns("cloudfront", map[string]int{"secondary": 100})
+		assert.Nil(t, err)
+		assert.Equal(t, "secondary", cdns[0].Name)
+		assert.Equal(t, 100, cdns[0].Weight)
+	})
+
+	t.Run("should reject duplicate names", func(t *testing.T) {
+		t.Setenv("CdnHosts", `[{"name":"primary"},{"name":"primary","host":"vod.cdn.example.com"}]`)
+
+		_, err := getCdns("cloudfront", nil)
+		assert.EqualError(t, err, `getCdns: CDN names must be unique and not empty: "primary"`)
+	})
+}
+
+func TestBuildCdnUrls(t *testing.T) {
+	t.Setenv("CdnHosts", testCdnHosts)
+	cdns, _ := getCdns("cloudfront", nil)
+
+	cdnUrls := buildCdnUrls(DynamoData{
+		Cdns:   cdns,
+		HlsUrl: aws.String("https://cloudfront/guid/hls/clip.m3u8"),
+	})
+	assert.Equal(t, CdnUrls{
+		"hls": {
+			"primary":   "https://cloudfront/guid/hls/clip.m3u8",
+			"secondary": "https://vod.cdn.example.com/guid/hls/clip.m3u8",
+			"origin":    "https://bucket.s3.us-east-1.amazonaws.com/guid/hls/clip.m3u8",
+		},
+	}, cdnUrls)
+
+	assert.Nil(t, buildCdnUrls(DynamoData{HlsUrl: aws.String("https://cloudfront/guid/hls/clip.m3u8")}))
+}
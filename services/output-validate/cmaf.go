@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// CmafGroup holds the manifests of one CMAF output group. A group writes an
+// HLS manifest, a DASH manifest or both.
+type CmafGroup struct {
+	Name         string  `json:"name,omitempty"`
+	DashPlaylist *string `json:"dashPlaylist,omitempty"`
+	DashUrl      *string `json:"dashUrl,omitempty"`
+	HlsPlaylist  *string `json:"hlsPlaylist,omitempty"`
+	HlsUrl       *string `json:"hlsUrl,omitempty"`
+}
+
+// resetCmafGroups drops the CMAF manifests loaded with the item, so those of
+// the previous job of a reprocessed asset are not kept as the first of each
+// type nor listed in CmafGroups.
+func resetCmafGroups(dynamoData *DynamoData) {
+	dynamoData.CmafDashPlaylist, dynamoData.CmafDashUrl = nil, nil
+	dynamoData.CmafHlsPlaylist, dynamoData.CmafHlsUrl = nil, nil
+	dynamoData.CmafGroups = nil
+}
+
+// addCmafGroup records the manifests of a CMAF output group by type.
+// CmafDashPlaylist and CmafHlsPlaylist keep the first of each type across
+// groups, every group is listed in CmafGroups. Call resetCmafGroups once
+// before the first group.
+func (h *Handler) addCmafGroup(dynamoData *DynamoData, outputGroupDetail *OutputGroupDetail, customName string) error {
+	group := &CmafGroup{Name: customName}
+
+	for _, playlist := range outputGroupDetail.PlaylistFilePaths {
+		kind := manifestType(*playlist, nil)
+		if kind == "" {
+			body, err := h.getObject(*playlist)
+			if err != nil {
+				return fmt.Errorf("addCmafGroup: %w", err)
+			}
+			kind = manifestType(*playlist, body)
+		}
+
+		url := aws.String(fmt.Sprintf("https://%s/%s", dynamoData.CloudFront, buildUrl(*playlist)))
+		switch {
+		case kind == manifestDash && group.DashPlaylist == nil:
+			group.DashPlaylist, group.DashUrl = playlist, url
+		case kind == manifestHls && group.HlsPlaylist == nil:
+			group.HlsPlaylist, group.HlsUrl = playlist, url
+		default:
+			log.Printf("CMAF playlist %s not recorded, type %q", *playlist, kind)
+		}
+	}
+
+	if group.DashPlaylist == nil && group.HlsPlaylist == nil {
+		log.Printf("CMAF group %q has no HLS or DASH manifest", customName)
+		return nil
+	}
+	dynamoData.CmafGroups = append(dynamoData.CmafGroups, group)
+
+	if dynamoData.CmafDashPlaylist == nil && group.DashPlaylist != nil {
+		dynamoData.CmafDashPlaylist, dynamoData.CmafDashUrl = group.DashPlaylist, group.DashUrl
+	}
+	if dynamoData.CmafHlsPlaylist == nil && group.HlsPlaylist != nil {
+		dynamoData.CmafHlsPlaylist, dynamoData.CmafHlsUrl = group.HlsPlaylist, group.HlsUrl
+	}
+	return nil
+}
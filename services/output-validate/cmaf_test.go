@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddCmafGroup(t *testing.T) {
+	t.Run("should detect manifests by extension in any order", func(t *testing.T) {
+		handler := Handler{S3Client: new(S3ClientMock)}
+		dynamoData := DynamoData{CloudFront: "cloudfront"}
+
+		err := handler.addCmafGroup(&dynamoData, &OutputGroupDetail{
+			PlaylistFilePaths: []*string{
+				aws.String("s3://vod-destination/12345/cmaf/big_bunny.m3u8"),
+				aws.String("s3://vod-destination/12345/cmaf/big_bunny.mpd"),
+			},
+			Type: "CMAF_GROUP",
+		}, "CMAF")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "s3://vod-destination/12345/cmaf/big_bunny.mpd", *dynamoData.CmafDashPlaylist)
+		assert.Equal(t, "https://cloudfront/12345/cmaf/big_bunny.mpd", *dynamoData.CmafDashUrl)
+		assert.Equal(t, "s3://vod-destination/12345/cmaf/big_bunny.m3u8", *dynamoData.CmafHlsPlaylist)
+		assert.Equal(t, "https://cloudfront/12345/cmaf/big_bunny.m3u8", *dynamoData.CmafHlsUrl)
+		assert.Equal(t, "CMAF", dynamoData.CmafGroups[0].Name)
+	})
+
+	t.Run("should support HLS only and DASH only groups", func(t *testing.T) {
+		handler := Handler{S3Client: new(S3ClientMock)}
+		dynamoData := DynamoData{CloudFront: "cloudfront"}
+
+		err := handler.addCmafGroup(&dynamoData, &OutputGroupDetail{
+			PlaylistFilePaths: []*string{aws.String("s3://vod-destination/12345/cmaf/big_bunny.m3u8")},
+		}, "")
+		assert.Nil(t, err)
+		assert.Nil(t, dynamoData.CmafDashPlaylist)
+		assert.Equal(t, "s3://vod-destination/12345/cmaf/big_bunny.m3u8", *dynamoData.CmafHlsPlaylist)
+
+		dynamoData = DynamoData{CloudFront: "cloudfront"}
+		err = handler.addCmafGroup(&dynamoData, &OutputGroupDetail{
+			PlaylistFilePaths: []*string{aws.String("s3://vod-destination/12345/cmaf/big_bunny.mpd")},
+		}, "")
+		assert.Nil(t, err)
+		assert.Nil(t, dynamoData.CmafHlsPlaylist)
+		assert.Equal(t, "s3://vod-destination/12345/cmaf/big_bunny.mpd", *dynamoData.CmafDashPlaylist)
+	})
+
+	t.Run("should detect manifests by content", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		handler := Handler{S3Client: s3ClientMock}
+		dynamoData := DynamoData{CloudFront: "cloudfront"}
+
+		s3ClientMock.On("GetObject", getObjectFor("12345/cmaf/big_bunny_hls")).Return("\xef\xbb\xbf#EXTM3U\n", nil)
+		s3ClientMock.On("GetObject", getObjectFor("12345/cmaf/big_bunny_dash")).Return(`<?xml version="1.0"?><MPD/>`, nil)
+
+		err := handler.addCmafGroup(&dynamoData, &OutputGroupDetail{
+			PlaylistFilePaths: []*string{
+				aws.String("s3://vod-destination/12345/cmaf/big_bunny_hls"),
+				aws.String("s3://vod-destination/12345/cmaf/big_bunny_dash"),
+			},
+		}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "s3://vod-destination/12345/cmaf/big_bunny_dash", *dynamoData.CmafDashPlaylist)
+		assert.Equal(t, "s3://vod-destination/12345/cmaf/big_bunny_hls", *dynamoData.CmafHlsPlaylist)
+	})
+
+	t.Run("should list every group and keep the first as default", func(t *testing.T) {
+		handler := Handler{S3Client: new(S3ClientMock)}
+		dynamoData := DynamoData{CloudFront: "cloudfront"}
+
+		for _, name := range []string{"clear", "encrypted"} {
+			err := handler.addCmafGroup(&dynamoData, &OutputGroupDetail{
+				PlaylistFilePaths: []*string{
+					aws.String("s3://vod-destination/12345/" + name + "/big_bunny.mpd"),
+					aws.String("s3://vod-destination/12345/" + name + "/big_bunny.m3u8"),
+				},
+			}, name)
+			assert.Nil(t, err)
+		}
+
+		assert.Len(t, dynamoData.CmafGroups, 2)
+		assert.Equal(t, "encrypted", dynamoData.CmafGroups[1].Name)
+		assert.Equal(t, "https://cloudfront/12345/encrypted/big_bunny.m3u8", *dynamoData.CmafGroups[1].HlsUrl)
+		assert.Equal(t, "s3://vod-destination/12345/clear/big_bunny.mpd", *dynamoData.CmafDashPlaylist)
+	})
+
+	t.Run("should skip groups without manifests", func(t *testing.T) {
+		handler := Handler{S3Client: new(S3ClientMock)}
+		dynamoData := DynamoData{}
+
+		err := handler.addCmafGroup(&dynamoData, &OutputGroupDetail{}, "")
+
+		assert.Nil(t, err)
+		assert.Empty(t, dynamoData.CmafGroups)
+	})
+
+	t.Run("should not keep the manifests of a previous job after a reset", func(t *testing.T) {
+		handler := Handler{S3Client: new(S3ClientMock)}
+		dynamoData := DynamoData{
+			CloudFront:       "cloudfront",
+			CmafDashPlaylist: aws.String("s3://vod-destination/12345/previous/big_bunny.mpd"),
+			CmafDashUrl:      aws.String("https://cloudfront/12345/previous/big_bunny.mpd"),
+			CmafGroups:       []*CmafGroup{{Name: "Previous"}},
+		}
+
+		resetCmafGroups(&dynamoData)
+		err := handler.addCmafGroup(&dynamoData, &OutputGroupDetail{
+			PlaylistFilePaths: []*string{aws.String("s3://vod-destination/12345/cmaf/big_bunny.m3u8")},
+		}, "CMAF")
+
+		assert.Nil(t, err)
+		assert.Nil(t, dynamoData.CmafDashPlaylist)
+		assert.Nil(t, dynamoData.CmafDashUrl)
+		assert.Equal(t, "s3://vod-destination/12345/cmaf/big_bunny.m3u8", *dynamoData.CmafHlsPlaylist)
+		assert.Len(t, dynamoData.CmafGroups, 1)
+		assert.Equal(t, "CMAF", dynamoData.CmafGroups[0].Name)
+	})
+}
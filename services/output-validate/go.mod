@@ -6,6 +6,7 @@ require (
 	github.com/aws/aws-lambda-go v1.47.0
 	github.com/aws/aws-sdk-go v1.55.6
 	github.com/stretchr/testify v1.7.2
+	manifest v0.0.0
 )
 
 require (
@@ -15,3 +16,5 @@ require (
 	github.com/stretchr/objx v0.1.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace manifest => ../manifest
@@ -79,22 +79,34 @@ type DynamoData struct {
 	EncodeJobId            string                      `json:"encodeJobId"`
 	EncodingOutput         EventDetail                 `json:"encodingOutput"`
 	EndTime                time.Time                   `json:"endTime"`
+	OutputVersion          int                         `json:"outputVersion,omitempty"`
+	OutputErrors           []string                    `json:"outputErrors,omitempty"`
+	QualityReport          *QualityReport              `json:"qualityReport,omitempty"`
+	Renditions             []*Rendition                `json:"renditions,omitempty"`
+	FileOutputs            *FileOutputs                `json:"fileOutputs,omitempty"`
+	PrivateContent         bool                        `json:"privateContent,omitempty"`
+	PlaybackUrl            string                      `json:"playbackUrl,omitempty"`
+	CdnWeights             map[string]int              `json:"cdnWeights,omitempty"`
+	Cdns                   []*Cdn                      `json:"cdns,omitempty"`
+	CdnUrls                CdnUrls                     `json:"cdnUrls,omitempty"`
+	Version                int                         `json:"version,omitempty"`
 
 	// Output
-	HlsPlaylist      *string   `json:"hlsPlaylist"`
-	HlsUrl           *string   `json:"hlsUrl"`
-	DashPlaylist     *string   `json:"dashPlaylist"`
-	DashUrl          *string   `json:"dashUrl"`
-	Mp4Outputs       []*string `json:"mp4Outputs"`
-	Mp4Urls          []*string `json:"mp4Urls"`
-	MssPlaylist      *string   `json:"mssPlaylist"`
-	MssUrl           *string   `json:"mssUrl"`
-	CmafDashPlaylist *string   `json:"cmafDashPlaylist"`
-	CmafDashUrl      *string   `json:"cmafDashUrl"`
-	CmafHlsPlaylist  *string   `json:"cmafHlsPlaylist"`
-	CmafHlsUrl       *string   `json:"cmafHlsUrl"`
-	ThumbNails       []*string `json:"thumbNails"`
-	ThumbNailsUrls   []*string `json:"thumbNailsUrls"`
+	HlsPlaylist      *string      `json:"hlsPlaylist"`
+	HlsUrl           *string      `json:"hlsUrl"`
+	DashPlaylist     *string      `json:"dashPlaylist"`
+	DashUrl          *string      `json:"dashUrl"`
+	Mp4Outputs       []*string    `json:"mp4Outputs"`
+	Mp4Urls          []*string    `json:"mp4Urls"`
+	MssPlaylist      *string      `json:"mssPlaylist"`
+	MssUrl           *string      `json:"mssUrl"`
+	CmafDashPlaylist *string      `json:"cmafDashPlaylist"`
+	CmafDashUrl      *string      `json:"cmafDashUrl"`
+	CmafHlsPlaylist  *string      `json:"cmafHlsPlaylist"`
+	CmafHlsUrl       *string      `json:"cmafHlsUrl"`
+	CmafGroups       []*CmafGroup `json:"cmafGroups,omitempty"`
+	ThumbNails       []*string    `json:"thumbNails"`
+	ThumbNailsUrls   []*string    `json:"thumbNailsUrls"`
 }
 
 type Warning struct {
@@ -112,6 +124,8 @@ type DynamoDBClient interface {
 }
 type S3Client interface {
 	ListObjects(input *s3.ListObjectsInput) (*s3.ListObjectsOutput, error)
+	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+	GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error)
 }
 
 type Handler struct {
@@ -154,6 +168,9 @@ func (h *Handler) HandleRequest(event events.EventBridgeEvent) (*DynamoData, err
 	if err != nil {
 		return nil, fmt.Errorf("output-validate: main.Handler.HandlerRequest: dynamodbattribute.UnmarshalMap %w", err)
 	}
+	if err := h.loadEncodingJob(&dynamoData, data.Item); err != nil {
+		return nil, fmt.Errorf("output-validate: main.Handler.HandleRequest: %w", err)
+	}
 
 	dynamoData.EncodingOutput = eventDetail
 	dynamoData.EndTime = time.Now().UTC()
@@ -163,7 +180,9 @@ func (h *Handler) HandleRequest(event events.EventBridgeEvent) (*DynamoData, err
 		return nil, fmt.Errorf("output-validate: main.Handler.HandleRequest: no output group details found")
 	}
 
-	for _, outputGroupDetail := range eventDetail.OutputGroupDetails {
+	resetFileOutputs(&dynamoData)
+	resetCmafGroups(&dynamoData)
+	for i, outputGroupDetail := range eventDetail.OutputGroupDetails {
 		log.Printf("%s found in outputs", outputGroupDetail.Type)
 
 		switch outputGroupDetail.Type {
@@ -174,41 +193,36 @@ func (h *Handler) HandleRequest(event events.EventBridgeEvent) (*DynamoData, err
 			dynamoData.DashPlaylist = outputGroupDetail.PlaylistFilePaths[0]
 			dynamoData.DashUrl = aws.String(fmt.Sprintf("https://%s/%s", dynamoData.CloudFront, buildUrl(*dynamoData.DashPlaylist)))
 		case "FILE_GROUP":
-			files, urls := []*string{}, []*string{}
-			for _, outputDetail := range outputGroupDetail.OutputDetails {
-				if outputDetail.OutputFilePaths != nil {
-					files = append(files, outputDetail.OutputFilePaths[0])
-					urls = append(urls, aws.String(fmt.Sprintf("https://%s/%s", dynamoData.CloudFront, buildUrl(*outputDetail.OutputFilePaths[0]))))
-				}
-			}
-
-			if len(files) > 0 {
-				if ext := strings.Split(*files[0], "."); ext[len(ext)-1] == "mp4" {
-					dynamoData.Mp4Outputs = files
-					dynamoData.Mp4Urls = urls
-				}
-			}
+			addFileGroup(&dynamoData, outputGroupDetail, getGroupCustomName(dynamoData.EncodingJob, i))
 		case "MS_SMOOTH_GROUP":
 			dynamoData.MssPlaylist = outputGroupDetail.PlaylistFilePaths[0]
 			dynamoData.MssUrl = aws.String(fmt.Sprintf("https://%s/%s", dynamoData.CloudFront, buildUrl(*dynamoData.MssPlaylist)))
 		case "CMAF_GROUP":
-			dynamoData.CmafDashPlaylist = outputGroupDetail.PlaylistFilePaths[0]
-			dynamoData.CmafDashUrl = aws.String(fmt.Sprintf("https://%s/%s", dynamoData.CloudFront, buildUrl(*dynamoData.CmafDashPlaylist)))
-
-			dynamoData.CmafHlsPlaylist = outputGroupDetail.PlaylistFilePaths[1]
-			dynamoData.CmafHlsUrl = aws.String(fmt.Sprintf("https://%s/%s", dynamoData.CloudFront, buildUrl(*dynamoData.CmafHlsPlaylist)))
+			if err := h.addCmafGroup(&dynamoData, outputGroupDetail, getGroupCustomName(dynamoData.EncodingJob, i)); err != nil {
+				return nil, fmt.Errorf("output-validate: main.Handler.HandleRequest: %w", err)
+			}
 		default:
-			return nil, fmt.Errorf("output-validate: main.Handler.HandleRequest: unknown output group type: %s", outputGroupDetail.Type)
+			// Still verified and listed in renditions, only the typed
+			// fields are left empty
+			log.Printf("Unknown output group type %s, skipped", outputGroupDetail.Type)
 		}
 	}
 
+	dynamoData.Cdns, err = getCdns(dynamoData.CloudFront, dynamoData.CdnWeights)
+	if err != nil {
+		return nil, fmt.Errorf("output-validate: main.Handler.HandleRequest: %w", err)
+	}
+	dynamoData.CdnUrls = buildCdnUrls(dynamoData)
+
+	dynamoData.Renditions = buildRenditions(eventDetail, dynamoData)
+
 	if dynamoData.FrameCapture {
 		thumbNails := []*string{}
 		thumbNailsUrls := []*string{}
 
 		thumbNailsData, err := h.S3Client.ListObjects(&s3.ListObjectsInput{
 			Bucket: aws.String(dynamoData.DestBucket),
-			Prefix: aws.String(fmt.Sprintf("%s/thumbnails", dynamoData.GUID)),
+			Prefix: aws.String(getOutputPrefix(dynamoData) + "/thumbnails"),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("output-validate: main.Handler.HandleRequest: s3.ListObjects: %w", err)
@@ -226,12 +240,43 @@ func (h *Handler) HandleRequest(event events.EventBridgeEvent) (*DynamoData, err
 		dynamoData.ThumbNailsUrls = thumbNailsUrls
 	}
 
+	// Only outputs that pass verification complete the asset
+	outputErrors, err := h.verifyOutputs(eventDetail, dynamoData.SrcMediainfo)
+	if err != nil {
+		return nil, fmt.Errorf("output-validate: main.Handler.HandleRequest: %w", err)
+	}
+	// Always assigned, so errors of an earlier check are not carried over
+	dynamoData.OutputErrors = outputErrors
+	if len(outputErrors) > 0 {
+		log.Printf("Outputs of %s are invalid: %s", dynamoData.GUID, strings.Join(outputErrors, "; "))
+		dynamoData.WorkflowStatus = "OutputInvalid"
+	}
+
+	// Low quality is reported, not failed, the asset still plays
+	dynamoData.QualityReport = buildQualityReport(eventDetail)
+	if dynamoData.QualityReport.Flagged {
+		log.Printf("Quality of %s flagged: %s", dynamoData.GUID, dynamoData.QualityReport.Summary)
+	}
+
+	setPrivateContent(&dynamoData)
+
 	return &dynamoData, nil
 }
 
+// buildUrl returns the key of an s3://bucket/key path, which is also its
+// path on the CloudFront distribution.
 func buildUrl(s3Path string) string {
-	s := strings.Split(s3Path, "/")
-	return fmt.Sprintf("%s/%s/%s", s[len(s)-3], s[len(s)-2], s[len(s)-1])
+	s := strings.SplitN(strings.TrimPrefix(s3Path, "s3://"), "/", 2)
+	return s[len(s)-1]
+}
+
+// getOutputPrefix returns the key prefix encode wrote the outputs under.
+// Reprocessed assets write each version to its own prefix.
+func getOutputPrefix(dynamoData DynamoData) string {
+	if dynamoData.OutputVersion > 1 {
+		return fmt.Sprintf("%s/v%d", dynamoData.GUID, dynamoData.OutputVersion)
+	}
+	return dynamoData.GUID
 }
 
 func main() {
@@ -2,10 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/stretchr/testify/assert"
@@ -36,6 +39,42 @@ func (m *S3ClientMock) ListObjects(input *s3.ListObjectsInput) (*s3.ListObjectsO
 	return args.Get(0).(*s3.ListObjectsOutput), args.Error(1)
 }
 
+func (m *S3ClientMock) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.HeadObjectOutput), args.Error(1)
+}
+
+func (m *S3ClientMock) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	// Bodies are given as strings so every call reads them from the start
+	if body, ok := args.Get(0).(string); ok {
+		return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetObjectOutput), args.Error(1)
+}
+
+// stubOutputs makes every output exist, with empty manifests
+func stubOutputs(s3ClientMock *S3ClientMock) {
+	manifests := map[string]string{
+		".m3u8": "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-ENDLIST\n",
+		".mpd":  `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" mediaPresentationDuration="PT0S"><Period></Period></MPD>`,
+		".ism":  `<smil xmlns="http://www.w3.org/2001/SMIL20/Language"></smil>`,
+	}
+	for ext, body := range manifests {
+		ext, body := ext, body
+		s3ClientMock.On("GetObject", mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+			return strings.HasSuffix(*input.Key, ext)
+		})).Return(body, nil)
+	}
+	s3ClientMock.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{ContentLength: aws.Int64(1024)}, nil)
+}
+
 func TestOutputValidate(t *testing.T) {
 	t.Run("should success on parsing CMAF MSS output", func(t *testing.T) {
 		dynamoClientMock := new(DynamoClientMock)
@@ -70,6 +109,7 @@ func TestOutputValidate(t *testing.T) {
 		}
 
 		dynamoClientMock.On("GetItem", mock.Anything).Return(data, nil)
+		stubOutputs(s3ClientMock)
 
 		res, err := handler.HandleRequest(event)
 		assert.Nil(t, err)
@@ -112,6 +152,7 @@ func TestOutputValidate(t *testing.T) {
 		}
 
 		dynamoClientMock.On("GetItem", mock.Anything).Return(data, nil)
+		stubOutputs(s3ClientMock)
 
 		res, err := handler.HandleRequest(event)
 		assert.Nil(t, err)
@@ -121,6 +162,47 @@ func TestOutputValidate(t *testing.T) {
 		assert.Equal(t, *res.DashUrl, "https://cloudfront/12345/dash/dude.mpd")
 	})
 
+	t.Run("should map manifests to every CDN", func(t *testing.T) {
+		t.Setenv("CdnHosts", `[{"name":"primary","weight":80},{"name":"secondary","host":"vod.cdn.example.com","weight":20}]`)
+		dynamoClientMock := new(DynamoClientMock)
+		s3ClientMock := new(S3ClientMock)
+
+		handler := Handler{
+			DynamoDBClient: dynamoClientMock,
+			S3Client:       s3ClientMock,
+		}
+
+		hlsBytes, _ := json.Marshal(HlsDash)
+
+		event := events.CloudWatchEvent{
+			Detail: hlsBytes,
+		}
+
+		data := &dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"guid":       {S: aws.String("guid")},
+				"cloudFront": {S: aws.String("cloudfront")},
+				"destBucket": {S: aws.String("vod-destination")},
+				"cdnWeights": {M: map[string]*dynamodb.AttributeValue{
+					"secondary": {N: aws.String("90")},
+				}},
+			},
+		}
+
+		dynamoClientMock.On("GetItem", mock.Anything).Return(data, nil)
+		stubOutputs(s3ClientMock)
+
+		res, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+		assert.Equal(t, "secondary", res.Cdns[0].Name)
+		assert.Equal(t, "cloudfront", res.Cdns[1].Host)
+		assert.Equal(t, map[string]string{
+			"primary":   "https://cloudfront/12345/hls/dude.m3u8",
+			"secondary": "https://vod.cdn.example.com/12345/hls/dude.m3u8",
+		}, res.CdnUrls["hls"])
+		assert.Equal(t, "https://vod.cdn.example.com/12345/dash/dude.mpd", res.CdnUrls["dash"]["secondary"])
+	})
+
 	t.Run("should success on parsing MP4 output", func(t *testing.T) {
 		dynamoClientMock := new(DynamoClientMock)
 		s3ClientMock := new(S3ClientMock)
@@ -152,10 +234,15 @@ func TestOutputValidate(t *testing.T) {
 		}
 
 		dynamoClientMock.On("GetItem", mock.Anything).Return(data, nil)
+		stubOutputs(s3ClientMock)
 		res, err := handler.HandleRequest(event)
 		assert.Nil(t, err)
 		assert.Equal(t, *res.Mp4Outputs[0], "s3://vod-destination/12345/mp4/dude_3.0Mbps.mp4")
 		assert.Equal(t, *res.Mp4Urls[0], "https://cloudfront/12345/mp4/dude_3.0Mbps.mp4")
+		assert.Equal(t, "s3://vod-destination/12345/mp4/dude_3.0Mbps.mp4", res.QualityReport.Renditions[0].Output)
+		assert.False(t, res.QualityReport.Flagged)
+		assert.Equal(t, int64(720), res.Renditions[0].HeightInPx)
+		assert.Equal(t, "https://cloudfront/12345/mp4/dude_3.0Mbps.mp4", res.Renditions[0].Url)
 	})
 
 	t.Run("should fail when DynamoDB GetItem failed", func(t *testing.T) {
@@ -178,6 +265,52 @@ func TestOutputValidate(t *testing.T) {
 		assert.Error(t, err, assert.AnError)
 	})
 
+	t.Run("should skip unknown output group types", func(t *testing.T) {
+		dynamoClientMock := new(DynamoClientMock)
+		s3ClientMock := new(S3ClientMock)
+
+		handler := Handler{
+			DynamoDBClient: dynamoClientMock,
+			S3Client:       s3ClientMock,
+		}
+
+		unknownEventDetail := Mp4
+		unknownEventDetail.OutputGroupDetails = append([]*OutputGroupDetail{
+			{
+				OutputDetails: []*OutputDetail{
+					{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/new/dude.bin")}},
+				},
+				Type: "NEW_GROUP",
+			},
+		}, Mp4.OutputGroupDetails...)
+		unknownEventBytes, _ := json.Marshal(unknownEventDetail)
+		event := events.CloudWatchEvent{
+			Detail: unknownEventBytes,
+		}
+		data := &dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"guid": {
+					S: aws.String("guid"),
+				},
+				"cloudFront": {
+					S: aws.String("cloudfront"),
+				},
+				"destBucket": {
+					S: aws.String("vod-destination"),
+				},
+			},
+		}
+
+		dynamoClientMock.On("GetItem", mock.Anything).Return(data, nil)
+		stubOutputs(s3ClientMock)
+
+		res, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+		assert.Equal(t, "Complete", res.WorkflowStatus)
+		assert.Equal(t, "s3://vod-destination/12345/mp4/dude_3.0Mbps.mp4", *res.Mp4Outputs[0])
+		assert.Equal(t, "s3://vod-destination/12345/new/dude.bin", res.Renditions[0].File)
+	})
+
 	t.Run("should fail when output parse fails", func(t *testing.T) {
 		dynamoClientMock := new(DynamoClientMock)
 		s3ClientMock := new(S3ClientMock)
@@ -250,6 +383,7 @@ func TestOutputValidate(t *testing.T) {
 		}
 
 		dynamoClientMock.On("GetItem", mock.Anything).Return(data, nil)
+		stubOutputs(s3ClientMock)
 		s3ClientMock.On("ListObjects", mock.Anything).Return(imageData, nil)
 
 		res, err := handler.HandleRequest(event)
@@ -258,4 +392,90 @@ func TestOutputValidate(t *testing.T) {
 		assert.Equal(t, *res.ThumbNailsUrls[0], "https://cloudfront/12345/thumbnails/dude3.000.jpg")
 	})
 
+	t.Run("should mark outputs that fail verification as invalid", func(t *testing.T) {
+		dynamoClientMock := new(DynamoClientMock)
+		s3ClientMock := new(S3ClientMock)
+
+		handler := Handler{
+			DynamoDBClient: dynamoClientMock,
+			S3Client:       s3ClientMock,
+		}
+
+		mp4EventBytes, _ := json.Marshal(Mp4)
+		event := events.CloudWatchEvent{
+			Detail: mp4EventBytes,
+		}
+
+		data := &dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"guid": {
+					S: aws.String("guid"),
+				},
+				"cloudFront": {
+					S: aws.String("cloudfront"),
+				},
+				"srcMediainfo": {
+					S: aws.String(`{"container": {"duration": 13.5}}`),
+				},
+			},
+		}
+
+		dynamoClientMock.On("GetItem", mock.Anything).Return(data, nil)
+		s3ClientMock.On("HeadObject", mock.Anything).Return(nil, awserr.New("NotFound", "Not Found", nil))
+
+		res, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+		assert.Equal(t, "OutputInvalid", res.WorkflowStatus)
+		assert.Equal(t, []string{"s3://vod-destination/12345/mp4/dude_3.0Mbps.mp4: not found"}, res.OutputErrors)
+		assert.Equal(t, "https://cloudfront/12345/mp4/dude_3.0Mbps.mp4", *res.Mp4Urls[0])
+	})
+
+	t.Run("should complete outputs that pass verification", func(t *testing.T) {
+		dynamoClientMock := new(DynamoClientMock)
+		s3ClientMock := new(S3ClientMock)
+
+		handler := Handler{
+			DynamoDBClient: dynamoClientMock,
+			S3Client:       s3ClientMock,
+		}
+
+		mp4EventBytes, _ := json.Marshal(Mp4)
+		event := events.CloudWatchEvent{
+			Detail: mp4EventBytes,
+		}
+
+		dynamoClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"guid": {
+					S: aws.String("guid"),
+				},
+				"outputErrors": {
+					L: []*dynamodb.AttributeValue{{S: aws.String("stale error")}},
+				},
+				"mp4Outputs": {
+					L: []*dynamodb.AttributeValue{{S: aws.String("s3://vod-destination/previous/mp4/dude.mp4")}},
+				},
+			},
+		}, nil)
+		stubOutputs(s3ClientMock)
+
+		res, err := handler.HandleRequest(event)
+		assert.Nil(t, err)
+		assert.Equal(t, "Complete", res.WorkflowStatus)
+		assert.Empty(t, res.OutputErrors)
+		assert.NotContains(t, aws.StringValueSlice(res.Mp4Outputs), "s3://vod-destination/previous/mp4/dude.mp4")
+		assert.Len(t, res.Mp4Outputs, len(res.FileOutputs.Mp4))
+	})
+
+}
+
+func TestBuildUrl(t *testing.T) {
+	assert.Equal(t, "12345/hls/dude.m3u8", buildUrl("s3://vod-destination/12345/hls/dude.m3u8"))
+	assert.Equal(t, "12345/v2/hls/dude.m3u8", buildUrl("s3://vod-destination/12345/v2/hls/dude.m3u8"))
+}
+
+func TestGetOutputPrefix(t *testing.T) {
+	assert.Equal(t, "guid", getOutputPrefix(DynamoData{GUID: "guid"}))
+	assert.Equal(t, "guid", getOutputPrefix(DynamoData{GUID: "guid", OutputVersion: 1}))
+	assert.Equal(t, "guid/v3", getOutputPrefix(DynamoData{GUID: "guid", OutputVersion: 3}))
 }
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"manifest"
+)
+
+// Manifest lists the objects a playlist references, resolved to s3:// paths.
+type Manifest struct {
+	// Playlists are referenced manifests, such as HLS variant playlists
+	Playlists []string
+	// Segments are media files, including initialization segments
+	Segments []string
+	// Duration in seconds, 0 when the manifest does not declare one
+	Duration float64
+	// Problems are the spec rules the manifest breaks
+	Problems []string
+}
+
+// Manifest types
+const (
+	manifestHls  = "hls"
+	manifestDash = "dash"
+	manifestMss  = "mss"
+)
+
+// manifestType returns the type of the manifest at s3Path from its
+// extension, or from its content when the extension is not a manifest one.
+// It returns an empty type for files that are not manifests.
+func manifestType(s3Path string, body []byte) string {
+	switch strings.ToLower(path.Ext(s3Path)) {
+	case ".m3u8":
+		return manifestHls
+	case ".mpd":
+		return manifestDash
+	case ".ism":
+		return manifestMss
+	}
+
+	body = bytes.TrimPrefix(bytes.TrimSpace(body), []byte("\xef\xbb\xbf"))
+	switch {
+	case bytes.HasPrefix(body, []byte("#EXTM3U")):
+		return manifestHls
+	case bytes.Contains(body, []byte("<MPD")):
+		return manifestDash
+	}
+	return ""
+}
+
+// parseManifest parses the manifest at s3Path by its type. It returns nil
+// for files that are not manifests.
+func parseManifest(s3Path string, body []byte) (*Manifest, error) {
+	switch manifestType(s3Path, body) {
+	case manifestHls:
+		return parseHls(s3Path, body)
+	case manifestDash:
+		return parseDash(s3Path, body)
+	case manifestMss:
+		return parseMss(s3Path, body)
+	}
+	return nil, nil
+}
+
+// resolve returns the s3:// path of ref relative to the directory dir.
+func resolve(dir string, ref string) string {
+	ref = strings.SplitN(ref, "?", 2)[0]
+	if strings.Contains(ref, "://") {
+		return ref
+	}
+	return "s3://" + path.Join(strings.TrimPrefix(dir, "s3://"), ref)
+}
+
+func resolveAll(dir string, refs []string) []string {
+	resolved := []string{}
+	for _, ref := range refs {
+		resolved = append(resolved, resolve(dir, ref))
+	}
+	return unique(resolved)
+}
+
+func dir(s3Path string) string {
+	return "s3://" + path.Dir(strings.TrimPrefix(s3Path, "s3://"))
+}
+
+// validationProblems returns the spec rules a parsed manifest breaks.
+func validationProblems(err error) ([]string, error) {
+	if err == nil {
+		return nil, nil
+	}
+	var validationError *manifest.ValidationError
+	if errors.As(err, &validationError) {
+		return validationError.Problems, nil
+	}
+	return nil, err
+}
+
+// parseHls reads a master or media playlist. Variant and rendition
+// playlists are returned as playlists, everything else as segments.
+func parseHls(s3Path string, body []byte) (*Manifest, error) {
+	playlist, err := manifest.ParseHLS(body)
+	if err != nil {
+		return nil, fmt.Errorf("parseHls: %w", err)
+	}
+
+	problems, err := validationProblems(playlist.Validate())
+	if err != nil {
+		return nil, fmt.Errorf("parseHls: %w", err)
+	}
+
+	base := dir(s3Path)
+	switch playlist := playlist.(type) {
+	case *manifest.MasterPlaylist:
+		return &Manifest{
+			Playlists: resolveAll(base, playlist.PlaylistURIs()),
+			Segments:  []string{},
+			Problems:  problems,
+		}, nil
+	case *manifest.MediaPlaylist:
+		return &Manifest{
+			Segments: resolveAll(base, playlist.SegmentURIs()),
+			Duration: playlist.Duration(),
+			Problems: problems,
+		}, nil
+	}
+	return nil, fmt.Errorf("parseHls: unexpected playlist %T", playlist)
+}
+
+// parseDash reads a static MPD and lists the segments of every
+// representation, expanding segment templates over the period duration.
+func parseDash(s3Path string, body []byte) (*Manifest, error) {
+	mpd, err := manifest.ParseDASH(body)
+	if err != nil {
+		return nil, fmt.Errorf("parseDash: %w", err)
+	}
+
+	problems, err := validationProblems(mpd.Validate())
+	if err != nil {
+		return nil, fmt.Errorf("parseDash: %w", err)
+	}
+
+	duration, err := mpd.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parseDash: %w", err)
+	}
+	segments, err := mpd.SegmentURIs()
+	if err != nil {
+		return nil, fmt.Errorf("parseDash: %w", err)
+	}
+
+	return &Manifest{
+		Segments: resolveAll(dir(s3Path), segments),
+		Duration: duration,
+		Problems: problems,
+	}, nil
+}
+
+type smil struct {
+	Meta []struct {
+		Name    string `xml:"name,attr"`
+		Content string `xml:"content,attr"`
+	} `xml:"head>meta"`
+	Switch struct {
+		Tracks []struct {
+			Src string `xml:"src,attr"`
+		} `xml:",any"`
+	} `xml:"body>switch"`
+}
+
+// parseMss reads a Smooth Streaming server manifest, which references the
+// client manifest and the fragmented track files.
+func parseMss(s3Path string, body []byte) (*Manifest, error) {
+	var document smil
+	if err := xml.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("parseMss: xml.Unmarshal: %w", err)
+	}
+
+	manifest := &Manifest{}
+	base := dir(s3Path)
+
+	for _, meta := range document.Meta {
+		if meta.Name == "clientManifestRelativePath" && meta.Content != "" {
+			manifest.Segments = append(manifest.Segments, resolve(base, meta.Content))
+		}
+	}
+	for _, track := range document.Switch.Tracks {
+		if track.Src != "" {
+			manifest.Segments = append(manifest.Segments, resolve(base, track.Src))
+		}
+	}
+
+	manifest.Segments = unique(manifest.Segments)
+	return manifest, nil
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const TestHlsMaster = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="English",URI="dude_audio.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=3000000,RESOLUTION=1280x720,AUDIO="audio"
+dude_720p.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=300000,URI="dude_720p_iframe.m3u8"
+`
+
+const TestHlsMedia = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:6
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MAP:URI="dude_720p_init.mp4"
+#EXTINF:6.000,
+dude_720p_00001.mp4
+#EXTINF:6.000,
+dude_720p_00002.mp4
+#EXTINF:1.471,
+dude_720p_00003.mp4?v=1
+#EXT-X-ENDLIST
+`
+
+const TestDashTimeline = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT13.471S">
+  <Period id="1" duration="PT13.471S">
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate timescale="90000" media="dude_$RepresentationID$_$Number%09d$.mp4" initialization="dude_$RepresentationID$init.mp4" startNumber="1">
+        <SegmentTimeline>
+          <S t="0" d="540000" r="1"/>
+          <S d="132390"/>
+        </SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="720p" bandwidth="3000000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+const TestDashDuration = `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" mediaPresentationDuration="PT0H0M13.471S">
+  <BaseURL>media/</BaseURL>
+  <Period>
+    <AdaptationSet>
+      <Representation id="audio" bandwidth="96000">
+        <SegmentTemplate timescale="1000" duration="6000" media="$Bandwidth$/$Time$.m4s" initialization="$Bandwidth$/init.m4s"/>
+      </Representation>
+      <Representation id="single" bandwidth="3000000">
+        <BaseURL>dude.mp4</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+const TestMssManifest = `<?xml version="1.0" encoding="utf-8"?>
+<smil xmlns="http://www.w3.org/2001/SMIL20/Language">
+  <head>
+    <meta name="clientManifestRelativePath" content="big_bunny.ismc"/>
+  </head>
+  <body>
+    <switch>
+      <video src="big_bunny_video.ismv" systemBitrate="3000000"/>
+      <audio src="big_bunny_audio.isma" systemBitrate="96000"/>
+    </switch>
+  </body>
+</smil>`
+
+func TestParseHls(t *testing.T) {
+	t.Run("should list variant and rendition playlists of a master playlist", func(t *testing.T) {
+		manifest, err := parseManifest("s3://vod-destination/12345/hls/dude.m3u8", []byte(TestHlsMaster))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"s3://vod-destination/12345/hls/dude_audio.m3u8",
+			"s3://vod-destination/12345/hls/dude_720p.m3u8",
+			"s3://vod-destination/12345/hls/dude_720p_iframe.m3u8",
+		}, manifest.Playlists)
+		assert.Empty(t, manifest.Segments)
+		assert.Equal(t, float64(0), manifest.Duration)
+	})
+
+	t.Run("should list segments and sum durations of a media playlist", func(t *testing.T) {
+		manifest, err := parseManifest("s3://vod-destination/12345/hls/dude_720p.m3u8", []byte(TestHlsMedia))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"s3://vod-destination/12345/hls/dude_720p_init.mp4",
+			"s3://vod-destination/12345/hls/dude_720p_00001.mp4",
+			"s3://vod-destination/12345/hls/dude_720p_00002.mp4",
+			"s3://vod-destination/12345/hls/dude_720p_00003.mp4",
+		}, manifest.Segments)
+		assert.InDelta(t, 13.471, manifest.Duration, 0.0001)
+	})
+
+	t.Run("should report validation problems", func(t *testing.T) {
+		playlist := strings.Replace(TestHlsMedia, "#EXT-X-TARGETDURATION:6", "#EXT-X-TARGETDURATION:5", 1)
+
+		manifest, err := parseManifest("s3://vod-destination/12345/hls/dude_720p.m3u8", []byte(playlist))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"segment 0 (dude_720p_00001.mp4): duration 6.000 exceeds EXT-X-TARGETDURATION 5",
+			"segment 1 (dude_720p_00002.mp4): duration 6.000 exceeds EXT-X-TARGETDURATION 5",
+		}, manifest.Problems)
+	})
+
+	t.Run("should fail without header", func(t *testing.T) {
+		_, err := parseManifest("s3://vod-destination/12345/hls/dude.m3u8", []byte("<html></html>"))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestParseDash(t *testing.T) {
+	t.Run("should expand segment timelines", func(t *testing.T) {
+		manifest, err := parseManifest("s3://vod-destination/12345/dash/dude.mpd", []byte(TestDashTimeline))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"s3://vod-destination/12345/dash/dude_720pinit.mp4",
+			"s3://vod-destination/12345/dash/dude_720p_000000001.mp4",
+			"s3://vod-destination/12345/dash/dude_720p_000000002.mp4",
+			"s3://vod-destination/12345/dash/dude_720p_000000003.mp4",
+		}, manifest.Segments)
+		assert.InDelta(t, 13.471, manifest.Duration, 0.0001)
+	})
+
+	t.Run("should expand segment durations and single file representations", func(t *testing.T) {
+		manifest, err := parseManifest("s3://vod-destination/12345/dash/dude.mpd", []byte(TestDashDuration))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"s3://vod-destination/12345/dash/media/96000/init.m4s",
+			"s3://vod-destination/12345/dash/media/96000/0.m4s",
+			"s3://vod-destination/12345/dash/media/96000/6000.m4s",
+			"s3://vod-destination/12345/dash/media/96000/12000.m4s",
+			"s3://vod-destination/12345/dash/media/dude.mp4",
+		}, manifest.Segments)
+	})
+
+	t.Run("should report validation problems", func(t *testing.T) {
+		manifest, err := parseManifest("s3://vod-destination/12345/dash/dude.mpd", []byte(TestDashDuration))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"Period 0 Representation audio: mimeType is required",
+			"Period 0 Representation audio: $Time$ requires a SegmentTimeline",
+			"Period 0 Representation single: mimeType is required",
+		}, manifest.Problems)
+	})
+
+	t.Run("should fail on invalid XML", func(t *testing.T) {
+		_, err := parseManifest("s3://vod-destination/12345/dash/dude.mpd", []byte("#EXTM3U"))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestParseMss(t *testing.T) {
+	manifest, err := parseManifest("s3://vod-destination/12345/mss/big_bunny.ism", []byte(TestMssManifest))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"s3://vod-destination/12345/mss/big_bunny.ismc",
+		"s3://vod-destination/12345/mss/big_bunny_video.ismv",
+		"s3://vod-destination/12345/mss/big_bunny_audio.isma",
+	}, manifest.Segments)
+}
+
+func TestParseManifestUnknown(t *testing.T) {
+	manifest, err := parseManifest("s3://vod-destination/12345/mp4/dude.mp4", []byte{})
+
+	assert.Nil(t, err)
+	assert.Nil(t, manifest)
+}
+
+func TestManifestType(t *testing.T) {
+	assert.Equal(t, manifestHls, manifestType("s3://vod-destination/12345/hls/dude.M3U8", nil))
+	assert.Equal(t, manifestDash, manifestType("s3://vod-destination/12345/dash/dude.mpd", nil))
+	assert.Equal(t, manifestMss, manifestType("s3://vod-destination/12345/mss/dude.ism", nil))
+	assert.Equal(t, manifestHls, manifestType("s3://vod-destination/12345/cmaf/dude", []byte(TestHlsMedia)))
+	assert.Equal(t, manifestDash, manifestType("s3://vod-destination/12345/cmaf/dude", []byte(TestDashTimeline)))
+	assert.Equal(t, "", manifestType("s3://vod-destination/12345/mp4/dude.mp4", []byte("ftypisom")))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// loadEncodingJob reads the encoding job from S3 when the dynamo service
+// offloaded it, the item then only holds encodingJobPointer.
+func (h *Handler) loadEncodingJob(dynamoData *DynamoData, item map[string]*dynamodb.AttributeValue) error {
+	pointer, ok := item["encodingJobPointer"]
+	if !ok || aws.StringValue(pointer.S) == "" {
+		return nil
+	}
+
+	body, err := h.getObject(*pointer.S)
+	if err != nil {
+		return fmt.Errorf("loadEncodingJob: %w", err)
+	}
+	if body == nil {
+		return fmt.Errorf("loadEncodingJob: %s not found", *pointer.S)
+	}
+	if err := json.Unmarshal(body, &dynamoData.EncodingJob); err != nil {
+		return fmt.Errorf("loadEncodingJob: json.Unmarshal %s: %w", *pointer.S, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEncodingJob(t *testing.T) {
+	t.Run("should keep the inline encoding job", func(t *testing.T) {
+		handler := Handler{S3Client: new(S3ClientMock)}
+		dynamoData := DynamoData{}
+
+		err := handler.loadEncodingJob(&dynamoData, map[string]*dynamodb.AttributeValue{})
+		assert.Nil(t, err)
+	})
+
+	t.Run("should read an offloaded encoding job", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		handler := Handler{S3Client: s3ClientMock}
+		dynamoData := DynamoData{}
+
+		s3ClientMock.On("GetObject", getObjectFor("12345/encodingJob.json")).
+			Return(`{"Queue":"queue","Settings":{"OutputGroups":[{"CustomName":"Captions"}]}}`, nil)
+
+		err := handler.loadEncodingJob(&dynamoData, map[string]*dynamodb.AttributeValue{
+			"encodingJobPointer": {S: aws.String("s3://metadata/12345/encodingJob.json")},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "queue", *dynamoData.EncodingJob.Queue)
+		assert.Equal(t, "Captions", getGroupCustomName(dynamoData.EncodingJob, 0))
+	})
+
+	t.Run("should fail when the offloaded encoding job is missing", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		handler := Handler{S3Client: s3ClientMock}
+		dynamoData := DynamoData{}
+
+		s3ClientMock.On("GetObject", getObjectFor("12345/encodingJob.json")).Return(nil, awserr.New(s3.ErrCodeNoSuchKey, "NoSuchKey", nil))
+
+		err := handler.loadEncodingJob(&dynamoData, map[string]*dynamodb.AttributeValue{
+			"encodingJobPointer": {S: aws.String("s3://metadata/12345/encodingJob.json")},
+		})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+// File output classes
+const (
+	fileClassMp4       = "mp4"
+	fileClassMezzanine = "mezzanine"
+	fileClassAudio     = "audio"
+	fileClassWebm      = "webm"
+	fileClassCaptions  = "captions"
+	fileClassThumbnail = "thumbnail"
+	fileClassOther     = "other"
+)
+
+var fileClassByExtension = map[string]string{
+	".mp4":  fileClassMp4,
+	".mov":  fileClassMezzanine,
+	".mxf":  fileClassMezzanine,
+	".m4a":  fileClassAudio,
+	".mp3":  fileClassAudio,
+	".aac":  fileClassAudio,
+	".wav":  fileClassAudio,
+	".flac": fileClassAudio,
+	".ogg":  fileClassAudio,
+	".webm": fileClassWebm,
+	".srt":  fileClassCaptions,
+	".vtt":  fileClassCaptions,
+	".scc":  fileClassCaptions,
+	".ttml": fileClassCaptions,
+	".dfxp": fileClassCaptions,
+	".smi":  fileClassCaptions,
+	".stl":  fileClassCaptions,
+	".jpg":  fileClassThumbnail,
+	".jpeg": fileClassThumbnail,
+	".png":  fileClassThumbnail,
+}
+
+// fileClassByCustomName matches keywords of the output group CustomName,
+// checked in order
+var fileClassByCustomName = []struct {
+	keyword string
+	class   string
+}{
+	{"frame capture", fileClassThumbnail},
+	{"thumbnail", fileClassThumbnail},
+	{"mezzanine", fileClassMezzanine},
+	{"caption", fileClassCaptions},
+	{"subtitle", fileClassCaptions},
+	{"audio", fileClassAudio},
+}
+
+// FileOutput is one file written by a FILE_GROUP output group.
+type FileOutput struct {
+	File string `json:"file"`
+	Url  string `json:"url"`
+}
+
+// FileOutputs holds the files of FILE_GROUP output groups by class. MP4
+// files are also kept in Mp4Outputs and Mp4Urls, thumbnails are looked up
+// separately when frame capture is enabled.
+type FileOutputs struct {
+	Mp4       []*FileOutput `json:"mp4,omitempty"`
+	Mezzanine []*FileOutput `json:"mezzanine,omitempty"`
+	Audio     []*FileOutput `json:"audio,omitempty"`
+	Webm      []*FileOutput `json:"webm,omitempty"`
+	Captions  []*FileOutput `json:"captions,omitempty"`
+	Other     []*FileOutput `json:"other,omitempty"`
+}
+
+// classifyFile returns the class of a file output. The group CustomName
+// wins over the file extension, a group named "Captions" holds captions
+// whatever container they are written in.
+func classifyFile(customName string, filePath string) string {
+	name := strings.ToLower(customName)
+	for _, match := range fileClassByCustomName {
+		if strings.Contains(name, match.keyword) {
+			return match.class
+		}
+	}
+
+	if class, ok := fileClassByExtension[strings.ToLower(path.Ext(filePath))]; ok {
+		return class
+	}
+	return fileClassOther
+}
+
+// resetFileOutputs drops the file outputs loaded with the item. A reprocessed
+// asset still holds those of the previous job, addFileGroup would append to
+// them.
+func resetFileOutputs(dynamoData *DynamoData) {
+	dynamoData.FileOutputs = nil
+	dynamoData.Mp4Outputs = nil
+	dynamoData.Mp4Urls = nil
+}
+
+// addFileGroup classifies every file of a FILE_GROUP output group into
+// dynamoData.FileOutputs. Call resetFileOutputs once before the first group.
+func addFileGroup(dynamoData *DynamoData, outputGroupDetail *OutputGroupDetail, customName string) {
+	if dynamoData.FileOutputs == nil {
+		dynamoData.FileOutputs = &FileOutputs{}
+	}
+	outputs := dynamoData.FileOutputs
+
+	for _, outputDetail := range outputGroupDetail.OutputDetails {
+		for _, filePath := range outputDetail.OutputFilePaths {
+			file := &FileOutput{
+				File: aws.StringValue(filePath),
+				Url:  fmt.Sprintf("https://%s/%s", dynamoData.CloudFront, buildUrl(aws.StringValue(filePath))),
+			}
+
+			switch classifyFile(customName, file.File) {
+			case fileClassMp4:
+				outputs.Mp4 = append(outputs.Mp4, file)
+				dynamoData.Mp4Outputs = append(dynamoData.Mp4Outputs, aws.String(file.File))
+				dynamoData.Mp4Urls = append(dynamoData.Mp4Urls, aws.String(file.Url))
+			case fileClassMezzanine:
+				outputs.Mezzanine = append(outputs.Mezzanine, file)
+			case fileClassAudio:
+				outputs.Audio = append(outputs.Audio, file)
+			case fileClassWebm:
+				outputs.Webm = append(outputs.Webm, file)
+			case fileClassCaptions:
+				outputs.Captions = append(outputs.Captions, file)
+			case fileClassThumbnail:
+				// Listed by the thumbnails lookup
+			default:
+				outputs.Other = append(outputs.Other, file)
+			}
+		}
+	}
+}
+
+// getGroupCustomName returns the CustomName of output group i in the job
+// settings, empty when the job does not describe it.
+func getGroupCustomName(job mediaconvert.CreateJobInput, i int) string {
+	if job.Settings == nil || i >= len(job.Settings.OutputGroups) {
+		return ""
+	}
+	return aws.StringValue(job.Settings.OutputGroups[i].CustomName)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFile(t *testing.T) {
+	tests := []struct {
+		customName string
+		filePath   string
+		class      string
+	}{
+		{"", "s3://vod-destination/12345/mp4/dude_3.0Mbps.mp4", fileClassMp4},
+		{"", "s3://vod-destination/12345/mezzanine/dude.MOV", fileClassMezzanine},
+		{"", "s3://vod-destination/12345/mezzanine/dude.mxf", fileClassMezzanine},
+		{"", "s3://vod-destination/12345/audio/dude.m4a", fileClassAudio},
+		{"", "s3://vod-destination/12345/audio/dude.mp3", fileClassAudio},
+		{"", "s3://vod-destination/12345/webm/dude.webm", fileClassWebm},
+		{"", "s3://vod-destination/12345/captions/dude.vtt", fileClassCaptions},
+		{"", "s3://vod-destination/12345/thumbnails/dude_tumb.0000001.jpg", fileClassThumbnail},
+		{"", "s3://vod-destination/12345/other/dude.ts", fileClassOther},
+		{"Frame Capture", "s3://vod-destination/12345/thumbnails/dude_tumb", fileClassThumbnail},
+		{"Mezzanine", "s3://vod-destination/12345/mezzanine/dude.mp4", fileClassMezzanine},
+		{"English Captions", "s3://vod-destination/12345/captions/dude.xml", fileClassCaptions},
+		{"Audio Only", "s3://vod-destination/12345/audio/dude.mp4", fileClassAudio},
+		{"Web", "s3://vod-destination/12345/mp4/dude.mp4", fileClassMp4},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.class, classifyFile(test.customName, test.filePath), test.filePath)
+	}
+}
+
+func TestAddFileGroup(t *testing.T) {
+	dynamoData := DynamoData{CloudFront: "cloudfront"}
+	group := &OutputGroupDetail{
+		OutputDetails: []*OutputDetail{
+			{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude_3.0Mbps.mp4")}},
+			{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude.mov")}},
+			{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude.m4a")}},
+			{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude.webm")}},
+			{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude.srt")}},
+			{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude.bin")}},
+			{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude.0000001.jpg")}},
+		},
+		Type: "FILE_GROUP",
+	}
+
+	addFileGroup(&dynamoData, group, "File Group")
+
+	assert.Equal(t, &FileOutputs{
+		Mp4:       []*FileOutput{{File: "s3://vod-destination/12345/mp4/dude_3.0Mbps.mp4", Url: "https://cloudfront/12345/mp4/dude_3.0Mbps.mp4"}},
+		Mezzanine: []*FileOutput{{File: "s3://vod-destination/12345/mp4/dude.mov", Url: "https://cloudfront/12345/mp4/dude.mov"}},
+		Audio:     []*FileOutput{{File: "s3://vod-destination/12345/mp4/dude.m4a", Url: "https://cloudfront/12345/mp4/dude.m4a"}},
+		Webm:      []*FileOutput{{File: "s3://vod-destination/12345/mp4/dude.webm", Url: "https://cloudfront/12345/mp4/dude.webm"}},
+		Captions:  []*FileOutput{{File: "s3://vod-destination/12345/mp4/dude.srt", Url: "https://cloudfront/12345/mp4/dude.srt"}},
+		Other:     []*FileOutput{{File: "s3://vod-destination/12345/mp4/dude.bin", Url: "https://cloudfront/12345/mp4/dude.bin"}},
+	}, dynamoData.FileOutputs)
+	assert.Equal(t, []*string{aws.String("s3://vod-destination/12345/mp4/dude_3.0Mbps.mp4")}, dynamoData.Mp4Outputs)
+	assert.Equal(t, []*string{aws.String("https://cloudfront/12345/mp4/dude_3.0Mbps.mp4")}, dynamoData.Mp4Urls)
+
+	t.Run("should append the files of further groups", func(t *testing.T) {
+		addFileGroup(&dynamoData, &OutputGroupDetail{
+			OutputDetails: []*OutputDetail{
+				{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude_1.0Mbps.mp4")}},
+			},
+			Type: "FILE_GROUP",
+		}, "")
+
+		assert.Len(t, dynamoData.FileOutputs.Mp4, 2)
+		assert.Len(t, dynamoData.Mp4Outputs, 2)
+	})
+
+	t.Run("should not keep the files of a previous job after a reset", func(t *testing.T) {
+		resetFileOutputs(&dynamoData)
+		addFileGroup(&dynamoData, &OutputGroupDetail{
+			OutputDetails: []*OutputDetail{
+				{OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mp4/dude_2.0Mbps.mp4")}},
+			},
+			Type: "FILE_GROUP",
+		}, "")
+
+		assert.Equal(t, &FileOutputs{
+			Mp4: []*FileOutput{{File: "s3://vod-destination/12345/mp4/dude_2.0Mbps.mp4", Url: "https://cloudfront/12345/mp4/dude_2.0Mbps.mp4"}},
+		}, dynamoData.FileOutputs)
+		assert.Equal(t, []*string{aws.String("s3://vod-destination/12345/mp4/dude_2.0Mbps.mp4")}, dynamoData.Mp4Outputs)
+		assert.Equal(t, []*string{aws.String("https://cloudfront/12345/mp4/dude_2.0Mbps.mp4")}, dynamoData.Mp4Urls)
+	})
+}
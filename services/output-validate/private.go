@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// setPrivateContent marks the asset private when PrivateContent is enabled.
+// The distribution then only serves signed requests, so players get their
+// URLs or cookies from PlaybackUrl on the playback-auth API. The plain URLs
+// are still stored, playback-auth signs them.
+func setPrivateContent(dynamoData *DynamoData) {
+	if os.Getenv("PrivateContent") != "true" {
+		return
+	}
+	dynamoData.PrivateContent = true
+	if api := os.Getenv("PlaybackApiUrl"); api != "" {
+		dynamoData.PlaybackUrl = strings.TrimSuffix(api, "/") + "/playback/" + dynamoData.GUID
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetPrivateContent(t *testing.T) {
+	t.Run("should leave public assets unchanged", func(t *testing.T) {
+		dynamoData := DynamoData{GUID: "abc"}
+		setPrivateContent(&dynamoData)
+		assert.False(t, dynamoData.PrivateContent)
+		assert.Empty(t, dynamoData.PlaybackUrl)
+	})
+
+	t.Run("should point private assets at the playback API", func(t *testing.T) {
+		t.Setenv("PrivateContent", "true")
+		t.Setenv("PlaybackApiUrl", "https://api.example.com/prod/")
+
+		dynamoData := DynamoData{GUID: "abc"}
+		setPrivateContent(&dynamoData)
+		assert.True(t, dynamoData.PrivateContent)
+		assert.Equal(t, "https://api.example.com/prod/playback/abc", dynamoData.PlaybackUrl)
+	})
+}
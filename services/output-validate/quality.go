@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultQvbrQualityFloor is the lowest QVBR quality a rendition may score,
+// on the 1 to 10 scale MediaConvert reports
+const defaultQvbrQualityFloor = 6.0
+
+// QualityReport summarises the video quality statistics and warnings
+// MediaConvert reports for a job.
+type QualityReport struct {
+	QvbrQualityFloor float64             `json:"qvbrQualityFloor"`
+	Renditions       []*RenditionQuality `json:"renditions"`
+	// BlackVideoMs and PaddingMs are reported for the whole job
+	BlackVideoMs int64      `json:"blackVideoMs,omitempty"`
+	PaddingMs    int64      `json:"paddingMs,omitempty"`
+	Warnings     []*Warning `json:"warnings,omitempty"`
+	Flagged      bool       `json:"flagged"`
+	Summary      string     `json:"summary"`
+}
+
+// RenditionQuality holds the statistics of one video output. QVBR fields
+// are 0 for outputs not encoded with QVBR.
+type RenditionQuality struct {
+	Output                 string   `json:"output"`
+	GroupType              string   `json:"groupType"`
+	WidthInPx              int64    `json:"widthInPx"`
+	HeightInPx             int64    `json:"heightInPx"`
+	AverageBitrate         float64  `json:"averageBitrate"`
+	QvbrAvgQuality         float64  `json:"qvbrAvgQuality,omitempty"`
+	QvbrMinQuality         float64  `json:"qvbrMinQuality,omitempty"`
+	QvbrMaxQuality         float64  `json:"qvbrMaxQuality,omitempty"`
+	QvbrMinQualityLocation float64  `json:"qvbrMinQualityLocation,omitempty"`
+	QvbrMaxQualityLocation float64  `json:"qvbrMaxQualityLocation,omitempty"`
+	Flags                  []string `json:"flags,omitempty"`
+}
+
+// buildQualityReport collects the video details of every output and flags
+// renditions whose QVBR quality falls below QvbrQualityFloor. Black video
+// and padding are reported per job, so they flag the whole report.
+func buildQualityReport(eventDetail EventDetail) *QualityReport {
+	report := &QualityReport{
+		QvbrQualityFloor: getEnvFloat("QvbrQualityFloor", defaultQvbrQualityFloor),
+		Renditions:       []*RenditionQuality{},
+		BlackVideoMs:     eventDetail.BlackVideoDetected,
+		PaddingMs:        eventDetail.PaddingInserted,
+		Warnings:         eventDetail.Warnings,
+	}
+
+	for _, outputGroupDetail := range eventDetail.OutputGroupDetails {
+		for i, outputDetail := range outputGroupDetail.OutputDetails {
+			video := outputDetail.VideoDetails
+			if video == nil {
+				continue
+			}
+
+			rendition := &RenditionQuality{
+				Output:                 fmt.Sprintf("%s output %d", outputGroupDetail.Type, i),
+				GroupType:              outputGroupDetail.Type,
+				WidthInPx:              video.WidthInPx,
+				HeightInPx:             video.HeightInPx,
+				AverageBitrate:         video.AverageBitrate,
+				QvbrAvgQuality:         video.QvbrAvgQuality,
+				QvbrMinQuality:         video.QvbrMinQuality,
+				QvbrMaxQuality:         video.QvbrMaxQuality,
+				QvbrMinQualityLocation: video.QvbrMinQualityLocation,
+				QvbrMaxQualityLocation: video.QvbrMaxQualityLocation,
+			}
+			if len(outputDetail.OutputFilePaths) > 0 && outputDetail.OutputFilePaths[0] != nil {
+				rendition.Output = *outputDetail.OutputFilePaths[0]
+			}
+
+			if video.QvbrAvgQuality > 0 && video.QvbrAvgQuality < report.QvbrQualityFloor {
+				rendition.Flags = append(rendition.Flags, fmt.Sprintf("average QVBR quality %.2f below floor %.2f", video.QvbrAvgQuality, report.QvbrQualityFloor))
+			}
+			if video.QvbrMinQuality > 0 && video.QvbrMinQuality < report.QvbrQualityFloor {
+				rendition.Flags = append(rendition.Flags, fmt.Sprintf("minimum QVBR quality %.2f below floor %.2f at %.0fms", video.QvbrMinQuality, report.QvbrQualityFloor, video.QvbrMinQualityLocation))
+			}
+
+			report.Renditions = append(report.Renditions, rendition)
+		}
+	}
+
+	report.Summary, report.Flagged = summarise(report)
+	return report
+}
+
+// summarise returns a one line summary of the report for notifications and
+// whether anything in it needs attention.
+func summarise(report *QualityReport) (string, bool) {
+	parts := []string{fmt.Sprintf("%d video renditions", len(report.Renditions))}
+
+	low, high := 0.0, 0.0
+	flagged := 0
+	for _, rendition := range report.Renditions {
+		if rendition.QvbrAvgQuality > 0 {
+			if low == 0 || rendition.QvbrAvgQuality < low {
+				low = rendition.QvbrAvgQuality
+			}
+			if rendition.QvbrAvgQuality > high {
+				high = rendition.QvbrAvgQuality
+			}
+		}
+		if len(rendition.Flags) > 0 {
+			flagged++
+		}
+	}
+	if high > 0 {
+		parts = append(parts, fmt.Sprintf("average QVBR quality %.2f to %.2f", low, high))
+	}
+	if flagged > 0 {
+		parts = append(parts, fmt.Sprintf("%d below QVBR floor %.2f", flagged, report.QvbrQualityFloor))
+	}
+	if report.BlackVideoMs > 0 {
+		parts = append(parts, fmt.Sprintf("%dms black video", report.BlackVideoMs))
+	}
+	if report.PaddingMs > 0 {
+		parts = append(parts, fmt.Sprintf("%dms padding inserted", report.PaddingMs))
+	}
+
+	var warnings int64
+	for _, warning := range report.Warnings {
+		warnings += warning.Count
+	}
+	if warnings > 0 {
+		parts = append(parts, fmt.Sprintf("%d warning(s)", warnings))
+	}
+
+	return strings.Join(parts, ", "), flagged > 0 || report.BlackVideoMs > 0 || report.PaddingMs > 0
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+var TestQvbrEvent = EventDetail{
+	OutputGroupDetails: []*OutputGroupDetail{
+		{
+			OutputDetails: []*OutputDetail{
+				{
+					OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/hls/dude_1080p.m3u8")},
+					VideoDetails: &VideoDetail{
+						WidthInPx:              1920,
+						HeightInPx:             1080,
+						AverageBitrate:         5000000,
+						QvbrAvgQuality:         8.9,
+						QvbrMinQuality:         7.2,
+						QvbrMaxQuality:         9.6,
+						QvbrMinQualityLocation: 2000,
+						QvbrMaxQualityLocation: 11000,
+					},
+				},
+				{
+					OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/hls/dude_360p.m3u8")},
+					VideoDetails: &VideoDetail{
+						WidthInPx:              640,
+						HeightInPx:             360,
+						AverageBitrate:         600000,
+						QvbrAvgQuality:         6.4,
+						QvbrMinQuality:         4.1,
+						QvbrMaxQuality:         7.8,
+						QvbrMinQualityLocation: 6000,
+						QvbrMaxQualityLocation: 1000,
+					},
+				},
+				{
+					// Audio only
+					OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/hls/dude_audio.m3u8")},
+				},
+			},
+			Type: "HLS_GROUP",
+		},
+	},
+}
+
+func TestBuildQualityReport(t *testing.T) {
+	t.Run("should flag renditions below the QVBR floor", func(t *testing.T) {
+		report := buildQualityReport(TestQvbrEvent)
+
+		assert.Equal(t, 6.0, report.QvbrQualityFloor)
+		assert.Len(t, report.Renditions, 2)
+		assert.Equal(t, "s3://vod-destination/12345/hls/dude_1080p.m3u8", report.Renditions[0].Output)
+		assert.Empty(t, report.Renditions[0].Flags)
+		assert.Equal(t, []string{"minimum QVBR quality 4.10 below floor 6.00 at 6000ms"}, report.Renditions[1].Flags)
+		assert.True(t, report.Flagged)
+		assert.Equal(t, "2 video renditions, average QVBR quality 6.40 to 8.90, 1 below QVBR floor 6.00", report.Summary)
+	})
+
+	t.Run("should use the configured floor", func(t *testing.T) {
+		os.Setenv("QvbrQualityFloor", "7")
+		defer os.Unsetenv("QvbrQualityFloor")
+
+		report := buildQualityReport(TestQvbrEvent)
+
+		assert.Equal(t, []string{
+			"average QVBR quality 6.40 below floor 7.00",
+			"minimum QVBR quality 4.10 below floor 7.00 at 6000ms",
+		}, report.Renditions[1].Flags)
+		assert.Equal(t, "2 video renditions, average QVBR quality 6.40 to 8.90, 1 below QVBR floor 7.00", report.Summary)
+	})
+
+	t.Run("should flag black video and padding", func(t *testing.T) {
+		event := Mp4
+		event.BlackVideoDetected = 1200
+		event.PaddingInserted = 500
+		event.Warnings = []*Warning{{Code: 230001, Count: 2}, {Code: 230005, Count: 1}}
+
+		report := buildQualityReport(event)
+
+		assert.Empty(t, report.Renditions[0].Flags)
+		assert.True(t, report.Flagged)
+		assert.Equal(t, "1 video renditions, 1200ms black video, 500ms padding inserted, 3 warning(s)", report.Summary)
+	})
+
+	t.Run("should not flag outputs without QVBR statistics", func(t *testing.T) {
+		report := buildQualityReport(Mp4)
+
+		assert.False(t, report.Flagged)
+		assert.Equal(t, "1 video renditions", report.Summary)
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+// Rendition describes one output of the encoding job.
+type Rendition struct {
+	GroupType string `json:"groupType"`
+	// File is the output file, or the group playlist for groups that do
+	// not report per output files
+	File           string  `json:"file"`
+	Url            string  `json:"url"`
+	WidthInPx      int64   `json:"widthInPx,omitempty"`
+	HeightInPx     int64   `json:"heightInPx,omitempty"`
+	AverageBitrate float64 `json:"averageBitrate,omitempty"`
+	DurationInMs   int64   `json:"durationInMs"`
+	// Codec is the video codec, or the audio codec of audio only outputs
+	Codec string `json:"codec,omitempty"`
+}
+
+// buildRenditions lists every output of the job. MediaConvert reports output
+// groups and outputs in the order of the job settings, which is where the
+// codecs are read from. Frame capture outputs are thumbnails, not renditions.
+func buildRenditions(eventDetail EventDetail, dynamoData DynamoData) []*Rendition {
+	renditions := []*Rendition{}
+
+	for i, outputGroupDetail := range eventDetail.OutputGroupDetails {
+		for j, outputDetail := range outputGroupDetail.OutputDetails {
+			codec := getOutputCodec(dynamoData.EncodingJob, i, j)
+			if codec == mediaconvert.VideoCodecFrameCapture {
+				continue
+			}
+
+			rendition := &Rendition{
+				GroupType:    outputGroupDetail.Type,
+				DurationInMs: outputDetail.DurationInMs,
+				Codec:        codec,
+			}
+			if len(outputDetail.OutputFilePaths) > 0 {
+				rendition.File = aws.StringValue(outputDetail.OutputFilePaths[0])
+			} else if len(outputGroupDetail.PlaylistFilePaths) > 0 {
+				rendition.File = aws.StringValue(outputGroupDetail.PlaylistFilePaths[0])
+			}
+			if rendition.File != "" {
+				rendition.Url = fmt.Sprintf("https://%s/%s", dynamoData.CloudFront, buildUrl(rendition.File))
+			}
+			if video := outputDetail.VideoDetails; video != nil {
+				rendition.WidthInPx = video.WidthInPx
+				rendition.HeightInPx = video.HeightInPx
+				rendition.AverageBitrate = video.AverageBitrate
+			}
+
+			renditions = append(renditions, rendition)
+		}
+	}
+
+	return renditions
+}
+
+// getOutputCodec returns the codec of output j of output group i in the job
+// settings, empty when the job does not describe it.
+func getOutputCodec(job mediaconvert.CreateJobInput, i int, j int) string {
+	if job.Settings == nil || i >= len(job.Settings.OutputGroups) {
+		return ""
+	}
+	outputs := job.Settings.OutputGroups[i].Outputs
+	if j >= len(outputs) {
+		return ""
+	}
+
+	output := outputs[j]
+	if output.VideoDescription != nil && output.VideoDescription.CodecSettings != nil {
+		return aws.StringValue(output.VideoDescription.CodecSettings.Codec)
+	}
+	for _, audio := range output.AudioDescriptions {
+		if audio.CodecSettings != nil {
+			return aws.StringValue(audio.CodecSettings.Codec)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/stretchr/testify/assert"
+)
+
+func videoOutput(codec string) *mediaconvert.Output {
+	return &mediaconvert.Output{
+		VideoDescription: &mediaconvert.VideoDescription{
+			CodecSettings: &mediaconvert.VideoCodecSettings{Codec: aws.String(codec)},
+		},
+	}
+}
+
+func audioOutput(codec string) *mediaconvert.Output {
+	return &mediaconvert.Output{
+		AudioDescriptions: []*mediaconvert.AudioDescription{
+			{CodecSettings: &mediaconvert.AudioCodecSettings{Codec: aws.String(codec)}},
+		},
+	}
+}
+
+func TestBuildRenditions(t *testing.T) {
+	eventDetail := EventDetail{
+		OutputGroupDetails: []*OutputGroupDetail{
+			{
+				OutputDetails: []*OutputDetail{
+					{
+						DurationInMs: 13471,
+						VideoDetails: &VideoDetail{WidthInPx: 1920, HeightInPx: 1080, AverageBitrate: 5000000},
+					},
+					{DurationInMs: 13471},
+				},
+				PlaylistFilePaths: []*string{
+					aws.String("s3://vod-destination/12345/cmaf/big_bunny.mpd"),
+					aws.String("s3://vod-destination/12345/cmaf/big_bunny.m3u8"),
+				},
+				Type: "CMAF_GROUP",
+			},
+			{
+				OutputDetails: []*OutputDetail{
+					{
+						OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/mss/big_bunny.ismv")},
+						DurationInMs:    13471,
+						VideoDetails:    &VideoDetail{WidthInPx: 1280, HeightInPx: 720, AverageBitrate: 3000000},
+					},
+				},
+				PlaylistFilePaths: []*string{aws.String("s3://vod-destination/12345/mss/big_bunny.ism")},
+				Type:              "MS_SMOOTH_GROUP",
+			},
+			{
+				OutputDetails: []*OutputDetail{
+					{
+						OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/thumbnails/big_bunny_tumb.0000002.jpg")},
+						VideoDetails:    &VideoDetail{WidthInPx: 1280, HeightInPx: 720},
+					},
+				},
+				Type: "FILE_GROUP",
+			},
+		},
+	}
+
+	dynamoData := DynamoData{
+		CloudFront: "cloudfront",
+		EncodingJob: mediaconvert.CreateJobInput{
+			Settings: &mediaconvert.JobSettings{
+				OutputGroups: []*mediaconvert.OutputGroup{
+					{Outputs: []*mediaconvert.Output{videoOutput("H_265"), audioOutput("AAC")}},
+					{Outputs: []*mediaconvert.Output{videoOutput("H_264")}},
+					{Outputs: []*mediaconvert.Output{videoOutput("FRAME_CAPTURE")}},
+				},
+			},
+		},
+	}
+
+	t.Run("should list every output with its codec", func(t *testing.T) {
+		renditions := buildRenditions(eventDetail, dynamoData)
+
+		assert.Equal(t, []*Rendition{
+			{
+				GroupType:      "CMAF_GROUP",
+				File:           "s3://vod-destination/12345/cmaf/big_bunny.mpd",
+				Url:            "https://cloudfront/12345/cmaf/big_bunny.mpd",
+				WidthInPx:      1920,
+				HeightInPx:     1080,
+				AverageBitrate: 5000000,
+				DurationInMs:   13471,
+				Codec:          "H_265",
+			},
+			{
+				GroupType:    "CMAF_GROUP",
+				File:         "s3://vod-destination/12345/cmaf/big_bunny.mpd",
+				Url:          "https://cloudfront/12345/cmaf/big_bunny.mpd",
+				DurationInMs: 13471,
+				Codec:        "AAC",
+			},
+			{
+				GroupType:      "MS_SMOOTH_GROUP",
+				File:           "s3://vod-destination/12345/mss/big_bunny.ismv",
+				Url:            "https://cloudfront/12345/mss/big_bunny.ismv",
+				WidthInPx:      1280,
+				HeightInPx:     720,
+				AverageBitrate: 3000000,
+				DurationInMs:   13471,
+				Codec:          "H_264",
+			},
+		}, renditions)
+	})
+
+	t.Run("should leave the codec empty without job settings", func(t *testing.T) {
+		renditions := buildRenditions(eventDetail, DynamoData{CloudFront: "cloudfront"})
+
+		assert.Len(t, renditions, 4)
+		assert.Equal(t, "", renditions[0].Codec)
+		assert.Equal(t, "s3://vod-destination/12345/thumbnails/big_bunny_tumb.0000002.jpg", renditions[3].File)
+	})
+}
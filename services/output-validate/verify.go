@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	defaultDurationTolerance = 1.0
+	defaultVerifyConcurrency = 20
+	// maxOutputErrors caps the reasons stored on the record and notified,
+	// a broken HLS group can miss thousands of segments
+	maxOutputErrors = 50
+)
+
+// verifyOutputs checks every output file, playlist and segment referenced by
+// the job exists in the destination bucket and that output durations match
+// the source within DurationTolerance seconds. It returns one reason per
+// failed check, errors are reserved for S3 failures.
+func (h *Handler) verifyOutputs(eventDetail EventDetail, srcMediainfo string) ([]string, error) {
+	var reasons []string
+	var objects []string
+	seen := map[string]bool{}
+	add := func(s3Path string) {
+		if !seen[s3Path] {
+			seen[s3Path] = true
+			objects = append(objects, s3Path)
+		}
+	}
+
+	srcDuration := getSourceDuration(srcMediainfo)
+	tolerance := getEnvFloat("DurationTolerance", defaultDurationTolerance)
+	checkDuration := func(s3Path string, duration float64) {
+		if srcDuration > 0 && duration > 0 && math.Abs(duration-srcDuration) > tolerance {
+			reasons = append(reasons, fmt.Sprintf("%s: duration %.3fs differs from source %.3fs by more than %gs", s3Path, duration, srcDuration, tolerance))
+		}
+	}
+
+	var playlists []string
+	for _, group := range eventDetail.OutputGroupDetails {
+		for _, outputDetail := range group.OutputDetails {
+			for _, filePath := range outputDetail.OutputFilePaths {
+				add(*filePath)
+				checkDuration(*filePath, float64(outputDetail.DurationInMs)/1000)
+			}
+		}
+		for _, playlistPath := range group.PlaylistFilePaths {
+			add(*playlistPath)
+			playlists = append(playlists, *playlistPath)
+		}
+	}
+
+	parsed := map[string]bool{}
+	for len(playlists) > 0 {
+		playlistPath := playlists[0]
+		playlists = playlists[1:]
+		if parsed[playlistPath] {
+			continue
+		}
+		parsed[playlistPath] = true
+
+		body, err := h.getObject(playlistPath)
+		if err != nil {
+			return nil, fmt.Errorf("verifyOutputs: %w", err)
+		}
+		if body == nil {
+			// Missing, reported with the other objects
+			continue
+		}
+
+		manifest, err := parseManifest(playlistPath, body)
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: invalid manifest: %v", playlistPath, err))
+			continue
+		}
+		if manifest == nil {
+			continue
+		}
+		for _, problem := range manifest.Problems {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", playlistPath, problem))
+		}
+
+		for _, referenced := range manifest.Playlists {
+			add(referenced)
+			playlists = append(playlists, referenced)
+		}
+		for _, segment := range manifest.Segments {
+			add(segment)
+		}
+		checkDuration(playlistPath, manifest.Duration)
+	}
+
+	missing, err := h.headObjects(objects)
+	if err != nil {
+		return nil, fmt.Errorf("verifyOutputs: %w", err)
+	}
+	reasons = append(reasons, missing...)
+
+	if len(reasons) > maxOutputErrors {
+		reasons = append(reasons[:maxOutputErrors], fmt.Sprintf("and %d more", len(reasons)-maxOutputErrors))
+	}
+
+	log.Printf("Verified %d output objects, %d problem(s)", len(objects), len(reasons))
+	return reasons, nil
+}
+
+// headObjects returns a reason for every object that is missing or empty,
+// in the order of s3Paths.
+func (h *Handler) headObjects(s3Paths []string) ([]string, error) {
+	results := make([]string, len(s3Paths))
+	errs := make([]error, len(s3Paths))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, int(getEnvFloat("VerifyConcurrency", defaultVerifyConcurrency)))
+	for i, s3Path := range s3Paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, s3Path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = h.headObject(s3Path)
+		}(i, s3Path)
+	}
+	wg.Wait()
+
+	var reasons []string
+	for i := range s3Paths {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if results[i] != "" {
+			reasons = append(reasons, results[i])
+		}
+	}
+	return reasons, nil
+}
+
+func (h *Handler) headObject(s3Path string) (string, error) {
+	bucket, key := splitS3Path(s3Path)
+	data, err := h.S3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Sprintf("%s: not found", s3Path), nil
+		}
+		return "", fmt.Errorf("headObject: HeadObject %s: %w", s3Path, err)
+	}
+
+	if data.ContentLength != nil && *data.ContentLength == 0 {
+		return fmt.Sprintf("%s: empty", s3Path), nil
+	}
+	return "", nil
+}
+
+// getObject returns the object body, or nil when the object does not exist.
+func (h *Handler) getObject(s3Path string) ([]byte, error) {
+	bucket, key := splitS3Path(s3Path)
+	data, err := h.S3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getObject: GetObject %s: %w", s3Path, err)
+	}
+	defer data.Body.Close()
+
+	body, err := io.ReadAll(data.Body)
+	if err != nil {
+		return nil, fmt.Errorf("getObject: ReadAll %s: %w", s3Path, err)
+	}
+	return body, nil
+}
+
+func splitS3Path(s3Path string) (string, string) {
+	s := strings.SplitN(strings.TrimPrefix(s3Path, "s3://"), "/", 2)
+	if len(s) < 2 {
+		return s[0], ""
+	}
+	return s[0], s[1]
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case "NotFound", s3.ErrCodeNoSuchKey:
+			return true
+		}
+	}
+	return false
+}
+
+// getSourceDuration returns the container duration mediainfo measured in
+// seconds, 0 when unknown.
+func getSourceDuration(srcMediainfo string) float64 {
+	var mediainfo struct {
+		Container struct {
+			Duration float64 `json:"duration"`
+		} `json:"container"`
+	}
+	if err := json.Unmarshal([]byte(srcMediainfo), &mediainfo); err != nil {
+		return 0
+	}
+	return mediainfo.Container.Duration
+}
+
+func getEnvFloat(name string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(name), 64)
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
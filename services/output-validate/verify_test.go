@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getObjectFor(key string) interface{} {
+	return mock.MatchedBy(func(input *s3.GetObjectInput) bool {
+		return *input.Key == key
+	})
+}
+
+func headObjectFor(key string) interface{} {
+	return mock.MatchedBy(func(input *s3.HeadObjectInput) bool {
+		return *input.Key == key
+	})
+}
+
+var TestHlsEvent = EventDetail{
+	OutputGroupDetails: []*OutputGroupDetail{
+		{
+			OutputDetails: []*OutputDetail{
+				{
+					OutputFilePaths: []*string{aws.String("s3://vod-destination/12345/hls/dude_720p.m3u8")},
+					DurationInMs:    13471,
+				},
+			},
+			PlaylistFilePaths: []*string{aws.String("s3://vod-destination/12345/hls/dude.m3u8")},
+			Type:              "HLS_GROUP",
+		},
+	},
+}
+
+const TestSrcMediainfo = `{"container": {"format": "MPEG-4", "duration": 13.5}}`
+
+func TestVerifyOutputs(t *testing.T) {
+	t.Run("should pass when every referenced object exists", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		handler := Handler{S3Client: s3ClientMock}
+
+		s3ClientMock.On("GetObject", getObjectFor("12345/hls/dude.m3u8")).Return("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=3000000\ndude_720p.m3u8\n", nil)
+		s3ClientMock.On("GetObject", getObjectFor("12345/hls/dude_720p.m3u8")).Return(TestHlsMedia, nil)
+		s3ClientMock.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{ContentLength: aws.Int64(1024)}, nil)
+
+		reasons, err := handler.verifyOutputs(TestHlsEvent, TestSrcMediainfo)
+
+		assert.Nil(t, err)
+		assert.Empty(t, reasons)
+		// Playlists and segments are each checked once
+		s3ClientMock.AssertNumberOfCalls(t, "GetObject", 2)
+		s3ClientMock.AssertNumberOfCalls(t, "HeadObject", 6)
+	})
+
+	t.Run("should report missing and empty objects and invalid manifests", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		handler := Handler{S3Client: s3ClientMock}
+
+		s3ClientMock.On("GetObject", getObjectFor("12345/hls/dude.m3u8")).Return("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=3000000\ndude_720p.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=600000\ndude_360p.m3u8\n", nil)
+		s3ClientMock.On("GetObject", getObjectFor("12345/hls/dude_720p.m3u8")).Return(TestHlsMedia, nil)
+		s3ClientMock.On("GetObject", getObjectFor("12345/hls/dude_360p.m3u8")).Return("garbage", nil)
+		s3ClientMock.On("HeadObject", headObjectFor("12345/hls/dude_720p_00002.mp4")).Return(nil, awserr.New("NotFound", "Not Found", nil))
+		s3ClientMock.On("HeadObject", headObjectFor("12345/hls/dude_720p_00003.mp4")).Return(&s3.HeadObjectOutput{ContentLength: aws.Int64(0)}, nil)
+		s3ClientMock.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{ContentLength: aws.Int64(1024)}, nil)
+
+		reasons, err := handler.verifyOutputs(TestHlsEvent, TestSrcMediainfo)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"s3://vod-destination/12345/hls/dude_360p.m3u8: invalid manifest: parseHls: manifest: ParseHLS: missing #EXTM3U header",
+			"s3://vod-destination/12345/hls/dude_720p_00002.mp4: not found",
+			"s3://vod-destination/12345/hls/dude_720p_00003.mp4: empty",
+		}, reasons)
+	})
+
+	t.Run("should report missing playlists once", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		handler := Handler{S3Client: s3ClientMock}
+
+		s3ClientMock.On("GetObject", mock.Anything).Return(nil, awserr.New(s3.ErrCodeNoSuchKey, "NoSuchKey", nil))
+		s3ClientMock.On("HeadObject", headObjectFor("12345/hls/dude.m3u8")).Return(nil, awserr.New("NotFound", "Not Found", nil))
+		s3ClientMock.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
+
+		reasons, err := handler.verifyOutputs(TestHlsEvent, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"s3://vod-destination/12345/hls/dude.m3u8: not found"}, reasons)
+	})
+
+	t.Run("should compare durations against the source", func(t *testing.T) {
+		os.Setenv("DurationTolerance", "0.01")
+		defer os.Unsetenv("DurationTolerance")
+
+		s3ClientMock := new(S3ClientMock)
+		handler := Handler{S3Client: s3ClientMock}
+
+		s3ClientMock.On("GetObject", getObjectFor("12345/hls/dude.m3u8")).Return("#EXTM3U\n#EXT-X-TARGETDURATION:6\n", nil)
+		s3ClientMock.On("HeadObject", mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
+
+		reasons, err := handler.verifyOutputs(TestHlsEvent, TestSrcMediainfo)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"s3://vod-destination/12345/hls/dude_720p.m3u8: duration 13.471s differs from source 13.500s by more than 0.01s",
+		}, reasons)
+	})
+
+	t.Run("should fail on S3 errors", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		handler := Handler{S3Client: s3ClientMock}
+
+		s3ClientMock.On("GetObject", mock.Anything).Return("#EXTM3U\n", nil)
+		s3ClientMock.On("HeadObject", mock.Anything).Return(nil, assert.AnError)
+
+		_, err := handler.verifyOutputs(TestHlsEvent, "")
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestGetSourceDuration(t *testing.T) {
+	assert.Equal(t, 13.5, getSourceDuration(TestSrcMediainfo))
+	assert.Equal(t, float64(0), getSourceDuration(""))
+}
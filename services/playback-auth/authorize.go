@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var (
+	ErrUnauthenticated = errors.New("caller is not authenticated")
+	ErrForbidden       = errors.New("caller is not allowed to play assets")
+)
+
+// authorize checks the caller of a playback request. API Gateway validates
+// the Cognito token of the caller with the user pool authorizer and passes
+// its claims on, a request without claims did not go through the
+// authorizer. When PlaybackGroups is set, a comma separated list of Cognito
+// groups, the caller must be in one of them.
+func authorize(request events.APIGatewayProxyRequest) error {
+	claims, _ := request.RequestContext.Authorizer["claims"].(map[string]interface{})
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return ErrUnauthenticated
+	}
+
+	allowed := os.Getenv("PlaybackGroups")
+	if allowed == "" {
+		return nil
+	}
+
+	groups, _ := claims["cognito:groups"].(string)
+	callerGroups := map[string]bool{}
+	for _, group := range strings.FieldsFunc(strings.Trim(groups, "[]"), func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		callerGroups[group] = true
+	}
+	for _, group := range strings.Split(allowed, ",") {
+		if callerGroups[strings.TrimSpace(group)] {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestWithClaims(claims map[string]interface{}) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"claims": claims},
+		},
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Run("should allow any authenticated caller without PlaybackGroups", func(t *testing.T) {
+		assert.Nil(t, authorize(requestWithClaims(map[string]interface{}{"sub": "user-1"})))
+	})
+
+	t.Run("should reject callers without claims", func(t *testing.T) {
+		assert.Equal(t, ErrUnauthenticated, authorize(events.APIGatewayProxyRequest{}))
+		assert.Equal(t, ErrUnauthenticated, authorize(requestWithClaims(map[string]interface{}{})))
+	})
+
+	t.Run("should match any of the caller groups", func(t *testing.T) {
+		t.Setenv("PlaybackGroups", "subscribers,staff")
+
+		assert.Nil(t, authorize(requestWithClaims(map[string]interface{}{"sub": "user-1", "cognito:groups": "staff"})))
+		assert.Nil(t, authorize(requestWithClaims(map[string]interface{}{"sub": "user-1", "cognito:groups": "[trial subscribers]"})))
+		assert.Nil(t, authorize(requestWithClaims(map[string]interface{}{"sub": "user-1", "cognito:groups": "trial,subscribers"})))
+		assert.Equal(t, ErrForbidden, authorize(requestWithClaims(map[string]interface{}{"sub": "user-1", "cognito:groups": "trial"})))
+		assert.Equal(t, ErrForbidden, authorize(requestWithClaims(map[string]interface{}{"sub": "user-1"})))
+	})
+}
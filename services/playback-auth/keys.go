@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// defaultKeyCacheSeconds is how long keys read from Secrets Manager are
+// reused before the secret is read again, which bounds how long a rotation
+// takes to reach warm Lambdas.
+const defaultKeyCacheSeconds = 300
+
+var ErrNoSigningKey = errors.New("no CloudFront signing key configured")
+
+// KeySecret is the SigningKeySecret value. Every key in ActiveKeyIds must be
+// in the key group of the distribution. The first one signs, the others
+// stay trusted so that URLs and cookies already issued keep working.
+//
+// To rotate, add the new public key to the key group, then add its private
+// key to PrivateKeys and put its id first in ActiveKeyIds. Drop the old key
+// from both once MaxExpirySeconds has passed.
+type KeySecret struct {
+	ActiveKeyIds []string          `json:"activeKeyIds"`
+	PrivateKeys  map[string]string `json:"privateKeys"`
+}
+
+// SigningKey is a CloudFront public key id and its private key.
+type SigningKey struct {
+	KeyID      string
+	PrivateKey *rsa.PrivateKey
+}
+
+// signingKey returns the key to sign with. Keys come from the Secrets
+// Manager secret SigningKeySecret, or from SigningKeyId and
+// SigningPrivateKey when no secret is configured.
+func (h *Handler) signingKey(now time.Time) (*SigningKey, error) {
+	secretId := os.Getenv("SigningKeySecret")
+	if secretId == "" {
+		return loadEnvKey()
+	}
+
+	ttl := time.Duration(getEnvInt("KeyCacheSeconds", defaultKeyCacheSeconds)) * time.Second
+	if h.key != nil && now.Sub(h.keyLoadedAt) < ttl {
+		return h.key, nil
+	}
+
+	data, err := h.SecretsManagerClient.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signingKey: GetSecretValue: %w", err)
+	}
+
+	key, err := parseKeySecret(aws.StringValue(data.SecretString))
+	if err != nil {
+		return nil, fmt.Errorf("signingKey: %w", err)
+	}
+
+	h.key, h.keyLoadedAt = key, now
+	return key, nil
+}
+
+// parseKeySecret returns the first active key of a KeySecret.
+func parseKeySecret(value string) (*SigningKey, error) {
+	var secret KeySecret
+	if err := json.Unmarshal([]byte(value), &secret); err != nil {
+		return nil, fmt.Errorf("parseKeySecret: json.Unmarshal: %w", err)
+	}
+	if len(secret.ActiveKeyIds) == 0 {
+		return nil, fmt.Errorf("parseKeySecret: %w", ErrNoSigningKey)
+	}
+
+	keyID := secret.ActiveKeyIds[0]
+	privateKey, ok := secret.PrivateKeys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("parseKeySecret: no private key for active key %s", keyID)
+	}
+	return parseKey(keyID, privateKey)
+}
+
+func loadEnvKey() (*SigningKey, error) {
+	keyID, privateKey := os.Getenv("SigningKeyId"), os.Getenv("SigningPrivateKey")
+	if keyID == "" || privateKey == "" {
+		return nil, fmt.Errorf("loadEnvKey: %w", ErrNoSigningKey)
+	}
+	return parseKey(keyID, privateKey)
+}
+
+// parseKey reads a PEM private key, PKCS #1 as written by older OpenSSL
+// versions or PKCS #8 as written by OpenSSL 3.
+func parseKey(keyID string, data string) (*SigningKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("parseKey: key %s: no PEM data", keyID)
+	}
+
+	if privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return &SigningKey{KeyID: keyID, PrivateKey: privateKey}, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parseKey: key %s: %w", keyID, err)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("parseKey: key %s: not an RSA key", keyID)
+	}
+	return &SigningKey{KeyID: keyID, PrivateKey: privateKey}, nil
+}
+
+func getEnvInt(name string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
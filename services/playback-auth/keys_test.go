@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testKey = generateKey()
+
+func generateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func pkcs1Pem(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func pkcs8Pem(key *rsa.PrivateKey) string {
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}))
+}
+
+func keySecret(activeKeyIds ...string) *secretsmanager.GetSecretValueOutput {
+	secret := KeySecret{ActiveKeyIds: activeKeyIds, PrivateKeys: map[string]string{}}
+	for _, keyID := range activeKeyIds {
+		secret.PrivateKeys[keyID] = pkcs1Pem(testKey)
+	}
+	data, _ := json.Marshal(secret)
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(string(data))}
+}
+
+func TestParseKey(t *testing.T) {
+	t.Run("should parse PKCS #1 keys", func(t *testing.T) {
+		key, err := parseKey("K1", pkcs1Pem(testKey))
+		assert.Nil(t, err)
+		assert.Equal(t, "K1", key.KeyID)
+		assert.True(t, testKey.Equal(key.PrivateKey))
+	})
+
+	t.Run("should parse PKCS #8 keys", func(t *testing.T) {
+		key, err := parseKey("K1", pkcs8Pem(testKey))
+		assert.Nil(t, err)
+		assert.True(t, testKey.Equal(key.PrivateKey))
+	})
+
+	t.Run("should fail without PEM data", func(t *testing.T) {
+		_, err := parseKey("K1", "not a key")
+		assert.EqualError(t, err, "parseKey: key K1: no PEM data")
+	})
+}
+
+func TestParseKeySecret(t *testing.T) {
+	t.Run("should sign with the first active key", func(t *testing.T) {
+		key, err := parseKeySecret(aws.StringValue(keySecret("K2", "K1").SecretString))
+		assert.Nil(t, err)
+		assert.Equal(t, "K2", key.KeyID)
+	})
+
+	t.Run("should fail without an active key", func(t *testing.T) {
+		_, err := parseKeySecret(`{"activeKeyIds":[],"privateKeys":{}}`)
+		assert.True(t, errors.Is(err, ErrNoSigningKey))
+	})
+
+	t.Run("should fail when the active key has no private key", func(t *testing.T) {
+		_, err := parseKeySecret(`{"activeKeyIds":["K2"],"privateKeys":{"K1":""}}`)
+		assert.EqualError(t, err, "parseKeySecret: no private key for active key K2")
+	})
+}
+
+func TestSigningKey(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should read the key from the environment", func(t *testing.T) {
+		t.Setenv("SigningKeyId", "KENV")
+		t.Setenv("SigningPrivateKey", pkcs1Pem(testKey))
+
+		h := &Handler{}
+		key, err := h.signingKey(now)
+		assert.Nil(t, err)
+		assert.Equal(t, "KENV", key.KeyID)
+	})
+
+	t.Run("should fail without a key", func(t *testing.T) {
+		h := &Handler{}
+		_, err := h.signingKey(now)
+		assert.True(t, errors.Is(err, ErrNoSigningKey))
+	})
+
+	t.Run("should cache the secret until KeyCacheSeconds pass", func(t *testing.T) {
+		t.Setenv("SigningKeySecret", "playback-keys")
+		t.Setenv("KeyCacheSeconds", "60")
+
+		secretsManagerClient := new(SecretsManagerClientMock)
+		secretsManagerClient.On("GetSecretValue", mock.MatchedBy(func(input *secretsmanager.GetSecretValueInput) bool {
+			return *input.SecretId == "playback-keys"
+		})).Return(keySecret("K1"), nil).Once()
+		secretsManagerClient.On("GetSecretValue", mock.Anything).Return(keySecret("K2", "K1"), nil).Once()
+
+		h := &Handler{SecretsManagerClient: secretsManagerClient}
+		key, err := h.signingKey(now)
+		assert.Nil(t, err)
+		assert.Equal(t, "K1", key.KeyID)
+
+		key, err = h.signingKey(now.Add(59 * time.Second))
+		assert.Nil(t, err)
+		assert.Equal(t, "K1", key.KeyID)
+
+		key, err = h.signingKey(now.Add(60 * time.Second))
+		assert.Nil(t, err)
+		assert.Equal(t, "K2", key.KeyID)
+		secretsManagerClient.AssertExpectations(t)
+	})
+}
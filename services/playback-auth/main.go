@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+type DynamoDBClient interface {
+	GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+}
+
+type SecretsManagerClient interface {
+	GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+type Handler struct {
+	DynamoDBClient       DynamoDBClient
+	SecretsManagerClient SecretsManagerClient
+	Now                  func() time.Time
+
+	// Signing key cached across invocations
+	key         *SigningKey
+	keyLoadedAt time.Time
+}
+
+// HandleRequest serves GET /playback/{guid}?mode=url|cookie&policy=canned|custom&expiry=seconds
+// to authorized callers. Signed cookies are returned as Set-Cookie headers,
+// the body lists the asset URLs, signed in url mode.
+func (h *Handler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	log.Printf("REQUEST:: %s %s %v", request.HTTPMethod, request.Path, request.QueryStringParameters)
+
+	if request.HTTPMethod != http.MethodGet {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusMethodNotAllowed,
+			Body:       "Method not allowed",
+		}, nil
+	}
+
+	if err := authorize(request); err != nil {
+		statusCode := http.StatusForbidden
+		if errors.Is(err, ErrUnauthenticated) {
+			statusCode = http.StatusUnauthorized
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	guid := request.PathParameters["guid"]
+	if guid == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "guid is required",
+		}, nil
+	}
+
+	playbackRequest := PlaybackRequest{
+		Mode:   request.QueryStringParameters["mode"],
+		Policy: request.QueryStringParameters["policy"],
+	}
+	if expiry := request.QueryStringParameters["expiry"]; expiry != "" {
+		seconds, err := strconv.Atoi(expiry)
+		if err != nil || seconds <= 0 {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       ErrInvalidExpiry.Error(),
+			}, nil
+		}
+		playbackRequest.Expiry = seconds
+	}
+	if os.Getenv("RestrictToSourceIp") == "true" {
+		playbackRequest.SourceIp = request.RequestContext.Identity.SourceIP
+	}
+
+	response, err := h.Playback(guid, playbackRequest)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+		case errors.Is(err, ErrNotPlayable):
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusConflict,
+				Body:       err.Error(),
+			}, nil
+		case errors.Is(err, ErrInvalidMode), errors.Is(err, ErrInvalidPolicy), errors.Is(err, ErrCannedCookie), errors.Is(err, ErrCannedStream), errors.Is(err, ErrInvalidExpiry):
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       err.Error(),
+			}, nil
+		}
+		log.Printf("playback-auth: main.Handler.HandleRequest: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error authorizing playback",
+		}, nil
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error creating response",
+		}, nil
+	}
+
+	headers := map[string][]string{
+		"Content-Type":  {"application/json"},
+		"Cache-Control": {"no-store"},
+	}
+	for _, cookie := range response.Cookies {
+		headers["Set-Cookie"] = append(headers["Set-Cookie"], cookie.String())
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		MultiValueHeaders: headers,
+		Body:              string(responseBody),
+	}, nil
+}
+
+func main() {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}))
+	handler := &Handler{
+		DynamoDBClient:       dynamodb.New(sess),
+		SecretsManagerClient: secretsmanager.New(sess),
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBClientMock struct {
+	mock.Mock
+}
+
+func (m *DynamoDBClientMock) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
+}
+
+type SecretsManagerClientMock struct {
+	mock.Mock
+}
+
+func (m *SecretsManagerClientMock) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*secretsmanager.GetSecretValueOutput), args.Error(1)
+}
+
+func playbackRequest(guid string, query map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Path:                  "/playback/" + guid,
+		PathParameters:        map[string]string{"guid": guid},
+		QueryStringParameters: query,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{
+				"claims": map[string]interface{}{"sub": "user-1", "cognito:groups": "viewers"},
+			},
+		},
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	t.Setenv("SigningKeyId", "K1")
+	t.Setenv("SigningPrivateKey", pkcs1Pem(testKey))
+
+	t.Run("should return signed URLs", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		response, err := newHandler(dynamoDBClient).HandleRequest(playbackRequest("abc", map[string]string{"mode": "url"}))
+		assert.Nil(t, err)
+		assert.Equal(t, 200, response.StatusCode)
+		assert.Equal(t, []string{"application/json"}, response.MultiValueHeaders["Content-Type"])
+		assert.Empty(t, response.MultiValueHeaders["Set-Cookie"])
+
+		var body PlaybackResponse
+		assert.Nil(t, json.Unmarshal([]byte(response.Body), &body))
+		assert.Equal(t, "abc", body.GUID)
+		assert.Contains(t, *body.Urls.HlsUrl, "Signature=")
+	})
+
+	t.Run("should set signed cookies", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		response, err := newHandler(dynamoDBClient).HandleRequest(playbackRequest("abc", map[string]string{"mode": "cookie"}))
+		assert.Nil(t, err)
+		assert.Equal(t, 200, response.StatusCode)
+		assert.Len(t, response.MultiValueHeaders["Set-Cookie"], 3)
+		assert.Contains(t, response.MultiValueHeaders["Set-Cookie"][0], "Path=/abc/")
+	})
+
+	t.Run("should restrict custom policies to the source IP when enabled", func(t *testing.T) {
+		t.Setenv("RestrictToSourceIp", "true")
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		request := playbackRequest("abc", map[string]string{"mode": "url", "policy": "custom"})
+		request.RequestContext.Identity.SourceIP = "198.51.100.4"
+		response, err := newHandler(dynamoDBClient).HandleRequest(request)
+		assert.Nil(t, err)
+
+		var body PlaybackResponse
+		assert.Nil(t, json.Unmarshal([]byte(response.Body), &body))
+		policy := decodePolicy(t, *body.Urls.HlsUrl)
+		assert.Equal(t, "198.51.100.4/32", policy.Statements[0].Condition.IPAddress.SourceIP)
+	})
+
+	t.Run("should reject other methods", func(t *testing.T) {
+		request := playbackRequest("abc", nil)
+		request.HTTPMethod = "POST"
+		response, err := newHandler(nil).HandleRequest(request)
+		assert.Nil(t, err)
+		assert.Equal(t, 405, response.StatusCode)
+	})
+
+	t.Run("should return 400 for bad requests", func(t *testing.T) {
+		response, _ := newHandler(nil).HandleRequest(playbackRequest("", nil))
+		assert.Equal(t, 400, response.StatusCode)
+
+		response, _ = newHandler(nil).HandleRequest(playbackRequest("abc", map[string]string{"expiry": "soon"}))
+		assert.Equal(t, 400, response.StatusCode)
+
+		response, _ = newHandler(nil).HandleRequest(playbackRequest("abc", map[string]string{"mode": "cookie", "policy": "canned"}))
+		assert.Equal(t, 400, response.StatusCode)
+		assert.Equal(t, ErrCannedCookie.Error(), response.Body)
+	})
+
+	t.Run("should return 400 for canned URLs of streaming outputs", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		response, _ := newHandler(dynamoDBClient).HandleRequest(playbackRequest("abc", map[string]string{"mode": "url", "policy": "canned"}))
+		assert.Equal(t, 400, response.StatusCode)
+		assert.Equal(t, ErrCannedStream.Error(), response.Body)
+	})
+
+	t.Run("should return 401 without authorizer claims", func(t *testing.T) {
+		request := playbackRequest("abc", nil)
+		request.RequestContext.Authorizer = nil
+		response, err := newHandler(nil).HandleRequest(request)
+		assert.Nil(t, err)
+		assert.Equal(t, 401, response.StatusCode)
+	})
+
+	t.Run("should return 403 outside of PlaybackGroups", func(t *testing.T) {
+		t.Setenv("PlaybackGroups", "subscribers, staff")
+		response, err := newHandler(nil).HandleRequest(playbackRequest("abc", nil))
+		assert.Nil(t, err)
+		assert.Equal(t, 403, response.StatusCode)
+	})
+
+	t.Run("should return 404 for unknown assets", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(&dynamodb.GetItemOutput{}, nil)
+
+		response, _ := newHandler(dynamoDBClient).HandleRequest(playbackRequest("abc", nil))
+		assert.Equal(t, 404, response.StatusCode)
+	})
+
+	t.Run("should return 409 for assets that are not Complete", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Error", ""), nil)
+
+		response, _ := newHandler(dynamoDBClient).HandleRequest(playbackRequest("abc", nil))
+		assert.Equal(t, 409, response.StatusCode)
+	})
+
+	t.Run("should return 500 when the lookup fails", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(nil, errors.New("throttled"))
+
+		response, _ := newHandler(dynamoDBClient).HandleRequest(playbackRequest("abc", nil))
+		assert.Equal(t, 500, response.StatusCode)
+	})
+}
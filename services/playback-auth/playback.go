@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	defaultExpirySeconds = 3600
+	defaultMaxExpiry     = 86400
+)
+
+// Playback modes and policies
+const (
+	ModeUrl       = "url"
+	ModeCookie    = "cookie"
+	PolicyCanned  = "canned"
+	PolicyCustom  = "custom"
+	playableState = "Complete"
+)
+
+var (
+	ErrNotFound      = errors.New("asset not found")
+	ErrNotPlayable   = errors.New("asset is not Complete")
+	ErrInvalidMode   = errors.New("mode must be url or cookie")
+	ErrInvalidPolicy = errors.New("policy must be canned or custom")
+	ErrCannedCookie  = errors.New("cookies need a custom policy, a canned policy cannot cover the asset prefix")
+	ErrCannedStream  = errors.New("streaming outputs need cookies or a custom policy, a canned policy only covers the manifest")
+	ErrInvalidExpiry = errors.New("expiry must be a positive number of seconds")
+)
+
+type PlaybackRequest struct {
+	Mode   string `json:"mode"`
+	Policy string `json:"policy"`
+	// Expiry in seconds, capped at MaxExpirySeconds
+	Expiry int `json:"expiry"`
+	// SourceIp restricts custom policies to the viewer address
+	SourceIp string `json:"-"`
+}
+
+// PlaybackUrls mirrors the URL fields of the asset record.
+type PlaybackUrls struct {
+	HlsUrl          *string           `json:"hlsUrl,omitempty"`
+	DashUrl         *string           `json:"dashUrl,omitempty"`
+	MssUrl          *string           `json:"mssUrl,omitempty"`
+	CmafDashUrl     *string           `json:"cmafDashUrl,omitempty"`
+	CmafHlsUrl      *string           `json:"cmafHlsUrl,omitempty"`
+	Mp4Urls         []*string         `json:"mp4Urls,omitempty"`
+	ThumbNailsUrls  []*string         `json:"thumbNailsUrls,omitempty"`
+	EgressEndpoints map[string]string `json:"egressEndpoints,omitempty"`
+	// CdnUrls maps a protocol to its manifest URL by CDN name
+	CdnUrls map[string]map[string]string `json:"cdnUrls,omitempty"`
+}
+
+type AssetRecord struct {
+	GUID           string `json:"guid"`
+	WorkflowStatus string `json:"workflowStatus"`
+	CloudFront     string `json:"cloudFront"`
+	OutputVersion  int    `json:"outputVersion"`
+	PlaybackUrls
+}
+
+type PlaybackResponse struct {
+	GUID    string        `json:"guid"`
+	Mode    string        `json:"mode"`
+	Policy  string        `json:"policy"`
+	Expires time.Time     `json:"expires"`
+	KeyId   string        `json:"keyId"`
+	Urls    *PlaybackUrls `json:"urls"`
+	// Cookies are set on the response, they are not part of the body
+	Cookies []*http.Cookie `json:"-"`
+}
+
+// Playback signs the output URLs of a Complete asset. In url mode every URL
+// is signed, with a canned policy for that URL alone or a custom policy for
+// the asset prefix. In cookie mode the URLs are returned as they are along
+// with custom policy cookies for the asset prefix.
+//
+// Players request the segments of HLS, DASH, MSS and CMAF outputs without
+// the query string of the manifest, so assets with streaming outputs default
+// to cookie mode and cannot use canned URLs. Custom policy URLs still work
+// for players that carry the query string over to segments.
+//
+// MediaPackage egress endpoints are served by MediaPackage, not by the
+// distribution, so they are returned as they are: a CloudFront signature
+// does not protect them. CDN URLs are only signed on CloudFront hosts, the
+// stack distribution and SignedCdnHosts.
+func (h *Handler) Playback(guid string, request PlaybackRequest) (*PlaybackResponse, error) {
+	switch {
+	case request.Mode != "" && request.Mode != ModeUrl && request.Mode != ModeCookie:
+		return nil, ErrInvalidMode
+	case request.Policy != "" && request.Policy != PolicyCanned && request.Policy != PolicyCustom:
+		return nil, ErrInvalidPolicy
+	case request.Mode == ModeCookie && request.Policy == PolicyCanned:
+		return nil, ErrCannedCookie
+	case request.Expiry < 0:
+		return nil, ErrInvalidExpiry
+	}
+
+	now := time.Now().UTC()
+	if h.Now != nil {
+		now = h.Now().UTC()
+	}
+
+	asset, err := h.getAsset(guid)
+	if err != nil {
+		return nil, fmt.Errorf("Playback: %w", err)
+	}
+
+	streaming := hasStreamingOutputs(asset.PlaybackUrls)
+	if request.Mode == "" {
+		request.Mode = ModeUrl
+		if streaming {
+			request.Mode = ModeCookie
+		}
+	}
+	if request.Policy == "" {
+		request.Policy = PolicyCanned
+		if request.Mode == ModeCookie || streaming {
+			request.Policy = PolicyCustom
+		}
+	}
+	if streaming && request.Policy == PolicyCanned {
+		return nil, ErrCannedStream
+	}
+
+	key, err := h.signingKey(now)
+	if err != nil {
+		return nil, fmt.Errorf("Playback: %w", err)
+	}
+
+	expiry := request.Expiry
+	if expiry == 0 {
+		expiry = getEnvInt("ExpirySeconds", defaultExpirySeconds)
+	}
+	if maxExpiry := getEnvInt("MaxExpirySeconds", defaultMaxExpiry); expiry > maxExpiry {
+		expiry = maxExpiry
+	}
+	expires := now.Add(time.Duration(expiry) * time.Second).Truncate(time.Second)
+
+	response := &PlaybackResponse{
+		GUID:    guid,
+		Mode:    request.Mode,
+		Policy:  request.Policy,
+		Expires: expires,
+		KeyId:   key.KeyID,
+		Urls:    &asset.PlaybackUrls,
+	}
+
+	prefix := fmt.Sprintf("https://%s/%s/*", asset.CloudFront, getOutputPrefix(asset))
+	if request.Mode == ModeCookie {
+		signer := sign.NewCookieSigner(key.KeyID, key.PrivateKey)
+		response.Cookies, err = signer.SignWithPolicy(customPolicy(prefix, expires, request.SourceIp), func(o *sign.CookieOptions) {
+			o.Path = "/" + getOutputPrefix(asset) + "/"
+			o.Domain = os.Getenv("CookieDomain")
+			o.Secure = true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Playback: CookieSigner.SignWithPolicy: %w", err)
+		}
+		return response, nil
+	}
+
+	signer := sign.NewURLSigner(key.KeyID, key.PrivateKey)
+	signUrl := func(u string) (string, error) {
+		if request.Policy == PolicyCanned {
+			return signer.Sign(u, expires)
+		}
+		resource := urlDir(u) + "/*"
+		if parsed, err := url.Parse(u); err == nil && strings.HasPrefix(parsed.Path, "/"+getOutputPrefix(asset)+"/") {
+			resource = fmt.Sprintf("https://%s/%s/*", parsed.Host, getOutputPrefix(asset))
+		}
+		return signer.SignWithPolicy(u, customPolicy(resource, expires, request.SourceIp))
+	}
+
+	signed, err := signUrls(asset.PlaybackUrls, getSignedHosts(asset.CloudFront), signUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Playback: %w", err)
+	}
+	response.Urls = signed
+	return response, nil
+}
+
+// hasStreamingOutputs reports whether the asset has segmented outputs, which
+// players fetch through a manifest.
+func hasStreamingOutputs(urls PlaybackUrls) bool {
+	return urls.HlsUrl != nil || urls.DashUrl != nil || urls.MssUrl != nil ||
+		urls.CmafDashUrl != nil || urls.CmafHlsUrl != nil
+}
+
+// getSignedHosts returns the hosts that trust the signing key: the CloudFront
+// domain of the asset and the comma separated SignedCdnHosts, CloudFront
+// distributions of other CDN entries that use the same key group.
+func getSignedHosts(cloudFront string) map[string]bool {
+	hosts := map[string]bool{cloudFront: true}
+	for _, host := range strings.Split(os.Getenv("SignedCdnHosts"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts[host] = true
+		}
+	}
+	return hosts
+}
+
+func (h *Handler) getAsset(guid string) (*AssetRecord, error) {
+	data, err := h.DynamoDBClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String("VIDEO#" + guid)},
+			"SK": {S: aws.String("METADATA")},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getAsset: dynamodb.GetItem: %w", err)
+	}
+	if len(data.Item) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var asset AssetRecord
+	if err := dynamodbattribute.UnmarshalMap(data.Item, &asset); err != nil {
+		return nil, fmt.Errorf("getAsset: dynamodbattribute.UnmarshalMap: %w", err)
+	}
+	if asset.WorkflowStatus != playableState {
+		return nil, ErrNotPlayable
+	}
+	return &asset, nil
+}
+
+// customPolicy allows resource until expires, from sourceIp only when set.
+func customPolicy(resource string, expires time.Time, sourceIp string) *sign.Policy {
+	statement := sign.Statement{
+		Resource: resource,
+		Condition: sign.Condition{
+			DateLessThan: sign.NewAWSEpochTime(expires),
+		},
+	}
+	if sourceIp != "" {
+		statement.Condition.IPAddress = &sign.IPAddress{SourceIP: sourceIp + "/32"}
+	}
+	return &sign.Policy{Statements: []sign.Statement{statement}}
+}
+
+// signUrls returns a copy of urls with the CloudFront URLs signed. Egress
+// endpoints and CDN URLs on other hosts are copied as they are.
+func signUrls(urls PlaybackUrls, signedHosts map[string]bool, signUrl func(string) (string, error)) (*PlaybackUrls, error) {
+	var err error
+	one := func(u *string) *string {
+		if u == nil || err != nil {
+			return u
+		}
+		var signed string
+		if signed, err = signUrl(*u); err != nil {
+			err = fmt.Errorf("signUrls: %s: %w", *u, err)
+		}
+		return aws.String(signed)
+	}
+	list := func(us []*string) []*string {
+		var signed []*string
+		for _, u := range us {
+			signed = append(signed, one(u))
+		}
+		return signed
+	}
+
+	signed := &PlaybackUrls{
+		HlsUrl:          one(urls.HlsUrl),
+		DashUrl:         one(urls.DashUrl),
+		MssUrl:          one(urls.MssUrl),
+		CmafDashUrl:     one(urls.CmafDashUrl),
+		CmafHlsUrl:      one(urls.CmafHlsUrl),
+		Mp4Urls:         list(urls.Mp4Urls),
+		ThumbNailsUrls:  list(urls.ThumbNailsUrls),
+		EgressEndpoints: urls.EgressEndpoints,
+	}
+	if len(urls.CdnUrls) > 0 {
+		signed.CdnUrls = map[string]map[string]string{}
+		for protocol, byCdn := range urls.CdnUrls {
+			signed.CdnUrls[protocol] = map[string]string{}
+			for cdn, u := range byCdn {
+				if parsed, perr := url.Parse(u); perr == nil && signedHosts[parsed.Host] {
+					u = aws.StringValue(one(aws.String(u)))
+				}
+				signed.CdnUrls[protocol][cdn] = u
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// getOutputPrefix returns the key prefix of the current output version,
+// reprocessed assets write each version to its own prefix.
+func getOutputPrefix(asset *AssetRecord) string {
+	if asset.OutputVersion > 1 {
+		return fmt.Sprintf("%s/v%d", asset.GUID, asset.OutputVersion)
+	}
+	return asset.GUID
+}
+
+func urlDir(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	parsed.Path = path.Dir(parsed.Path)
+	parsed.RawQuery = ""
+	return parsed.String()
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func getAssetItem(guid string, status string, version string) *dynamodb.GetItemOutput {
+	prefix := guid
+	if version != "" && version != "1" {
+		prefix = guid + "/v" + version
+	}
+	item := map[string]*dynamodb.AttributeValue{
+		"guid":           {S: aws.String(guid)},
+		"workflowStatus": {S: aws.String(status)},
+		"cloudFront":     {S: aws.String("d123.cloudfront.net")},
+		"hlsUrl":         {S: aws.String("https://d123.cloudfront.net/" + prefix + "/hls/clip.m3u8")},
+		"mp4Urls":        {L: []*dynamodb.AttributeValue{{S: aws.String("https://d123.cloudfront.net/" + prefix + "/mp4/clip.mp4")}}},
+		"egressEndpoints": {M: map[string]*dynamodb.AttributeValue{
+			"HLS": {S: aws.String("https://123.egress.mediapackage-vod.us-east-1.amazonaws.com/out/v1/123/index.m3u8")},
+		}},
+	}
+	if version != "" {
+		item["outputVersion"] = &dynamodb.AttributeValue{N: aws.String(version)}
+	}
+	return &dynamodb.GetItemOutput{Item: item}
+}
+
+func getItemFor(guid string) interface{} {
+	return mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+		return *input.Key["PK"].S == "VIDEO#"+guid && *input.Key["SK"].S == "METADATA"
+	})
+}
+
+func newHandler(dynamoDBClient DynamoDBClient) *Handler {
+	return &Handler{
+		DynamoDBClient: dynamoDBClient,
+		Now:            func() time.Time { return testNow },
+	}
+}
+
+// decodePolicy reads the Policy parameter of a custom policy signed URL.
+func decodePolicy(t *testing.T, signed string) *sign.Policy {
+	parsed, err := url.Parse(signed)
+	assert.Nil(t, err)
+	encoded := strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(parsed.Query().Get("Policy"))
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	assert.Nil(t, err)
+
+	var policy sign.Policy
+	assert.Nil(t, json.Unmarshal(data, &policy))
+	return &policy
+}
+
+func TestPlayback(t *testing.T) {
+	t.Setenv("SigningKeyId", "K1")
+	t.Setenv("SigningPrivateKey", pkcs1Pem(testKey))
+
+	t.Run("should sign file outputs with a canned policy by default", func(t *testing.T) {
+		item := getAssetItem("abc", "Complete", "")
+		delete(item.Item, "hlsUrl")
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(item, nil)
+
+		response, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, ModeUrl, response.Mode)
+		assert.Equal(t, PolicyCanned, response.Policy)
+		assert.Equal(t, "K1", response.KeyId)
+		assert.Equal(t, testNow.Add(time.Hour), response.Expires)
+
+		mp4, err := url.Parse(*response.Urls.Mp4Urls[0])
+		assert.Nil(t, err)
+		assert.Equal(t, "/abc/mp4/clip.mp4", mp4.Path)
+		assert.Equal(t, "1714568400", mp4.Query().Get("Expires"))
+		assert.Equal(t, "K1", mp4.Query().Get("Key-Pair-Id"))
+		assert.NotEmpty(t, mp4.Query().Get("Signature"))
+		assert.Empty(t, response.Cookies)
+	})
+
+	t.Run("should default to cookies for streaming outputs", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		response, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, ModeCookie, response.Mode)
+		assert.Equal(t, PolicyCustom, response.Policy)
+		assert.Len(t, response.Cookies, 3)
+	})
+
+	t.Run("should use a custom policy for streaming outputs in url mode", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		response, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{Mode: ModeUrl})
+		assert.Nil(t, err)
+		assert.Equal(t, PolicyCustom, response.Policy)
+		assert.Equal(t, "https://d123.cloudfront.net/abc/*", decodePolicy(t, *response.Urls.HlsUrl).Statements[0].Resource)
+	})
+
+	t.Run("should reject canned URLs for streaming outputs", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		_, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{Mode: ModeUrl, Policy: PolicyCanned})
+		assert.Equal(t, ErrCannedStream, err)
+	})
+
+	t.Run("should scope custom policies to the output prefix", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", "2"), nil)
+
+		response, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{Mode: ModeUrl, Policy: PolicyCustom, Expiry: 600, SourceIp: "203.0.113.7"})
+		assert.Nil(t, err)
+
+		policy := decodePolicy(t, *response.Urls.HlsUrl)
+		assert.Equal(t, "https://d123.cloudfront.net/abc/v2/*", policy.Statements[0].Resource)
+		assert.Equal(t, testNow.Add(10*time.Minute).Unix(), policy.Statements[0].Condition.DateLessThan.Unix())
+		assert.Equal(t, "203.0.113.7/32", policy.Statements[0].Condition.IPAddress.SourceIP)
+	})
+
+	t.Run("should not sign MediaPackage egress endpoints", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		response, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{Mode: ModeUrl})
+		assert.Nil(t, err)
+		assert.Equal(t, "https://123.egress.mediapackage-vod.us-east-1.amazonaws.com/out/v1/123/index.m3u8", response.Urls.EgressEndpoints["HLS"])
+	})
+
+	t.Run("should sign CDN URLs on CloudFront hosts only", func(t *testing.T) {
+		t.Setenv("SignedCdnHosts", "d456.cloudfront.net")
+		item := getAssetItem("abc", "Complete", "")
+		item.Item["cdnUrls"] = &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+			"hls": {M: map[string]*dynamodb.AttributeValue{
+				"primary":   {S: aws.String("https://d123.cloudfront.net/abc/hls/clip.m3u8")},
+				"secondary": {S: aws.String("https://d456.cloudfront.net/abc/hls/clip.m3u8")},
+				"other":     {S: aws.String("https://vod.cdn.example.com/abc/hls/clip.m3u8")},
+			}},
+		}}
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(item, nil)
+
+		response, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{Mode: ModeUrl})
+		assert.Nil(t, err)
+
+		hls := response.Urls.CdnUrls["hls"]
+		assert.Equal(t, "https://d123.cloudfront.net/abc/*", decodePolicy(t, hls["primary"]).Statements[0].Resource)
+		assert.Equal(t, "https://d456.cloudfront.net/abc/*", decodePolicy(t, hls["secondary"]).Statements[0].Resource)
+		assert.Equal(t, "https://vod.cdn.example.com/abc/hls/clip.m3u8", hls["other"])
+	})
+
+	t.Run("should cap the expiry at MaxExpirySeconds", func(t *testing.T) {
+		t.Setenv("MaxExpirySeconds", "900")
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", ""), nil)
+
+		response, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{Expiry: 7200})
+		assert.Nil(t, err)
+		assert.Equal(t, testNow.Add(15*time.Minute), response.Expires)
+	})
+
+	t.Run("should return cookies for the output prefix", func(t *testing.T) {
+		t.Setenv("CookieDomain", "d123.cloudfront.net")
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Complete", "2"), nil)
+
+		response, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{Mode: ModeCookie})
+		assert.Nil(t, err)
+		assert.Equal(t, PolicyCustom, response.Policy)
+		assert.Equal(t, "https://d123.cloudfront.net/abc/v2/hls/clip.m3u8", *response.Urls.HlsUrl)
+
+		names := map[string]bool{}
+		for _, cookie := range response.Cookies {
+			names[cookie.Name] = true
+			assert.Equal(t, "/abc/v2/", cookie.Path)
+			assert.Equal(t, "d123.cloudfront.net", cookie.Domain)
+			assert.True(t, cookie.Secure)
+		}
+		assert.Equal(t, map[string]bool{
+			sign.CookiePolicyName:    true,
+			sign.CookieSignatureName: true,
+			sign.CookieKeyIDName:     true,
+		}, names)
+	})
+
+	t.Run("should reject canned cookies", func(t *testing.T) {
+		_, err := newHandler(nil).Playback("abc", PlaybackRequest{Mode: ModeCookie, Policy: PolicyCanned})
+		assert.Equal(t, ErrCannedCookie, err)
+	})
+
+	t.Run("should reject unknown modes and policies", func(t *testing.T) {
+		_, err := newHandler(nil).Playback("abc", PlaybackRequest{Mode: "stream"})
+		assert.Equal(t, ErrInvalidMode, err)
+
+		_, err = newHandler(nil).Playback("abc", PlaybackRequest{Policy: "open"})
+		assert.Equal(t, ErrInvalidPolicy, err)
+	})
+
+	t.Run("should fail when the asset is missing", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(&dynamodb.GetItemOutput{}, nil)
+
+		_, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{})
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("should fail when the asset is not Complete", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("GetItem", getItemFor("abc")).Return(getAssetItem("abc", "Ingest", ""), nil)
+
+		_, err := newHandler(dynamoDBClient).Playback("abc", PlaybackRequest{})
+		assert.True(t, errors.Is(err, ErrNotPlayable))
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Accelerated transcoding modes. AccelerationAuto is not a MediaConvert mode,
+// it lets the profiler pick one of the others per asset.
+const (
+	AccelerationEnabled   = "ENABLED"
+	AccelerationPreferred = "PREFERRED"
+	AccelerationDisabled  = "DISABLED"
+	AccelerationAuto      = "AUTO"
+)
+
+// Default thresholds, overridden with the AccelerationMinDuration,
+// AccelerationMinHeight, AccelerationEnabledDuration and AccelerationCodecs
+// env vars. Durations are in seconds.
+const (
+	defaultAccelerationMinDuration     = 600
+	defaultAccelerationMinHeight       = 2160
+	defaultAccelerationEnabledDuration = 1800
+	defaultAccelerationCodecs          = "AVC,HEVC,ProRes,MPEG Video"
+)
+
+// chooseAcceleration picks the accelerated transcoding mode for a source.
+// Acceleration only pays off for long or high resolution jobs:
+//   - sources in a codec outside AccelerationCodecs are DISABLED
+//   - sources shorter than AccelerationMinDuration and below
+//     AccelerationMinHeight are DISABLED
+//   - sources at least AccelerationEnabledDuration long are ENABLED
+//   - everything else is PREFERRED, so MediaConvert falls back to a regular
+//     transcode when a job setting is not supported
+func chooseAcceleration(video Video, duration float64) string {
+	if !containsFold(strings.Split(getEnvString("AccelerationCodecs", defaultAccelerationCodecs), ","), video.Codec) {
+		return AccelerationDisabled
+	}
+
+	minDuration := getEnvInt("AccelerationMinDuration", defaultAccelerationMinDuration)
+	minHeight := getEnvInt("AccelerationMinHeight", defaultAccelerationMinHeight)
+	if duration < float64(minDuration) && video.Height < minHeight {
+		return AccelerationDisabled
+	}
+
+	if duration >= float64(getEnvInt("AccelerationEnabledDuration", defaultAccelerationEnabledDuration)) {
+		return AccelerationEnabled
+	}
+
+	return AccelerationPreferred
+}
+
+func getEnvString(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestChooseAcceleration(t *testing.T) {
+	tests := []struct {
+		name     string
+		video    Video
+		duration float64
+		expected string
+	}{
+		{
+			name:     "should disable short HD sources",
+			video:    Video{Codec: "AVC", Height: 1080},
+			duration: 120,
+			expected: AccelerationDisabled,
+		},
+		{
+			name:     "should prefer short UHD sources",
+			video:    Video{Codec: "HEVC", Height: 2160},
+			duration: 120,
+			expected: AccelerationPreferred,
+		},
+		{
+			name:     "should prefer medium length sources",
+			video:    Video{Codec: "AVC", Height: 720},
+			duration: 900,
+			expected: AccelerationPreferred,
+		},
+		{
+			name:     "should enable long sources",
+			video:    Video{Codec: "ProRes", Height: 1080},
+			duration: 3600,
+			expected: AccelerationEnabled,
+		},
+		{
+			name:     "should disable unsupported codecs",
+			video:    Video{Codec: "VC-1", Height: 2160},
+			duration: 3600,
+			expected: AccelerationDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, chooseAcceleration(tt.video, tt.duration))
+		})
+	}
+
+	t.Run("should read thresholds from env", func(t *testing.T) {
+		os.Setenv("AccelerationMinDuration", "60")
+		os.Setenv("AccelerationEnabledDuration", "100")
+		os.Setenv("AccelerationCodecs", "VC-1")
+		defer os.Unsetenv("AccelerationMinDuration")
+		defer os.Unsetenv("AccelerationEnabledDuration")
+		defer os.Unsetenv("AccelerationCodecs")
+
+		assert.Equal(t, AccelerationEnabled, chooseAcceleration(Video{Codec: "VC-1", Height: 720}, 120))
+		assert.Equal(t, AccelerationDisabled, chooseAcceleration(Video{Codec: "AVC", Height: 720}, 120))
+	})
+
+	t.Run("should only choose the mode in HandleRequest when set to AUTO", func(t *testing.T) {
+		for _, c := range []struct {
+			mode     string
+			expected string
+		}{
+			{mode: AccelerationAuto, expected: AccelerationEnabled},
+			{mode: AccelerationDisabled, expected: AccelerationDisabled},
+		} {
+			dynamoDBClientMock := new(DynamoDBClientMock)
+			dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{
+				Item: map[string]*dynamodb.AttributeValue{
+					"guid":                   {S: aws.String("guid")},
+					"acceleratedTranscoding": {S: aws.String(c.mode)},
+					"jobTemplate_1080p":      {S: aws.String("tmpl2")},
+					"srcMediainfo":           {S: aws.String(`{"container":{"duration":3600},"video":[{"codec":"AVC","width":1920,"height":1080,"framerate":25}]}`)},
+				},
+			}, nil)
+
+			handler := &Handler{DynamoDBClient: dynamoDBClientMock}
+			output, err := handler.HandleRequest(ProfilerInput{GUID: "guid"})
+
+			assert.Nil(t, err)
+			assert.Equal(t, c.expected, output.AcceleratedTranscoding)
+		}
+	})
+
+	t.Run("should keep the acceleration mode pinned by a resubmission", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"guid":                   {S: aws.String("guid")},
+				"acceleratedTranscoding": {S: aws.String(AccelerationAuto)},
+				"srcMediainfo":           {S: aws.String(`{"container":{"duration":3600},"video":[{"codec":"AVC","width":1920,"height":1080,"framerate":25}]}`)},
+			},
+		}, nil)
+
+		handler := &Handler{DynamoDBClient: dynamoDBClientMock}
+		output, err := handler.HandleRequest(ProfilerInput{
+			GUID:                   "guid",
+			JobTemplate:            aws.String("ladder-1080p"),
+			AcceleratedTranscoding: aws.String(AccelerationDisabled),
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, AccelerationDisabled, output.AcceleratedTranscoding)
+		assert.Equal(t, "ladder-1080p", output.JobTemplate)
+	})
+
+	t.Run("should resolve AUTO when it comes from a resubmission", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"guid":                   {S: aws.String("guid")},
+				"acceleratedTranscoding": {S: aws.String(AccelerationDisabled)},
+				"srcMediainfo":           {S: aws.String(`{"container":{"duration":3600},"video":[{"codec":"AVC","width":1920,"height":1080,"framerate":25}]}`)},
+			},
+		}, nil)
+
+		handler := &Handler{DynamoDBClient: dynamoDBClientMock}
+		output, err := handler.HandleRequest(ProfilerInput{
+			GUID:                   "guid",
+			JobTemplate:            aws.String("ladder-1080p"),
+			AcceleratedTranscoding: aws.String(AccelerationAuto),
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, AccelerationEnabled, output.AcceleratedTranscoding)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// Framerate classes assigned to a source. The encode step uses the class to
+// decide which frame rate every video rendition of the ladder is encoded at.
+const (
+	FramerateStandard = "STANDARD"
+	FramerateHigh     = "HIGH"
+	FramerateLow      = "LOW"
+	FramerateVariable = "VARIABLE"
+)
+
+const (
+	highFramerateThreshold = 49.0
+	lowFramerateThreshold  = 23.9
+	framerateTolerance     = 0.05
+)
+
+type Framerate struct {
+	Numerator   int
+	Denominator int
+}
+
+var standardFramerates = []Framerate{
+	{Numerator: 24000, Denominator: 1001},
+	{Numerator: 24, Denominator: 1},
+	{Numerator: 25, Denominator: 1},
+	{Numerator: 30000, Denominator: 1001},
+	{Numerator: 30, Denominator: 1},
+	{Numerator: 50, Denominator: 1},
+	{Numerator: 60000, Denominator: 1001},
+	{Numerator: 60, Denominator: 1},
+}
+
+// defaultFramerate is used for variable frame rate sources when mediainfo
+// could not report an average frame rate.
+var defaultFramerate = Framerate{Numerator: 30, Denominator: 1}
+
+func (f Framerate) Value() float64 {
+	return float64(f.Numerator) / float64(f.Denominator)
+}
+
+func classifyFramerate(video Video) string {
+	switch {
+	case strings.EqualFold(video.FramerateMode, "VFR"):
+		return FramerateVariable
+	case video.Framerate >= highFramerateThreshold:
+		return FramerateHigh
+	case video.Framerate > 0 && video.Framerate < lowFramerateThreshold:
+		return FramerateLow
+	default:
+		return FramerateStandard
+	}
+}
+
+// normalizeFramerate maps a measured frame rate to the rational rate the
+// outputs are encoded at. Rates close to a broadcast rate snap to it, sub-24fps
+// rates (screen recordings) keep their own whole-frame rate so they are never
+// padded with duplicated frames, and anything else goes to the nearest
+// broadcast rate.
+func normalizeFramerate(fps float64) Framerate {
+	if fps <= 0 {
+		return defaultFramerate
+	}
+
+	for _, framerate := range standardFramerates {
+		if math.Abs(framerate.Value()-fps) <= framerateTolerance {
+			return framerate
+		}
+	}
+
+	if fps < lowFramerateThreshold {
+		return Framerate{Numerator: int(math.Max(1, math.Round(fps))), Denominator: 1}
+	}
+
+	nearest := standardFramerates[0]
+	for _, framerate := range standardFramerates[1:] {
+		if math.Abs(framerate.Value()-fps) < math.Abs(nearest.Value()-fps) {
+			nearest = framerate
+		}
+	}
+
+	return nearest
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFramerate(t *testing.T) {
+	t.Run("Classify", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			video    Video
+			expected string
+		}{
+			{
+				name:     "should classify 29.97 fps as standard",
+				video:    Video{Framerate: 29.97},
+				expected: FramerateStandard,
+			},
+			{
+				name:     "should classify 59.94 fps as high",
+				video:    Video{Framerate: 59.94},
+				expected: FramerateHigh,
+			},
+			{
+				name:     "should classify 50 fps as high",
+				video:    Video{Framerate: 50},
+				expected: FramerateHigh,
+			},
+			{
+				name:     "should classify 15 fps as low",
+				video:    Video{Framerate: 15},
+				expected: FramerateLow,
+			},
+			{
+				name:     "should classify VFR before looking at the rate",
+				video:    Video{Framerate: 60, FramerateMode: "VFR"},
+				expected: FramerateVariable,
+			},
+			{
+				name:     "should classify unknown rate as standard",
+				video:    Video{},
+				expected: FramerateStandard,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, classifyFramerate(tt.video))
+			})
+		}
+	})
+
+	t.Run("Normalize", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			fps      float64
+			expected Framerate
+		}{
+			{
+				name:     "should snap 29.97 to 30000/1001",
+				fps:      29.97,
+				expected: Framerate{Numerator: 30000, Denominator: 1001},
+			},
+			{
+				name:     "should snap 59.94 to 60000/1001",
+				fps:      59.94,
+				expected: Framerate{Numerator: 60000, Denominator: 1001},
+			},
+			{
+				name:     "should keep 25 as 25/1",
+				fps:      25,
+				expected: Framerate{Numerator: 25, Denominator: 1},
+			},
+			{
+				name:     "should keep low rates at whole frames",
+				fps:      14.8,
+				expected: Framerate{Numerator: 15, Denominator: 1},
+			},
+			{
+				name:     "should never go below one frame per second",
+				fps:      0.2,
+				expected: Framerate{Numerator: 1, Denominator: 1},
+			},
+			{
+				name:     "should snap an odd VFR average to the nearest broadcast rate",
+				fps:      28.5,
+				expected: Framerate{Numerator: 30000, Denominator: 1001},
+			},
+			{
+				name:     "should fall back to the default rate when unknown",
+				fps:      0,
+				expected: defaultFramerate,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, normalizeFramerate(tt.fps))
+			})
+		}
+	})
+}
@@ -6,47 +6,61 @@ import (
 	"log"
 	"math"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 type ProfilerInput struct {
-	GUID        string  `json:"guid"`
-	JobTemplate *string `json:"jobTemplate,omitempty"`
+	GUID                   string  `json:"guid"`
+	JobTemplate            *string `json:"jobTemplate,omitempty"`
+	AcceleratedTranscoding *string `json:"acceleratedTranscoding,omitempty"`
 }
 
 type ProfilerOutput struct {
-	GUID                   string `json:"guid"`
-	StartTime              string `json:"startTime"`
-	WorkflowTrigger        string `json:"workflowTrigger"`
-	WorkflowStatus         string `json:"workflowStatus"`
-	WorkflowName           string `json:"workflowName"`
-	SrcBucket              string `json:"srcBucket"`
-	DestBucket             string `json:"destBucket"`
-	CloudFront             string `json:"cloudFront"`
-	FrameCapture           bool   `json:"frameCapture"`
-	ArchiveSource          string `json:"archiveSource"`
-	JobTemplate2160p       string `json:"jobTemplate_2160p"`
-	JobTemplate1080p       string `json:"jobTemplate_1080p"`
-	JobTemplate720p        string `json:"jobTemplate_720p"`
-	InputRotate            string `json:"inputRotate"`
-	AcceleratedTranscoding string `json:"acceleratedTranscoding"`
-	EnableSns              bool   `json:"enableSns"`
-	EnableSqs              bool   `json:"enableSqs"`
-	SrcVideo               string `json:"srcVideo"`
-	EnableMediaPackage     bool   `json:"enableMediaPackage"`
-	SrcMediainfo           string `json:"srcMediainfo"`
-	SrcHeight              int    `json:"srcHeight"`
-	SrcWidth               int    `json:"srcWidth"`
-	EncodingProfile        int    `json:"encodingProfile"`
-	FrameCaptureHeight     int    `json:"frameCaptureHeight"`
-	FrameCaptureWidth      int    `json:"frameCaptureWidth"`
-	JobTemplate            string `json:"jobTemplate"`
-	IsCustomTemplate       bool   `json:"isCustomTemplate"`
+	GUID                   string  `json:"guid"`
+	StartTime              string  `json:"startTime"`
+	WorkflowTrigger        string  `json:"workflowTrigger"`
+	WorkflowStatus         string  `json:"workflowStatus"`
+	WorkflowName           string  `json:"workflowName"`
+	SrcBucket              string  `json:"srcBucket"`
+	DestBucket             string  `json:"destBucket"`
+	CloudFront             string  `json:"cloudFront"`
+	FrameCapture           bool    `json:"frameCapture"`
+	ArchiveSource          string  `json:"archiveSource"`
+	JobTemplate2160p       string  `json:"jobTemplate_2160p"`
+	JobTemplate1080p       string  `json:"jobTemplate_1080p"`
+	JobTemplate720p        string  `json:"jobTemplate_720p"`
+	InputRotate            string  `json:"inputRotate"`
+	AcceleratedTranscoding string  `json:"acceleratedTranscoding"`
+	EnableSns              bool    `json:"enableSns"`
+	EnableSqs              bool    `json:"enableSqs"`
+	SrcVideo               string  `json:"srcVideo"`
+	EnableMediaPackage     bool    `json:"enableMediaPackage"`
+	SrcMediainfo           string  `json:"srcMediainfo"`
+	SrcHeight              int     `json:"srcHeight"`
+	SrcWidth               int     `json:"srcWidth"`
+	EncodingProfile        int     `json:"encodingProfile"`
+	FrameCaptureHeight     int     `json:"frameCaptureHeight"`
+	FrameCaptureWidth      int     `json:"frameCaptureWidth"`
+	JobTemplate            string  `json:"jobTemplate"`
+	IsCustomTemplate       bool    `json:"isCustomTemplate"`
+	SrcFramerate           float64 `json:"srcFramerate"`
+	FramerateClass         string  `json:"framerateClass"`
+	FramerateNumerator     int     `json:"framerateNumerator"`
+	FramerateDenominator   int     `json:"framerateDenominator"`
+	SrcScanType            string  `json:"srcScanType"`
+	SrcUploader            string  `json:"srcUploader"`
+	TemplateRule           string  `json:"templateRule"`
+	Queue                  string  `json:"queue"`
+	Priority               *int    `json:"priority,omitempty"`
+	OutputVersion          int     `json:"outputVersion,omitempty"`
+	Version                int     `json:"version,omitempty"`
 }
 
 type MediaInfo struct {
@@ -64,15 +78,21 @@ type Container struct {
 }
 
 type Video struct {
-	Codec       string  `json:"codec"`
-	Bitrate     int     `json:"bitrate"`
-	Duration    float64 `json:"duration"`
-	FrameCount  int     `json:"frameCount"`
-	Width       int     `json:"width"`
-	Height      int     `json:"height"`
-	Framerate   float64 `json:"framerate"`
-	AspectRatio string  `json:"aspectRatio"`
-	ColorSpace  string  `json:"colorSpace"`
+	Codec         string  `json:"codec"`
+	Bitrate       int     `json:"bitrate"`
+	Duration      float64 `json:"duration"`
+	FrameCount    int     `json:"frameCount"`
+	Width         int     `json:"width"`
+	Height        int     `json:"height"`
+	Framerate     float64 `json:"framerate"`
+	FramerateMode string  `json:"framerateMode"`
+	ScanType      string  `json:"scanType"`
+	ScanOrder     string  `json:"scanOrder"`
+	AspectRatio   string  `json:"aspectRatio"`
+	ColorSpace    string  `json:"colorSpace"`
+
+	HdrFormat               string `json:"hdrFormat"`
+	TransferCharacteristics string `json:"transferCharacteristics"`
 }
 
 type Audio struct {
@@ -88,10 +108,18 @@ type Audio struct {
 
 type DynamoDBClient interface {
 	GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+}
+
+type S3Client interface {
+	GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error)
+	GetObjectTagging(input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error)
 }
 
 type Handler struct {
 	DynamoDBClient DynamoDBClient
+	S3Client       S3Client
+	RulesCache     *RulesCache
 }
 
 func (h *Handler) HandleRequest(event ProfilerInput) (*ProfilerOutput, error) {
@@ -148,6 +176,9 @@ func (h *Handler) HandleRequest(event ProfilerInput) (*ProfilerOutput, error) {
 		SrcVideo:               getStringValue(data.Item, "srcVideo"),
 		EnableMediaPackage:     getBoolValue(data.Item, "enableMediaPackage"),
 		SrcMediainfo:           getStringValue(data.Item, "srcMediainfo"),
+		SrcUploader:            getStringValue(data.Item, "srcUploader"),
+		OutputVersion:          getIntValue(data.Item, "outputVersion"),
+		Version:                getIntValue(data.Item, "version"),
 	}
 
 	formatedSrcMediainfo := output.SrcMediainfo
@@ -170,6 +201,19 @@ func (h *Handler) HandleRequest(event ProfilerInput) (*ProfilerOutput, error) {
 	output.SrcHeight = mediainfo.Video[0].Height
 	output.SrcWidth = mediainfo.Video[0].Width
 
+	framerate := normalizeFramerate(mediainfo.Video[0].Framerate)
+	output.SrcFramerate = mediainfo.Video[0].Framerate
+	output.FramerateClass = classifyFramerate(mediainfo.Video[0])
+
+	output.SrcScanType = classifyScanType(mediainfo.Video[0])
+	if output.SrcScanType == ScanTelecine {
+		framerate = telecineFramerate
+	}
+	output.FramerateNumerator = framerate.Numerator
+	output.FramerateDenominator = framerate.Denominator
+	log.Printf("Framerate:: %s %d/%d", output.FramerateClass, output.FramerateNumerator, output.FramerateDenominator)
+	log.Printf("Scan type:: %s", output.SrcScanType)
+
 	profiles := []int{2160, 1080, 720}
 	var encodingProfile int
 	minProfileDiff := math.MaxInt32
@@ -183,16 +227,6 @@ func (h *Handler) HandleRequest(event ProfilerInput) (*ProfilerOutput, error) {
 	}
 
 	output.EncodingProfile = encodingProfile
-	if output.FrameCapture {
-		ratio := map[int]int{
-			2160: 3840,
-			1080: 1920,
-			720:  1280,
-		}
-
-		output.FrameCaptureHeight = encodingProfile
-		output.FrameCaptureWidth = ratio[encodingProfile]
-	}
 
 	if event.JobTemplate == nil {
 		jobTemplates := map[int]string{
@@ -201,12 +235,56 @@ func (h *Handler) HandleRequest(event ProfilerInput) (*ProfilerOutput, error) {
 			720:  output.JobTemplate720p,
 		}
 		output.JobTemplate = jobTemplates[encodingProfile]
-		log.Printf("Chosen template:: %s", output.JobTemplate)
 		output.IsCustomTemplate = false
+
+		// Rules only apply when no custom template was requested
+		rules, err := h.loadRules()
+		if err != nil {
+			return nil, fmt.Errorf("profiler: main.Handler: %w", err)
+		}
+
+		rule, err := h.evaluateRules(rules, RuleSubject{
+			Key:      output.SrcVideo,
+			Uploader: output.SrcUploader,
+			Video:    mediainfo.Video[0],
+			Duration: mediainfo.Container.Duration,
+		}, output.SrcBucket)
+		if err != nil {
+			return nil, fmt.Errorf("profiler: main.Handler: %w", err)
+		}
+
+		if rule != nil {
+			applyRule(output, rule)
+		}
+		log.Printf("Chosen template:: %s", output.JobTemplate)
 	} else {
+		output.JobTemplate = *event.JobTemplate
 		output.IsCustomTemplate = true
 	}
 
+	// Resubmissions of failed jobs pin the acceleration mode
+	if event.AcceleratedTranscoding != nil {
+		output.AcceleratedTranscoding = *event.AcceleratedTranscoding
+	}
+
+	// AUTO can come from the stack setting, a rule or a resubmission, and
+	// the state machine only understands the concrete modes
+	if output.AcceleratedTranscoding == AccelerationAuto {
+		output.AcceleratedTranscoding = chooseAcceleration(mediainfo.Video[0], mediainfo.Container.Duration)
+		log.Printf("Accelerated transcoding:: %s", output.AcceleratedTranscoding)
+	}
+
+	if output.FrameCapture && output.FrameCaptureHeight == 0 {
+		ratio := map[int]int{
+			2160: 3840,
+			1080: 1920,
+			720:  1280,
+		}
+
+		output.FrameCaptureHeight = encodingProfile
+		output.FrameCaptureWidth = ratio[encodingProfile]
+	}
+
 	outputJson, err := json.Marshal(output)
 	if err != nil {
 		return nil, fmt.Errorf("profiler: main.Handler: json.Marshal: %w", err)
@@ -230,6 +308,15 @@ func getBoolValue(item map[string]*dynamodb.AttributeValue, key string) bool {
 	return false
 }
 
+func getIntValue(item map[string]*dynamodb.AttributeValue, key string) int {
+	if val, exists := item[key]; exists && val.N != nil {
+		if value, err := strconv.Atoi(*val.N); err == nil {
+			return value
+		}
+	}
+	return 0
+}
+
 func main() {
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(os.Getenv("AWS_REGION")),
@@ -240,6 +327,8 @@ func main() {
 
 	handler := Handler{
 		DynamoDBClient: dynamodb.New(sess),
+		S3Client:       s3.New(sess),
+		RulesCache:     NewRulesCacheFromEnv(),
 	}
 
 	lambda.Start(handler.HandleRequest)
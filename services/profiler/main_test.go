@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -21,6 +22,34 @@ func (m *DynamoDBClientMock) GetItem(input *dynamodb.GetItemInput) (*dynamodb.Ge
 	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
 }
 
+func (m *DynamoDBClientMock) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+}
+
+type S3ClientMock struct {
+	mock.Mock
+}
+
+func (m *S3ClientMock) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetObjectOutput), args.Error(1)
+}
+
+func (m *S3ClientMock) GetObjectTagging(input *s3.GetObjectTaggingInput) (*s3.GetObjectTaggingOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*s3.GetObjectTaggingOutput), args.Error(1)
+}
+
 func TestProfiler(t *testing.T) {
 	t.Run("should success on profile set", func(t *testing.T) {
 		dynamoDBClientMock := new(DynamoDBClientMock)
@@ -44,6 +73,12 @@ func TestProfiler(t *testing.T) {
 				"frameCapture": {
 					BOOL: aws.Bool(true),
 				},
+				"outputVersion": {
+					N: aws.String("2"),
+				},
+				"version": {
+					N: aws.String("4"),
+				},
 			},
 		}, nil)
 
@@ -61,6 +96,12 @@ func TestProfiler(t *testing.T) {
 		assert.Equal(t, "tmpl2", output.JobTemplate1080p)
 		assert.Equal(t, "tmpl3", output.JobTemplate720p)
 		assert.Equal(t, true, output.FrameCapture)
+		assert.Equal(t, FramerateStandard, output.FramerateClass)
+		assert.Equal(t, 30000, output.FramerateNumerator)
+		assert.Equal(t, 1001, output.FramerateDenominator)
+		assert.Equal(t, ScanProgressive, output.SrcScanType)
+		assert.Equal(t, 2, output.OutputVersion)
+		assert.Equal(t, 4, output.Version)
 	})
 
 	t.Run("should retuirn error when db get fails", func(t *testing.T) {
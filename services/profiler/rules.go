@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Rule sources selected with the RulesSource env var. Rules are disabled when
+// it is empty.
+const (
+	RulesSourceS3       = "S3"
+	RulesSourceDynamoDB = "DYNAMODB"
+)
+
+// Rule maps source properties to encoding settings. Rules are evaluated in
+// ascending priority and the first matching rule wins.
+type Rule struct {
+	Name     string     `json:"name"`
+	Priority int        `json:"priority"`
+	Match    RuleMatch  `json:"match"`
+	Action   RuleAction `json:"action"`
+}
+
+// RuleMatch holds the conditions of a rule. Empty conditions always match and
+// every non-empty condition must match for the rule to fire.
+type RuleMatch struct {
+	KeyPrefix   string            `json:"keyPrefix,omitempty"`
+	Extensions  []string          `json:"extensions,omitempty"`
+	Codecs      []string          `json:"codecs,omitempty"`
+	MinDuration float64           `json:"minDuration,omitempty"`
+	MaxDuration float64           `json:"maxDuration,omitempty"`
+	MinHeight   int               `json:"minHeight,omitempty"`
+	MaxHeight   int               `json:"maxHeight,omitempty"`
+	Hdr         *bool             `json:"hdr,omitempty"`
+	Uploaders   []string          `json:"uploaders,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+type RuleAction struct {
+	JobTemplate            string `json:"jobTemplate,omitempty"`
+	AcceleratedTranscoding string `json:"acceleratedTranscoding,omitempty"`
+	FrameCapture           *bool  `json:"frameCapture,omitempty"`
+	FrameCaptureWidth      int    `json:"frameCaptureWidth,omitempty"`
+	FrameCaptureHeight     int    `json:"frameCaptureHeight,omitempty"`
+	Queue                  string `json:"queue,omitempty"`
+	Priority               *int   `json:"priority,omitempty"`
+}
+
+// RuleSubject is everything a rule can match on.
+type RuleSubject struct {
+	Key      string
+	Uploader string
+	Video    Video
+	Duration float64
+	Tags     map[string]string
+}
+
+func (m RuleMatch) NeedsTags() bool {
+	return len(m.Tags) > 0
+}
+
+func (m RuleMatch) Matches(subject RuleSubject) bool {
+	if m.KeyPrefix != "" && !strings.HasPrefix(subject.Key, m.KeyPrefix) {
+		return false
+	}
+
+	if len(m.Extensions) > 0 && !containsFold(m.Extensions, strings.TrimPrefix(path.Ext(subject.Key), ".")) {
+		return false
+	}
+
+	if len(m.Codecs) > 0 && !containsFold(m.Codecs, subject.Video.Codec) {
+		return false
+	}
+
+	if m.MinDuration > 0 && subject.Duration < m.MinDuration {
+		return false
+	}
+
+	if m.MaxDuration > 0 && subject.Duration > m.MaxDuration {
+		return false
+	}
+
+	if m.MinHeight > 0 && subject.Video.Height < m.MinHeight {
+		return false
+	}
+
+	if m.MaxHeight > 0 && subject.Video.Height > m.MaxHeight {
+		return false
+	}
+
+	if m.Hdr != nil && *m.Hdr != isHdr(subject.Video) {
+		return false
+	}
+
+	if len(m.Uploaders) > 0 && !containsFold(m.Uploaders, subject.Uploader) {
+		return false
+	}
+
+	for key, value := range m.Tags {
+		if tag, ok := subject.Tags[key]; !ok || (value != "*" && tag != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isHdr reports whether mediainfo found HDR metadata or an HDR transfer
+// function (PQ / HLG) on the video track.
+func isHdr(video Video) bool {
+	if video.HdrFormat != "" {
+		return true
+	}
+
+	transfer := strings.ToUpper(video.TransferCharacteristics)
+	return strings.Contains(transfer, "PQ") || strings.Contains(transfer, "2084") || strings.Contains(transfer, "HLG")
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRules returns the rule set sorted by priority, from the cache or from
+// the source configured in RulesSource.
+func (h *Handler) loadRules() ([]Rule, error) {
+	if rules, ok := h.RulesCache.Get(); ok {
+		return rules, nil
+	}
+
+	rules, err := h.readRules()
+	if err != nil {
+		return nil, fmt.Errorf("loadRules: %w", err)
+	}
+
+	h.RulesCache.Put(rules)
+	return rules, nil
+}
+
+func (h *Handler) readRules() ([]Rule, error) {
+	var rules []Rule
+
+	switch os.Getenv("RulesSource") {
+	case "":
+		return nil, nil
+	case RulesSourceS3:
+		data, err := h.S3Client.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(os.Getenv("RulesBucket")),
+			Key:    aws.String(os.Getenv("RulesKey")),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("readRules: GetObject: %w", err)
+		}
+		defer data.Body.Close()
+
+		rulesJson, err := io.ReadAll(data.Body)
+		if err != nil {
+			return nil, fmt.Errorf("readRules: ReadAll: %w", err)
+		}
+
+		if err := json.Unmarshal(rulesJson, &rules); err != nil {
+			return nil, fmt.Errorf("readRules: json.Unmarshal: %w", err)
+		}
+	case RulesSourceDynamoDB:
+		var startKey map[string]*dynamodb.AttributeValue
+		for {
+			data, err := h.DynamoDBClient.Query(&dynamodb.QueryInput{
+				TableName:              aws.String(os.Getenv("DynamoDBTable")),
+				KeyConditionExpression: aws.String("PK = :pk"),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":pk": {
+						S: aws.String("RULE"),
+					},
+				},
+				ExclusiveStartKey: startKey,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("readRules: Query: %w", err)
+			}
+
+			var page []Rule
+			if err := dynamodbattribute.UnmarshalListOfMaps(data.Items, &page); err != nil {
+				return nil, fmt.Errorf("readRules: UnmarshalListOfMaps: %w", err)
+			}
+			rules = append(rules, page...)
+
+			if len(data.LastEvaluatedKey) == 0 {
+				break
+			}
+			startKey = data.LastEvaluatedKey
+		}
+	default:
+		return nil, fmt.Errorf("readRules: unknown rules source %s", os.Getenv("RulesSource"))
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+
+	return rules, nil
+}
+
+// evaluateRules returns the first rule matching the subject. Object tags are
+// only fetched from S3 when a rule needs them.
+func (h *Handler) evaluateRules(rules []Rule, subject RuleSubject, bucket string) (*Rule, error) {
+	for i, rule := range rules {
+		if rule.Match.NeedsTags() && subject.Tags == nil {
+			data, err := h.S3Client.GetObjectTagging(&s3.GetObjectTaggingInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(subject.Key),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("evaluateRules: GetObjectTagging: %w", err)
+			}
+
+			subject.Tags = map[string]string{}
+			for _, tag := range data.TagSet {
+				subject.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+
+		if rule.Match.Matches(subject) {
+			log.Printf("Rule matched:: %s", rule.Name)
+			return &rules[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func applyRule(output *ProfilerOutput, rule *Rule) {
+	output.TemplateRule = rule.Name
+
+	if rule.Action.JobTemplate != "" {
+		output.JobTemplate = rule.Action.JobTemplate
+		output.IsCustomTemplate = true
+	}
+
+	if rule.Action.AcceleratedTranscoding != "" {
+		output.AcceleratedTranscoding = rule.Action.AcceleratedTranscoding
+	}
+
+	if rule.Action.FrameCapture != nil {
+		output.FrameCapture = *rule.Action.FrameCapture
+	}
+
+	if rule.Action.FrameCaptureWidth > 0 && rule.Action.FrameCaptureHeight > 0 {
+		output.FrameCaptureWidth = rule.Action.FrameCaptureWidth
+		output.FrameCaptureHeight = rule.Action.FrameCaptureHeight
+	}
+
+	if rule.Action.Queue != "" {
+		output.Queue = rule.Action.Queue
+	}
+
+	if rule.Action.Priority != nil {
+		output.Priority = rule.Action.Priority
+	}
+}
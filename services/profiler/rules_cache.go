@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRulesCacheTTL is used when RulesCacheTTL (seconds) is not set.
+const defaultRulesCacheTTL = 5 * time.Minute
+
+// RulesCache keeps the rule set in memory across warm invocations so the
+// profiler does not read it from S3 or DynamoDB for every asset. Rule changes
+// are picked up once the TTL expires.
+type RulesCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	rules   []Rule
+	expires time.Time
+}
+
+func NewRulesCache(ttl time.Duration) *RulesCache {
+	return &RulesCache{
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// NewRulesCacheFromEnv builds a cache with the TTL from RulesCacheTTL. A TTL
+// of 0 disables the cache.
+func NewRulesCacheFromEnv() *RulesCache {
+	ttl := defaultRulesCacheTTL
+	if value, err := strconv.Atoi(os.Getenv("RulesCacheTTL")); err == nil && value >= 0 {
+		ttl = time.Duration(value) * time.Second
+	}
+	return NewRulesCache(ttl)
+}
+
+// Get returns the cached rule set. Rules are never modified once loaded, so
+// callers share the cached slice.
+func (c *RulesCache) Get() ([]Rule, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.expires.IsZero() || !c.now().Before(c.expires) {
+		return nil, false
+	}
+	return c.rules, true
+}
+
+func (c *RulesCache) Put(rules []Rule) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = rules
+	c.expires = c.now().Add(c.ttl)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRulesCache(t *testing.T) {
+	t.Run("should return the rules until the TTL expires", func(t *testing.T) {
+		now := time.Now()
+		cache := NewRulesCache(time.Minute)
+		cache.now = func() time.Time { return now }
+
+		_, ok := cache.Get()
+		assert.False(t, ok)
+
+		cache.Put([]Rule{{Name: "news"}})
+		rules, ok := cache.Get()
+		assert.True(t, ok)
+		assert.Equal(t, "news", rules[0].Name)
+
+		now = now.Add(time.Minute)
+		_, ok = cache.Get()
+		assert.False(t, ok)
+	})
+
+	t.Run("should not cache with a TTL of 0", func(t *testing.T) {
+		cache := NewRulesCache(0)
+		cache.Put([]Rule{{Name: "news"}})
+
+		_, ok := cache.Get()
+		assert.False(t, ok)
+	})
+
+	t.Run("should read the rules once across invocations", func(t *testing.T) {
+		os.Setenv("RulesSource", RulesSourceS3)
+		defer os.Unsetenv("RulesSource")
+
+		s3ClientMock := new(S3ClientMock)
+		s3ClientMock.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(bytes.NewReader([]byte(TestRuleSet))),
+		}, nil).Once()
+
+		handler := &Handler{S3Client: s3ClientMock, RulesCache: NewRulesCache(time.Minute)}
+		for i := 0; i < 2; i++ {
+			rules, err := handler.loadRules()
+			assert.Nil(t, err)
+			assert.Len(t, rules, 3)
+		}
+		s3ClientMock.AssertNumberOfCalls(t, "GetObject", 1)
+	})
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const TestRuleSet = `[
+	{
+		"name": "default-news",
+		"priority": 20,
+		"match": {"keyPrefix": "news/"},
+		"action": {"jobTemplate": "news-template"}
+	},
+	{
+		"name": "hdr-news",
+		"priority": 10,
+		"match": {"keyPrefix": "news/", "hdr": true},
+		"action": {"jobTemplate": "hdr-template", "acceleratedTranscoding": "ENABLED"}
+	},
+	{
+		"name": "tagged",
+		"priority": 30,
+		"match": {"tags": {"ladder": "premium"}},
+		"action": {"jobTemplate": "premium-template", "frameCapture": true, "frameCaptureWidth": 640, "frameCaptureHeight": 360}
+	}
+]`
+
+func TestRules(t *testing.T) {
+	t.Run("Match", func(t *testing.T) {
+		subject := RuleSubject{
+			Key:      "news/2024/clip.MOV",
+			Uploader: "AWS:editor",
+			Duration: 120,
+			Video: Video{
+				Codec:  "ProRes",
+				Height: 1080,
+			},
+		}
+
+		tests := []struct {
+			name     string
+			match    RuleMatch
+			expected bool
+		}{
+			{name: "should match an empty rule", match: RuleMatch{}, expected: true},
+			{name: "should match key prefix", match: RuleMatch{KeyPrefix: "news/"}, expected: true},
+			{name: "should not match other prefix", match: RuleMatch{KeyPrefix: "sport/"}, expected: false},
+			{name: "should match extension ignoring case", match: RuleMatch{Extensions: []string{"mov"}}, expected: true},
+			{name: "should match codec", match: RuleMatch{Codecs: []string{"prores"}}, expected: true},
+			{name: "should not match long minimum duration", match: RuleMatch{MinDuration: 600}, expected: false},
+			{name: "should match resolution bounds", match: RuleMatch{MinHeight: 720, MaxHeight: 1080}, expected: true},
+			{name: "should not match HDR on SDR source", match: RuleMatch{Hdr: aws.Bool(true)}, expected: false},
+			{name: "should match uploader", match: RuleMatch{Uploaders: []string{"AWS:editor"}}, expected: true},
+			{name: "should not match missing tag", match: RuleMatch{Tags: map[string]string{"ladder": "*"}}, expected: false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, tt.match.Matches(subject))
+			})
+		}
+	})
+
+	t.Run("should detect HDR from format or transfer characteristics", func(t *testing.T) {
+		assert.True(t, isHdr(Video{HdrFormat: "SMPTE ST 2086"}))
+		assert.True(t, isHdr(Video{TransferCharacteristics: "PQ"}))
+		assert.True(t, isHdr(Video{TransferCharacteristics: "HLG"}))
+		assert.False(t, isHdr(Video{TransferCharacteristics: "BT.709"}))
+	})
+
+	t.Run("should load rules from S3 sorted by priority", func(t *testing.T) {
+		os.Setenv("RulesSource", RulesSourceS3)
+		defer os.Unsetenv("RulesSource")
+
+		s3ClientMock := new(S3ClientMock)
+		s3ClientMock.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(bytes.NewReader([]byte(TestRuleSet))),
+		}, nil)
+
+		handler := &Handler{S3Client: s3ClientMock}
+		rules, err := handler.loadRules()
+
+		assert.Nil(t, err)
+		assert.Len(t, rules, 3)
+		assert.Equal(t, "hdr-news", rules[0].Name)
+		assert.Equal(t, "default-news", rules[1].Name)
+	})
+
+	t.Run("should load rules from DynamoDB", func(t *testing.T) {
+		os.Setenv("RulesSource", RulesSourceDynamoDB)
+		defer os.Unsetenv("RulesSource")
+
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{
+					"PK":       {S: aws.String("RULE")},
+					"SK":       {S: aws.String("RULE#long-form")},
+					"name":     {S: aws.String("long-form")},
+					"priority": {N: aws.String("1")},
+					"match": {M: map[string]*dynamodb.AttributeValue{
+						"minDuration": {N: aws.String("1800")},
+					}},
+					"action": {M: map[string]*dynamodb.AttributeValue{
+						"acceleratedTranscoding": {S: aws.String("PREFERRED")},
+					}},
+				},
+			},
+		}, nil)
+
+		handler := &Handler{DynamoDBClient: dynamoDBClientMock}
+		rules, err := handler.loadRules()
+
+		assert.Nil(t, err)
+		assert.Len(t, rules, 1)
+		assert.Equal(t, float64(1800), rules[0].Match.MinDuration)
+		assert.Equal(t, "PREFERRED", rules[0].Action.AcceleratedTranscoding)
+	})
+
+	t.Run("should read every page of DynamoDB rules", func(t *testing.T) {
+		os.Setenv("RulesSource", RulesSourceDynamoDB)
+		defer os.Unsetenv("RulesSource")
+
+		lastKey := map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String("RULE")},
+			"SK": {S: aws.String("RULE#b")},
+		}
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("Query", mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.ExclusiveStartKey == nil
+		})).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"name": {S: aws.String("b")}, "priority": {N: aws.String("2")}},
+			},
+			LastEvaluatedKey: lastKey,
+		}, nil)
+		dynamoDBClientMock.On("Query", mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.ExclusiveStartKey != nil
+		})).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"name": {S: aws.String("a")}, "priority": {N: aws.String("1")}},
+			},
+		}, nil)
+
+		handler := &Handler{DynamoDBClient: dynamoDBClientMock}
+		rules, err := handler.loadRules()
+
+		assert.Nil(t, err)
+		assert.Len(t, rules, 2)
+		assert.Equal(t, "a", rules[0].Name)
+		assert.Equal(t, "b", rules[1].Name)
+	})
+
+	t.Run("should not load rules when disabled", func(t *testing.T) {
+		handler := &Handler{}
+		rules, err := handler.loadRules()
+
+		assert.Nil(t, err)
+		assert.Nil(t, rules)
+	})
+
+	t.Run("should fetch object tags only when a rule needs them", func(t *testing.T) {
+		s3ClientMock := new(S3ClientMock)
+		s3ClientMock.On("GetObjectTagging", mock.Anything).Return(&s3.GetObjectTaggingOutput{
+			TagSet: []*s3.Tag{
+				{Key: aws.String("ladder"), Value: aws.String("premium")},
+			},
+		}, nil)
+
+		handler := &Handler{S3Client: s3ClientMock}
+		rules := []Rule{
+			{Name: "sport", Match: RuleMatch{KeyPrefix: "sport/"}},
+			{Name: "tagged", Match: RuleMatch{Tags: map[string]string{"ladder": "premium"}}},
+		}
+
+		rule, err := handler.evaluateRules(rules, RuleSubject{Key: "news/clip.mp4"}, "src")
+		assert.Nil(t, err)
+		assert.Equal(t, "tagged", rule.Name)
+		s3ClientMock.AssertNumberOfCalls(t, "GetObjectTagging", 1)
+
+		rule, err = handler.evaluateRules(rules[:1], RuleSubject{Key: "news/clip.mp4"}, "src")
+		assert.Nil(t, err)
+		assert.Nil(t, rule)
+		s3ClientMock.AssertNumberOfCalls(t, "GetObjectTagging", 1)
+	})
+
+	t.Run("should apply the rule that fired in HandleRequest", func(t *testing.T) {
+		os.Setenv("RulesSource", RulesSourceS3)
+		defer os.Unsetenv("RulesSource")
+
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"guid":                   {S: aws.String("guid")},
+				"srcVideo":               {S: aws.String("news/clip.mp4")},
+				"srcBucket":              {S: aws.String("src")},
+				"acceleratedTranscoding": {S: aws.String("DISABLED")},
+				"jobTemplate_1080p":      {S: aws.String("tmpl2")},
+				"srcMediainfo":           {S: aws.String(`{"container":{"duration":60},"video":[{"codec":"HEVC","width":1920,"height":1080,"framerate":25,"transferCharacteristics":"PQ"}]}`)},
+			},
+		}, nil)
+
+		s3ClientMock := new(S3ClientMock)
+		s3ClientMock.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(bytes.NewReader([]byte(TestRuleSet))),
+		}, nil)
+
+		handler := &Handler{
+			DynamoDBClient: dynamoDBClientMock,
+			S3Client:       s3ClientMock,
+		}
+
+		output, err := handler.HandleRequest(ProfilerInput{GUID: "guid"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "hdr-news", output.TemplateRule)
+		assert.Equal(t, "hdr-template", output.JobTemplate)
+		assert.Equal(t, "ENABLED", output.AcceleratedTranscoding)
+		assert.True(t, output.IsCustomTemplate)
+	})
+
+	t.Run("should resolve AUTO set by a rule", func(t *testing.T) {
+		os.Setenv("RulesSource", RulesSourceS3)
+		defer os.Unsetenv("RulesSource")
+
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"guid":                   {S: aws.String("guid")},
+				"srcVideo":               {S: aws.String("sport/match.mp4")},
+				"srcBucket":              {S: aws.String("src")},
+				"acceleratedTranscoding": {S: aws.String("DISABLED")},
+				"jobTemplate_1080p":      {S: aws.String("tmpl2")},
+				"srcMediainfo":           {S: aws.String(`{"container":{"duration":3600},"video":[{"codec":"AVC","width":1920,"height":1080,"framerate":25}]}`)},
+			},
+		}, nil)
+
+		s3ClientMock := new(S3ClientMock)
+		s3ClientMock.On("GetObject", mock.Anything).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(bytes.NewReader([]byte(`[{"name":"sport","match":{"keyPrefix":"sport/"},"action":{"acceleratedTranscoding":"AUTO"}}]`))),
+		}, nil)
+
+		handler := &Handler{
+			DynamoDBClient: dynamoDBClientMock,
+			S3Client:       s3ClientMock,
+		}
+
+		output, err := handler.HandleRequest(ProfilerInput{GUID: "guid"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "sport", output.TemplateRule)
+		assert.Equal(t, AccelerationEnabled, output.AcceleratedTranscoding)
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import "strings"
+
+// Scan types assigned to a source. The encode step adds a deinterlacer to
+// every video output when the source is not progressive.
+const (
+	ScanProgressive = "PROGRESSIVE"
+	ScanInterlaced  = "INTERLACED"
+	ScanMixed       = "MIXED"
+	ScanTelecine    = "TELECINE"
+)
+
+// telecineFramerate is the film rate restored by inverse telecine.
+var telecineFramerate = Framerate{Numerator: 24000, Denominator: 1001}
+
+// classifyScanType maps the mediainfo scanType and scanOrder of a video track
+// to a scan type. Pulldown in the scan order (e.g. "2:3 Pulldown") marks film
+// content that was telecined to 29.97 fps; MBAFF and mixed sources switch
+// between progressive and interlaced frames.
+func classifyScanType(video Video) string {
+	scanType := strings.ToLower(video.ScanType)
+	scanOrder := strings.ToLower(video.ScanOrder)
+
+	switch {
+	case strings.Contains(scanOrder, "pulldown"):
+		return ScanTelecine
+	case scanType == "mbaff" || scanType == "mixed":
+		return ScanMixed
+	case scanType == "interlaced":
+		return ScanInterlaced
+	default:
+		return ScanProgressive
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyScanType(t *testing.T) {
+	tests := []struct {
+		name     string
+		video    Video
+		expected string
+	}{
+		{
+			name:     "should classify progressive sources",
+			video:    Video{ScanType: "Progressive"},
+			expected: ScanProgressive,
+		},
+		{
+			name:     "should classify sources without scan type as progressive",
+			video:    Video{},
+			expected: ScanProgressive,
+		},
+		{
+			name:     "should classify interlaced sources",
+			video:    Video{ScanType: "Interlaced", ScanOrder: "TFF"},
+			expected: ScanInterlaced,
+		},
+		{
+			name:     "should classify MBAFF sources as mixed",
+			video:    Video{ScanType: "MBAFF"},
+			expected: ScanMixed,
+		},
+		{
+			name:     "should classify pulldown as telecine",
+			video:    Video{ScanType: "Interlaced", ScanOrder: "2:3 Pulldown"},
+			expected: ScanTelecine,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, classifyScanType(tt.video))
+		})
+	}
+}
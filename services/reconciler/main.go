@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	awslambda "github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// defaultGracePeriod leaves time for the EventBridge completion event before
+// the reconciler acts on a finished job. Overridden with GracePeriodMinutes.
+const defaultGracePeriod = 15 * time.Minute
+
+// Workflow statuses the reconciler never touches.
+var terminalStatuses = []string{"Complete", "Error", "OutputInvalid", "Rejected", "Duplicate"}
+
+type AssetRecord struct {
+	GUID            string `json:"guid"`
+	WorkflowStatus  string `json:"workflowStatus"`
+	EncodeJobId     string `json:"encodeJobId"`
+	LastFailedJobId string `json:"lastFailedJobId"`
+	AdmissionSlot   bool   `json:"admissionSlot"`
+	AdmissionId     string `json:"admissionId"`
+}
+
+// MediaConvertEvent is the EventBridge event the publish workflow is started
+// with.
+type MediaConvertEvent struct {
+	Version    string      `json:"version"`
+	ID         string      `json:"id"`
+	DetailType string      `json:"detail-type"`
+	Source     string      `json:"source"`
+	Time       time.Time   `json:"time"`
+	Region     string      `json:"region"`
+	Detail     EventDetail `json:"detail"`
+}
+
+type ReconcilerOutput struct {
+	Scanned   int `json:"scanned"`
+	Published int `json:"published"`
+	Failed    int `json:"failed"`
+	Pending   int `json:"pending"`
+}
+
+type DynamoDBClient interface {
+	Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+}
+
+type MediaConvertClient interface {
+	GetJob(input *mediaconvert.GetJobInput) (*mediaconvert.GetJobOutput, error)
+}
+
+type StepFunctionClient interface {
+	StartExecution(input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error)
+	DescribeExecution(input *sfn.DescribeExecutionInput) (*sfn.DescribeExecutionOutput, error)
+}
+
+type Handler struct {
+	DynamoDBClient     DynamoDBClient
+	MediaConvertClient MediaConvertClient
+	StepFunctionClient StepFunctionClient
+	LambdaClient       LambdaClient
+}
+
+// HandleRequest runs on a schedule. It looks up the MediaConvert job of every
+// asset that is not in a terminal state and starts the publish workflow for
+// jobs that completed, or marks the asset failed for jobs that errored, when
+// the completion event was missed.
+func (h *Handler) HandleRequest() (*ReconcilerOutput, error) {
+	output := &ReconcilerOutput{}
+
+	records, err := h.scanOpenRecords()
+	if err != nil {
+		return nil, fmt.Errorf("reconciler: main.Handler.HandleRequest: %w", err)
+	}
+
+	for _, record := range records {
+		output.Scanned++
+
+		action, err := h.reconcile(record)
+		if err != nil {
+			// Keep going, the next run picks the asset up again
+			log.Printf("reconciler: main.Handler.HandleRequest: %s: %v", record.GUID, err)
+			continue
+		}
+
+		switch action {
+		case "Published":
+			output.Published++
+		case "Failed":
+			output.Failed++
+		default:
+			output.Pending++
+		}
+	}
+
+	outputJson, _ := json.Marshal(output)
+	log.Printf("RESPONSE:: %s", outputJson)
+
+	return output, nil
+}
+
+func (h *Handler) scanOpenRecords() ([]AssetRecord, error) {
+	values := map[string]*dynamodb.AttributeValue{
+		":metadata": {S: aws.String("METADATA")},
+		":empty":    {S: aws.String("")},
+	}
+	statusFilter := ""
+	for i, status := range terminalStatuses {
+		placeholder := ":status" + strconv.Itoa(i)
+		values[placeholder] = &dynamodb.AttributeValue{S: aws.String(status)}
+		if statusFilter != "" {
+			statusFilter += ", "
+		}
+		statusFilter += placeholder
+	}
+
+	var records []AssetRecord
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		data, err := h.DynamoDBClient.Scan(&dynamodb.ScanInput{
+			TableName:                 aws.String(os.Getenv("DynamoDBTable")),
+			FilterExpression:          aws.String("SK = :metadata AND encodeJobId <> :empty AND NOT workflowStatus IN (" + statusFilter + ")"),
+			ProjectionExpression:      aws.String("guid, workflowStatus, encodeJobId, lastFailedJobId, admissionSlot, admissionId"),
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("scanOpenRecords: Scan: %w", err)
+		}
+
+		var page []AssetRecord
+		if err := dynamodbattribute.UnmarshalListOfMaps(data.Items, &page); err != nil {
+			return nil, fmt.Errorf("scanOpenRecords: UnmarshalListOfMaps: %w", err)
+		}
+		records = append(records, page...)
+
+		if len(data.LastEvaluatedKey) == 0 {
+			return records, nil
+		}
+		startKey = data.LastEvaluatedKey
+	}
+}
+
+func (h *Handler) reconcile(record AssetRecord) (string, error) {
+	// error-handler already resubmitted this job
+	if record.LastFailedJobId == record.EncodeJobId {
+		return "Pending", nil
+	}
+
+	data, err := h.MediaConvertClient.GetJob(&mediaconvert.GetJobInput{
+		Id: aws.String(record.EncodeJobId),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == mediaconvert.ErrCodeNotFoundException {
+			return "Failed", h.markFailed(record, "job not found")
+		}
+		return "", fmt.Errorf("reconcile: GetJob: %w", err)
+	}
+	job := data.Job
+
+	switch aws.StringValue(job.Status) {
+	case mediaconvert.JobStatusComplete:
+		if !isPastGracePeriod(job) {
+			return "Pending", nil
+		}
+		return h.startPublish(record, job)
+	case mediaconvert.JobStatusError, mediaconvert.JobStatusCanceled:
+		if !isPastGracePeriod(job) {
+			return "Pending", nil
+		}
+		reason := fmt.Sprintf("job %s: %d %s", aws.StringValue(job.Status), aws.Int64Value(job.ErrorCode), aws.StringValue(job.ErrorMessage))
+		return "Failed", h.markFailed(record, reason)
+	}
+
+	return "Pending", nil
+}
+
+func isPastGracePeriod(job *mediaconvert.Job) bool {
+	gracePeriod := defaultGracePeriod
+	if value, err := strconv.Atoi(os.Getenv("GracePeriodMinutes")); err == nil && value >= 0 {
+		gracePeriod = time.Duration(value) * time.Minute
+	}
+
+	if job.Timing == nil || job.Timing.FinishTime == nil {
+		return true
+	}
+	return time.Since(*job.Timing.FinishTime) >= gracePeriod
+}
+
+// startPublish starts the publish workflow with a rebuilt completion event.
+// The execution name is the one step-functions gives the publish of the job,
+// so a job is only ever published once, by the event or the reconciler. When
+// that execution already exists its status decides the action: a failed
+// publish marks the asset failed, a running one leaves it pending.
+func (h *Handler) startPublish(record AssetRecord, job *mediaconvert.Job) (string, error) {
+	event := MediaConvertEvent{
+		Version:    "0",
+		ID:         "reconciler-" + aws.StringValue(job.Id),
+		DetailType: "MediaConvert Job State Change",
+		Source:     "aws.mediaconvert",
+		Time:       time.Now().UTC(),
+		Region:     os.Getenv("AWS_REGION"),
+		Detail:     buildEventDetail(job),
+	}
+
+	eventJson, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("startPublish: json.Marshal: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-publish-%s", record.GUID, aws.StringValue(job.Id))
+	_, err = h.StepFunctionClient.StartExecution(&sfn.StartExecutionInput{
+		Name:            aws.String(name),
+		Input:           aws.String(string(eventJson)),
+		StateMachineArn: aws.String(os.Getenv("PublishWorkflow")),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sfn.ErrCodeExecutionAlreadyExists {
+			return h.checkPublish(record, name)
+		}
+		return "", fmt.Errorf("startPublish: StartExecution: %w", err)
+	}
+
+	log.Printf("Started publish workflow for %s (job %s)", record.GUID, aws.StringValue(job.Id))
+	if err := h.releaseSlot(record, mediaconvert.JobStatusComplete); err != nil {
+		return "", fmt.Errorf("startPublish: %w", err)
+	}
+	return "Published", nil
+}
+
+// checkPublish returns the action for an asset whose publish execution was
+// already started, by the completion event or an earlier run.
+func (h *Handler) checkPublish(record AssetRecord, name string) (string, error) {
+	data, err := h.StepFunctionClient.DescribeExecution(&sfn.DescribeExecutionInput{
+		ExecutionArn: aws.String(executionArn(os.Getenv("PublishWorkflow"), name)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("checkPublish: DescribeExecution: %w", err)
+	}
+
+	status := aws.StringValue(data.Status)
+	log.Printf("Publish of %s already started: %s", record.GUID, status)
+
+	switch status {
+	case sfn.ExecutionStatusFailed, sfn.ExecutionStatusTimedOut, sfn.ExecutionStatusAborted:
+		if err := h.markFailed(record, fmt.Sprintf("publish %s %s", name, status)); err != nil {
+			return "", fmt.Errorf("checkPublish: %w", err)
+		}
+		return "Failed", nil
+	}
+
+	if err := h.releaseSlot(record, mediaconvert.JobStatusComplete); err != nil {
+		return "", fmt.Errorf("checkPublish: %w", err)
+	}
+	if status == sfn.ExecutionStatusSucceeded {
+		return "Published", nil
+	}
+	return "Pending", nil
+}
+
+// executionArn returns the ARN of the execution of the state machine with the
+// given name.
+func executionArn(stateMachineArn string, name string) string {
+	return strings.Replace(stateMachineArn, ":stateMachine:", ":execution:", 1) + ":" + name
+}
+
+// markFailed moves the asset to Error, unless its job changed since the scan.
+func (h *Handler) markFailed(record AssetRecord, reason string) error {
+	_, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String("VIDEO#" + record.GUID)},
+			"SK": {S: aws.String("METADATA")},
+		},
+		UpdateExpression:    aws.String("SET workflowStatus = :status, errorMessage = :reason, endTime = :now, #version = if_not_exists(#version, :zero) + :one"),
+		ConditionExpression: aws.String("encodeJobId = :jobId"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String("Error")},
+			":reason": {S: aws.String(reason)},
+			":now":    {S: aws.String(time.Now().UTC().Format(time.RFC3339))},
+			":jobId":  {S: aws.String(record.EncodeJobId)},
+			":zero":   {N: aws.String("0")},
+			":one":    {N: aws.String("1")},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil
+		}
+		return fmt.Errorf("markFailed: UpdateItem: %w", err)
+	}
+
+	log.Printf("Marked %s as failed: %s", record.GUID, reason)
+	if err := h.releaseSlot(record, mediaconvert.JobStatusError); err != nil {
+		return fmt.Errorf("markFailed: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("reconciler: main: session.NewSession: %v", err)
+	}
+
+	handler := &Handler{
+		DynamoDBClient:     dynamodb.New(sess),
+		MediaConvertClient: mediaconvert.New(sess),
+		StepFunctionClient: sfn.New(sess),
+		LambdaClient:       awslambda.New(sess),
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBClientMock struct {
+	mock.Mock
+}
+
+func (m *DynamoDBClientMock) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+type MediaConvertClientMock struct {
+	mock.Mock
+}
+
+func (m *MediaConvertClientMock) GetJob(input *mediaconvert.GetJobInput) (*mediaconvert.GetJobOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*mediaconvert.GetJobOutput), args.Error(1)
+}
+
+type StepFunctionClientMock struct {
+	mock.Mock
+}
+
+func (m *StepFunctionClientMock) StartExecution(input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sfn.StartExecutionOutput), args.Error(1)
+}
+
+func (m *StepFunctionClientMock) DescribeExecution(input *sfn.DescribeExecutionInput) (*sfn.DescribeExecutionOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sfn.DescribeExecutionOutput), args.Error(1)
+}
+
+func getRecord(guid string, jobId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"guid":           {S: aws.String(guid)},
+		"workflowStatus": {S: aws.String("Encoding")},
+		"encodeJobId":    {S: aws.String(jobId)},
+	}
+}
+
+func getJob(id string, status string, finished time.Time) *mediaconvert.GetJobOutput {
+	return &mediaconvert.GetJobOutput{
+		Job: &mediaconvert.Job{
+			Id:           aws.String(id),
+			Status:       aws.String(status),
+			ErrorCode:    aws.Int64(1999),
+			ErrorMessage: aws.String("failed"),
+			UserMetadata: map[string]*string{"guid": aws.String("guid")},
+			Timing:       &mediaconvert.Timing{FinishTime: aws.Time(finished)},
+			Settings:     TestJobSettings,
+		},
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+
+	t.Run("should publish completed jobs and fail errored ones", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("Scan", mock.Anything).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				getRecord("complete", "job-1"),
+				getRecord("error", "job-2"),
+				getRecord("progressing", "job-3"),
+				getRecord("recent", "job-4"),
+			},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		mediaConvertClientMock.On("GetJob", &mediaconvert.GetJobInput{Id: aws.String("job-1")}).Return(getJob("job-1", "COMPLETE", old), nil)
+		mediaConvertClientMock.On("GetJob", &mediaconvert.GetJobInput{Id: aws.String("job-2")}).Return(getJob("job-2", "ERROR", old), nil)
+		mediaConvertClientMock.On("GetJob", &mediaconvert.GetJobInput{Id: aws.String("job-3")}).Return(getJob("job-3", "PROGRESSING", old), nil)
+		mediaConvertClientMock.On("GetJob", &mediaconvert.GetJobInput{Id: aws.String("job-4")}).Return(getJob("job-4", "COMPLETE", time.Now()), nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			MediaConvertClient: mediaConvertClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+		}
+
+		output, err := handler.HandleRequest()
+
+		assert.Nil(t, err)
+		assert.Equal(t, &ReconcilerOutput{Scanned: 4, Published: 1, Failed: 1, Pending: 2}, output)
+
+		stepFunctionClientMock.AssertNumberOfCalls(t, "StartExecution", 1)
+		start := stepFunctionClientMock.Calls[0].Arguments.Get(0).(*sfn.StartExecutionInput)
+		assert.Equal(t, "complete-publish-job-1", *start.Name)
+
+		var event MediaConvertEvent
+		assert.Nil(t, json.Unmarshal([]byte(*start.Input), &event))
+		assert.Equal(t, "aws.mediaconvert", event.Source)
+		assert.Equal(t, "COMPLETE", event.Detail.Status)
+
+		update := dynamoDBClientMock.Calls[1].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+		assert.Equal(t, "VIDEO#error", *update.Key["PK"].S)
+		assert.Equal(t, "Error", *update.ExpressionAttributeValues[":status"].S)
+		assert.Contains(t, *update.UpdateExpression, "#version = if_not_exists(#version, :zero) + :one")
+		assert.Equal(t, "version", *update.ExpressionAttributeNames["#version"])
+	})
+
+	t.Run("should skip jobs already resubmitted by error-handler", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		mediaConvertClientMock := new(MediaConvertClientMock)
+
+		record := getRecord("guid", "job-1")
+		record["lastFailedJobId"] = &dynamodb.AttributeValue{S: aws.String("job-1")}
+		dynamoDBClientMock.On("Scan", mock.Anything).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{record},
+		}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			MediaConvertClient: mediaConvertClientMock,
+		}
+
+		output, err := handler.HandleRequest()
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, output.Pending)
+		mediaConvertClientMock.AssertNotCalled(t, "GetJob", mock.Anything)
+	})
+
+	t.Run("should check the status of an existing publish execution", func(t *testing.T) {
+		t.Setenv("PublishWorkflow", "arn:aws:states:us-east-1:123456789012:stateMachine:publish")
+
+		for _, c := range []struct {
+			status   string
+			expected ReconcilerOutput
+		}{
+			{status: sfn.ExecutionStatusSucceeded, expected: ReconcilerOutput{Scanned: 1, Published: 1}},
+			{status: sfn.ExecutionStatusRunning, expected: ReconcilerOutput{Scanned: 1, Pending: 1}},
+			{status: sfn.ExecutionStatusFailed, expected: ReconcilerOutput{Scanned: 1, Failed: 1}},
+		} {
+			dynamoDBClientMock := new(DynamoDBClientMock)
+			mediaConvertClientMock := new(MediaConvertClientMock)
+			stepFunctionClientMock := new(StepFunctionClientMock)
+
+			dynamoDBClientMock.On("Scan", mock.Anything).Return(&dynamodb.ScanOutput{
+				Items: []map[string]*dynamodb.AttributeValue{getRecord("guid", "job-1")},
+			}, nil)
+			dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+			mediaConvertClientMock.On("GetJob", mock.Anything).Return(getJob("job-1", "COMPLETE", old), nil)
+			stepFunctionClientMock.On("StartExecution", mock.Anything).Return(nil, awserr.New(sfn.ErrCodeExecutionAlreadyExists, "exists", nil))
+			stepFunctionClientMock.On("DescribeExecution", &sfn.DescribeExecutionInput{
+				ExecutionArn: aws.String("arn:aws:states:us-east-1:123456789012:execution:publish:guid-publish-job-1"),
+			}).Return(&sfn.DescribeExecutionOutput{Status: aws.String(c.status)}, nil)
+
+			handler := Handler{
+				DynamoDBClient:     dynamoDBClientMock,
+				MediaConvertClient: mediaConvertClientMock,
+				StepFunctionClient: stepFunctionClientMock,
+			}
+
+			output, err := handler.HandleRequest()
+
+			assert.Nil(t, err)
+			assert.Equal(t, c.expected, *output, c.status)
+			if c.status == sfn.ExecutionStatusFailed {
+				update := dynamoDBClientMock.Calls[1].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+				assert.Equal(t, "Error", *update.ExpressionAttributeValues[":status"].S)
+			} else {
+				dynamoDBClientMock.AssertNotCalled(t, "UpdateItem", mock.Anything)
+			}
+		}
+	})
+
+	t.Run("should not scan assets in a terminal status", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("Scan", mock.Anything).Return(&dynamodb.ScanOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		_, err := handler.HandleRequest()
+		assert.Nil(t, err)
+
+		scan := dynamoDBClientMock.Calls[0].Arguments.Get(0).(*dynamodb.ScanInput)
+		var excluded []string
+		for placeholder, value := range scan.ExpressionAttributeValues {
+			if strings.HasPrefix(placeholder, ":status") {
+				excluded = append(excluded, *value.S)
+			}
+		}
+		assert.ElementsMatch(t, []string{"Complete", "Error", "OutputInvalid", "Rejected", "Duplicate"}, excluded)
+	})
+
+	t.Run("should fail when the scan fails", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("Scan", mock.Anything).Return(nil, assert.AnError)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		_, err := handler.HandleRequest()
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+)
+
+// EventDetail mirrors the detail of a MediaConvert Job State Change event,
+// which is what the publish workflow expects as input.
+type EventDetail struct {
+	Timestamp          int64                `json:"timestamp"`
+	AccountId          string               `json:"accountId"`
+	Queue              string               `json:"queue"`
+	JobId              string               `json:"jobId"`
+	Status             string               `json:"status"`
+	UserMetadata       map[string]*string   `json:"userMetadata"`
+	OutputGroupDetails []*OutputGroupDetail `json:"outputGroupDetails"`
+}
+
+type OutputGroupDetail struct {
+	OutputDetails     []*OutputDetail `json:"outputDetails"`
+	PlaylistFilePaths []*string       `json:"playlistFilePaths,omitempty"`
+	Type              string          `json:"type"`
+}
+
+type OutputDetail struct {
+	OutputFilePaths []*string    `json:"outputFilePaths,omitempty"`
+	DurationInMs    int64        `json:"durationInMs"`
+	VideoDetails    *VideoDetail `json:"videoDetails,omitempty"`
+}
+
+type VideoDetail struct {
+	WidthInPx  int64 `json:"widthInPx"`
+	HeightInPx int64 `json:"heightInPx"`
+}
+
+// buildEventDetail rebuilds the completion event of a job. GetJob does not
+// return the file paths of the outputs, so they are derived from the output
+// group destinations with the MediaConvert naming rules: the input file name
+// without extension, then the output name modifier and extension.
+func buildEventDetail(job *mediaconvert.Job) EventDetail {
+	detail := EventDetail{
+		JobId:        aws.StringValue(job.Id),
+		Queue:        aws.StringValue(job.Queue),
+		Status:       aws.StringValue(job.Status),
+		UserMetadata: job.UserMetadata,
+	}
+	if job.Timing != nil && job.Timing.FinishTime != nil {
+		detail.Timestamp = job.Timing.FinishTime.UnixMilli()
+	}
+
+	if job.Settings == nil || len(job.Settings.Inputs) == 0 {
+		return detail
+	}
+
+	input := path.Base(aws.StringValue(job.Settings.Inputs[0].FileInput))
+	baseName := strings.TrimSuffix(input, path.Ext(input))
+
+	for i, group := range job.Settings.OutputGroups {
+		if group.OutputGroupSettings == nil {
+			continue
+		}
+
+		groupDetail := &OutputGroupDetail{
+			Type: strings.TrimSuffix(aws.StringValue(group.OutputGroupSettings.Type), "_SETTINGS"),
+		}
+		destination := getDestination(group.OutputGroupSettings)
+		prefix := destination
+		if strings.HasSuffix(destination, "/") {
+			prefix = destination + baseName
+		}
+
+		switch groupDetail.Type {
+		case "HLS_GROUP":
+			groupDetail.PlaylistFilePaths = []*string{aws.String(prefix + ".m3u8")}
+		case "DASH_ISO_GROUP":
+			groupDetail.PlaylistFilePaths = []*string{aws.String(prefix + ".mpd")}
+		case "CMAF_GROUP":
+			groupDetail.PlaylistFilePaths = []*string{aws.String(prefix + ".mpd"), aws.String(prefix + ".m3u8")}
+		case "MS_SMOOTH_GROUP":
+			groupDetail.PlaylistFilePaths = []*string{aws.String(prefix + ".ism")}
+		}
+
+		for j, output := range group.Outputs {
+			outputDetail := &OutputDetail{}
+			if i < len(job.OutputGroupDetails) && j < len(job.OutputGroupDetails[i].OutputDetails) {
+				jobOutput := job.OutputGroupDetails[i].OutputDetails[j]
+				outputDetail.DurationInMs = aws.Int64Value(jobOutput.DurationInMs)
+				if jobOutput.VideoDetails != nil {
+					outputDetail.VideoDetails = &VideoDetail{
+						WidthInPx:  aws.Int64Value(jobOutput.VideoDetails.WidthInPx),
+						HeightInPx: aws.Int64Value(jobOutput.VideoDetails.HeightInPx),
+					}
+				}
+			}
+
+			switch groupDetail.Type {
+			case "HLS_GROUP":
+				outputDetail.OutputFilePaths = []*string{aws.String(prefix + aws.StringValue(output.NameModifier) + ".m3u8")}
+			case "FILE_GROUP":
+				outputDetail.OutputFilePaths = []*string{aws.String(prefix + aws.StringValue(output.NameModifier) + "." + getExtension(output))}
+			}
+
+			groupDetail.OutputDetails = append(groupDetail.OutputDetails, outputDetail)
+		}
+
+		detail.OutputGroupDetails = append(detail.OutputGroupDetails, groupDetail)
+	}
+
+	return detail
+}
+
+func getDestination(settings *mediaconvert.OutputGroupSettings) string {
+	switch {
+	case settings.HlsGroupSettings != nil:
+		return aws.StringValue(settings.HlsGroupSettings.Destination)
+	case settings.DashIsoGroupSettings != nil:
+		return aws.StringValue(settings.DashIsoGroupSettings.Destination)
+	case settings.CmafGroupSettings != nil:
+		return aws.StringValue(settings.CmafGroupSettings.Destination)
+	case settings.MsSmoothGroupSettings != nil:
+		return aws.StringValue(settings.MsSmoothGroupSettings.Destination)
+	case settings.FileGroupSettings != nil:
+		return aws.StringValue(settings.FileGroupSettings.Destination)
+	}
+	return ""
+}
+
+// getExtension returns the file extension MediaConvert uses for a file group
+// output.
+func getExtension(output *mediaconvert.Output) string {
+	if output.Extension != nil {
+		return aws.StringValue(output.Extension)
+	}
+
+	if output.ContainerSettings != nil {
+		switch aws.StringValue(output.ContainerSettings.Container) {
+		case "MP4":
+			return "mp4"
+		case "MOV":
+			return "mov"
+		case "MXF":
+			return "mxf"
+		case "M2TS":
+			return "m2ts"
+		case "WEBM":
+			return "webm"
+		case "RAW":
+			return "jpg"
+		}
+	}
+	return "mp4"
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/stretchr/testify/assert"
+)
+
+var TestJobSettings = &mediaconvert.JobSettings{
+	Inputs: []*mediaconvert.Input{
+		{FileInput: aws.String("s3://src/news/clip.mov")},
+	},
+	OutputGroups: []*mediaconvert.OutputGroup{
+		{
+			OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+				Type:             aws.String("HLS_GROUP_SETTINGS"),
+				HlsGroupSettings: &mediaconvert.HlsGroupSettings{Destination: aws.String("s3://dest/guid/hls/")},
+			},
+			Outputs: []*mediaconvert.Output{
+				{NameModifier: aws.String("_720")},
+			},
+		},
+		{
+			OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+				Type:              aws.String("CMAF_GROUP_SETTINGS"),
+				CmafGroupSettings: &mediaconvert.CmafGroupSettings{Destination: aws.String("s3://dest/guid/cmaf/")},
+			},
+		},
+		{
+			OutputGroupSettings: &mediaconvert.OutputGroupSettings{
+				Type:              aws.String("FILE_GROUP_SETTINGS"),
+				FileGroupSettings: &mediaconvert.FileGroupSettings{Destination: aws.String("s3://dest/guid/mp4/")},
+			},
+			Outputs: []*mediaconvert.Output{
+				{
+					NameModifier:      aws.String("_1080"),
+					ContainerSettings: &mediaconvert.ContainerSettings{Container: aws.String("MP4")},
+				},
+			},
+		},
+	},
+}
+
+func TestBuildEventDetail(t *testing.T) {
+	detail := buildEventDetail(&mediaconvert.Job{
+		Id:       aws.String("job"),
+		Status:   aws.String("COMPLETE"),
+		Settings: TestJobSettings,
+		OutputGroupDetails: []*mediaconvert.OutputGroupDetail{
+			{
+				OutputDetails: []*mediaconvert.OutputDetail{
+					{
+						DurationInMs: aws.Int64(30000),
+						VideoDetails: &mediaconvert.VideoDetail{WidthInPx: aws.Int64(1280), HeightInPx: aws.Int64(720)},
+					},
+				},
+			},
+		},
+	})
+
+	assert.Len(t, detail.OutputGroupDetails, 3)
+
+	hls := detail.OutputGroupDetails[0]
+	assert.Equal(t, "HLS_GROUP", hls.Type)
+	assert.Equal(t, "s3://dest/guid/hls/clip.m3u8", *hls.PlaylistFilePaths[0])
+	assert.Equal(t, "s3://dest/guid/hls/clip_720.m3u8", *hls.OutputDetails[0].OutputFilePaths[0])
+	assert.Equal(t, int64(30000), hls.OutputDetails[0].DurationInMs)
+	assert.Equal(t, int64(720), hls.OutputDetails[0].VideoDetails.HeightInPx)
+
+	cmaf := detail.OutputGroupDetails[1]
+	assert.Equal(t, "s3://dest/guid/cmaf/clip.mpd", *cmaf.PlaylistFilePaths[0])
+	assert.Equal(t, "s3://dest/guid/cmaf/clip.m3u8", *cmaf.PlaylistFilePaths[1])
+
+	file := detail.OutputGroupDetails[2]
+	assert.Equal(t, "FILE_GROUP", file.Type)
+	assert.Equal(t, "s3://dest/guid/mp4/clip_1080.mp4", *file.OutputDetails[0].OutputFilePaths[0])
+}
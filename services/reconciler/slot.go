@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awslambda "github.com/aws/aws-sdk-go/service/lambda"
+)
+
+type LambdaClient interface {
+	Invoke(input *awslambda.InvokeInput) (*awslambda.InvokeOutput, error)
+}
+
+// releaseSlot hands the admission slot of the asset back when the reconciler
+// settles a job whose EventBridge event was missed. It invokes the admission
+// function with the same MediaConvert event it would have received, tagged
+// with the admissionId of the slot, so the slot is returned and the next
+// queued asset admitted. Admission ignores admissions that hold no slot,
+// which makes this safe to repeat.
+func (h *Handler) releaseSlot(record AssetRecord, status string) error {
+	function := os.Getenv("AdmissionFunction")
+	if !record.AdmissionSlot || function == "" {
+		return nil
+	}
+
+	eventJson, err := json.Marshal(MediaConvertEvent{
+		Version:    "0",
+		ID:         "reconciler-release-" + record.EncodeJobId,
+		DetailType: "MediaConvert Job State Change",
+		Source:     "aws.mediaconvert",
+		Time:       time.Now().UTC(),
+		Region:     os.Getenv("AWS_REGION"),
+		Detail: EventDetail{
+			JobId:  record.EncodeJobId,
+			Status: status,
+			UserMetadata: map[string]*string{
+				"guid":        aws.String(record.GUID),
+				"admissionId": aws.String(record.AdmissionId),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("releaseSlot: json.Marshal: %w", err)
+	}
+
+	_, err = h.LambdaClient.Invoke(&awslambda.InvokeInput{
+		FunctionName:   aws.String(function),
+		InvocationType: aws.String(awslambda.InvocationTypeEvent),
+		Payload:        eventJson,
+	})
+	if err != nil {
+		return fmt.Errorf("releaseSlot: Invoke: %w", err)
+	}
+
+	log.Printf("Released the admission slot of %s", record.GUID)
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	awslambda "github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/mediaconvert"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type LambdaClientMock struct {
+	mock.Mock
+}
+
+func (m *LambdaClientMock) Invoke(input *awslambda.InvokeInput) (*awslambda.InvokeOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*awslambda.InvokeOutput), args.Error(1)
+}
+
+func TestReleaseSlot(t *testing.T) {
+	os.Setenv("AdmissionFunction", "admission")
+	defer os.Unsetenv("AdmissionFunction")
+
+	old := time.Now().Add(-time.Hour)
+
+	t.Run("should release the slot of published and failed assets", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		mediaConvertClientMock := new(MediaConvertClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+		lambdaClientMock := new(LambdaClientMock)
+
+		complete := getRecord("complete", "job-1")
+		complete["admissionSlot"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+		complete["admissionId"] = &dynamodb.AttributeValue{S: aws.String("00000000000000000001#complete")}
+		failed := getRecord("error", "job-2")
+		failed["admissionSlot"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+
+		dynamoDBClientMock.On("Scan", mock.Anything).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{complete, failed, getRecord("noslot", "job-3")},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		mediaConvertClientMock.On("GetJob", &mediaconvert.GetJobInput{Id: aws.String("job-1")}).Return(getJob("job-1", "COMPLETE", old), nil)
+		mediaConvertClientMock.On("GetJob", &mediaconvert.GetJobInput{Id: aws.String("job-2")}).Return(getJob("job-2", "ERROR", old), nil)
+		mediaConvertClientMock.On("GetJob", &mediaconvert.GetJobInput{Id: aws.String("job-3")}).Return(getJob("job-3", "ERROR", old), nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+		lambdaClientMock.On("Invoke", mock.Anything).Return(&awslambda.InvokeOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			MediaConvertClient: mediaConvertClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+			LambdaClient:       lambdaClientMock,
+		}
+
+		output, err := handler.HandleRequest()
+
+		assert.Nil(t, err)
+		assert.Equal(t, &ReconcilerOutput{Scanned: 3, Published: 1, Failed: 2}, output)
+		lambdaClientMock.AssertNumberOfCalls(t, "Invoke", 2)
+
+		invoke := lambdaClientMock.Calls[0].Arguments.Get(0).(*awslambda.InvokeInput)
+		assert.Equal(t, "admission", *invoke.FunctionName)
+		assert.Equal(t, awslambda.InvocationTypeEvent, *invoke.InvocationType)
+
+		var event MediaConvertEvent
+		assert.Nil(t, json.Unmarshal(invoke.Payload, &event))
+		assert.Equal(t, "aws.mediaconvert", event.Source)
+		assert.Equal(t, "COMPLETE", event.Detail.Status)
+		assert.Equal(t, "complete", *event.Detail.UserMetadata["guid"])
+		assert.Equal(t, "00000000000000000001#complete", *event.Detail.UserMetadata["admissionId"])
+
+		invoke = lambdaClientMock.Calls[1].Arguments.Get(0).(*awslambda.InvokeInput)
+		assert.Nil(t, json.Unmarshal(invoke.Payload, &event))
+		assert.Equal(t, "ERROR", event.Detail.Status)
+		assert.Equal(t, "error", *event.Detail.UserMetadata["guid"])
+	})
+
+	t.Run("should keep the slot when the job changed since the scan", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		lambdaClientMock := new(LambdaClientMock)
+
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil))
+
+		handler := Handler{
+			DynamoDBClient: dynamoDBClientMock,
+			LambdaClient:   lambdaClientMock,
+		}
+
+		err := handler.markFailed(AssetRecord{GUID: "guid", EncodeJobId: "job-1", AdmissionSlot: true}, "failed")
+
+		assert.Nil(t, err)
+		lambdaClientMock.AssertNotCalled(t, "Invoke", mock.Anything)
+	})
+}
@@ -0,0 +1,120 @@
+// Command reprocess re-encodes one asset, or every asset matching a filter,
+// by invoking the reprocess Lambda with the same request the API takes.
+//
+//	go run ./cmd/reprocess -function-name reprocess -guid 123e4567-e89b-12d3-a456-426614174000
+//	go run ./cmd/reprocess -function-name reprocess -filter-template vod_Ott_1080p -from 2025-01-01 -job-template vod_Ott_1080p_v2 -dry-run
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+type ReprocessRequest struct {
+	GUID        string  `json:"guid,omitempty"`
+	Filter      *Filter `json:"filter,omitempty"`
+	JobTemplate string  `json:"jobTemplate,omitempty"`
+	Limit       int     `json:"limit,omitempty"`
+	DryRun      bool    `json:"dryRun,omitempty"`
+}
+
+type Filter struct {
+	WorkflowName string `json:"workflowName,omitempty"`
+	JobTemplate  string `json:"jobTemplate,omitempty"`
+	From         string `json:"from,omitempty"`
+	To           string `json:"to,omitempty"`
+}
+
+type LambdaClient interface {
+	Invoke(input *lambda.InvokeInput) (*lambda.InvokeOutput, error)
+}
+
+// invoke sends the request as an API Gateway proxy event and returns the
+// response body.
+func invoke(client LambdaClient, functionName string, request ReprocessRequest) (string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("invoke: json.Marshal: %w", err)
+	}
+
+	payload, err := json.Marshal(events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost,
+		Path:       "/reprocess",
+		Body:       string(body),
+	})
+	if err != nil {
+		return "", fmt.Errorf("invoke: json.Marshal: %w", err)
+	}
+
+	data, err := client.Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(functionName),
+		InvocationType: aws.String(lambda.InvocationTypeRequestResponse),
+		Payload:        payload,
+	})
+	if err != nil {
+		return "", fmt.Errorf("invoke: Invoke: %w", err)
+	}
+	if data.FunctionError != nil {
+		return "", fmt.Errorf("invoke: Invoke: %s: %s", aws.StringValue(data.FunctionError), data.Payload)
+	}
+
+	var response events.APIGatewayProxyResponse
+	if err := json.Unmarshal(data.Payload, &response); err != nil {
+		return "", fmt.Errorf("invoke: json.Unmarshal: %w", err)
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("invoke: %d: %s", response.StatusCode, response.Body)
+	}
+	return response.Body, nil
+}
+
+func main() {
+	functionName := flag.String("function-name", os.Getenv("ReprocessFunction"), "name or ARN of the reprocess Lambda")
+	guid := flag.String("guid", "", "reprocess a single asset")
+	workflowName := flag.String("workflow-name", "", "reprocess assets of this workflow")
+	filterTemplate := flag.String("filter-template", "", "reprocess assets last encoded with this job template")
+	from := flag.String("from", "", "reprocess assets started at or after this date or RFC 3339 time")
+	to := flag.String("to", "", "reprocess assets started at or before this date or RFC 3339 time")
+	jobTemplate := flag.String("job-template", "", "job template to encode with instead of the profiler's choice")
+	limit := flag.Int("limit", 0, "maximum number of assets to reprocess, 0 for all")
+	dryRun := flag.Bool("dry-run", false, "list the matching assets without reprocessing them")
+	flag.Parse()
+
+	request := ReprocessRequest{
+		GUID:        *guid,
+		JobTemplate: *jobTemplate,
+		Limit:       *limit,
+		DryRun:      *dryRun,
+	}
+	if *workflowName != "" || *filterTemplate != "" || *from != "" || *to != "" {
+		request.Filter = &Filter{
+			WorkflowName: *workflowName,
+			JobTemplate:  *filterTemplate,
+			From:         *from,
+			To:           *to,
+		}
+	}
+	if *functionName == "" || (request.GUID == "" && request.Filter == nil) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}))
+
+	body, err := invoke(lambda.New(sess), *functionName, request)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(body)
+}
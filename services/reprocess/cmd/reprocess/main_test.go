@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type LambdaClientMock struct {
+	mock.Mock
+}
+
+func (m *LambdaClientMock) Invoke(input *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*lambda.InvokeOutput), args.Error(1)
+}
+
+func response(statusCode int, body string) *lambda.InvokeOutput {
+	payload, _ := json.Marshal(events.APIGatewayProxyResponse{StatusCode: statusCode, Body: body})
+	return &lambda.InvokeOutput{Payload: payload}
+}
+
+func TestInvoke(t *testing.T) {
+	t.Run("should send the request as a POST body", func(t *testing.T) {
+		lambdaClientMock := new(LambdaClientMock)
+		lambdaClientMock.On("Invoke", mock.Anything).Return(response(202, `{"started":1}`), nil)
+
+		body, err := invoke(lambdaClientMock, "reprocess", ReprocessRequest{
+			Filter:      &Filter{JobTemplate: "old"},
+			JobTemplate: "new",
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, `{"started":1}`, body)
+
+		input := lambdaClientMock.Calls[0].Arguments.Get(0).(*lambda.InvokeInput)
+		assert.Equal(t, "reprocess", *input.FunctionName)
+
+		var request events.APIGatewayProxyRequest
+		assert.Nil(t, json.Unmarshal(input.Payload, &request))
+		assert.Equal(t, "POST", request.HTTPMethod)
+		assert.JSONEq(t, `{"filter":{"jobTemplate":"old"},"jobTemplate":"new"}`, request.Body)
+	})
+
+	t.Run("should fail on an error response", func(t *testing.T) {
+		lambdaClientMock := new(LambdaClientMock)
+		lambdaClientMock.On("Invoke", mock.Anything).Return(response(400, "either guid or filter is required"), nil)
+
+		_, err := invoke(lambdaClientMock, "reprocess", ReprocessRequest{})
+
+		assert.ErrorContains(t, err, "either guid or filter is required")
+	})
+
+	t.Run("should fail on a function error", func(t *testing.T) {
+		lambdaClientMock := new(LambdaClientMock)
+		lambdaClientMock.On("Invoke", mock.Anything).Return(&lambda.InvokeOutput{FunctionError: aws.String("Unhandled")}, nil)
+
+		_, err := invoke(lambdaClientMock, "reprocess", ReprocessRequest{GUID: "guid"})
+
+		assert.Error(t, err)
+	})
+}
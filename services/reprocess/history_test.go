@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPutHistoryEvent(t *testing.T) {
+	t.Run("should not fail when the event is already recorded", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil))
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		err := handler.putHistoryEvent(newHistoryEvent("guid", time.Date(2025, 2, 23, 12, 0, 0, 0, time.UTC)))
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("should still start the workflow when the event cannot be written", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(getRecord("guid", "Complete", ""), nil)
+		dynamoDBClientMock.On("PutItem", mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+			return input.ConditionExpression != nil
+		})).Return(nil, errors.New("throttled"))
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock, StepFunctionClient: stepFunctionClientMock}
+		response, err := handler.Reprocess(ReprocessRequest{GUID: "guid"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Started)
+		stepFunctionClientMock.AssertNumberOfCalls(t, "StartExecution", 1)
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+type Handler struct {
+	DynamoDBClient     DynamoDBClient
+	StepFunctionClient StepFunctionClient
+	Now                func() time.Time
+}
+
+// HandleRequest serves POST /reprocess and POST /reprocess/{guid}. The body
+// is a ReprocessRequest; a guid in the path takes precedence over the body.
+func (h *Handler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	log.Printf("REQUEST:: %s %s %s", request.HTTPMethod, request.Path, request.Body)
+
+	if request.HTTPMethod != http.MethodPost {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusMethodNotAllowed,
+			Body:       "Method not allowed",
+		}, nil
+	}
+
+	var reprocessRequest ReprocessRequest
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &reprocessRequest); err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       "Invalid request body",
+			}, nil
+		}
+	}
+	if guid := request.PathParameters["guid"]; guid != "" {
+		reprocessRequest.GUID = guid
+	}
+
+	response, err := h.Reprocess(reprocessRequest)
+	if err != nil {
+		if errors.Is(err, ErrNoTarget) || errors.Is(err, ErrInvalidFilter) {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       err.Error(),
+			}, nil
+		}
+		log.Printf("reprocess: main.Handler.HandleRequest: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error reprocessing assets",
+		}, nil
+	}
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error creating response",
+		}, nil
+	}
+	log.Printf("RESPONSE:: %s", responseBody)
+
+	statusCode := http.StatusAccepted
+	if reprocessRequest.DryRun {
+		statusCode = http.StatusOK
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: string(responseBody),
+	}, nil
+}
+
+func main() {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}))
+	handler := &Handler{
+		DynamoDBClient:     dynamodb.New(sess),
+		StepFunctionClient: sfn.New(sess),
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandleRequest(t *testing.T) {
+	t.Run("should reprocess the asset in the path", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("GetItem", getItemFor("guid")).Return(&dynamodb.GetItemOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		response, err := handler.HandleRequest(events.APIGatewayProxyRequest{
+			HTTPMethod:     http.MethodPost,
+			PathParameters: map[string]string{"guid": "guid"},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, response.StatusCode)
+		assert.Contains(t, response.Body, `"reason":"not found"`)
+	})
+
+	t.Run("should return 400 on an invalid request", func(t *testing.T) {
+		handler := Handler{}
+
+		response, err := handler.HandleRequest(events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: "{"})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+		response, err = handler.HandleRequest(events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: "{}"})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+
+	t.Run("should return 500 when the scan fails", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("Scan", mock.Anything).Return(nil, assert.AnError)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		response, err := handler.HandleRequest(events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Body:       `{"filter":{"workflowName":"vod"}}`,
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	})
+
+	t.Run("should only accept POST", func(t *testing.T) {
+		handler := Handler{}
+
+		response, err := handler.HandleRequest(events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+	})
+}
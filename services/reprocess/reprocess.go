@@ -0,0 +1,401 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// startTimeFormat is the startTime format written by input-validate.
+const startTimeFormat = "2006-01-02T15:04:05.000Z"
+
+var (
+	ErrNoTarget      = errors.New("either guid or filter is required")
+	ErrInvalidFilter = errors.New("filter needs at least one of workflowName, jobTemplate, from or to")
+)
+
+// Workflow statuses an asset can be reprocessed from. Assets in any other
+// status still have a workflow running.
+var reprocessableStatuses = []string{"Complete", "Error", "Stalled", "Duplicate", "OutputInvalid"}
+
+// versionAttributes are copied from the asset record into its VERSION# item
+// before a reprocess overwrites them. The encoding job and output are only
+// kept by their S3 pointer, inline they would not fit next to the outputs.
+var versionAttributes = []string{
+	"workflowStatus", "startTime", "endTime", "jobTemplate", "encodingProfile", "encodeJobId",
+	"hlsPlaylist", "hlsUrl", "dashPlaylist", "dashUrl", "mp4Outputs", "mp4Urls",
+	"mssPlaylist", "mssUrl", "cmafDashPlaylist", "cmafDashUrl", "cmafHlsPlaylist", "cmafHlsUrl",
+	"thumbNails", "thumbNailsUrls", "renditions", "qualityReport", "fileOutputs", "cmafGroups",
+	"cdnUrls", "cdnEgressEndpoints", "egressEndpoints", "playbackUrl", "outputErrors",
+	"encodingJobPointer", "encodingOutputPointer",
+}
+
+// rollbackAttributes are the attributes of the asset record reprocessAsset
+// changes, restored when the process workflow cannot be started.
+var rollbackAttributes = []string{
+	"workflowStatus", "outputVersion", "startTime", "retryCount", "encodeJobId", "endTime", "lastFailedJobId",
+}
+
+type ReprocessRequest struct {
+	GUID        string  `json:"guid,omitempty"`
+	Filter      *Filter `json:"filter,omitempty"`
+	JobTemplate string  `json:"jobTemplate,omitempty"`
+	Limit       int     `json:"limit,omitempty"`
+	DryRun      bool    `json:"dryRun,omitempty"`
+}
+
+// Filter selects assets for a bulk re-encode. From and To bound the
+// startTime of the last workflow run, inclusive.
+type Filter struct {
+	WorkflowName string `json:"workflowName,omitempty"`
+	JobTemplate  string `json:"jobTemplate,omitempty"`
+	From         string `json:"from,omitempty"`
+	To           string `json:"to,omitempty"`
+}
+
+type ProcessWorkflowInput struct {
+	GUID        string  `json:"guid"`
+	JobTemplate *string `json:"jobTemplate,omitempty"`
+}
+
+type AssetRecord struct {
+	GUID           string `json:"guid"`
+	WorkflowStatus string `json:"workflowStatus"`
+	OutputVersion  int    `json:"outputVersion"`
+}
+
+type ReprocessResult struct {
+	GUID    string `json:"guid"`
+	Status  string `json:"status"`
+	Version int    `json:"version,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type ReprocessResponse struct {
+	Matched int                `json:"matched"`
+	Started int                `json:"started"`
+	Results []*ReprocessResult `json:"results"`
+}
+
+type DynamoDBClient interface {
+	GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+}
+
+type StepFunctionClient interface {
+	StartExecution(input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error)
+}
+
+// Reprocess re-encodes the asset or every asset matching the filter.
+func (h *Handler) Reprocess(request ReprocessRequest) (*ReprocessResponse, error) {
+	var guids []string
+	switch {
+	case request.GUID != "":
+		guids = []string{request.GUID}
+	case request.Filter != nil:
+		var err error
+		guids, err = h.findAssets(request.Filter, request.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("Reprocess: %w", err)
+		}
+	default:
+		return nil, ErrNoTarget
+	}
+
+	response := &ReprocessResponse{Matched: len(guids), Results: []*ReprocessResult{}}
+	for _, guid := range guids {
+		result, err := h.reprocessAsset(guid, request)
+		if err != nil {
+			// Keep going, a bulk request reports failures per asset
+			log.Printf("reprocess: %s: %v", guid, err)
+			result = &ReprocessResult{GUID: guid, Status: "Failed", Reason: err.Error()}
+		}
+		if result.Status == "Started" {
+			response.Started++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
+
+func (h *Handler) findAssets(filter *Filter, limit int) ([]string, error) {
+	conditions := []string{"SK = :metadata"}
+	values := map[string]*dynamodb.AttributeValue{
+		":metadata": {S: aws.String("METADATA")},
+	}
+	if filter.WorkflowName != "" {
+		conditions = append(conditions, "workflowName = :workflowName")
+		values[":workflowName"] = &dynamodb.AttributeValue{S: aws.String(filter.WorkflowName)}
+	}
+	if filter.JobTemplate != "" {
+		conditions = append(conditions, "jobTemplate = :jobTemplate")
+		values[":jobTemplate"] = &dynamodb.AttributeValue{S: aws.String(filter.JobTemplate)}
+	}
+	for _, bound := range []struct{ placeholder, operator, value string }{
+		{":from", ">=", filter.From},
+		{":to", "<=", filter.To},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		value, err := normalizeTime(bound.value)
+		if err != nil {
+			return nil, fmt.Errorf("findAssets: %w", err)
+		}
+		conditions = append(conditions, "startTime "+bound.operator+" "+bound.placeholder)
+		values[bound.placeholder] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+	if len(conditions) == 1 {
+		return nil, ErrInvalidFilter
+	}
+
+	var guids []string
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		data, err := h.DynamoDBClient.Scan(&dynamodb.ScanInput{
+			TableName:                 aws.String(os.Getenv("DynamoDBTable")),
+			FilterExpression:          aws.String(strings.Join(conditions, " AND ")),
+			ProjectionExpression:      aws.String("guid"),
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("findAssets: Scan: %w", err)
+		}
+
+		var page []AssetRecord
+		if err := dynamodbattribute.UnmarshalListOfMaps(data.Items, &page); err != nil {
+			return nil, fmt.Errorf("findAssets: UnmarshalListOfMaps: %w", err)
+		}
+		for _, record := range page {
+			guids = append(guids, record.GUID)
+			if limit > 0 && len(guids) >= limit {
+				return guids, nil
+			}
+		}
+
+		if len(data.LastEvaluatedKey) == 0 {
+			return guids, nil
+		}
+		startKey = data.LastEvaluatedKey
+	}
+}
+
+// normalizeTime accepts dates and RFC 3339 times and formats them like
+// startTime, so they compare as strings.
+func normalizeTime(value string) (string, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format(startTimeFormat), nil
+		}
+	}
+	return "", fmt.Errorf("normalizeTime: invalid time %q", value)
+}
+
+// reprocessAsset keeps the current outputs as a VERSION# item, moves the
+// asset to the next output version and starts the process workflow. Encode
+// writes versions after the first under a v<version> prefix, so the files of
+// earlier versions stay in place. When the workflow cannot be started the
+// record is rolled back and the asset reported as Failed.
+func (h *Handler) reprocessAsset(guid string, request ReprocessRequest) (*ReprocessResult, error) {
+	key := map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String("VIDEO#" + guid)},
+		"SK": {S: aws.String("METADATA")},
+	}
+
+	data, err := h.DynamoDBClient.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(os.Getenv("DynamoDBTable")),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reprocessAsset: GetItem: %w", err)
+	}
+	if data.Item == nil {
+		return &ReprocessResult{GUID: guid, Status: "Skipped", Reason: "not found"}, nil
+	}
+
+	var record AssetRecord
+	if err := dynamodbattribute.UnmarshalMap(data.Item, &record); err != nil {
+		return nil, fmt.Errorf("reprocessAsset: UnmarshalMap: %w", err)
+	}
+	if !isReprocessable(record.WorkflowStatus) {
+		return &ReprocessResult{GUID: guid, Status: "Skipped", Reason: "workflow status " + record.WorkflowStatus}, nil
+	}
+
+	current := record.OutputVersion
+	if current == 0 {
+		current = 1
+	}
+	next := current + 1
+
+	if request.DryRun {
+		return &ReprocessResult{GUID: guid, Status: "DryRun", Version: next}, nil
+	}
+
+	if err := h.putVersion(guid, current, data.Item); err != nil {
+		return nil, fmt.Errorf("reprocessAsset: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if h.Now != nil {
+		now = h.Now().UTC()
+	}
+
+	// encodeJobId is removed so the reconciler does not publish the previous
+	// job again while the asset is reprocessing. retryCount and
+	// lastFailedJobId are reset so the new run gets the full retry budget of
+	// error-handler. The record version is bumped
+	// so that updates of a workflow still running for the previous version
+	// fail their version check.
+	_, err = h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(os.Getenv("DynamoDBTable")),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET outputVersion = :next, workflowStatus = :reprocessing, startTime = :now, retryCount = :zero, #version = if_not_exists(#version, :zero) + :one REMOVE encodeJobId, endTime, lastFailedJobId"),
+		ConditionExpression: aws.String("workflowStatus = :status AND (attribute_not_exists(outputVersion) OR outputVersion = :current)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":next":         {N: aws.String(strconv.Itoa(next))},
+			":current":      {N: aws.String(strconv.Itoa(record.OutputVersion))},
+			":reprocessing": {S: aws.String("Reprocessing")},
+			":status":       {S: aws.String(record.WorkflowStatus)},
+			":now":          {S: aws.String(now.Format(startTimeFormat))},
+			":zero":         {N: aws.String("0")},
+			":one":          {N: aws.String("1")},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return &ReprocessResult{GUID: guid, Status: "Skipped", Reason: "changed concurrently"}, nil
+		}
+		return nil, fmt.Errorf("reprocessAsset: UpdateItem: %w", err)
+	}
+
+	input := ProcessWorkflowInput{GUID: guid}
+	if request.JobTemplate != "" {
+		input.JobTemplate = aws.String(request.JobTemplate)
+	}
+	inputJson, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("reprocessAsset: json.Marshal: %w", err)
+	}
+
+	_, err = h.StepFunctionClient.StartExecution(&sfn.StartExecutionInput{
+		Name:            aws.String(fmt.Sprintf("%s-reprocess-%d", guid, next)),
+		Input:           aws.String(string(inputJson)),
+		StateMachineArn: aws.String(os.Getenv("ProcessWorkflow")),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != sfn.ErrCodeExecutionAlreadyExists {
+			// Without a workflow the asset would stay in Reprocessing, which
+			// cannot be reprocessed again
+			log.Printf("reprocess: reprocessAsset: %s: StartExecution: %v", guid, err)
+			reason := fmt.Sprintf("StartExecution: %v", err)
+			if rollbackErr := h.rollback(key, data.Item, next); rollbackErr != nil {
+				log.Printf("reprocess: reprocessAsset: %s: %v", guid, rollbackErr)
+				reason += fmt.Sprintf(", %v", rollbackErr)
+			}
+			return &ReprocessResult{GUID: guid, Status: "Failed", Version: next, Reason: reason}, nil
+		}
+	}
+
+	// Only the request that moved the asset to Reprocessing gets here, so
+	// the event is written once. The timeline is for troubleshooting, a
+	// failed write does not stop the reprocess.
+	if err := h.putHistoryEvent(newHistoryEvent(guid, now)); err != nil {
+		log.Printf("reprocess: reprocessAsset: %v", err)
+	}
+
+	log.Printf("Reprocessing %s as version %d", guid, next)
+	return &ReprocessResult{GUID: guid, Status: "Started", Version: next}, nil
+}
+
+// rollback restores the attributes the asset record had before it was moved
+// to the next version, unless the asset changed since.
+func (h *Handler) rollback(key map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue, next int) error {
+	set := []string{"#version = if_not_exists(#version, :zero) + :one"}
+	var remove []string
+	values := map[string]*dynamodb.AttributeValue{
+		":reprocessing": {S: aws.String("Reprocessing")},
+		":next":         {N: aws.String(strconv.Itoa(next))},
+		":zero":         {N: aws.String("0")},
+		":one":          {N: aws.String("1")},
+	}
+	for _, attribute := range rollbackAttributes {
+		if value, ok := item[attribute]; ok {
+			set = append(set, attribute+" = :"+attribute)
+			values[":"+attribute] = value
+		} else {
+			remove = append(remove, attribute)
+		}
+	}
+
+	expression := "SET " + strings.Join(set, ", ")
+	if len(remove) > 0 {
+		expression += " REMOVE " + strings.Join(remove, ", ")
+	}
+
+	_, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(os.Getenv("DynamoDBTable")),
+		Key:                 key,
+		UpdateExpression:    aws.String(expression),
+		ConditionExpression: aws.String("workflowStatus = :reprocessing AND outputVersion = :next"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		return fmt.Errorf("rollback: UpdateItem: %w", err)
+	}
+	return nil
+}
+
+func isReprocessable(status string) bool {
+	for _, reprocessable := range reprocessableStatuses {
+		if status == reprocessable {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) putVersion(guid string, version int, item map[string]*dynamodb.AttributeValue) error {
+	versionItem := map[string]*dynamodb.AttributeValue{
+		"PK":         {S: aws.String("VIDEO#" + guid)},
+		"SK":         {S: aws.String(fmt.Sprintf("VERSION#%04d", version))},
+		"guid":       {S: aws.String(guid)},
+		"version":    {N: aws.String(strconv.Itoa(version))},
+		"archivedAt": {S: aws.String(time.Now().UTC().Format(startTimeFormat))},
+	}
+	for _, attribute := range versionAttributes {
+		if value, ok := item[attribute]; ok {
+			versionItem[attribute] = value
+		}
+	}
+
+	_, err := h.DynamoDBClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Item:      versionItem,
+	})
+	if err != nil {
+		return fmt.Errorf("putVersion: PutItem: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBClientMock struct {
+	mock.Mock
+}
+
+func (m *DynamoDBClientMock) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
+}
+
+type StepFunctionClientMock struct {
+	mock.Mock
+}
+
+func (m *StepFunctionClientMock) StartExecution(input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sfn.StartExecutionOutput), args.Error(1)
+}
+
+func getRecord(guid string, status string, version string) *dynamodb.GetItemOutput {
+	item := map[string]*dynamodb.AttributeValue{
+		"guid":           {S: aws.String(guid)},
+		"workflowStatus": {S: aws.String(status)},
+		"jobTemplate":    {S: aws.String("vod_Ott_1080p")},
+		"hlsUrl":         {S: aws.String("https://cdn/" + guid + "/hls/clip.m3u8")},
+		"srcMediainfo":   {S: aws.String("{}")},
+		"renditions":     {L: []*dynamodb.AttributeValue{{M: map[string]*dynamodb.AttributeValue{"height": {N: aws.String("1080")}}}}},
+		"cdnUrls":        {M: map[string]*dynamodb.AttributeValue{"default": {M: map[string]*dynamodb.AttributeValue{}}}},
+	}
+	if version != "" {
+		item["outputVersion"] = &dynamodb.AttributeValue{N: aws.String(version)}
+	}
+	return &dynamodb.GetItemOutput{Item: item}
+}
+
+func getItemFor(guid string) interface{} {
+	return mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+		return *input.Key["PK"].S == "VIDEO#"+guid
+	})
+}
+
+func TestReprocess(t *testing.T) {
+	t.Run("should archive the current outputs and start the next version", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(getRecord("guid", "Complete", "2"), nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient:     dynamoDBClientMock,
+			StepFunctionClient: stepFunctionClientMock,
+			Now:                func() time.Time { return time.Date(2025, 2, 23, 12, 0, 0, 0, time.UTC) },
+		}
+		response, err := handler.Reprocess(ReprocessRequest{GUID: "guid", JobTemplate: "vod_Ott_1080p_v2"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Started)
+		assert.Equal(t, &ReprocessResult{GUID: "guid", Status: "Started", Version: 3}, response.Results[0])
+
+		version := dynamoDBClientMock.Calls[1].Arguments.Get(0).(*dynamodb.PutItemInput)
+		assert.Equal(t, "VERSION#0002", *version.Item["SK"].S)
+		assert.Equal(t, "https://cdn/guid/hls/clip.m3u8", *version.Item["hlsUrl"].S)
+		assert.NotContains(t, version.Item, "srcMediainfo")
+		assert.Contains(t, version.Item, "renditions")
+		assert.Contains(t, version.Item, "cdnUrls")
+
+		update := dynamoDBClientMock.Calls[2].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+		assert.Equal(t, "3", *update.ExpressionAttributeValues[":next"].N)
+		assert.Equal(t, "Reprocessing", *update.ExpressionAttributeValues[":reprocessing"].S)
+		assert.Contains(t, *update.UpdateExpression, "#version = if_not_exists(#version, :zero) + :one")
+		assert.Contains(t, *update.UpdateExpression, "retryCount = :zero")
+		assert.Contains(t, *update.UpdateExpression, "lastFailedJobId")
+		assert.Equal(t, "2025-02-23T12:00:00.000Z", *update.ExpressionAttributeValues[":now"].S)
+
+		history := dynamoDBClientMock.Calls[3].Arguments.Get(0).(*dynamodb.PutItemInput)
+		assert.Equal(t, "VIDEO#guid", *history.Item["PK"].S)
+		assert.Equal(t, "EVENT#2025-02-23T12:00:00.000000Z#Reprocess", *history.Item["SK"].S)
+		assert.Equal(t, "Reprocessing", *history.Item["status"].S)
+		assert.Equal(t, "attribute_not_exists(SK)", *history.ConditionExpression)
+
+		start := stepFunctionClientMock.Calls[0].Arguments.Get(0).(*sfn.StartExecutionInput)
+		assert.Equal(t, "guid-reprocess-3", *start.Name)
+		var input ProcessWorkflowInput
+		assert.Nil(t, json.Unmarshal([]byte(*start.Input), &input))
+		assert.Equal(t, "vod_Ott_1080p_v2", *input.JobTemplate)
+	})
+
+	t.Run("should treat assets without a version as version 1", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(getRecord("guid", "Error", ""), nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(nil, awserr.New(sfn.ErrCodeExecutionAlreadyExists, "exists", nil))
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock, StepFunctionClient: stepFunctionClientMock}
+		response, err := handler.Reprocess(ReprocessRequest{GUID: "guid"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, response.Results[0].Version)
+
+		version := dynamoDBClientMock.Calls[1].Arguments.Get(0).(*dynamodb.PutItemInput)
+		assert.Equal(t, "VERSION#0001", *version.Item["SK"].S)
+	})
+
+	t.Run("should roll the record back when the workflow cannot be started", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		record := getRecord("guid", "Complete", "2")
+		record.Item["encodeJobId"] = &dynamodb.AttributeValue{S: aws.String("job-1")}
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(record, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(nil, awserr.New(sfn.ErrCodeExecutionLimitExceeded, "limit", nil))
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock, StepFunctionClient: stepFunctionClientMock}
+		response, err := handler.Reprocess(ReprocessRequest{GUID: "guid"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, response.Started)
+		assert.Equal(t, "Failed", response.Results[0].Status)
+
+		rollback := dynamoDBClientMock.Calls[3].Arguments.Get(0).(*dynamodb.UpdateItemInput)
+		assert.Equal(t, "workflowStatus = :reprocessing AND outputVersion = :next", *rollback.ConditionExpression)
+		assert.Equal(t, "SET #version = if_not_exists(#version, :zero) + :one, workflowStatus = :workflowStatus, outputVersion = :outputVersion, encodeJobId = :encodeJobId REMOVE startTime, retryCount, endTime, lastFailedJobId", *rollback.UpdateExpression)
+		assert.Equal(t, "Complete", *rollback.ExpressionAttributeValues[":workflowStatus"].S)
+		assert.Equal(t, "3", *rollback.ExpressionAttributeValues[":next"].N)
+		dynamoDBClientMock.AssertNumberOfCalls(t, "PutItem", 1)
+	})
+
+	t.Run("should skip assets that are still processing", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(getRecord("guid", "Ingest", ""), nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		response, err := handler.Reprocess(ReprocessRequest{GUID: "guid"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Skipped", response.Results[0].Status)
+		dynamoDBClientMock.AssertNotCalled(t, "UpdateItem", mock.Anything)
+	})
+
+	t.Run("should reprocess every asset matching the filter", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		stepFunctionClientMock := new(StepFunctionClientMock)
+
+		dynamoDBClientMock.On("Scan", mock.Anything).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"guid": {S: aws.String("a")}},
+				{"guid": {S: aws.String("b")}},
+			},
+		}, nil)
+		dynamoDBClientMock.On("GetItem", getItemFor("a")).Return(getRecord("a", "Complete", ""), nil)
+		dynamoDBClientMock.On("GetItem", getItemFor("b")).Return(nil, assert.AnError)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		stepFunctionClientMock.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock, StepFunctionClient: stepFunctionClientMock}
+		response, err := handler.Reprocess(ReprocessRequest{
+			Filter: &Filter{WorkflowName: "vod", JobTemplate: "vod_Ott_1080p", From: "2025-01-01"},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, response.Matched)
+		assert.Equal(t, 1, response.Started)
+		assert.Equal(t, "Failed", response.Results[1].Status)
+
+		scan := dynamoDBClientMock.Calls[0].Arguments.Get(0).(*dynamodb.ScanInput)
+		assert.Equal(t, "SK = :metadata AND workflowName = :workflowName AND jobTemplate = :jobTemplate AND startTime >= :from", *scan.FilterExpression)
+		assert.Equal(t, "2025-01-01T00:00:00.000Z", *scan.ExpressionAttributeValues[":from"].S)
+	})
+
+	t.Run("should only list matches on a dry run", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("Scan", mock.Anything).Return(&dynamodb.ScanOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"guid": {S: aws.String("a")}},
+				{"guid": {S: aws.String("b")}},
+			},
+		}, nil)
+		dynamoDBClientMock.On("GetItem", mock.Anything).Return(getRecord("a", "Complete", ""), nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		response, err := handler.Reprocess(ReprocessRequest{Filter: &Filter{WorkflowName: "vod"}, Limit: 1, DryRun: true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, response.Matched)
+		assert.Equal(t, "DryRun", response.Results[0].Status)
+		dynamoDBClientMock.AssertNotCalled(t, "UpdateItem", mock.Anything)
+	})
+
+	t.Run("should reject requests without a target", func(t *testing.T) {
+		handler := Handler{}
+
+		_, err := handler.Reprocess(ReprocessRequest{})
+		assert.ErrorIs(t, err, ErrNoTarget)
+
+		_, err = handler.Reprocess(ReprocessRequest{Filter: &Filter{}})
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+	})
+}
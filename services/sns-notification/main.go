@@ -61,10 +61,24 @@ type SNSNotificationEvent struct {
 	SrcVideo               string                      `json:"srcVideo"`
 	EnableMediaPackage     bool                        `json:"enableMediaPackage"`
 	SrcMediainfo           string                      `json:"srcMediainfo"`
+	Fingerprint            string                      `json:"fingerprint,omitempty"`
+	DuplicateOf            string                      `json:"duplicateOf,omitempty"`
+	DuplicatePolicy        string                      `json:"duplicatePolicy,omitempty"`
+	RejectReasons          []string                    `json:"rejectReasons,omitempty"`
 	EncodingJob            mediaconvert.CreateJobInput `json:"encodingJob"`
 	EncodeJobId            string                      `json:"encodeJobId"`
 	EncodingOutput         EventDetail                 `json:"encodingOutput"`
 	EndTime                time.Time                   `json:"endTime"`
+	OutputErrors           []string                    `json:"outputErrors,omitempty"`
+	QualityReport          *QualityReport              `json:"qualityReport,omitempty"`
+	Renditions             []*Rendition                `json:"renditions,omitempty"`
+	FileOutputs            *FileOutputs                `json:"fileOutputs,omitempty"`
+	PrivateContent         bool                        `json:"privateContent,omitempty"`
+	PlaybackUrl            string                      `json:"playbackUrl,omitempty"`
+	Cdns                   []*Cdn                      `json:"cdns,omitempty"`
+	CdnUrls                CdnUrls                     `json:"cdnUrls,omitempty"`
+	CdnEgressEndpoints     CdnUrls                     `json:"cdnEgressEndpoints,omitempty"`
+	Version                int                         `json:"version,omitempty"`
 
 	// Output
 	HlsPlaylist            *string           `json:"hlsPlaylist"`
@@ -79,6 +93,7 @@ type SNSNotificationEvent struct {
 	CmafDashUrl            *string           `json:"cmafDashUrl"`
 	CmafHlsPlaylist        *string           `json:"cmafHlsPlaylist"`
 	CmafHlsUrl             *string           `json:"cmafHlsUrl"`
+	CmafGroups             []*CmafGroup      `json:"cmafGroups,omitempty"`
 	ThumbNails             []*string         `json:"thumbNails"`
 	ThumbNailsUrls         []*string         `json:"thumbNailsUrls"`
 	MediaPackageResourceId string            `json:"mediaPackageResourceId"`
@@ -103,6 +118,75 @@ type Warning struct {
 	Count int64 `json:"count"`
 }
 
+type QualityReport struct {
+	QvbrQualityFloor float64             `json:"qvbrQualityFloor"`
+	Renditions       []*RenditionQuality `json:"renditions"`
+	BlackVideoMs     int64               `json:"blackVideoMs,omitempty"`
+	PaddingMs        int64               `json:"paddingMs,omitempty"`
+	Warnings         []*Warning          `json:"warnings,omitempty"`
+	Flagged          bool                `json:"flagged"`
+	Summary          string              `json:"summary"`
+}
+
+type RenditionQuality struct {
+	Output                 string   `json:"output"`
+	GroupType              string   `json:"groupType"`
+	WidthInPx              int64    `json:"widthInPx"`
+	HeightInPx             int64    `json:"heightInPx"`
+	AverageBitrate         float64  `json:"averageBitrate"`
+	QvbrAvgQuality         float64  `json:"qvbrAvgQuality,omitempty"`
+	QvbrMinQuality         float64  `json:"qvbrMinQuality,omitempty"`
+	QvbrMaxQuality         float64  `json:"qvbrMaxQuality,omitempty"`
+	QvbrMinQualityLocation float64  `json:"qvbrMinQualityLocation,omitempty"`
+	QvbrMaxQualityLocation float64  `json:"qvbrMaxQualityLocation,omitempty"`
+	Flags                  []string `json:"flags,omitempty"`
+}
+
+type Rendition struct {
+	GroupType      string  `json:"groupType"`
+	File           string  `json:"file"`
+	Url            string  `json:"url"`
+	WidthInPx      int64   `json:"widthInPx,omitempty"`
+	HeightInPx     int64   `json:"heightInPx,omitempty"`
+	AverageBitrate float64 `json:"averageBitrate,omitempty"`
+	DurationInMs   int64   `json:"durationInMs"`
+	Codec          string  `json:"codec,omitempty"`
+}
+
+type FileOutput struct {
+	File string `json:"file"`
+	Url  string `json:"url"`
+}
+
+type FileOutputs struct {
+	Mp4       []*FileOutput `json:"mp4,omitempty"`
+	Mezzanine []*FileOutput `json:"mezzanine,omitempty"`
+	Audio     []*FileOutput `json:"audio,omitempty"`
+	Webm      []*FileOutput `json:"webm,omitempty"`
+	Captions  []*FileOutput `json:"captions,omitempty"`
+	Other     []*FileOutput `json:"other,omitempty"`
+}
+
+type CmafGroup struct {
+	Name         string  `json:"name,omitempty"`
+	DashPlaylist *string `json:"dashPlaylist,omitempty"`
+	DashUrl      *string `json:"dashUrl,omitempty"`
+	HlsPlaylist  *string `json:"hlsPlaylist,omitempty"`
+	HlsUrl       *string `json:"hlsUrl,omitempty"`
+}
+
+// Cdn is a delivery hostname, see output-validate
+type Cdn struct {
+	Name    string   `json:"name"`
+	Host    string   `json:"host"`
+	Weight  int      `json:"weight"`
+	Regions []string `json:"regions,omitempty"`
+	Origin  bool     `json:"origin,omitempty"`
+}
+
+// CdnUrls maps a protocol to its URL on each CDN, by CDN name
+type CdnUrls map[string]map[string]string
+
 type UserMetadata struct {
 	GUID     string `json:"guid"`
 	Workflow string `json:"workflow"`
@@ -152,10 +236,24 @@ type SNSNotificationOutput struct {
 	SrcVideo               string                      `json:"srcVideo"`
 	EnableMediaPackage     bool                        `json:"enableMediaPackage"`
 	SrcMediainfo           string                      `json:"srcMediainfo"`
+	Fingerprint            string                      `json:"fingerprint,omitempty"`
+	DuplicateOf            string                      `json:"duplicateOf,omitempty"`
+	DuplicatePolicy        string                      `json:"duplicatePolicy,omitempty"`
+	RejectReasons          []string                    `json:"rejectReasons,omitempty"`
 	EncodingJob            mediaconvert.CreateJobInput `json:"encodingJob"`
 	EncodeJobId            string                      `json:"encodeJobId"`
 	EncodingOutput         EventDetail                 `json:"encodingOutput"`
 	EndTime                time.Time                   `json:"endTime"`
+	OutputErrors           []string                    `json:"outputErrors,omitempty"`
+	QualityReport          *QualityReport              `json:"qualityReport,omitempty"`
+	Renditions             []*Rendition                `json:"renditions,omitempty"`
+	FileOutputs            *FileOutputs                `json:"fileOutputs,omitempty"`
+	PrivateContent         bool                        `json:"privateContent,omitempty"`
+	PlaybackUrl            string                      `json:"playbackUrl,omitempty"`
+	Cdns                   []*Cdn                      `json:"cdns,omitempty"`
+	CdnUrls                CdnUrls                     `json:"cdnUrls,omitempty"`
+	CdnEgressEndpoints     CdnUrls                     `json:"cdnEgressEndpoints,omitempty"`
+	Version                int                         `json:"version,omitempty"`
 
 	// Output
 	HlsPlaylist            *string           `json:"hlsPlaylist"`
@@ -170,6 +268,7 @@ type SNSNotificationOutput struct {
 	CmafDashUrl            *string           `json:"cmafDashUrl"`
 	CmafHlsPlaylist        *string           `json:"cmafHlsPlaylist"`
 	CmafHlsUrl             *string           `json:"cmafHlsUrl"`
+	CmafGroups             []*CmafGroup      `json:"cmafGroups,omitempty"`
 	ThumbNails             []*string         `json:"thumbNails"`
 	ThumbNailsUrls         []*string         `json:"thumbNailsUrls"`
 	MediaPackageResourceId string            `json:"mediaPackageResourceId"`
@@ -177,9 +276,15 @@ type SNSNotificationOutput struct {
 }
 
 type Message struct {
-	Status   string `json:"workflowStatus"`
-	GUID     string `json:"guid"`
-	SrcVideo string `json:"srcVideo"`
+	Status          string   `json:"workflowStatus"`
+	GUID            string   `json:"guid"`
+	SrcVideo        string   `json:"srcVideo"`
+	DuplicateOf     string   `json:"duplicateOf,omitempty"`
+	DuplicatePolicy string   `json:"duplicatePolicy,omitempty"`
+	RejectReasons   []string `json:"rejectReasons,omitempty"`
+	OutputErrors    []string `json:"outputErrors,omitempty"`
+	QualitySummary  string   `json:"qualitySummary,omitempty"`
+	QualityFlagged  bool     `json:"qualityFlagged,omitempty"`
 }
 
 type CompleteMessage struct {
@@ -209,6 +314,15 @@ type CompleteMessage struct {
 	ThumbNailsUrls         []*string         `json:"thumbNailsUrls"`
 	MediaPackageResourceId string            `json:"mediaPackageResourceId"`
 	EgressEndpoints        map[string]string `json:"egressEndpoints"`
+	Renditions             []*Rendition      `json:"renditions,omitempty"`
+	FileOutputs            *FileOutputs      `json:"fileOutputs,omitempty"`
+	PrivateContent         bool              `json:"privateContent,omitempty"`
+	PlaybackUrl            string            `json:"playbackUrl,omitempty"`
+	Cdns                   []*Cdn            `json:"cdns,omitempty"`
+	CdnUrls                CdnUrls           `json:"cdnUrls,omitempty"`
+	CdnEgressEndpoints     CdnUrls           `json:"cdnEgressEndpoints,omitempty"`
+	QualitySummary         string            `json:"qualitySummary,omitempty"`
+	QualityFlagged         bool              `json:"qualityFlagged,omitempty"`
 }
 
 func (h *Handler) HandleRequest(event SNSNotificationEvent) (*SNSNotificationOutput, error) {
@@ -221,6 +335,13 @@ func (h *Handler) HandleRequest(event SNSNotificationEvent) (*SNSNotificationOut
 	var message interface{}
 	subject := "Workflow Status:: " + event.WorkflowStatus + ":: " + event.GUID
 
+	var qualitySummary string
+	var qualityFlagged bool
+	if event.QualityReport != nil {
+		qualitySummary = event.QualityReport.Summary
+		qualityFlagged = event.QualityReport.Flagged
+	}
+
 	if event.WorkflowStatus == "Complete" {
 		message = CompleteMessage{
 			GUID:                   event.GUID,
@@ -248,13 +369,31 @@ func (h *Handler) HandleRequest(event SNSNotificationEvent) (*SNSNotificationOut
 			ThumbNailsUrls:         event.ThumbNailsUrls,
 			MediaPackageResourceId: event.MediaPackageResourceId,
 			EgressEndpoints:        event.EgressEndpoints,
+			Renditions:             event.Renditions,
+			FileOutputs:            event.FileOutputs,
+			PrivateContent:         event.PrivateContent,
+			PlaybackUrl:            event.PlaybackUrl,
+			Cdns:                   event.Cdns,
+			CdnUrls:                event.CdnUrls,
+			CdnEgressEndpoints:     event.CdnEgressEndpoints,
+			QualitySummary:         qualitySummary,
+			QualityFlagged:         qualityFlagged,
 		}
 
-	} else if event.WorkflowStatus == "Ingest" {
+	} else if event.WorkflowStatus == "Ingest" || event.WorkflowStatus == "Duplicate" || event.WorkflowStatus == "Rejected" || event.WorkflowStatus == "OutputInvalid" {
+		// Duplicates are reported whatever the policy, reprocessed ones
+		// keep the Ingest status. Rejected sources carry the broken rules,
+		// invalid outputs the failed checks
 		message = Message{
-			Status:   event.WorkflowStatus,
-			GUID:     event.GUID,
-			SrcVideo: event.SrcVideo,
+			Status:          event.WorkflowStatus,
+			GUID:            event.GUID,
+			SrcVideo:        event.SrcVideo,
+			DuplicateOf:     event.DuplicateOf,
+			DuplicatePolicy: event.DuplicatePolicy,
+			RejectReasons:   event.RejectReasons,
+			OutputErrors:    event.OutputErrors,
+			QualitySummary:  qualitySummary,
+			QualityFlagged:  qualityFlagged,
 		}
 	} else {
 		return nil, ErrWorkflowStatusNotDefined
@@ -296,6 +435,20 @@ func (h *Handler) HandleRequest(event SNSNotificationEvent) (*SNSNotificationOut
 		SrcVideo:               event.SrcVideo,
 		EnableMediaPackage:     event.EnableMediaPackage,
 		SrcMediainfo:           event.SrcMediainfo,
+		Fingerprint:            event.Fingerprint,
+		DuplicateOf:            event.DuplicateOf,
+		DuplicatePolicy:        event.DuplicatePolicy,
+		RejectReasons:          event.RejectReasons,
+		OutputErrors:           event.OutputErrors,
+		QualityReport:          event.QualityReport,
+		Renditions:             event.Renditions,
+		FileOutputs:            event.FileOutputs,
+		PrivateContent:         event.PrivateContent,
+		PlaybackUrl:            event.PlaybackUrl,
+		Cdns:                   event.Cdns,
+		CdnUrls:                event.CdnUrls,
+		CdnEgressEndpoints:     event.CdnEgressEndpoints,
+		Version:                event.Version,
 		EncodingJob:            event.EncodingJob,
 		EncodeJobId:            event.EncodeJobId,
 		EncodingOutput:         event.EncodingOutput,
@@ -312,6 +465,7 @@ func (h *Handler) HandleRequest(event SNSNotificationEvent) (*SNSNotificationOut
 		CmafDashUrl:            event.CmafDashUrl,
 		CmafHlsPlaylist:        event.CmafHlsPlaylist,
 		CmafHlsUrl:             event.CmafHlsUrl,
+		CmafGroups:             event.CmafGroups,
 		ThumbNails:             event.ThumbNails,
 		ThumbNailsUrls:         event.ThumbNailsUrls,
 		MediaPackageResourceId: event.MediaPackageResourceId,
@@ -141,3 +141,186 @@ func TestHandleRequest(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, &output, result)
 }
+
+func TestHandleRequestDuplicate(t *testing.T) {
+	mockSns := new(mockSnsClient)
+	handler := Handler{
+		snsClient: mockSns,
+	}
+
+	mockSns.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+	result, err := handler.HandleRequest(SNSNotificationEvent{
+		GUID:            "guid",
+		WorkflowStatus:  "Duplicate",
+		SrcVideo:        "clang.mp4",
+		DuplicateOf:     "existing",
+		DuplicatePolicy: "skip",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "existing", result.DuplicateOf)
+
+	input := mockSns.Calls[0].Arguments.Get(0).(*sns.PublishInput)
+	assert.Equal(t, "Workflow Status:: Duplicate:: guid", *input.Subject)
+	assert.Contains(t, *input.Message, `"duplicateOf": "existing"`)
+	assert.Contains(t, *input.Message, `"duplicatePolicy": "skip"`)
+}
+
+func TestHandleRequestRejected(t *testing.T) {
+	mockSns := new(mockSnsClient)
+	handler := Handler{
+		snsClient: mockSns,
+	}
+
+	mockSns.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+	result, err := handler.HandleRequest(SNSNotificationEvent{
+		GUID:           "guid",
+		WorkflowStatus: "Rejected",
+		SrcVideo:       "clang.mp4",
+		RejectReasons:  []string{"no video stream"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"no video stream"}, result.RejectReasons)
+
+	input := mockSns.Calls[0].Arguments.Get(0).(*sns.PublishInput)
+	assert.Equal(t, "Workflow Status:: Rejected:: guid", *input.Subject)
+	assert.Contains(t, *input.Message, `"no video stream"`)
+}
+
+func TestHandleRequestOutputInvalid(t *testing.T) {
+	mockSns := new(mockSnsClient)
+	handler := Handler{
+		snsClient: mockSns,
+	}
+
+	mockSns.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+	result, err := handler.HandleRequest(SNSNotificationEvent{
+		GUID:           "guid",
+		WorkflowStatus: "OutputInvalid",
+		SrcVideo:       "clang.mp4",
+		OutputErrors:   []string{"s3://vod-destination/guid/hls/clang.m3u8: not found"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "OutputInvalid", result.WorkflowStatus)
+
+	input := mockSns.Calls[0].Arguments.Get(0).(*sns.PublishInput)
+	assert.Equal(t, "Workflow Status:: OutputInvalid:: guid", *input.Subject)
+	assert.Contains(t, *input.Message, "clang.m3u8: not found")
+}
+
+func TestHandleRequestRenditions(t *testing.T) {
+	mockSns := new(mockSnsClient)
+	handler := Handler{
+		snsClient: mockSns,
+	}
+
+	mockSns.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+	_, err := handler.HandleRequest(SNSNotificationEvent{
+		GUID:           "guid",
+		WorkflowStatus: "Complete",
+		SrcVideo:       "clang.mp4",
+		Renditions: []*Rendition{
+			{
+				GroupType:      "FILE_GROUP",
+				File:           "s3://vod-destination/guid/mp4/clang_1080p.mp4",
+				Url:            "https://cloudfront/guid/mp4/clang_1080p.mp4",
+				WidthInPx:      1920,
+				HeightInPx:     1080,
+				AverageBitrate: 5000000,
+				DurationInMs:   28189,
+				Codec:          "H_264",
+			},
+		},
+		FileOutputs: &FileOutputs{
+			Captions: []*FileOutput{{File: "s3://vod-destination/guid/mp4/clang.vtt", Url: "https://cloudfront/guid/mp4/clang.vtt"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	input := mockSns.Calls[0].Arguments.Get(0).(*sns.PublishInput)
+	assert.Contains(t, *input.Message, `"url": "https://cloudfront/guid/mp4/clang_1080p.mp4"`)
+	assert.Contains(t, *input.Message, `"heightInPx": 1080`)
+	assert.Contains(t, *input.Message, `"url": "https://cloudfront/guid/mp4/clang.vtt"`)
+}
+
+func TestHandleRequestPrivateContent(t *testing.T) {
+	mockSns := new(mockSnsClient)
+	handler := Handler{
+		snsClient: mockSns,
+	}
+
+	mockSns.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+	result, err := handler.HandleRequest(SNSNotificationEvent{
+		GUID:           "guid",
+		WorkflowStatus: "Complete",
+		SrcVideo:       "clang.mp4",
+		PrivateContent: true,
+		PlaybackUrl:    "https://api.example.com/prod/playback/guid",
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.PrivateContent)
+
+	input := mockSns.Calls[0].Arguments.Get(0).(*sns.PublishInput)
+	assert.Contains(t, *input.Message, `"playbackUrl": "https://api.example.com/prod/playback/guid"`)
+	assert.Contains(t, *input.Message, `"privateContent": true`)
+}
+
+func TestHandleRequestCdnUrls(t *testing.T) {
+	mockSns := new(mockSnsClient)
+	handler := Handler{
+		snsClient: mockSns,
+	}
+
+	mockSns.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+	_, err := handler.HandleRequest(SNSNotificationEvent{
+		GUID:           "guid",
+		WorkflowStatus: "Complete",
+		SrcVideo:       "clang.mp4",
+		Cdns: []*Cdn{
+			{Name: "primary", Host: "cloudfront", Weight: 80},
+			{Name: "secondary", Host: "vod.cdn.example.com", Weight: 20},
+		},
+		CdnUrls: CdnUrls{
+			"hls": {
+				"primary":   "https://cloudfront/guid/hls/clang.m3u8",
+				"secondary": "https://vod.cdn.example.com/guid/hls/clang.m3u8",
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	input := mockSns.Calls[0].Arguments.Get(0).(*sns.PublishInput)
+	assert.Contains(t, *input.Message, `"secondary": "https://vod.cdn.example.com/guid/hls/clang.m3u8"`)
+	assert.Contains(t, *input.Message, `"weight": 80`)
+}
+
+func TestHandleRequestQualitySummary(t *testing.T) {
+	mockSns := new(mockSnsClient)
+	handler := Handler{
+		snsClient: mockSns,
+	}
+
+	mockSns.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+	result, err := handler.HandleRequest(SNSNotificationEvent{
+		GUID:           "guid",
+		WorkflowStatus: "Complete",
+		SrcVideo:       "clang.mp4",
+		QualityReport: &QualityReport{
+			QvbrQualityFloor: 6,
+			Flagged:          true,
+			Summary:          "2 video renditions, average QVBR quality 6.40 to 8.90, 1 below QVBR floor 6.00",
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.QualityReport.Flagged)
+
+	input := mockSns.Calls[0].Arguments.Get(0).(*sns.PublishInput)
+	assert.Contains(t, *input.Message, `"qualitySummary": "2 video renditions, average QVBR quality 6.40 to 8.90, 1 below QVBR floor 6.00"`)
+	assert.Contains(t, *input.Message, `"qualityFlagged": true`)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// HandleRequest runs after mediainfo in the ingest workflow. It checks
+// srcMediainfo against the configured rules and marks sources that fail as
+// Rejected with the reasons, so they are recorded and notified but never
+// encoded. The rest of the event passes through unchanged.
+func HandleRequest(event map[string]interface{}) (map[string]interface{}, error) {
+	eventJson, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("source-validate: main.HandleRequest: json.Marshal: %w", err)
+	}
+	log.Printf("REQUEST:: %s", eventJson)
+
+	// Duplicates were validated when first ingested
+	if event["workflowStatus"] != "Ingest" {
+		return event, nil
+	}
+
+	var mediainfo MediaInfo
+	srcMediainfo, _ := event["srcMediainfo"].(string)
+	if err := json.Unmarshal([]byte(srcMediainfo), &mediainfo); err != nil {
+		// mediainfo could not describe the file
+		log.Printf("source-validate: main.HandleRequest: json.Unmarshal: %v", err)
+	}
+
+	reasons := validate(mediainfo, getRules())
+	if len(reasons) > 0 {
+		log.Printf("Rejected %v: %s", event["guid"], strings.Join(reasons, "; "))
+		event["workflowStatus"] = "Rejected"
+		event["rejectReasons"] = reasons
+	}
+
+	return event, nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
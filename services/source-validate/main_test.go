@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getEvent(status string, mediainfo MediaInfo) map[string]interface{} {
+	srcMediainfo, _ := json.MarshalIndent(mediainfo, "", "  ")
+	return map[string]interface{}{
+		"guid":           "guid",
+		"workflowStatus": status,
+		"enableSns":      true,
+		"srcMediainfo":   string(srcMediainfo),
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	t.Run("should pass valid sources through unchanged", func(t *testing.T) {
+		event := getEvent("Ingest", TestMediaInfo)
+
+		output, err := HandleRequest(event)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Ingest", output["workflowStatus"])
+		assert.Equal(t, true, output["enableSns"])
+		assert.NotContains(t, output, "rejectReasons")
+	})
+
+	t.Run("should reject sources that break a rule", func(t *testing.T) {
+		os.Setenv("MaxDuration", "10")
+		defer os.Unsetenv("MaxDuration")
+
+		output, err := HandleRequest(getEvent("Ingest", TestMediaInfo))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Rejected", output["workflowStatus"])
+		assert.Equal(t, []string{"duration 28.189s exceeds 10s"}, output["rejectReasons"])
+	})
+
+	t.Run("should reject sources mediainfo could not describe", func(t *testing.T) {
+		event := getEvent("Ingest", TestMediaInfo)
+		event["srcMediainfo"] = "invalid"
+
+		output, err := HandleRequest(event)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Rejected", output["workflowStatus"])
+	})
+
+	t.Run("should not validate duplicates", func(t *testing.T) {
+		output, err := HandleRequest(getEvent("Duplicate", MediaInfo{}))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Duplicate", output["workflowStatus"])
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type MediaInfo struct {
+	Filename  string    `json:"filename"`
+	Container Container `json:"container"`
+	Video     []Video   `json:"video"`
+	Audio     []Audio   `json:"audio"`
+}
+
+type Container struct {
+	Format   string  `json:"format"`
+	FileSize int64   `json:"fileSize"`
+	Duration float64 `json:"duration"`
+}
+
+type Video struct {
+	Codec  string `json:"codec"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type Audio struct {
+	Codec string `json:"codec"`
+}
+
+// Rules are the limits a source must meet to be encoded. Zero values and
+// empty lists disable a rule.
+type Rules struct {
+	MinDuration    float64
+	MaxDuration    float64
+	MaxFileSize    int64
+	AllowedCodecs  []string
+	AllowedFormats []string
+	RequireAudio   bool
+	MinWidth       int
+	MinHeight      int
+	MaxWidth       int
+	MaxHeight      int
+}
+
+// getRules reads the rules from the environment. Durations are in seconds,
+// sizes in bytes and lists are comma separated.
+func getRules() Rules {
+	return Rules{
+		MinDuration:    getEnvFloat("MinDuration"),
+		MaxDuration:    getEnvFloat("MaxDuration"),
+		MaxFileSize:    int64(getEnvFloat("MaxFileSize")),
+		AllowedCodecs:  getEnvList("AllowedCodecs"),
+		AllowedFormats: getEnvList("AllowedContainers"),
+		RequireAudio:   os.Getenv("RequireAudio") == "true",
+		MinWidth:       int(getEnvFloat("MinWidth")),
+		MinHeight:      int(getEnvFloat("MinHeight")),
+		MaxWidth:       int(getEnvFloat("MaxWidth")),
+		MaxHeight:      int(getEnvFloat("MaxHeight")),
+	}
+}
+
+// validate returns every rule the source breaks. Sources without a video
+// stream, size or duration are always rejected, they cannot be encoded.
+func validate(mediainfo MediaInfo, rules Rules) []string {
+	var reasons []string
+
+	container := mediainfo.Container
+	if container.FileSize <= 0 {
+		reasons = append(reasons, "file is empty")
+	} else if rules.MaxFileSize > 0 && container.FileSize > rules.MaxFileSize {
+		reasons = append(reasons, fmt.Sprintf("file size %d exceeds %d bytes", container.FileSize, rules.MaxFileSize))
+	}
+
+	if container.Duration <= 0 {
+		reasons = append(reasons, "duration is unknown")
+	} else {
+		if rules.MinDuration > 0 && container.Duration < rules.MinDuration {
+			reasons = append(reasons, fmt.Sprintf("duration %.3fs is below %gs", container.Duration, rules.MinDuration))
+		}
+		if rules.MaxDuration > 0 && container.Duration > rules.MaxDuration {
+			reasons = append(reasons, fmt.Sprintf("duration %.3fs exceeds %gs", container.Duration, rules.MaxDuration))
+		}
+	}
+
+	if len(rules.AllowedFormats) > 0 && !contains(rules.AllowedFormats, container.Format) {
+		reasons = append(reasons, fmt.Sprintf("container %q is not allowed", container.Format))
+	}
+
+	if len(mediainfo.Video) == 0 {
+		reasons = append(reasons, "no video stream")
+	} else {
+		video := mediainfo.Video[0]
+		if len(rules.AllowedCodecs) > 0 && !contains(rules.AllowedCodecs, video.Codec) {
+			reasons = append(reasons, fmt.Sprintf("video codec %q is not allowed", video.Codec))
+		}
+		if (rules.MinWidth > 0 && video.Width < rules.MinWidth) || (rules.MinHeight > 0 && video.Height < rules.MinHeight) {
+			reasons = append(reasons, fmt.Sprintf("resolution %dx%d is below %dx%d", video.Width, video.Height, rules.MinWidth, rules.MinHeight))
+		}
+		if (rules.MaxWidth > 0 && video.Width > rules.MaxWidth) || (rules.MaxHeight > 0 && video.Height > rules.MaxHeight) {
+			reasons = append(reasons, fmt.Sprintf("resolution %dx%d exceeds %dx%d", video.Width, video.Height, rules.MaxWidth, rules.MaxHeight))
+		}
+	}
+
+	if rules.RequireAudio && len(mediainfo.Audio) == 0 {
+		reasons = append(reasons, "no audio stream")
+	}
+
+	return reasons
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func getEnvFloat(key string) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil && value > 0 {
+		return value
+	}
+	return 0
+}
+
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var TestMediaInfo = MediaInfo{
+	Container: Container{Format: "MPEG-4", FileSize: 1540047, Duration: 28.189},
+	Video:     []Video{{Codec: "AVC", Width: 1920, Height: 1080}},
+	Audio:     []Audio{{Codec: "AAC"}},
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("should accept sources within the rules", func(t *testing.T) {
+		reasons := validate(TestMediaInfo, Rules{
+			MinDuration:    1,
+			MaxDuration:    3600,
+			MaxFileSize:    1 << 30,
+			AllowedCodecs:  []string{"avc", "HEVC"},
+			AllowedFormats: []string{"MPEG-4"},
+			RequireAudio:   true,
+			MinWidth:       640,
+			MinHeight:      360,
+			MaxWidth:       3840,
+			MaxHeight:      2160,
+		})
+
+		assert.Empty(t, reasons)
+	})
+
+	t.Run("should reject empty sources without streams", func(t *testing.T) {
+		reasons := validate(MediaInfo{}, Rules{})
+
+		assert.Equal(t, []string{"file is empty", "duration is unknown", "no video stream"}, reasons)
+	})
+
+	t.Run("should list every broken rule", func(t *testing.T) {
+		reasons := validate(TestMediaInfo, Rules{
+			MinDuration:    60,
+			MaxFileSize:    1024,
+			AllowedCodecs:  []string{"HEVC"},
+			AllowedFormats: []string{"QuickTime"},
+			MaxHeight:      720,
+		})
+
+		assert.Equal(t, []string{
+			"file size 1540047 exceeds 1024 bytes",
+			"duration 28.189s is below 60s",
+			`container "MPEG-4" is not allowed`,
+			`video codec "AVC" is not allowed`,
+			"resolution 1920x1080 exceeds 0x720",
+		}, reasons)
+	})
+
+	t.Run("should require audio when configured", func(t *testing.T) {
+		mediainfo := TestMediaInfo
+		mediainfo.Audio = nil
+
+		assert.Empty(t, validate(mediainfo, Rules{}))
+		assert.Equal(t, []string{"no audio stream"}, validate(mediainfo, Rules{RequireAudio: true}))
+	})
+}
+
+func TestGetRules(t *testing.T) {
+	os.Setenv("MaxDuration", "7200")
+	os.Setenv("AllowedCodecs", "AVC, HEVC ,")
+	os.Setenv("RequireAudio", "true")
+	os.Setenv("MinHeight", "invalid")
+	defer os.Unsetenv("MaxDuration")
+	defer os.Unsetenv("AllowedCodecs")
+	defer os.Unsetenv("RequireAudio")
+	defer os.Unsetenv("MinHeight")
+
+	rules := getRules()
+
+	assert.Equal(t, float64(7200), rules.MaxDuration)
+	assert.Equal(t, []string{"AVC", "HEVC"}, rules.AllowedCodecs)
+	assert.True(t, rules.RequireAudio)
+	assert.Equal(t, 0, rules.MinHeight)
+}
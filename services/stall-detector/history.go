@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	// eventTimeFormat is fixed width so EVENT# sort keys order by time
+	eventTimeFormat = "2006-01-02T15:04:05.000000Z"
+	stepStall       = "Stall"
+	stallActor      = "stall-detector"
+)
+
+// HistoryEvent is an EVENT#<timestamp>#<step> item of the asset timeline,
+// written by dynamo for workflow steps and here for stalled assets.
+type HistoryEvent struct {
+	GUID      string `json:"guid"`
+	Step      string `json:"step"`
+	Status    string `json:"status"`
+	Actor     string `json:"actor"`
+	Timestamp string `json:"timestamp"`
+	// DurationMs is the time since the workflow started
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// newHistoryEvent describes an asset marked Stalled at stalledAt, the
+// stalledAt value written on the record.
+func newHistoryEvent(stalled StalledAsset, stalledAt time.Time) HistoryEvent {
+	history := HistoryEvent{
+		GUID:      stalled.GUID,
+		Step:      stepStall,
+		Status:    "Stalled",
+		Actor:     stallActor,
+		Timestamp: stalledAt.Format(eventTimeFormat),
+		Error:     fmt.Sprintf("%s for %d minutes, SLA %d, last step %s", stalled.Status, stalled.Minutes, stalled.SLA, stalled.LastStep),
+	}
+	if startTime, err := time.Parse(startTimeFormat, stalled.StartTime); err == nil {
+		history.DurationMs = stalledAt.Sub(startTime).Milliseconds()
+	}
+	return history
+}
+
+// putHistoryEvent appends history to the timeline of the asset. An event
+// already written with the same sort key is left as it is.
+func (h *Handler) putHistoryEvent(history HistoryEvent) error {
+	item, err := dynamodbattribute.MarshalMap(history)
+	if err != nil {
+		return fmt.Errorf("putHistoryEvent: MarshalMap: %w", err)
+	}
+	item["PK"] = &dynamodb.AttributeValue{S: aws.String("VIDEO#" + history.GUID)}
+	item["SK"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("EVENT#%s#%s", history.Timestamp, history.Step))}
+
+	_, err = h.DynamoDBClient.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(os.Getenv("DynamoDBTable")),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(SK)"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			log.Printf("History event %s already recorded", aws.StringValue(item["SK"].S))
+			return nil
+		}
+		return fmt.Errorf("putHistoryEvent: PutItem: %w", err)
+	}
+	return nil
+}
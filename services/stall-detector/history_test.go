@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMarkStalledHistory(t *testing.T) {
+	stalled := StalledAsset{GUID: "guid", Status: "Processing", StartTime: "2025-02-23T08:00:00.000Z", LastStep: "encode", Minutes: 240, SLA: 120}
+
+	t.Run("should add the stall to the timeline", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+		handler := Handler{DynamoDBClient: dynamoDBClientMock}
+		marked, err := handler.markStalled(stalled, TestNow)
+
+		assert.Nil(t, err)
+		assert.True(t, marked)
+		put := dynamoDBClientMock.Calls[1].Arguments.Get(0).(*dynamodb.PutItemInput)
+		assert.Equal(t, "VIDEO#guid", *put.Item["PK"].S)
+		assert.Equal(t, "EVENT#2025-02-23T12:00:00.000000Z#Stall", *put.Item["SK"].S)
+		assert.Equal(t, "Stalled", *put.Item["status"].S)
+		assert.Equal(t, "stall-detector", *put.Item["actor"].S)
+		assert.Equal(t, "14400000", *put.Item["durationMs"].N)
+		assert.Equal(t, "Processing for 240 minutes, SLA 120, last step encode", *put.Item["error"].S)
+		assert.Equal(t, "attribute_not_exists(SK)", *put.ConditionExpression)
+	})
+
+	t.Run("should still alert when the event cannot be written", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		snsClientMock := new(SNSClientMock)
+		dynamoDBClientMock.On("Query", queryFor("Ingest")).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"guid": {S: aws.String("guid")}, "startTime": {S: aws.String("2025-02-23T08:00:00.000Z")}},
+			},
+		}, nil)
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(nil, errors.New("throttled"))
+		snsClientMock.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient: dynamoDBClientMock,
+			SNSClient:      snsClientMock,
+			Now:            func() time.Time { return TestNow },
+		}
+		output, err := handler.HandleRequest()
+
+		assert.Nil(t, err)
+		assert.Len(t, output.Stalled, 1)
+		snsClientMock.AssertNumberOfCalls(t, "Publish", 1)
+	})
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// startTimeFormat is the startTime format written by input-validate. It
+// sorts lexically, so the index range query compares strings.
+const startTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// defaultSLAs are the minutes since startTime an asset may stay in a status.
+// Overridden with the StatusSLAs env var, a JSON map of status to minutes.
+// Ingest and Reprocessing only cover the Lambda steps and the admission queue
+// before encode moves the asset to Processing, which covers the MediaConvert
+// job. Retrying keeps the startTime of the first attempt, so it gets the
+// encode budget too.
+var defaultSLAs = map[string]int{
+	"Ingest":       30,
+	"Reprocessing": 30,
+	"Processing":   240,
+	"Retrying":     240,
+}
+
+const defaultStatusIndex = "workflowStatus-startTime-index"
+
+type AssetRecord struct {
+	GUID           string `json:"guid"`
+	SrcVideo       string `json:"srcVideo"`
+	StartTime      string `json:"startTime"`
+	WorkflowStatus string `json:"workflowStatus"`
+	SrcMediainfo   string `json:"srcMediainfo"`
+	JobTemplate    string `json:"jobTemplate"`
+	EncodeJobId    string `json:"encodeJobId"`
+}
+
+type StalledAsset struct {
+	GUID      string `json:"guid"`
+	SrcVideo  string `json:"srcVideo"`
+	Status    string `json:"status"`
+	StartTime string `json:"startTime"`
+	LastStep  string `json:"lastStep"`
+	Minutes   int    `json:"minutes"`
+	SLA       int    `json:"sla"`
+}
+
+type StallDetectorOutput struct {
+	Stalled []StalledAsset `json:"stalled"`
+}
+
+type DynamoDBClient interface {
+	Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+}
+
+type SNSClient interface {
+	Publish(input *sns.PublishInput) (*sns.PublishOutput, error)
+}
+
+type Handler struct {
+	DynamoDBClient DynamoDBClient
+	SNSClient      SNSClient
+	Now            func() time.Time
+}
+
+// HandleRequest runs on a schedule. For every status with an SLA it queries
+// the status/startTime index for records started before the SLA cutoff,
+// marks them Stalled with the last step they reached and publishes one SNS
+// alert listing all of them.
+func (h *Handler) HandleRequest() (*StallDetectorOutput, error) {
+	now := time.Now().UTC()
+	if h.Now != nil {
+		now = h.Now().UTC()
+	}
+
+	slas, err := getSLAs()
+	if err != nil {
+		return nil, fmt.Errorf("stall-detector: main.Handler.HandleRequest: %w", err)
+	}
+
+	statuses := make([]string, 0, len(slas))
+	for status := range slas {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	output := &StallDetectorOutput{Stalled: []StalledAsset{}}
+	for _, status := range statuses {
+		cutoff := now.Add(-time.Duration(slas[status]) * time.Minute)
+
+		records, err := h.queryStarted(status, cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("stall-detector: main.Handler.HandleRequest: %w", err)
+		}
+
+		for _, record := range records {
+			stalled := StalledAsset{
+				GUID:      record.GUID,
+				SrcVideo:  record.SrcVideo,
+				Status:    status,
+				StartTime: record.StartTime,
+				LastStep:  getLastStep(record),
+				SLA:       slas[status],
+			}
+			if startTime, err := time.Parse(startTimeFormat, record.StartTime); err == nil {
+				stalled.Minutes = int(now.Sub(startTime).Minutes())
+			}
+
+			marked, err := h.markStalled(stalled, now)
+			if err != nil {
+				return nil, fmt.Errorf("stall-detector: main.Handler.HandleRequest: %w", err)
+			}
+			if marked {
+				output.Stalled = append(output.Stalled, stalled)
+			}
+		}
+	}
+
+	if len(output.Stalled) > 0 {
+		if err := h.alert(output.Stalled); err != nil {
+			return nil, fmt.Errorf("stall-detector: main.Handler.HandleRequest: %w", err)
+		}
+	}
+
+	outputJson, _ := json.Marshal(output)
+	log.Printf("RESPONSE:: %s", outputJson)
+
+	return output, nil
+}
+
+func getSLAs() (map[string]int, error) {
+	value := os.Getenv("StatusSLAs")
+	if value == "" {
+		return defaultSLAs, nil
+	}
+
+	var slas map[string]int
+	if err := json.Unmarshal([]byte(value), &slas); err != nil {
+		return nil, fmt.Errorf("getSLAs: json.Unmarshal: %w", err)
+	}
+	return slas, nil
+}
+
+// queryStarted returns the records in a status that started before cutoff.
+// The index must project the AssetRecord attributes.
+func (h *Handler) queryStarted(status string, cutoff time.Time) ([]AssetRecord, error) {
+	indexName := os.Getenv("StatusIndex")
+	if indexName == "" {
+		indexName = defaultStatusIndex
+	}
+
+	var records []AssetRecord
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		data, err := h.DynamoDBClient.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(os.Getenv("DynamoDBTable")),
+			IndexName:              aws.String(indexName),
+			KeyConditionExpression: aws.String("workflowStatus = :status AND startTime < :cutoff"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":status": {S: aws.String(status)},
+				":cutoff": {S: aws.String(cutoff.Format(startTimeFormat))},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queryStarted: Query: %w", err)
+		}
+
+		var page []AssetRecord
+		if err := dynamodbattribute.UnmarshalListOfMaps(data.Items, &page); err != nil {
+			return nil, fmt.Errorf("queryStarted: UnmarshalListOfMaps: %w", err)
+		}
+		records = append(records, page...)
+
+		if len(data.LastEvaluatedKey) == 0 {
+			return records, nil
+		}
+		startKey = data.LastEvaluatedKey
+	}
+}
+
+// getLastStep infers the last workflow step that wrote to the record.
+func getLastStep(record AssetRecord) string {
+	switch {
+	case record.EncodeJobId != "":
+		return "encode"
+	case record.JobTemplate != "":
+		return "profiler"
+	case record.SrcMediainfo != "":
+		return "mediainfo"
+	}
+	return "input-validate"
+}
+
+// markStalled moves the record to Stalled, keeping the status it was stuck
+// in, and adds the stall to the timeline. It returns false when the record
+// moved on since the query. Only the run that moves the record writes the
+// event, a retried run finds it Stalled already.
+func (h *Handler) markStalled(stalled StalledAsset, now time.Time) (bool, error) {
+	_, err := h.DynamoDBClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(os.Getenv("DynamoDBTable")),
+		Key: map[string]*dynamodb.AttributeValue{
+			"PK": {S: aws.String("VIDEO#" + stalled.GUID)},
+			"SK": {S: aws.String("METADATA")},
+		},
+		UpdateExpression:    aws.String("SET workflowStatus = :stalled, stalledStatus = :status, stalledStep = :step, stalledAt = :now, #version = if_not_exists(#version, :zero) + :one"),
+		ConditionExpression: aws.String("workflowStatus = :status"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String("version"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":stalled": {S: aws.String("Stalled")},
+			":status":  {S: aws.String(stalled.Status)},
+			":step":    {S: aws.String(stalled.LastStep)},
+			":now":     {S: aws.String(now.Format(startTimeFormat))},
+			":zero":    {N: aws.String("0")},
+			":one":     {N: aws.String("1")},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, fmt.Errorf("markStalled: UpdateItem: %w", err)
+	}
+
+	log.Printf("Marked %s as Stalled after %d minutes in %s (last step %s)", stalled.GUID, stalled.Minutes, stalled.Status, stalled.LastStep)
+
+	// The timeline is for troubleshooting, a failed write does not stop the
+	// alert
+	if err := h.putHistoryEvent(newHistoryEvent(stalled, now)); err != nil {
+		log.Printf("stall-detector: markStalled: %v", err)
+	}
+	return true, nil
+}
+
+func (h *Handler) alert(stalled []StalledAsset) error {
+	messageJson, err := json.MarshalIndent(stalled, "", "  ")
+	if err != nil {
+		return fmt.Errorf("alert: json.MarshalIndent: %w", err)
+	}
+
+	_, err = h.SNSClient.Publish(&sns.PublishInput{
+		Subject:  aws.String(fmt.Sprintf("Workflow Stalled: %d asset(s) exceeded their SLA", len(stalled))),
+		Message:  aws.String(string(messageJson)),
+		TopicArn: aws.String(os.Getenv("SnsTopic")),
+	})
+	if err != nil {
+		return fmt.Errorf("alert: Publish: %w", err)
+	}
+	return nil
+}
+
+func main() {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("stall-detector: main: session.NewSession: %v", err)
+	}
+
+	handler := &Handler{
+		DynamoDBClient: dynamodb.New(sess),
+		SNSClient:      sns.New(sess),
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBClientMock struct {
+	mock.Mock
+}
+
+func (m *DynamoDBClientMock) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+func (m *DynamoDBClientMock) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+type SNSClientMock struct {
+	mock.Mock
+}
+
+func (m *SNSClientMock) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sns.PublishOutput), args.Error(1)
+}
+
+var TestNow = time.Date(2025, 2, 23, 12, 0, 0, 0, time.UTC)
+
+func queryFor(status string) interface{} {
+	return mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.ExpressionAttributeValues[":status"].S == status
+	})
+}
+
+func TestHandleRequest(t *testing.T) {
+	t.Run("should mark stalled records and send one alert", func(t *testing.T) {
+		os.Setenv("StatusSLAs", `{"Ingest": 30, "Processing": 120}`)
+		defer os.Unsetenv("StatusSLAs")
+
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		snsClientMock := new(SNSClientMock)
+
+		dynamoDBClientMock.On("Query", queryFor("Ingest")).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{
+					"guid":           {S: aws.String("ingest")},
+					"startTime":      {S: aws.String("2025-02-23T11:00:00.000Z")},
+					"workflowStatus": {S: aws.String("Ingest")},
+					"srcMediainfo":   {S: aws.String("{}")},
+				},
+			},
+		}, nil)
+		dynamoDBClientMock.On("Query", queryFor("Processing")).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{
+					"guid":           {S: aws.String("processing")},
+					"startTime":      {S: aws.String("2025-02-23T08:00:00.000Z")},
+					"workflowStatus": {S: aws.String("Processing")},
+					"encodeJobId":    {S: aws.String("job")},
+				},
+			},
+		}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(&dynamodb.UpdateItemOutput{}, nil)
+		dynamoDBClientMock.On("PutItem", mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+		snsClientMock.On("Publish", mock.Anything).Return(&sns.PublishOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient: dynamoDBClientMock,
+			SNSClient:      snsClientMock,
+			Now:            func() time.Time { return TestNow },
+		}
+
+		output, err := handler.HandleRequest()
+
+		assert.Nil(t, err)
+		assert.Equal(t, []StalledAsset{
+			{GUID: "ingest", Status: "Ingest", StartTime: "2025-02-23T11:00:00.000Z", LastStep: "mediainfo", Minutes: 60, SLA: 30},
+			{GUID: "processing", Status: "Processing", StartTime: "2025-02-23T08:00:00.000Z", LastStep: "encode", Minutes: 240, SLA: 120},
+		}, output.Stalled)
+
+		query := dynamoDBClientMock.Calls[0].Arguments.Get(0).(*dynamodb.QueryInput)
+		assert.Equal(t, "workflowStatus-startTime-index", *query.IndexName)
+
+		dynamoDBClientMock.AssertCalled(t, "UpdateItem", mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+			return *input.ExpressionAttributeNames["#version"] == "version"
+		}))
+		assert.Equal(t, "2025-02-23T11:30:00.000Z", *query.ExpressionAttributeValues[":cutoff"].S)
+
+		snsClientMock.AssertNumberOfCalls(t, "Publish", 1)
+		publish := snsClientMock.Calls[0].Arguments.Get(0).(*sns.PublishInput)
+		assert.Contains(t, *publish.Subject, "2 asset(s)")
+	})
+
+	t.Run("should skip records that moved on and not alert without stalled assets", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		snsClientMock := new(SNSClientMock)
+
+		dynamoDBClientMock.On("Query", queryFor("Ingest")).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{
+				{"guid": {S: aws.String("guid")}, "startTime": {S: aws.String("2025-02-23T08:00:00.000Z")}},
+			},
+		}, nil)
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+		dynamoDBClientMock.On("UpdateItem", mock.Anything).Return(nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil))
+
+		handler := Handler{
+			DynamoDBClient: dynamoDBClientMock,
+			SNSClient:      snsClientMock,
+			Now:            func() time.Time { return TestNow },
+		}
+
+		output, err := handler.HandleRequest()
+
+		assert.Nil(t, err)
+		assert.Empty(t, output.Stalled)
+		snsClientMock.AssertNotCalled(t, "Publish", mock.Anything)
+		dynamoDBClientMock.AssertNotCalled(t, "PutItem", mock.Anything)
+	})
+
+	t.Run("should use per-status default SLAs", func(t *testing.T) {
+		dynamoDBClientMock := new(DynamoDBClientMock)
+		dynamoDBClientMock.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+
+		handler := Handler{
+			DynamoDBClient: dynamoDBClientMock,
+			Now:            func() time.Time { return TestNow },
+		}
+
+		_, err := handler.HandleRequest()
+
+		assert.Nil(t, err)
+		cutoffs := map[string]string{}
+		for _, call := range dynamoDBClientMock.Calls {
+			query := call.Arguments.Get(0).(*dynamodb.QueryInput)
+			cutoffs[*query.ExpressionAttributeValues[":status"].S] = *query.ExpressionAttributeValues[":cutoff"].S
+		}
+		assert.Equal(t, map[string]string{
+			"Ingest":       "2025-02-23T11:30:00.000Z",
+			"Reprocessing": "2025-02-23T11:30:00.000Z",
+			"Processing":   "2025-02-23T08:00:00.000Z",
+			"Retrying":     "2025-02-23T08:00:00.000Z",
+		}, cutoffs)
+	})
+
+	t.Run("should fail on invalid SLAs", func(t *testing.T) {
+		os.Setenv("StatusSLAs", "invalid")
+		defer os.Unsetenv("StatusSLAs")
+
+		handler := Handler{}
+		_, err := handler.HandleRequest()
+
+		assert.Error(t, err)
+	})
+}
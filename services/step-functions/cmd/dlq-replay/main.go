@@ -0,0 +1,190 @@
+// Command dlq-replay re-drives step-functions events from its dead-letter
+// queue. Each message is invoked synchronously against the step-functions
+// Lambda and deleted only when the invocation succeeds, so failures stay on
+// the queue for the next run.
+//
+//	go run ./cmd/dlq-replay -queue-url https://sqs.../step-functions-dlq -function-name step-functions
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// DestinationRecord is the envelope Lambda on-failure destinations wrap the
+// original event in. Messages from a plain dead-letter queue are the event
+// itself.
+type DestinationRecord struct {
+	RequestPayload json.RawMessage `json:"requestPayload"`
+}
+
+type ReplayResult struct {
+	Replayed int `json:"replayed"`
+	Failed   int `json:"failed"`
+	DryRun   int `json:"dryRun"`
+}
+
+type SQSClient interface {
+	ReceiveMessage(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+type LambdaClient interface {
+	Invoke(input *lambda.InvokeInput) (*lambda.InvokeOutput, error)
+}
+
+type Replayer struct {
+	SQSClient    SQSClient
+	LambdaClient LambdaClient
+	QueueURL     string
+	FunctionName string
+	DryRun       bool
+}
+
+// Replay drains up to max messages from the queue. A dry run receives the
+// messages without invoking or deleting them and makes them visible again
+// when it is done, so they are still there for the real run.
+func (r *Replayer) Replay(max int) (*ReplayResult, error) {
+	result := &ReplayResult{}
+
+	// Received messages stay hidden until the end of the run, which keeps a
+	// dry run from receiving the same message twice
+	var hidden []*sqs.Message
+	defer func() {
+		r.unhide(hidden)
+	}()
+
+	for result.Replayed+result.Failed+result.DryRun < max {
+		batch := max - result.Replayed - result.Failed - result.DryRun
+		if batch > 10 {
+			batch = 10
+		}
+
+		data, err := r.SQSClient.ReceiveMessage(&sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(r.QueueURL),
+			MaxNumberOfMessages: aws.Int64(int64(batch)),
+			WaitTimeSeconds:     aws.Int64(1),
+		})
+		if err != nil {
+			return result, fmt.Errorf("dlq-replay: main.Replayer.Replay: ReceiveMessage: %w", err)
+		}
+		if len(data.Messages) == 0 {
+			return result, nil
+		}
+
+		for _, message := range data.Messages {
+			if r.DryRun {
+				log.Printf("Would replay %s: %s", aws.StringValue(message.MessageId), getPayload(aws.StringValue(message.Body)))
+				hidden = append(hidden, message)
+				result.DryRun++
+				continue
+			}
+
+			if err := r.replayMessage(message); err != nil {
+				log.Printf("dlq-replay: %s: %v", aws.StringValue(message.MessageId), err)
+				result.Failed++
+				continue
+			}
+			result.Replayed++
+		}
+	}
+
+	return result, nil
+}
+
+func (r *Replayer) replayMessage(message *sqs.Message) error {
+	payload := getPayload(aws.StringValue(message.Body))
+
+	data, err := r.LambdaClient.Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(r.FunctionName),
+		InvocationType: aws.String(lambda.InvocationTypeRequestResponse),
+		Payload:        payload,
+	})
+	if err != nil {
+		return fmt.Errorf("replayMessage: Invoke: %w", err)
+	}
+	if data.FunctionError != nil {
+		return fmt.Errorf("replayMessage: Invoke: %s: %s", aws.StringValue(data.FunctionError), data.Payload)
+	}
+
+	_, err = r.SQSClient.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(r.QueueURL),
+		ReceiptHandle: message.ReceiptHandle,
+	})
+	if err != nil {
+		return fmt.Errorf("replayMessage: DeleteMessage: %w", err)
+	}
+
+	log.Printf("Replayed %s", aws.StringValue(message.MessageId))
+	return nil
+}
+
+// unhide makes the messages of a dry run visible again right away instead of
+// after the queue visibility timeout.
+func (r *Replayer) unhide(messages []*sqs.Message) {
+	for _, message := range messages {
+		_, err := r.SQSClient.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          aws.String(r.QueueURL),
+			ReceiptHandle:     message.ReceiptHandle,
+			VisibilityTimeout: aws.Int64(0),
+		})
+		if err != nil {
+			log.Printf("dlq-replay: %s: ChangeMessageVisibility: %v", aws.StringValue(message.MessageId), err)
+		}
+	}
+}
+
+// getPayload unwraps on-failure destination records and returns any other
+// message body unchanged.
+func getPayload(body string) []byte {
+	var record DestinationRecord
+	if err := json.Unmarshal([]byte(body), &record); err == nil && len(record.RequestPayload) > 0 {
+		return record.RequestPayload
+	}
+	return []byte(body)
+}
+
+func main() {
+	queueURL := flag.String("queue-url", os.Getenv("DeadLetterQueue"), "URL of the dead-letter queue")
+	functionName := flag.String("function-name", os.Getenv("StepFunctionsFunction"), "name or ARN of the step-functions Lambda")
+	max := flag.Int("max", 100, "maximum number of messages to replay")
+	dryRun := flag.Bool("dry-run", false, "log the events without invoking or deleting them")
+	flag.Parse()
+
+	if *queueURL == "" || *functionName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}))
+	replayer := &Replayer{
+		SQSClient:    sqs.New(sess),
+		LambdaClient: lambda.New(sess),
+		QueueURL:     *queueURL,
+		FunctionName: *functionName,
+		DryRun:       *dryRun,
+	}
+
+	result, err := replayer.Replay(*max)
+	if result != nil {
+		resultJson, _ := json.Marshal(result)
+		log.Printf("RESULT:: %s", resultJson)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type SQSClientMock struct {
+	mock.Mock
+}
+
+func (m *SQSClientMock) ReceiveMessage(input *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sqs.ReceiveMessageOutput), args.Error(1)
+}
+
+func (m *SQSClientMock) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sqs.DeleteMessageOutput), args.Error(1)
+}
+
+func (m *SQSClientMock) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sqs.ChangeMessageVisibilityOutput), args.Error(1)
+}
+
+type LambdaClientMock struct {
+	mock.Mock
+}
+
+func (m *LambdaClientMock) Invoke(input *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*lambda.InvokeOutput), args.Error(1)
+}
+
+func payloadFor(payload string) interface{} {
+	return mock.MatchedBy(func(input *lambda.InvokeInput) bool {
+		return string(input.Payload) == payload
+	})
+}
+
+func TestReplay(t *testing.T) {
+	t.Run("should invoke and delete replayed messages and keep failed ones", func(t *testing.T) {
+		sqsClientMock := new(SQSClientMock)
+		lambdaClientMock := new(LambdaClientMock)
+
+		sqsClientMock.On("ReceiveMessage", mock.Anything).Return(&sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{
+				{MessageId: aws.String("1"), ReceiptHandle: aws.String("r1"), Body: aws.String(`{"requestPayload":{"guid":"a"},"responsePayload":{}}`)},
+				{MessageId: aws.String("2"), ReceiptHandle: aws.String("r2"), Body: aws.String(`{"guid":"b"}`)},
+			},
+		}, nil).Once()
+		sqsClientMock.On("ReceiveMessage", mock.Anything).Return(&sqs.ReceiveMessageOutput{}, nil)
+		sqsClientMock.On("DeleteMessage", mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil)
+		lambdaClientMock.On("Invoke", payloadFor(`{"guid":"a"}`)).Return(&lambda.InvokeOutput{}, nil)
+		lambdaClientMock.On("Invoke", payloadFor(`{"guid":"b"}`)).Return(&lambda.InvokeOutput{FunctionError: aws.String("Unhandled")}, nil)
+
+		replayer := Replayer{
+			SQSClient:    sqsClientMock,
+			LambdaClient: lambdaClientMock,
+			QueueURL:     "queue",
+			FunctionName: "step-functions",
+		}
+
+		result, err := replayer.Replay(100)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &ReplayResult{Replayed: 1, Failed: 1}, result)
+		sqsClientMock.AssertNumberOfCalls(t, "DeleteMessage", 1)
+		sqsClientMock.AssertCalled(t, "DeleteMessage", &sqs.DeleteMessageInput{QueueUrl: aws.String("queue"), ReceiptHandle: aws.String("r1")})
+	})
+
+	t.Run("should not invoke or delete on a dry run and make messages visible again", func(t *testing.T) {
+		sqsClientMock := new(SQSClientMock)
+		lambdaClientMock := new(LambdaClientMock)
+
+		sqsClientMock.On("ReceiveMessage", mock.Anything).Return(&sqs.ReceiveMessageOutput{
+			Messages: []*sqs.Message{
+				{MessageId: aws.String("1"), ReceiptHandle: aws.String("r1"), Body: aws.String(`{"guid":"a"}`)},
+				{MessageId: aws.String("2"), ReceiptHandle: aws.String("r2"), Body: aws.String(`{"guid":"b"}`)},
+			},
+		}, nil).Once()
+		sqsClientMock.On("ReceiveMessage", mock.Anything).Return(&sqs.ReceiveMessageOutput{}, nil)
+		sqsClientMock.On("ChangeMessageVisibility", mock.Anything).Return(&sqs.ChangeMessageVisibilityOutput{}, nil)
+
+		replayer := Replayer{
+			SQSClient:    sqsClientMock,
+			LambdaClient: lambdaClientMock,
+			QueueURL:     "queue",
+			DryRun:       true,
+		}
+
+		result, err := replayer.Replay(100)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &ReplayResult{DryRun: 2}, result)
+		lambdaClientMock.AssertNotCalled(t, "Invoke", mock.Anything)
+		sqsClientMock.AssertNotCalled(t, "DeleteMessage", mock.Anything)
+		sqsClientMock.AssertNumberOfCalls(t, "ChangeMessageVisibility", 2)
+		sqsClientMock.AssertCalled(t, "ChangeMessageVisibility", &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          aws.String("queue"),
+			ReceiptHandle:     aws.String("r1"),
+			VisibilityTimeout: aws.Int64(0),
+		})
+	})
+}
@@ -3,12 +3,14 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sfn"
 	"github.com/google/uuid"
@@ -18,6 +20,23 @@ var (
 	ErrInvalidEventObject = errors.New("invalid event object")
 )
 
+// WorkflowError is returned when a workflow could not be started. Failing the
+// invocation lets Lambda retry the event and then hand it to the on-failure
+// destination, from where it can be replayed with cmd/dlq-replay.
+type WorkflowError struct {
+	Workflow string
+	Op       string
+	Err      error
+}
+
+func (e *WorkflowError) Error() string {
+	return fmt.Sprintf("step-functions: main.Handler.HandleRequest: %s workflow: %s: %v", e.Workflow, e.Op, e.Err)
+}
+
+func (e *WorkflowError) Unwrap() error {
+	return e.Err
+}
+
 type StepFunctionEvent struct {
 	Records                []events.S3EventRecord `json:"Records"`
 	GUID                   *string                `json:"guid"`
@@ -40,6 +59,16 @@ type StepFunctionEvent struct {
 	SrcVideo               *string                `json:"srcVideo"`
 	EnableMediaPackage     *bool                  `json:"enableMediaPackage"`
 	SrcMediainfo           *string                `json:"srcMediainfo"`
+	DuplicateOf            *string                `json:"duplicateOf"`
+}
+
+// JobStateDetail is the part of a MediaConvert job state change the publish
+// execution is named after.
+type JobStateDetail struct {
+	JobId        string `json:"jobId"`
+	UserMetadata struct {
+		GUID string `json:"guid"`
+	} `json:"userMetadata"`
 }
 
 type ProcessWorkflowInput struct {
@@ -92,68 +121,130 @@ func (h *Handler) HandleRequest(generalEvent map[string]interface{}) (*string, e
 		log.Printf("REQUEST:: %s", eventBytes)
 	}
 
-	var response string
-	var startExecutionInput sfn.StartExecutionInput
+	var workflow string
+	var startExecutionInputs []*sfn.StartExecutionInput
 
 	switch {
 	case event.Records != nil:
-		// Ingest workflow triggerd by s3 event::
-		event.GUID = aws.String(uuid.New().String())
-		event.WorkflowTrigger = aws.String("Video")
+		// Ingest workflow triggerd by s3 event, one execution per record::
+		workflow = "Ingest"
+		records := event.Records
+		for _, record := range records {
+			event.Records = []events.S3EventRecord{record}
+			event.GUID = aws.String(getRecordGUID(record))
+			event.WorkflowTrigger = aws.String("Video")
 
-		inputBytes, err := json.Marshal(event)
-		if err != nil {
-			log.Printf("step-function: main.Handler: Error marshalling event: %v", err)
-		}
+			inputBytes, err := json.Marshal(event)
+			if err != nil {
+				return nil, &WorkflowError{Workflow: workflow, Op: "json.Marshal", Err: err}
+			}
 
-		startExecutionInput = sfn.StartExecutionInput{
-			Name:            event.GUID,
-			Input:           aws.String(string(inputBytes)),
-			StateMachineArn: aws.String(os.Getenv("IngestWorkflow")),
+			startExecutionInputs = append(startExecutionInputs, &sfn.StartExecutionInput{
+				Name:            event.GUID,
+				Input:           aws.String(string(inputBytes)),
+				StateMachineArn: aws.String(os.Getenv("IngestWorkflow")),
+			})
 		}
-		response = "success"
 	case event.GUID != nil:
+		// Process workflow trigger
+		workflow = "Process"
+		switch aws.StringValue(event.WorkflowStatus) {
+		case "Duplicate":
+			// input-validate applied the skip or link-to-existing policy
+			log.Printf("Not processing %s, duplicate of %s", *event.GUID, aws.StringValue(event.DuplicateOf))
+			response := "skipped"
+			return &response, nil
+		case "Rejected":
+			// source-validate found the source unencodable
+			log.Printf("Not processing %s, source rejected", *event.GUID)
+			response := "skipped"
+			return &response, nil
+		}
+
 		inputBytes, err := json.Marshal(ProcessWorkflowInput{
 			GUID: event.GUID,
 		})
 		if err != nil {
-			log.Printf("step-function: main.Handler: Error marshalling event: %v", err)
+			return nil, &WorkflowError{Workflow: workflow, Op: "json.Marshal", Err: err}
 		}
-		// Process workflow trigger
-		startExecutionInput = sfn.StartExecutionInput{
+
+		startExecutionInputs = append(startExecutionInputs, &sfn.StartExecutionInput{
 			Name:            event.GUID,
 			Input:           aws.String(string(inputBytes)),
 			StateMachineArn: aws.String(os.Getenv("ProcessWorkflow")),
-		}
-		response = "success"
+		})
 	case eventBridgeEvent.Detail != nil:
+		workflow = "Publish"
 		eventBridgeBytes, err := json.Marshal(eventBridgeEvent)
 		if err != nil {
-			log.Printf("step-function: main.Handler: Error marshalling eventBridge: %v", err)
+			return nil, &WorkflowError{Workflow: workflow, Op: "json.Marshal", Err: err}
 		}
 
-		startExecutionInput = sfn.StartExecutionInput{
-			Name:            event.GUID,
+		var detail JobStateDetail
+		if err := json.Unmarshal(eventBridgeEvent.Detail, &detail); err != nil {
+			return nil, &WorkflowError{Workflow: workflow, Op: "json.Unmarshal", Err: err}
+		}
+
+		startExecutionInputs = append(startExecutionInputs, &sfn.StartExecutionInput{
+			Name:            getPublishName(detail),
 			Input:           aws.String(string(eventBridgeBytes)),
 			StateMachineArn: aws.String(os.Getenv("PublishWorkflow")),
-		}
-		response = "success"
+		})
 	default:
 		return nil, ErrInvalidEventObject
 	}
 
-	data, err := h.StepFunctionClient.StartExecution(&startExecutionInput)
-	if err != nil {
-		log.Printf("step-function: main.Handler: Error starting execution: %v", err)
+	// Start every execution before failing, retries skip the ones that
+	// already started
+	var errs []error
+	for _, startExecutionInput := range startExecutionInputs {
+		if err := h.startExecution(workflow, startExecutionInput); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	response := "success"
+	return &response, nil
+}
+
+// getRecordGUID derives the asset GUID from the S3 object and its sequencer,
+// so a retried event maps to the same execution name while every upload of
+// a key still gets its own GUID.
+func getRecordGUID(record events.S3EventRecord) string {
+	name := fmt.Sprintf("s3://%s/%s#%s", record.S3.Bucket.Name, record.S3.Object.Key, record.S3.Object.Sequencer)
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(name)).String()
+}
+
+// getPublishName names the publish execution after the asset and its job, so
+// a redelivered completion event, or the reconciler publishing the same job,
+// maps to the execution that already started.
+func getPublishName(detail JobStateDetail) *string {
+	if detail.JobId == "" {
+		return nil
+	}
+	if detail.UserMetadata.GUID == "" {
+		return aws.String("publish-" + detail.JobId)
 	}
+	return aws.String(fmt.Sprintf("%s-publish-%s", detail.UserMetadata.GUID, detail.JobId))
+}
 
-	dataJson, err := json.Marshal(data)
+func (h *Handler) startExecution(workflow string, startExecutionInput *sfn.StartExecutionInput) error {
+	data, err := h.StepFunctionClient.StartExecution(startExecutionInput)
 	if err != nil {
-		log.Printf("step-function: main.Handler: Error marshalling data: %v", err)
+		// A retried or replayed event for an execution that already started
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sfn.ErrCodeExecutionAlreadyExists {
+			log.Printf("%s workflow execution %s already exists", workflow, aws.StringValue(startExecutionInput.Name))
+			return nil
+		}
+		return &WorkflowError{Workflow: workflow, Op: "StartExecution", Err: err}
 	}
-	log.Printf("STATEMACHINE EXECUTE:: %s", dataJson)
 
-	return &response, nil
+	dataJson, _ := json.Marshal(data)
+	log.Printf("STATEMACHINE EXECUTE:: %s", dataJson)
+	return nil
 }
 
 func main() {
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sfn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -15,7 +18,11 @@ type StepFunctionClientMock struct {
 }
 
 func (m *StepFunctionClientMock) StartExecution(input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error) {
-	return &sfn.StartExecutionOutput{}, nil
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sfn.StartExecutionOutput), args.Error(1)
 }
 
 func TestHandleRequest(t *testing.T) {
@@ -62,6 +69,25 @@ func TestHandleRequest(t *testing.T) {
 			expectedResponse: aws.String("success"),
 			expectedError:    nil,
 		},
+		{
+			name: "should return \"skipped\" for duplicates",
+			event: map[string]interface{}{
+				"guid":           aws.String("123e4567-e89b-12d3-a456-426614174000"),
+				"workflowStatus": aws.String("Duplicate"),
+				"duplicateOf":    aws.String("existing"),
+			},
+			expectedResponse: aws.String("skipped"),
+			expectedError:    nil,
+		},
+		{
+			name: "should return \"skipped\" for rejected sources",
+			event: map[string]interface{}{
+				"guid":           aws.String("123e4567-e89b-12d3-a456-426614174000"),
+				"workflowStatus": aws.String("Rejected"),
+			},
+			expectedResponse: aws.String("skipped"),
+			expectedError:    nil,
+		},
 		{
 			name:             "should return error on invalid event object",
 			event:            map[string]interface{}{},
@@ -77,12 +103,122 @@ func TestHandleRequest(t *testing.T) {
 				StepFunctionClient: mockStepFunctionClient,
 			}
 
-			mockStepFunctionClient.On("StartExecution", &sfn.StartExecutionInput{}).Return(&sfn.StartExecutionOutput{}, nil)
+			mockStepFunctionClient.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
 
 			response, err := handler.HandleRequest(tt.event)
 			assert.Equal(t, tt.expectedResponse, response)
 			assert.Equal(t, tt.expectedError, err)
 		})
 	}
+}
+
+func TestHandleRequestPublishName(t *testing.T) {
+	mockStepFunctionClient := new(StepFunctionClientMock)
+	mockStepFunctionClient.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+	handler := Handler{StepFunctionClient: mockStepFunctionClient}
+	_, err := handler.HandleRequest(map[string]interface{}{
+		"detail": map[string]interface{}{
+			"status":       "COMPLETE",
+			"jobId":        "1740305088427-714h8k",
+			"userMetadata": map[string]interface{}{"guid": "123e4567-e89b-12d3-a456-426614174000"},
+		},
+		"source":      "aws.mediaconvert",
+		"detail-type": "MediaConvert Job State Change",
+	})
+
+	assert.Nil(t, err)
+	input := mockStepFunctionClient.Calls[0].Arguments.Get(0).(*sfn.StartExecutionInput)
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000-publish-1740305088427-714h8k", *input.Name)
+}
+
+func TestHandleRequestStartExecutionErrors(t *testing.T) {
+	event := map[string]interface{}{
+		"guid": aws.String("123e4567-e89b-12d3-a456-426614174000"),
+	}
+
+	t.Run("should return a WorkflowError when StartExecution fails", func(t *testing.T) {
+		mockStepFunctionClient := new(StepFunctionClientMock)
+		mockStepFunctionClient.On("StartExecution", mock.Anything).Return(nil, assert.AnError)
+
+		handler := Handler{StepFunctionClient: mockStepFunctionClient}
+		response, err := handler.HandleRequest(event)
+
+		assert.Nil(t, response)
+		var workflowErr *WorkflowError
+		assert.True(t, errors.As(err, &workflowErr))
+		assert.Equal(t, "Process", workflowErr.Workflow)
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("should return \"success\" when the execution already exists", func(t *testing.T) {
+		mockStepFunctionClient := new(StepFunctionClientMock)
+		mockStepFunctionClient.On("StartExecution", mock.Anything).Return(nil, awserr.New(sfn.ErrCodeExecutionAlreadyExists, "exists", nil))
+
+		handler := Handler{StepFunctionClient: mockStepFunctionClient}
+		response, err := handler.HandleRequest(event)
+
+		assert.Nil(t, err)
+		assert.Equal(t, aws.String("success"), response)
+	})
+}
+
+func TestHandleRequestMultipleRecords(t *testing.T) {
+	record := func(key string, sequencer string) events.S3EventRecord {
+		return events.S3EventRecord{
+			S3: events.S3Entity{
+				Bucket: events.S3Bucket{Name: "source"},
+				Object: events.S3Object{Key: key, Sequencer: sequencer},
+			},
+		}
+	}
+	event := map[string]interface{}{
+		"Records": []events.S3EventRecord{
+			record("a.mp4", "01"),
+			record("b.mp4", "02"),
+			record("a.mp4", "03"),
+		},
+	}
+
+	t.Run("should start one execution with its own GUID per record", func(t *testing.T) {
+		mockStepFunctionClient := new(StepFunctionClientMock)
+		mockStepFunctionClient.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{StepFunctionClient: mockStepFunctionClient}
+		response, err := handler.HandleRequest(event)
+
+		assert.Nil(t, err)
+		assert.Equal(t, aws.String("success"), response)
+		mockStepFunctionClient.AssertNumberOfCalls(t, "StartExecution", 3)
+
+		names := map[string]bool{}
+		for _, call := range mockStepFunctionClient.Calls {
+			input := call.Arguments.Get(0).(*sfn.StartExecutionInput)
+			names[*input.Name] = true
+
+			var started StepFunctionEvent
+			assert.Nil(t, json.Unmarshal([]byte(*input.Input), &started))
+			assert.Len(t, started.Records, 1)
+			assert.Equal(t, *input.Name, *started.GUID)
+		}
+		assert.Len(t, names, 3)
+	})
+
+	t.Run("should reuse GUIDs for a retried event", func(t *testing.T) {
+		assert.Equal(t, getRecordGUID(record("a.mp4", "01")), getRecordGUID(record("a.mp4", "01")))
+		assert.NotEqual(t, getRecordGUID(record("a.mp4", "01")), getRecordGUID(record("a.mp4", "03")))
+	})
+
+	t.Run("should start the remaining records when one fails", func(t *testing.T) {
+		mockStepFunctionClient := new(StepFunctionClientMock)
+		mockStepFunctionClient.On("StartExecution", mock.Anything).Return(nil, assert.AnError).Once()
+		mockStepFunctionClient.On("StartExecution", mock.Anything).Return(&sfn.StartExecutionOutput{}, nil)
+
+		handler := Handler{StepFunctionClient: mockStepFunctionClient}
+		response, err := handler.HandleRequest(event)
 
+		assert.Nil(t, response)
+		assert.ErrorIs(t, err, assert.AnError)
+		mockStepFunctionClient.AssertNumberOfCalls(t, "StartExecution", 3)
+	})
 }
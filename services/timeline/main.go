@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+type DynamoDBClient interface {
+	Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+}
+
+type Handler struct {
+	DynamoDBClient DynamoDBClient
+}
+
+// HandleRequest serves GET /timeline/{guid} with the Timeline of the asset.
+func (h *Handler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	log.Printf("REQUEST:: %s %s", request.HTTPMethod, request.Path)
+
+	if request.HTTPMethod != http.MethodGet {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusMethodNotAllowed,
+			Body:       "Method not allowed",
+		}, nil
+	}
+
+	guid := request.PathParameters["guid"]
+	if guid == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "guid is required",
+		}, nil
+	}
+
+	timeline, err := h.getTimeline(guid)
+	if err != nil {
+		if errors.Is(err, ErrNoEvents) {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       err.Error(),
+			}, nil
+		}
+		log.Printf("timeline: main.Handler.HandleRequest: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error reading timeline",
+		}, nil
+	}
+
+	responseBody, err := json.Marshal(timeline)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       "Error creating response",
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(responseBody),
+	}, nil
+}
+
+func main() {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("AWS_REGION")),
+	}))
+	handler := &Handler{
+		DynamoDBClient: dynamodb.New(sess),
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
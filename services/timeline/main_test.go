@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type DynamoDBClientMock struct {
+	mock.Mock
+}
+
+func (m *DynamoDBClientMock) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+}
+
+func eventItem(timestamp string, step string, status string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK":         {S: aws.String("VIDEO#guid")},
+		"SK":         {S: aws.String("EVENT#" + timestamp + "#" + step)},
+		"guid":       {S: aws.String("guid")},
+		"step":       {S: aws.String(step)},
+		"status":     {S: aws.String(status)},
+		"actor":      {S: aws.String("workflow")},
+		"timestamp":  {S: aws.String(timestamp)},
+		"durationMs": {N: aws.String("0")},
+	}
+}
+
+func timelineRequest(guid string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:     "GET",
+		Path:           "/timeline/" + guid,
+		PathParameters: map[string]string{"guid": guid},
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	t.Run("should return every page of events", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		lastKey := map[string]*dynamodb.AttributeValue{"PK": {S: aws.String("VIDEO#guid")}}
+		dynamoDBClient.On("Query", mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.ExclusiveStartKey == nil && *input.ExpressionAttributeValues[":pk"].S == "VIDEO#guid"
+		})).Return(&dynamodb.QueryOutput{
+			Items:            []map[string]*dynamodb.AttributeValue{eventItem("2025-02-23T10:04:40.000000Z", "Ingest", "Ingest")},
+			LastEvaluatedKey: lastKey,
+		}, nil)
+		dynamoDBClient.On("Query", mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+			return input.ExclusiveStartKey != nil
+		})).Return(&dynamodb.QueryOutput{
+			Items: []map[string]*dynamodb.AttributeValue{eventItem("2025-02-23T10:14:40.000000Z", "Publish", "Complete")},
+		}, nil)
+
+		handler := &Handler{DynamoDBClient: dynamoDBClient}
+		response, err := handler.HandleRequest(timelineRequest("guid"))
+		assert.Nil(t, err)
+		assert.Equal(t, 200, response.StatusCode)
+
+		var timeline Timeline
+		assert.Nil(t, json.Unmarshal([]byte(response.Body), &timeline))
+		assert.Len(t, timeline.Events, 2)
+		assert.Equal(t, "Complete", timeline.Status)
+		assert.Equal(t, int64(600000), timeline.TotalMs)
+	})
+
+	t.Run("should return 404 without events", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("Query", mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+
+		handler := &Handler{DynamoDBClient: dynamoDBClient}
+		response, _ := handler.HandleRequest(timelineRequest("guid"))
+		assert.Equal(t, 404, response.StatusCode)
+	})
+
+	t.Run("should return 500 when the query fails", func(t *testing.T) {
+		dynamoDBClient := new(DynamoDBClientMock)
+		dynamoDBClient.On("Query", mock.Anything).Return(nil, errors.New("throttled"))
+
+		handler := &Handler{DynamoDBClient: dynamoDBClient}
+		response, _ := handler.HandleRequest(timelineRequest("guid"))
+		assert.Equal(t, 500, response.StatusCode)
+	})
+
+	t.Run("should reject bad requests", func(t *testing.T) {
+		handler := &Handler{}
+		response, _ := handler.HandleRequest(timelineRequest(""))
+		assert.Equal(t, 400, response.StatusCode)
+
+		request := timelineRequest("guid")
+		request.HTTPMethod = "DELETE"
+		response, _ = handler.HandleRequest(request)
+		assert.Equal(t, 405, response.StatusCode)
+	})
+}
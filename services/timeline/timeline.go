@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// eventTimeFormat is the timestamp of EVENT# items, see dynamo
+const eventTimeFormat = "2006-01-02T15:04:05.000000Z"
+
+var ErrNoEvents = errors.New("no events recorded for asset")
+
+// HistoryEvent is an EVENT#<timestamp>#<step> item, written by dynamo for
+// workflow steps, by error-handler for failed jobs, by stall-detector for
+// stalled assets and by reprocess.
+type HistoryEvent struct {
+	GUID      string `json:"guid"`
+	Step      string `json:"step"`
+	Status    string `json:"status"`
+	Actor     string `json:"actor"`
+	Timestamp string `json:"timestamp"`
+	// DurationMs is the time since the workflow started
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+	// SincePreviousMs is the time since the previous event, 0 for the first
+	SincePreviousMs int64 `json:"sincePreviousMs"`
+}
+
+// Timeline lists the events of an asset, oldest first.
+type Timeline struct {
+	GUID   string          `json:"guid"`
+	Status string          `json:"status"`
+	Events []*HistoryEvent `json:"events"`
+	// TotalMs is the time from the first to the last event
+	TotalMs int64 `json:"totalMs"`
+	// StepDurationsMs sums the time each step took to reach its events,
+	// for SLA reporting
+	StepDurationsMs map[string]int64 `json:"stepDurationsMs"`
+	// Errors counts the events that recorded an error
+	Errors int `json:"errors"`
+}
+
+// getTimeline queries the EVENT# items under the PK of the asset. Sort keys
+// start with the timestamp, so the query returns them in order.
+func (h *Handler) getTimeline(guid string) (*Timeline, error) {
+	var events []*HistoryEvent
+	var startKey map[string]*dynamodb.AttributeValue
+	for {
+		data, err := h.DynamoDBClient.Query(&dynamodb.QueryInput{
+			TableName:              aws.String(os.Getenv("DynamoDBTable")),
+			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :event)"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":pk":    {S: aws.String("VIDEO#" + guid)},
+				":event": {S: aws.String("EVENT#")},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getTimeline: Query: %w", err)
+		}
+
+		var page []*HistoryEvent
+		if err := dynamodbattribute.UnmarshalListOfMaps(data.Items, &page); err != nil {
+			return nil, fmt.Errorf("getTimeline: UnmarshalListOfMaps: %w", err)
+		}
+		events = append(events, page...)
+
+		if len(data.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = data.LastEvaluatedKey
+	}
+
+	if len(events) == 0 {
+		return nil, ErrNoEvents
+	}
+	return buildTimeline(guid, events), nil
+}
+
+// buildTimeline fills in the time between events and sums it by step.
+// Events with a timestamp that does not parse are listed without timings.
+func buildTimeline(guid string, events []*HistoryEvent) *Timeline {
+	timeline := &Timeline{
+		GUID:            guid,
+		Status:          events[len(events)-1].Status,
+		Events:          events,
+		StepDurationsMs: map[string]int64{},
+	}
+
+	var first, previous time.Time
+	for _, event := range events {
+		if event.Error != "" {
+			timeline.Errors++
+		}
+
+		timestamp, err := time.Parse(eventTimeFormat, event.Timestamp)
+		if err != nil {
+			continue
+		}
+		if first.IsZero() {
+			first = timestamp
+		}
+		if !previous.IsZero() {
+			event.SincePreviousMs = timestamp.Sub(previous).Milliseconds()
+		}
+		timeline.StepDurationsMs[event.Step] += event.SincePreviousMs
+		timeline.TotalMs = timestamp.Sub(first).Milliseconds()
+		previous = timestamp
+	}
+	return timeline
+}
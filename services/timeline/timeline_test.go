@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	timeline := buildTimeline("guid", []*HistoryEvent{
+		{Step: "Ingest", Status: "Ingest", Timestamp: "2025-02-23T10:04:40.000000Z"},
+		{Step: "Process", Status: "Ingest", Timestamp: "2025-02-23T10:05:10.000000Z"},
+		{Step: "Encode", Status: "Retrying", Timestamp: "2025-02-23T10:09:10.000000Z", Error: "job job-1 failed with 1550"},
+		{Step: "Process", Status: "Retrying", Timestamp: "2025-02-23T10:09:40.000000Z"},
+		{Step: "Publish", Status: "Complete", Timestamp: "2025-02-23T10:14:40.000000Z"},
+	})
+
+	assert.Equal(t, "Complete", timeline.Status)
+	assert.Equal(t, int64(600000), timeline.TotalMs)
+	assert.Equal(t, 1, timeline.Errors)
+	assert.Equal(t, map[string]int64{
+		"Ingest":  0,
+		"Process": 60000,
+		"Encode":  240000,
+		"Publish": 300000,
+	}, timeline.StepDurationsMs)
+	assert.Equal(t, int64(0), timeline.Events[0].SincePreviousMs)
+	assert.Equal(t, int64(240000), timeline.Events[2].SincePreviousMs)
+}
+
+func TestBuildTimelineSkipsBadTimestamps(t *testing.T) {
+	timeline := buildTimeline("guid", []*HistoryEvent{
+		{Step: "Ingest", Status: "Ingest", Timestamp: "yesterday"},
+		{Step: "Publish", Status: "Complete", Timestamp: "2025-02-23T10:14:40.000000Z"},
+	})
+
+	assert.Equal(t, int64(0), timeline.TotalMs)
+	assert.Len(t, timeline.Events, 2)
+}